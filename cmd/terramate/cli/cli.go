@@ -31,7 +31,6 @@ import (
 	"github.com/mineiros-io/terramate/cmd/terramate/cli/cliconfig"
 	"github.com/mineiros-io/terramate/cmd/terramate/cli/out"
 	"github.com/mineiros-io/terramate/config/filter"
-	"github.com/mineiros-io/terramate/config/tag"
 	"github.com/mineiros-io/terramate/errors"
 	"github.com/mineiros-io/terramate/errors/errlog"
 	"github.com/mineiros-io/terramate/event"
@@ -42,7 +41,6 @@ import (
 	"github.com/mineiros-io/terramate/hcl/fmt"
 	"github.com/mineiros-io/terramate/hcl/info"
 	"github.com/mineiros-io/terramate/modvendor/download"
-	"github.com/mineiros-io/terramate/versions"
 
 	"github.com/mineiros-io/terramate/stack/trigger"
 	"github.com/mineiros-io/terramate/stdlib"
@@ -139,10 +137,13 @@ type cliSpec struct {
 		NoRecursive           bool     `default:"false" help:"Do not recurse into child stacks"`
 		DryRun                bool     `default:"false" help:"Plan the execution but do not execute it"`
 		Reverse               bool     `default:"false" help:"Reverse the order of execution"`
-		Command               []string `arg:"" name:"cmd" predictor:"file" passthrough:"" help:"Command to execute"`
+		Command               []string `arg:"" optional:"" name:"cmd" predictor:"file" passthrough:"" help:"Command to execute (defaults to terramate.config.run.command)"`
 	} `cmd:"" help:"Run command in the stacks"`
 
-	Generate struct{} `cmd:"" help:"Generate terraform code for stacks"`
+	Generate struct {
+		Lock     bool          `help:"Lock the project during the code generation, failing if it's locked by another process"`
+		LockWait time.Duration `help:"Wait up to the given duration for the project lock, if --lock is set (negative waits indefinitely)"`
+	} `cmd:"" help:"Generate terraform code for stacks"`
 
 	InstallCompletions kongplete.InstallCompletions `cmd:"" help:"Install shell completions"`
 
@@ -476,11 +477,16 @@ func (c *cli) run() {
 		c.setupGit()
 		c.printStacks()
 	case "run":
-		log.Fatal().Msg("no command specified")
+		if !run.HasCommand(c.cfg()) {
+			log.Fatal().Msg("no command specified")
+		}
+		c.setupGit()
+		c.runOnStacks()
 	case "run <cmd>":
 		c.setupGit()
 		c.runOnStacks()
 	case "generate":
+		c.setupGenerateLock()
 		c.generate()
 	case "experimental clone <srcdir> <destdir>":
 		c.cloneStack()
@@ -733,6 +739,14 @@ func (c *cli) cloneStack() {
 	c.generate()
 }
 
+func (c *cli) setupGenerateLock() {
+	if !c.parsedArgs.Generate.Lock {
+		return
+	}
+	c.cfg().SetLockEnabled(true)
+	c.cfg().SetLockWait(c.parsedArgs.Generate.LockWait)
+}
+
 func (c *cli) generate() {
 	report, vendorReport := c.gencodeWithVendor()
 
@@ -1289,7 +1303,7 @@ func (c *cli) generateDebug() {
 		}
 
 		for _, file := range files {
-			filepath := path.Join(res.Dir.String(), file.Label())
+			filepath := path.Join(res.Dir.String(), file.OutDir(), file.Label())
 			c.output.MsgStdOut("%s origin: %v", filepath, file.Range())
 		}
 	}
@@ -1595,7 +1609,7 @@ func (c *cli) runOnStacks() {
 
 	c.gitSafeguardDefaultBranchIsReachable()
 
-	if len(c.parsedArgs.Run.Command) == 0 {
+	if len(c.parsedArgs.Run.Command) == 0 && !run.HasCommand(c.cfg()) {
 		logger.Fatal().Msgf("run expects a cmd")
 	}
 
@@ -1758,22 +1772,7 @@ func (c cli) checkVersion() {
 
 	logger.Trace().Msg("checking if terramate version satisfies project constraint")
 
-	rootcfg := c.rootNode()
-	if rootcfg.Terramate == nil {
-		logger.Debug().Msg("project root has no config, skipping version check")
-		return
-	}
-
-	if rootcfg.Terramate.RequiredVersion == "" {
-		logger.Debug().Msg("project root config has no required_version, skipping version check")
-		return
-	}
-
-	if err := versions.Check(
-		c.version,
-		rootcfg.Terramate.RequiredVersion,
-		rootcfg.Terramate.RequiredVersionAllowPreReleases,
-	); err != nil {
+	if err := c.cfg().CheckRequiredVersion(c.version); err != nil {
 		fatal(err)
 	}
 }
@@ -1831,57 +1830,13 @@ func runCheckpoint(
 }
 
 func (c *cli) setupFilterTags() {
-	clauses, found, err := filter.ParseTagClauses(c.parsedArgs.Tags...)
+	clauses, found, err := filter.ParseTagFilters(c.parsedArgs.Tags, c.parsedArgs.NoTags)
 	if err != nil {
 		fatal(err)
 	}
 	if found {
 		c.tags = clauses
 	}
-
-	for _, val := range c.parsedArgs.NoTags {
-		err := tag.Validate(val)
-		if err != nil {
-			fatal(err)
-		}
-	}
-	var noClauses filter.TagClause
-	if len(c.parsedArgs.NoTags) == 0 {
-		return
-	}
-	if len(c.parsedArgs.NoTags) == 1 {
-		noClauses = filter.TagClause{
-			Op:  filter.NEQ,
-			Tag: c.parsedArgs.NoTags[0],
-		}
-	} else {
-		var children []filter.TagClause
-		for _, tagname := range c.parsedArgs.NoTags {
-			children = append(children, filter.TagClause{
-				Op:  filter.NEQ,
-				Tag: tagname,
-			})
-		}
-		noClauses = filter.TagClause{
-			Op:       filter.AND,
-			Children: children,
-		}
-	}
-
-	if c.tags.IsEmpty() {
-		c.tags = noClauses
-		return
-	}
-
-	switch c.tags.Op {
-	case filter.AND:
-		c.tags.Children = append(c.tags.Children, noClauses)
-	default:
-		c.tags = filter.TagClause{
-			Op:       filter.AND,
-			Children: []filter.TagClause{c.tags, noClauses},
-		}
-	}
 }
 
 func newGit(basedir string, checkrepo bool) (*git.Git, error) {
@@ -23,18 +23,79 @@ import (
 	hhcl "github.com/hashicorp/hcl/v2"
 )
 
+// AssertSeverity is the severity level of a failed assertion.
+type AssertSeverity string
+
+const (
+	// AssertSeverityInfo is the severity of failed assertions that are
+	// only informative.
+	AssertSeverityInfo AssertSeverity = "info"
+
+	// AssertSeverityWarn is the severity of failed assertions that are
+	// reported as warnings.
+	AssertSeverityWarn AssertSeverity = "warn"
+
+	// AssertSeverityError is the severity of failed assertions that are
+	// reported as errors. It is the default severity.
+	AssertSeverityError AssertSeverity = "error"
+)
+
 // Assert represents evaluated assert block configuration.
 type Assert struct {
 	Assertion bool
-	Warning   bool
-	Message   string
-	Range     hhcl.Range
+	Severity  AssertSeverity
+
+	// Warning is true if the severity is AssertSeverityWarn. It is kept
+	// for compatibility with the old boolean warning attribute.
+	Warning bool
+
+	Message string
+	Range   hhcl.Range
+}
+
+// Failed tells if the assertion failed with the error severity.
+func (a Assert) Failed() bool {
+	return !a.Assertion && a.Severity == AssertSeverityError
+}
+
+// AssertReport is the aggregated result of a set of evaluated assertions.
+// The failed assertions are partitioned by severity.
+type AssertReport struct {
+	Infos    []Assert
+	Warnings []Assert
+	Errors   []Assert
+}
+
+// NewAssertReport creates a report of the failed assertions in asserts.
+func NewAssertReport(asserts []Assert) AssertReport {
+	var report AssertReport
+	for _, assert := range asserts {
+		if assert.Assertion {
+			continue
+		}
+		switch assert.Severity {
+		case AssertSeverityInfo:
+			report.Infos = append(report.Infos, assert)
+		case AssertSeverityWarn:
+			report.Warnings = append(report.Warnings, assert)
+		default:
+			report.Errors = append(report.Errors, assert)
+		}
+	}
+	return report
+}
+
+// HasErrors tells if any assertion failed with the error severity.
+func (r AssertReport) HasErrors() bool {
+	return len(r.Errors) > 0
 }
 
 // EvalAssert evaluates a given assert configuration and returns its
 // evaluated form.
 func EvalAssert(evalctx *eval.Context, cfg hcl.AssertConfig) (Assert, error) {
-	res := Assert{}
+	res := Assert{
+		Severity: AssertSeverityError,
+	}
 	errs := errors.L()
 
 	assertion, err := evalBool(evalctx, cfg.Assertion, "assert.assertion")
@@ -54,13 +115,32 @@ func EvalAssert(evalctx *eval.Context, cfg hcl.AssertConfig) (Assert, error) {
 
 	if cfg.Warning != nil {
 		warning, err := evalBool(evalctx, cfg.Warning, "assert.warning")
+		if err != nil {
+			errs.Append(err)
+		} else if warning {
+			res.Severity = AssertSeverityWarn
+		}
+	}
+
+	if cfg.Severity != nil {
+		severity, err := evalString(evalctx, cfg.Severity, "assert.severity")
 		if err != nil {
 			errs.Append(err)
 		} else {
-			res.Warning = warning
+			switch s := AssertSeverity(severity); s {
+			case AssertSeverityInfo, AssertSeverityWarn, AssertSeverityError:
+				res.Severity = s
+			default:
+				errs.Append(errors.E(ErrSchema,
+					"assert.severity must be one of %q, %q or %q, got %q",
+					AssertSeverityInfo, AssertSeverityWarn, AssertSeverityError,
+					severity))
+			}
 		}
 	}
 
+	res.Warning = res.Severity == AssertSeverityWarn
+
 	if err := errs.AsError(); err != nil {
 		return Assert{}, err
 	}
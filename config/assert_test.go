@@ -51,6 +51,7 @@ func TestAssertConfigEval(t *testing.T) {
 			},
 			want: config.Assert{
 				Assertion: false,
+				Severity:  config.AssertSeverityError,
 				Message:   "something",
 			},
 		},
@@ -70,6 +71,7 @@ func TestAssertConfigEval(t *testing.T) {
 			},
 			want: config.Assert{
 				Assertion: true,
+				Severity:  config.AssertSeverityError,
 				Message:   "message",
 			},
 		},
@@ -87,10 +89,87 @@ func TestAssertConfigEval(t *testing.T) {
 			},
 			want: config.Assert{
 				Assertion: true,
+				Severity:  config.AssertSeverityWarn,
 				Message:   "msg",
 				Warning:   true,
 			},
 		},
+		{
+			name: "warning false is error severity",
+			assert: hcl.AssertConfig{
+				Assertion: expr(`false`),
+				Message:   expr(`"msg"`),
+				Warning:   expr("false"),
+			},
+			want: config.Assert{
+				Assertion: false,
+				Severity:  config.AssertSeverityError,
+				Message:   "msg",
+			},
+		},
+		{
+			name: "info severity",
+			assert: hcl.AssertConfig{
+				Assertion: expr(`false`),
+				Message:   expr(`"msg"`),
+				Severity:  expr(`"info"`),
+			},
+			want: config.Assert{
+				Assertion: false,
+				Severity:  config.AssertSeverityInfo,
+				Message:   "msg",
+			},
+		},
+		{
+			name: "warn severity",
+			namespaces: namespaces{
+				"ns": nsvalues{
+					"severity": "warn",
+				},
+			},
+			assert: hcl.AssertConfig{
+				Assertion: expr(`false`),
+				Message:   expr(`"msg"`),
+				Severity:  expr(`ns.severity`),
+			},
+			want: config.Assert{
+				Assertion: false,
+				Severity:  config.AssertSeverityWarn,
+				Message:   "msg",
+				Warning:   true,
+			},
+		},
+		{
+			name: "error severity",
+			assert: hcl.AssertConfig{
+				Assertion: expr(`false`),
+				Message:   expr(`"msg"`),
+				Severity:  expr(`"error"`),
+			},
+			want: config.Assert{
+				Assertion: false,
+				Severity:  config.AssertSeverityError,
+				Message:   "msg",
+			},
+		},
+		{
+			name: "unknown severity fails",
+			assert: hcl.AssertConfig{
+				Assertion: expr(`true`),
+				Message:   expr(`"msg"`),
+				Severity:  expr(`"fatal"`),
+			},
+			wantErr: errors.E(config.ErrSchema),
+		},
+		{
+			name: "severity is not string fails",
+			assert: hcl.AssertConfig{
+				Assertion: expr(`true`),
+				Message:   expr(`"msg"`),
+				Severity:  expr(`true`),
+			},
+			wantErr: errors.E(config.ErrSchema),
+		},
 		{
 			name: "assertion undefined fails",
 			assert: hcl.AssertConfig{
@@ -176,6 +255,7 @@ func TestAssertConfigEval(t *testing.T) {
 			},
 			want: config.Assert{
 				Assertion: true,
+				Severity:  config.AssertSeverityError,
 				Message:   "FUNC",
 			},
 		},
@@ -198,6 +278,85 @@ func TestAssertConfigEval(t *testing.T) {
 	}
 }
 
+func TestAssertReport(t *testing.T) {
+	type testcase struct {
+		name       string
+		asserts    []config.Assert
+		wantInfos  int
+		wantWarns  int
+		wantErrors int
+	}
+
+	failed := func(severity config.AssertSeverity) config.Assert {
+		return config.Assert{
+			Severity: severity,
+			Message:  string(severity),
+		}
+	}
+
+	passed := func(severity config.AssertSeverity) config.Assert {
+		a := failed(severity)
+		a.Assertion = true
+		return a
+	}
+
+	for _, tc := range []testcase{
+		{
+			name: "no asserts",
+		},
+		{
+			name: "passed asserts are not reported",
+			asserts: []config.Assert{
+				passed(config.AssertSeverityInfo),
+				passed(config.AssertSeverityWarn),
+				passed(config.AssertSeverityError),
+			},
+		},
+		{
+			name: "failed info",
+			asserts: []config.Assert{
+				failed(config.AssertSeverityInfo),
+				passed(config.AssertSeverityError),
+			},
+			wantInfos: 1,
+		},
+		{
+			name: "failed warn",
+			asserts: []config.Assert{
+				failed(config.AssertSeverityWarn),
+				failed(config.AssertSeverityWarn),
+			},
+			wantWarns: 2,
+		},
+		{
+			name: "failed error",
+			asserts: []config.Assert{
+				failed(config.AssertSeverityError),
+			},
+			wantErrors: 1,
+		},
+		{
+			name: "failed with all severities",
+			asserts: []config.Assert{
+				failed(config.AssertSeverityInfo),
+				failed(config.AssertSeverityWarn),
+				failed(config.AssertSeverityError),
+			},
+			wantInfos:  1,
+			wantWarns:  1,
+			wantErrors: 1,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			report := config.NewAssertReport(tc.asserts)
+			assert.EqualInts(t, tc.wantInfos, len(report.Infos), "infos mismatch")
+			assert.EqualInts(t, tc.wantWarns, len(report.Warnings), "warnings mismatch")
+			assert.EqualInts(t, tc.wantErrors, len(report.Errors), "errors mismatch")
+			assert.IsTrue(t, report.HasErrors() == (tc.wantErrors > 0), "HasErrors() mismatch")
+		})
+	}
+}
+
 type namespaces map[string]nsvalues
 
 type nsvalues map[string]interface{}
@@ -224,6 +383,7 @@ func equalAsserts(a config.Assert, o config.Assert) bool {
 	// They don't have a proper Filename on their Ranges so checking
 	// The range on these tests would be tricky to check properly.
 	return a.Message == o.Message &&
+		a.Severity == o.Severity &&
 		a.Warning == o.Warning &&
 		a.Assertion == o.Assertion
 }
@@ -20,12 +20,15 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 
 	"github.com/mineiros-io/terramate"
 	"github.com/mineiros-io/terramate/config/filter"
 	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/git"
 	"github.com/mineiros-io/terramate/hcl"
 	"github.com/mineiros-io/terramate/project"
+	"github.com/mineiros-io/terramate/versions"
 	"github.com/rs/zerolog/log"
 	"github.com/zclconf/go-cty/cty"
 )
@@ -36,6 +39,10 @@ const (
 
 	// SkipFilename is the name of Terramate skip file.
 	SkipFilename = ".tmskip"
+
+	// defaultGitRemote is the git remote used if terramate.config.git.default_remote
+	// is not set.
+	defaultGitRemote = "origin"
 )
 
 const (
@@ -46,10 +53,29 @@ const (
 // Root is the root configuration tree.
 // This type is just for ensure better type checking for the cases where a
 // configuration for the root directory is expected and not from anywhere else.
+//
+// The Root and its tree are fully built when loaded and are never modified by
+// its accessors (eg.: [Root.Lookup], [Root.Tree] and [Root.Runtime]), so a Root
+// is safe for concurrent use by multiple goroutines. The exception is
+// [Root.LoadSubTree], which modifies the tree in place and must not be called
+// concurrently with any other method. The project lock state of [Root.Lock]
+// is synchronized internally.
 type Root struct {
 	tree Tree
 
 	runtime project.Runtime
+
+	// remote is shared by copies of the root, so git is only executed once
+	// and only when the runtime is used.
+	remote *lazyRemote
+
+	lock *rootLock
+}
+
+// lazyRemote is the git remote URL of the project, computed on first use.
+type lazyRemote struct {
+	once sync.Once
+	url  cty.Value
 }
 
 // Tree is the configuration tree.
@@ -119,7 +145,9 @@ func TryLoadConfig(fromdir string) (tree *Root, configpath string, found bool, e
 // NewRoot creates a new [Root] tree for the cfg tree.
 func NewRoot(tree *Tree) *Root {
 	r := &Root{
-		tree: *tree,
+		tree:   *tree,
+		remote: &lazyRemote{},
+		lock:   &rootLock{},
 	}
 	r.initRuntime()
 	return r
@@ -204,7 +232,36 @@ func (root *Root) StacksByTagsFilters(filters []string) (project.Paths, error) {
 	}).Paths(), nil
 }
 
+// StacksByOwner returns all stacks owned by the given owner, sorted by path.
+// Stacks with multiple owners are returned for each one of their owners.
+// The owner comparison is case sensitive. Stacks with invalid configuration
+// are ignored.
+func (root *Root) StacksByOwner(owner string) []*Stack {
+	logger := log.With().
+		Str("action", "root.StacksByOwner()").
+		Str("owner", owner).
+		Logger()
+
+	var stacks []*Stack
+	for _, tree := range root.tree.Stacks() {
+		if !contains(tree.Node.Stack.Owners, owner) {
+			continue
+		}
+		st, err := NewStackFromHCL(root.HostDir(), tree.Node)
+		if err != nil {
+			logger.Warn().
+				Err(err).
+				Stringer("stack", tree.Dir()).
+				Msg("ignoring invalid stack")
+			continue
+		}
+		stacks = append(stacks, st)
+	}
+	return stacks
+}
+
 // LoadSubTree loads a subtree located at cfgdir into the current tree.
+// It's not safe to call it concurrently with other methods of the root.
 func (root *Root) LoadSubTree(cfgdir project.Path) error {
 	var parent project.Path
 
@@ -251,13 +308,35 @@ func (root *Root) Stacks() project.Paths {
 	return root.tree.Stacks().Paths()
 }
 
+// CheckRequiredVersion checks if the current terramate version satisfies the
+// terramate.required_version constraint of the project root. The check is
+// skipped if the root has no constraint. On failure, the returned error has
+// kind [versions.ErrCheck] and points to the range of the constraint.
+func (root *Root) CheckRequiredVersion(current string) error {
+	tm := root.tree.Node.Terramate
+	if tm == nil || tm.RequiredVersion == "" {
+		return nil
+	}
+
+	err := versions.Check(current, tm.RequiredVersion, tm.RequiredVersionAllowPreReleases)
+	if err != nil {
+		return errors.E(err, tm.RequiredVersionRange)
+	}
+	return nil
+}
+
 // Runtime returns a copy the runtime for the root terramate namespace as a
 // cty.Value map.
+// The git remote of the project (terramate.repo.remote) is computed on the
+// first call, so loading the configuration never executes git.
 func (root *Root) Runtime() project.Runtime {
 	runtime := project.Runtime{}
 	for k, v := range root.runtime {
 		runtime[k] = v
 	}
+	runtime["repo"] = cty.ObjectVal(map[string]cty.Value{
+		"remote": root.repoRemote(),
+	})
 	return runtime
 }
 
@@ -267,7 +346,8 @@ func (root *Root) initRuntime() {
 		"basename": cty.StringVal(filepath.Base(root.HostDir())),
 	})
 	rootpath := cty.ObjectVal(map[string]cty.Value{
-		"fs": rootfs,
+		"absolute": cty.StringVal(root.HostDir()),
+		"fs":       rootfs,
 	})
 	rootNS := cty.ObjectVal(map[string]cty.Value{
 		"path": rootpath,
@@ -282,6 +362,51 @@ func (root *Root) initRuntime() {
 	}
 }
 
+func (root *Root) repoRemote() cty.Value {
+	root.remote.once.Do(func() {
+		root.remote.url = root.repoRemoteURL()
+	})
+	return root.remote.url
+}
+
+// repoRemoteURL returns the URL of the default git remote of the project,
+// without credentials, or null if the project is not a git repository or the
+// remote is not configured.
+func (root *Root) repoRemoteURL() cty.Value {
+	logger := log.With().
+		Str("action", "Root.repoRemoteURL()").
+		Str("root", root.HostDir()).
+		Logger()
+
+	null := cty.NullVal(cty.String)
+
+	remote := defaultGitRemote
+	if tm := root.tree.Node.Terramate; tm != nil && tm.Config != nil &&
+		tm.Config.Git != nil && tm.Config.Git.DefaultRemote != "" {
+		remote = tm.Config.Git.DefaultRemote
+	}
+
+	g, err := git.WithConfig(git.Config{
+		WorkingDir: root.HostDir(),
+	})
+	if err != nil || !g.IsRepository() {
+		logger.Trace().Err(err).Msg("no git repository, ignoring remote")
+		return null
+	}
+
+	url, err := g.RemoteURL(remote)
+	if err != nil {
+		logger.Trace().Err(err).Msg("failed to get remote URL, ignoring")
+		return null
+	}
+	url = git.StripCredentials(url)
+	if url == "" {
+		logger.Trace().Msg("failed to parse remote URL, ignoring")
+		return null
+	}
+	return cty.StringVal(url)
+}
+
 // LoadTree loads the whole hierarchical configuration from cfgdir downwards
 // using rootdir as project root.
 func LoadTree(rootdir string, cfgdir string) (*Tree, error) {
@@ -496,3 +621,12 @@ func toCtyStringList(list []string) cty.Value {
 	}
 	return cty.ListVal(res)
 }
+
+func contains(list []string, elem string) bool {
+	for _, e := range list {
+		if e == elem {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/hcl"
+	"github.com/mineiros-io/terramate/hcl/ast"
+	"github.com/mineiros-io/terramate/project"
+)
+
+// ErrEffectiveConfig indicates that the effective configuration of a stack
+// could not be computed.
+const ErrEffectiveConfig errors.Kind = "computing effective configuration"
+
+// EffectiveConfig returns the configuration that applies to the stack as a
+// single document, merging the configuration of the project root, of every
+// directory between the root and the stack and of the stack directory.
+// It is intended for introspection, so expressions are not evaluated.
+//
+// The configurations are merged from the root to the stack directory, so
+// deeper directories take precedence:
+//
+//   - terramate: the block of the project root, since it can't be
+//     redefined by other directories.
+//   - stack: the stack block of the stack directory.
+//   - globals: the attributes of globals blocks with the same labels are
+//     merged, attributes of deeper directories overriding the ones with the
+//     same name of parent directories.
+//   - conditional globals, asserts, generate_hcl and generate_file: the
+//     blocks of all directories, parent directory blocks first.
+//     generate_file blocks with context=root are ignored, since they are
+//     not generated for stacks.
+//   - vendor: the block of the deepest directory declaring one.
+func (s *Stack) EffectiveConfig(root *Root) (hcl.Config, error) {
+	cfg, err := hcl.NewConfig(s.HostDir(root))
+	if err != nil {
+		return hcl.Config{}, errors.E(ErrEffectiveConfig, err)
+	}
+
+	var dirs []project.Path
+	for dir := s.Dir; ; dir = dir.Dir() {
+		dirs = append([]project.Path{dir}, dirs...)
+		if dir.String() == "/" {
+			break
+		}
+	}
+
+	cfg.Terramate = root.Tree().Node.Terramate
+	cfg.Globals = ast.MergedLabelBlocks{}
+
+	for _, dir := range dirs {
+		tree, ok := root.Lookup(dir)
+		if !ok {
+			continue
+		}
+		node := tree.Node
+
+		if dir == s.Dir {
+			cfg.Stack = node.Stack
+		}
+		if node.Vendor != nil {
+			cfg.Vendor = node.Vendor
+		}
+
+		for _, block := range node.Globals.AsList() {
+			lb, err := ast.NewLabelBlockType(string(block.Type), block.Labels)
+			if err != nil {
+				return hcl.Config{}, errors.E(ErrEffectiveConfig, err)
+			}
+			merged, ok := cfg.Globals[lb]
+			if !ok {
+				merged = ast.NewMergedBlock(string(block.Type), block.Labels)
+				cfg.Globals[lb] = merged
+			}
+			for _, raw := range block.RawOrigins {
+				if err := merged.MergeBlock(raw, true); err != nil {
+					return hcl.Config{}, errors.E(ErrEffectiveConfig, err,
+						"merging globals of %s", dir)
+				}
+			}
+		}
+
+		cfg.ConditionalGlobals = append(cfg.ConditionalGlobals, node.ConditionalGlobals...)
+		cfg.Asserts = append(cfg.Asserts, node.Asserts...)
+		cfg.Generate.HCLs = append(cfg.Generate.HCLs, node.Generate.HCLs...)
+		for _, block := range node.Generate.Files {
+			if block.Context == "root" {
+				continue
+			}
+			cfg.Generate.Files = append(cfg.Generate.Files, block)
+		}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,150 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/madlambda/spells/assert"
+	"github.com/mineiros-io/terramate/config"
+	"github.com/mineiros-io/terramate/hcl/ast"
+	"github.com/mineiros-io/terramate/project"
+	"github.com/mineiros-io/terramate/test/sandbox"
+)
+
+func TestStackEffectiveConfig(t *testing.T) {
+	s := sandbox.NoGit(t)
+	s.BuildTree([]string{
+		`f:terramate.tm:terramate {
+			config {
+				run {
+					env {
+						FROM_ROOT = "root"
+					}
+				}
+			}
+		}`,
+		`f:root.tm:globals {
+			a = "root"
+			b = "root"
+		}
+		globals "labeled" {
+			x = "root"
+		}
+		assert {
+			assertion = true
+			message   = "root assert"
+		}
+		generate_hcl "root.tf" {
+			content {
+				a = 1
+			}
+		}
+		generate_file "/root.txt" {
+			context = root
+			content = "root"
+		}`,
+		"d:dir",
+		`f:dir/dir.tm:globals {
+			c = "dir"
+		}
+		vendor {
+			dir = "/vendor/dir"
+		}`,
+		"s:dir/stack",
+		`f:dir/stack/stack.tm:globals {
+			b = "stack"
+		}
+		assert {
+			assertion = true
+			message   = "stack assert"
+		}
+		generate_file "stack.txt" {
+			content = "stack"
+		}`,
+		"s:other",
+		`f:other/other.tm:globals {
+			a = "other"
+		}`,
+	})
+
+	root := s.Config()
+	tree, ok := root.Lookup(project.NewPath("/dir/stack"))
+	assert.IsTrue(t, ok)
+	st, err := config.NewStackFromHCL(root.HostDir(), tree.Node)
+	assert.NoError(t, err)
+
+	cfg, err := st.EffectiveConfig(root)
+	assert.NoError(t, err)
+
+	assert.EqualStrings(t, filepath.Join(s.RootDir(), "dir", "stack"), cfg.AbsDir())
+	assert.IsTrue(t, cfg.Stack != nil, "stack block missing")
+	assert.IsTrue(t, cfg.Terramate != nil &&
+		cfg.Terramate.Config != nil &&
+		cfg.Terramate.Config.Run != nil &&
+		cfg.Terramate.Config.Run.Env != nil, "root run config missing")
+	_, ok = cfg.Terramate.Config.Run.Env.Attributes["FROM_ROOT"]
+	assert.IsTrue(t, ok, "root run env missing")
+
+	assert.IsTrue(t, cfg.Vendor != nil, "vendor block missing")
+	assert.EqualStrings(t, "/vendor/dir", cfg.Vendor.Dir)
+
+	globalsOrigin := func(labels ...string) map[string]string {
+		t.Helper()
+		lb, err := ast.NewLabelBlockType("globals", labels)
+		assert.NoError(t, err)
+		block, ok := cfg.Globals[lb]
+		assert.IsTrue(t, ok, "globals %v missing", labels)
+
+		origins := map[string]string{}
+		for name, attr := range block.Attributes {
+			origins[name] = filepath.Base(attr.Range.HostPath())
+		}
+		return origins
+	}
+
+	wantGlobals := map[string]string{
+		"a": "root.tm",
+		"b": "stack.tm",
+		"c": "dir.tm",
+	}
+	if diff := cmp.Diff(wantGlobals, globalsOrigin()); diff != "" {
+		t.Fatalf("-(want) +(got):\n%s", diff)
+	}
+	if diff := cmp.Diff(map[string]string{"x": "root.tm"}, globalsOrigin("labeled")); diff != "" {
+		t.Fatalf("-(want) +(got):\n%s", diff)
+	}
+
+	var asserts []string
+	for _, a := range cfg.Asserts {
+		asserts = append(asserts, filepath.Base(a.Range.HostPath()))
+	}
+	if diff := cmp.Diff([]string{"root.tm", "stack.tm"}, asserts); diff != "" {
+		t.Fatalf("-(want) +(got):\n%s", diff)
+	}
+
+	assert.EqualInts(t, 1, len(cfg.Generate.HCLs))
+	assert.EqualStrings(t, "root.tf", cfg.Generate.HCLs[0].Label)
+
+	var files []string
+	for _, block := range cfg.Generate.Files {
+		files = append(files, block.Label)
+	}
+	if diff := cmp.Diff([]string{"stack.txt"}, files); diff != "" {
+		t.Fatalf("-(want) +(got):\n%s", diff)
+	}
+}
@@ -108,8 +108,15 @@ func tomap(tags []string) map[string]bool {
 // It returns a boolean telling if the clauses are not empty.
 func ParseTagClauses(filters ...string) (TagClause, bool, error) {
 	for _, filter := range filters {
+		if filter == "" {
+			continue
+		}
 		for _, orClause := range strings.Split(filter, ",") {
 			for _, andClause := range strings.Split(orClause, ":") {
+				if andClause == "" {
+					return TagClause{}, false, errors.E(tag.ErrInvalidTag,
+						"%q: tag filter has an empty tag", filter)
+				}
 				err := tag.Validate(andClause)
 				if err != nil {
 					return TagClause{}, false, err
@@ -120,6 +127,62 @@ func ParseTagClauses(filters ...string) (TagClause, bool, error) {
 	return parseInternalTagClauses(filters...)
 }
 
+// ParseTagFilters parses the tags filters, with the [ParseTagClauses] syntax,
+// and the noTags list of tag names into a single [TagClause] matcher, which
+// matches the tags matched by the tags filters and not containing any of the
+// noTags. It returns a boolean telling if the clauses are not empty.
+func ParseTagFilters(tags []string, noTags []string) (TagClause, bool, error) {
+	clauses, found, err := ParseTagClauses(tags...)
+	if err != nil {
+		return TagClause{}, false, err
+	}
+
+	for _, val := range noTags {
+		err := tag.Validate(val)
+		if err != nil {
+			return TagClause{}, false, err
+		}
+	}
+	if len(noTags) == 0 {
+		return clauses, found, nil
+	}
+
+	var noClauses TagClause
+	if len(noTags) == 1 {
+		noClauses = TagClause{
+			Op:  NEQ,
+			Tag: noTags[0],
+		}
+	} else {
+		var children []TagClause
+		for _, tagname := range noTags {
+			children = append(children, TagClause{
+				Op:  NEQ,
+				Tag: tagname,
+			})
+		}
+		noClauses = TagClause{
+			Op:       AND,
+			Children: children,
+		}
+	}
+
+	if !found {
+		return noClauses, true, nil
+	}
+
+	switch clauses.Op {
+	case AND:
+		clauses.Children = append(clauses.Children, noClauses)
+	default:
+		clauses = TagClause{
+			Op:       AND,
+			Children: []TagClause{clauses, noClauses},
+		}
+	}
+	return clauses, true, nil
+}
+
 func parseInternalTagClauses(filters ...string) (TagClause, bool, error) {
 	var clauses []TagClause
 	for _, filter := range filters {
@@ -520,3 +520,85 @@ func TestFilterMatchTags(t *testing.T) {
 		})
 	}
 }
+
+func TestFilterParseTagFilters(t *testing.T) {
+	t.Parallel()
+
+	type testcase struct {
+		tags      []string
+		noTags    []string
+		want      TagClause
+		noClauses bool
+		err       error
+	}
+
+	for _, tc := range []testcase{
+		{
+			noClauses: true,
+		},
+		{
+			tags: []string{"a"},
+			want: TagClause{Op: EQ, Tag: "a"},
+		},
+		{
+			noTags: []string{"a"},
+			want:   TagClause{Op: NEQ, Tag: "a"},
+		},
+		{
+			noTags: []string{"a", "b"},
+			want: TagClause{
+				Op: AND,
+				Children: []TagClause{
+					{Op: NEQ, Tag: "a"},
+					{Op: NEQ, Tag: "b"},
+				},
+			},
+		},
+		{
+			tags:   []string{"a,b"},
+			noTags: []string{"c"},
+			want: TagClause{
+				Op: AND,
+				Children: []TagClause{
+					{
+						Op: OR,
+						Children: []TagClause{
+							{Op: EQ, Tag: "a"},
+							{Op: EQ, Tag: "b"},
+						},
+					},
+					{Op: NEQ, Tag: "c"},
+				},
+			},
+		},
+		{
+			tags: []string{"a,"},
+			err:  errors.E(tag.ErrInvalidTag),
+		},
+		{
+			tags: []string{"a::b"},
+			err:  errors.E(tag.ErrInvalidTag),
+		},
+		{
+			noTags: []string{"_invalid"},
+			err:    errors.E(tag.ErrInvalidTag),
+		},
+	} {
+		tc := tc
+		t.Run(fmt.Sprintf("tags:%v no-tags:%v", tc.tags, tc.noTags), func(t *testing.T) {
+			t.Parallel()
+
+			got, hasClauses, err := ParseTagFilters(tc.tags, tc.noTags)
+			errtest.Assert(t, err, tc.err)
+			if tc.err != nil {
+				return
+			}
+			if !hasClauses != tc.noClauses {
+				t.Fatalf("filter emptiness mismatch: %t != %t", !hasClauses, tc.noClauses)
+			}
+			if diff := cmp.Diff(got, tc.want); diff != "" {
+				t.Fatalf("got[-], want[+], diff = %s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,265 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// ErrLocked indicates that the project is locked by another process.
+	ErrLocked errors.Kind = "project is locked"
+
+	// ErrLock indicates a failure acquiring or releasing the project lock.
+	ErrLock errors.Kind = "project lock error"
+)
+
+// LockFilename is the name of the project lock file, which is created inside
+// the .terramate directory of the project root.
+const LockFilename = "terramate.lock"
+
+const (
+	lockDir          = ".terramate"
+	lockPollInterval = 100 * time.Millisecond
+)
+
+// rootLock is the state of the project lock held by a [Root].
+type rootLock struct {
+	mu         sync.Mutex
+	enabled    bool
+	wait       time.Duration
+	count      int
+	file       *os.File
+	createdDir bool
+}
+
+// SetLockEnabled configures if the project lock must be acquired by the
+// operations modifying the project, like the code generation. It's disabled
+// by default, since acquiring the lock requires creating the .terramate
+// directory in the project root, which fails on read-only checkouts.
+func (root *Root) SetLockEnabled(enabled bool) {
+	root.lock.mu.Lock()
+	defer root.lock.mu.Unlock()
+
+	root.lock.enabled = enabled
+}
+
+// LockEnabled tells if the project lock is enabled (see [Root.SetLockEnabled]).
+func (root *Root) LockEnabled() bool {
+	root.lock.mu.Lock()
+	defer root.lock.mu.Unlock()
+
+	return root.lock.enabled
+}
+
+// SetLockWait configures how [Root.Lock] behaves when the project is locked by
+// another process. If wait is zero (the default) it fails immediately, if it
+// is positive it waits up to the given duration for the lock to be released
+// and if it is negative it waits indefinitely.
+func (root *Root) SetLockWait(wait time.Duration) {
+	root.lock.mu.Lock()
+	defer root.lock.mu.Unlock()
+
+	root.lock.wait = wait
+}
+
+// Lock acquires the project lock, which guarantees that no other Terramate
+// process modifies the project concurrently (eg.: by generating code).
+// The lock is an OS advisory lock on the .terramate/terramate.lock file of the
+// project root, so it is automatically released by the OS if the process
+// holding it crashes, and a lock file left behind is just reused.
+//
+// Lock can be called even if the lock is not enabled with
+// [Root.SetLockEnabled], which only configures if the operations modifying
+// the project acquire it.
+//
+// It fails with [ErrLock] on platforms without advisory file locks.
+// If the project is locked by another process, it fails with [ErrLocked] or
+// waits for the lock to be released, as configured by [Root.SetLockWait].
+// The lock is reentrant: each successful call must be paired with a call to
+// [Root.Unlock].
+func (root *Root) Lock() error {
+	logger := log.With().
+		Str("action", "Root.Lock()").
+		Str("root", root.HostDir()).
+		Logger()
+
+	l := root.lock
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.count > 0 {
+		l.count++
+		return nil
+	}
+
+	dir := filepath.Join(root.HostDir(), lockDir)
+	lockfile := filepath.Join(dir, LockFilename)
+
+	var deadline time.Time
+	if l.wait > 0 {
+		deadline = time.Now().Add(l.wait)
+	}
+
+	for {
+		createdDir := false
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return errors.E(ErrLock, err, "creating lock directory")
+			}
+			createdDir = true
+		}
+
+		f, err := os.OpenFile(lockfile, os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			if os.IsNotExist(err) {
+				// the lock directory was removed by the previous holder.
+				continue
+			}
+			return errors.E(ErrLock, err, "opening lock file")
+		}
+
+		locked, err := tryLockFile(f)
+		if err != nil {
+			_ = f.Close()
+			return errors.E(ErrLock, err, "locking %s", lockfile)
+		}
+
+		if locked {
+			// The previous holder removes the lock file before releasing the
+			// lock, so the file we locked may not be the lock file anymore.
+			if !isSameFile(f, lockfile) {
+				_ = unlockFile(f)
+				_ = f.Close()
+				continue
+			}
+
+			if err := writeLockOwner(f); err != nil {
+				logger.Warn().Err(err).Msg("writing lock owner")
+			}
+
+			logger.Trace().Msg("project locked")
+
+			l.file = f
+			l.count = 1
+			l.createdDir = createdDir
+			return nil
+		}
+
+		owner := readLockOwner(f)
+		_ = f.Close()
+
+		if l.wait == 0 {
+			return errors.E(ErrLocked, "lock held by process %s", owner)
+		}
+		if l.wait > 0 && time.Now().After(deadline) {
+			return errors.E(ErrLocked,
+				"timeout after %s waiting for lock held by process %s", l.wait, owner)
+		}
+
+		logger.Debug().
+			Str("owner", owner).
+			Msg("waiting for project lock")
+
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// Unlock releases the project lock acquired by [Root.Lock].
+// It fails if the project is not locked by this root.
+func (root *Root) Unlock() error {
+	logger := log.With().
+		Str("action", "Root.Unlock()").
+		Str("root", root.HostDir()).
+		Logger()
+
+	l := root.lock
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.count == 0 {
+		return errors.E(ErrLock, "project is not locked")
+	}
+
+	l.count--
+	if l.count > 0 {
+		return nil
+	}
+
+	dir := filepath.Join(root.HostDir(), lockDir)
+
+	// the lock file is removed while still holding the lock, so it's never
+	// removed from under another process.
+	if err := os.Remove(filepath.Join(dir, LockFilename)); err != nil {
+		logger.Debug().Err(err).Msg("removing lock file")
+	}
+
+	f := l.file
+	l.file = nil
+
+	err := unlockFile(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return errors.E(ErrLock, err, "unlocking project")
+	}
+
+	if l.createdDir {
+		// only succeeds if the directory is empty.
+		_ = os.Remove(dir)
+	}
+
+	logger.Trace().Msg("project unlocked")
+	return nil
+}
+
+func isSameFile(f *os.File, path string) bool {
+	fst, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	st, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(fst, st)
+}
+
+func writeLockOwner(f *os.File) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err := f.WriteAt([]byte(fmt.Sprintf("%d\n", os.Getpid())), 0)
+	return err
+}
+
+func readLockOwner(f *os.File) string {
+	data, err := io.ReadAll(io.NewSectionReader(f, 0, 32))
+	owner := strings.TrimSpace(string(data))
+	if err != nil || owner == "" {
+		return "<unknown>"
+	}
+	return owner
+}
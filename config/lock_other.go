@@ -0,0 +1,32 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows)
+
+package config
+
+import (
+	"os"
+	"runtime"
+
+	"github.com/mineiros-io/terramate/errors"
+)
+
+func tryLockFile(_ *os.File) (bool, error) {
+	return false, errors.E("advisory file locks are not supported on %s", runtime.GOOS)
+}
+
+func unlockFile(_ *os.File) error {
+	return errors.E("advisory file locks are not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,133 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/madlambda/spells/assert"
+	"github.com/mineiros-io/terramate/config"
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/test"
+	"github.com/mineiros-io/terramate/test/sandbox"
+)
+
+func TestRootLockFailsFastWhenLocked(t *testing.T) {
+	s := sandbox.NoGit(t)
+	s.BuildTree([]string{"s:stack"})
+
+	root1 := loadRoot(t, s.RootDir())
+	root2 := loadRoot(t, s.RootDir())
+
+	assert.NoError(t, root1.Lock())
+	assert.IsError(t, root2.Lock(), errors.E(config.ErrLocked))
+
+	// the lock is reentrant for the same root.
+	assert.NoError(t, root1.Lock())
+	assert.NoError(t, root1.Unlock())
+	assert.IsError(t, root2.Lock(), errors.E(config.ErrLocked))
+
+	assert.NoError(t, root1.Unlock())
+	assert.NoError(t, root2.Lock())
+	assert.IsError(t, root1.Lock(), errors.E(config.ErrLocked))
+	assert.NoError(t, root2.Unlock())
+
+	assert.IsError(t, root2.Unlock(), errors.E(config.ErrLock))
+
+	_, err := os.Stat(filepath.Join(s.RootDir(), ".terramate"))
+	assert.IsTrue(t, os.IsNotExist(err), "lock directory not removed")
+}
+
+func TestRootLockWaitsForRelease(t *testing.T) {
+	s := sandbox.NoGit(t)
+	s.BuildTree([]string{"s:stack"})
+
+	root1 := loadRoot(t, s.RootDir())
+	root2 := loadRoot(t, s.RootDir())
+	root2.SetLockWait(-1)
+
+	assert.NoError(t, root1.Lock())
+
+	locked := make(chan error)
+	go func() {
+		locked <- root2.Lock()
+	}()
+
+	select {
+	case err := <-locked:
+		t.Fatalf("lock acquired while held by other root: %v", err)
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	assert.NoError(t, root1.Unlock())
+
+	select {
+	case err := <-locked:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("lock not acquired after release")
+	}
+	assert.NoError(t, root2.Unlock())
+}
+
+func TestRootLockWaitTimeout(t *testing.T) {
+	s := sandbox.NoGit(t)
+	s.BuildTree([]string{"s:stack"})
+
+	root1 := loadRoot(t, s.RootDir())
+	root2 := loadRoot(t, s.RootDir())
+	root2.SetLockWait(200 * time.Millisecond)
+
+	assert.NoError(t, root1.Lock())
+	defer func() {
+		assert.NoError(t, root1.Unlock())
+	}()
+
+	start := time.Now()
+	assert.IsError(t, root2.Lock(), errors.E(config.ErrLocked))
+	assert.IsTrue(t, time.Since(start) >= 200*time.Millisecond,
+		"lock attempt didn't wait for the timeout")
+}
+
+func TestRootLockIgnoresStaleLockFile(t *testing.T) {
+	s := sandbox.NoGit(t)
+	s.BuildTree([]string{
+		"s:stack",
+		"d:.terramate",
+	})
+
+	// lock file left behind by a crashed process.
+	test.WriteFile(t, filepath.Join(s.RootDir(), ".terramate"), config.LockFilename, "999999\n")
+
+	root := loadRoot(t, s.RootDir())
+	assert.NoError(t, root.Lock())
+	assert.NoError(t, root.Unlock())
+
+	// the directory is kept since it was not created by the lock.
+	_, err := os.Stat(filepath.Join(s.RootDir(), ".terramate"))
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(s.RootDir(), ".terramate", config.LockFilename))
+	assert.IsTrue(t, os.IsNotExist(err), "lock file not removed")
+}
+
+func loadRoot(t *testing.T, rootdir string) *config.Root {
+	t.Helper()
+	root, err := config.LoadRoot(rootdir)
+	assert.NoError(t, err)
+	return root
+}
@@ -0,0 +1,302 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/project"
+	"github.com/mineiros-io/terramate/tf"
+	"github.com/rs/zerolog/log"
+)
+
+// OwnedFiles returns the files owned by the stack, which are the files that
+// contribute to the stack deployment:
+//
+//   - All files inside the stack directory and its subdirectories, except the
+//     ones inside child stacks and hidden directories (starting with a dot).
+//   - All files of the local modules used by the stack (see [Stack.LocalModules]),
+//     with the same rules of the stack directory.
+//
+// Each file is returned once, even if it is owned through multiple modules.
+// The returned paths are sorted. The change detection only considers the
+// changed module files owned by the stack.
+func (s *Stack) OwnedFiles(root *Root) ([]project.Path, error) {
+	return s.OwnedFilesWithCache(root, nil)
+}
+
+// OwnedFilesWithCache works like [Stack.OwnedFiles] but parses the Terraform
+// files using the given cache (see [Stack.LocalModulesWithCache]), which can
+// be nil.
+func (s *Stack) OwnedFilesWithCache(root *Root, cache *tf.ModuleCache) ([]project.Path, error) {
+	modules, err := s.LocalModulesWithCache(root, cache)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[project.Path]struct{}{}
+	var files []project.Path
+
+	dirs := []project.Path{s.Dir}
+	for _, mod := range modules {
+		dirs = append(dirs, mod.Dir)
+	}
+	for _, dir := range dirs {
+		dirfiles, err := listOwnedFiles(root, dir)
+		if err != nil {
+			return nil, errors.E(err, "listing files of %s", dir)
+		}
+		for _, file := range dirfiles {
+			if _, ok := seen[file]; ok {
+				continue
+			}
+			seen[file] = struct{}{}
+			files = append(files, file)
+		}
+	}
+
+	project.Paths(files).Sort()
+	return files, nil
+}
+
+// LocalModule is a local Terraform module used by a stack, directly or
+// through other local modules.
+type LocalModule struct {
+	// Dir is the module directory.
+	Dir project.Path
+	// Source is the source of the module, as declared in the module block.
+	Source string
+	// UsedBy is the directory of the stack or module that uses the module.
+	UsedBy project.Path
+}
+
+// LocalModules returns the local modules used by the Terraform files of the
+// stack directory and, recursively, by the Terraform files of those modules.
+// Each module is returned once, in depth-first order, and its UsedBy is
+// where it was first found. Modules outside the project root are ignored,
+// since they can't be represented as project paths.
+//
+// Module sources are resolved following symbolic links, so a module used
+// through a symlinked directory is returned with the project path of the
+// target directory, whose files are the ones actually changed, and symlink
+// cycles are visited only once.
+//
+// It returns an error if a local module source is not a directory or if it
+// is a symbolic link that can't be resolved.
+func (s *Stack) LocalModules(root *Root) ([]LocalModule, error) {
+	return s.LocalModulesWithCache(root, nil)
+}
+
+// LocalModulesWithCache works like [Stack.LocalModules] but parses the
+// Terraform files using the given cache, which avoids parsing the files of
+// modules shared by multiple stacks again. The cache can be nil.
+func (s *Stack) LocalModulesWithCache(root *Root, cache *tf.ModuleCache) ([]LocalModule, error) {
+	seen := map[project.Path]struct{}{}
+	var modules []LocalModule
+	err := s.WalkLocalModules(root, cache, func(use LocalModuleUse) error {
+		if use.Err != nil {
+			return use.Err
+		}
+		if _, ok := seen[use.Dir]; ok {
+			return nil
+		}
+		seen[use.Dir] = struct{}{}
+		modules = append(modules, LocalModule{
+			Dir:    use.Dir,
+			Source: use.Source,
+			UsedBy: use.UsedBy,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return modules, nil
+}
+
+// LocalModuleUse is a local module block of a Terraform file of a stack or
+// of one of its local modules.
+type LocalModuleUse struct {
+	// File is the Terraform file declaring the module block.
+	File project.Path
+	// Source is the source of the module, as declared in the module block.
+	Source string
+	// UsedBy is the directory of the stack or module that uses the module.
+	UsedBy project.Path
+	// Dir is the module directory. It is empty if Err is not nil.
+	Dir project.Path
+	// Err is the error resolving the module source, if any.
+	Err error
+}
+
+// WalkLocalModules calls fn for each local module block of the Terraform
+// files of the stack directory and, recursively, of the local modules used
+// by them, parsing the files using the given cache, which can be nil.
+//
+// Each module directory is walked once, and the stack directory is never
+// walked again, but fn is called for every module block, even if the module
+// was already walked. Module sources are resolved
+// as in [Stack.LocalModules] and the uses of modules outside the project root
+// are ignored. If the source can't be resolved, fn is called with the error
+// in LocalModuleUse.Err and the walk continues if fn returns nil. The walk
+// stops at the first error returned by fn.
+func (s *Stack) WalkLocalModules(root *Root, cache *tf.ModuleCache, fn func(LocalModuleUse) error) error {
+	rootdir, err := filepath.EvalSymlinks(root.HostDir())
+	if err != nil {
+		return errors.E(err, "resolving project root directory")
+	}
+	stackdir, err := filepath.EvalSymlinks(s.HostDir(root))
+	if err != nil {
+		return errors.E(err, "resolving stack directory")
+	}
+
+	// the stack directory is visited first, so modules using the stack
+	// directory as source don't walk it again.
+	visited := map[project.Path]struct{}{
+		project.PrjAbsPath(rootdir, stackdir): {},
+	}
+	return walkLocalModules(cache, rootdir, stackdir, visited, fn)
+}
+
+// walkLocalModules walks the local modules of the dir directory. The rootdir
+// and dir must have their symbolic links resolved.
+func walkLocalModules(
+	cache *tf.ModuleCache,
+	rootdir string,
+	dir string,
+	visited map[project.Path]struct{},
+	fn func(LocalModuleUse) error,
+) error {
+	logger := log.With().
+		Str("action", "config.walkLocalModules()").
+		Str("dir", dir).
+		Logger()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return errors.E(err, "listing files of directory %q", dir)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !tf.IsTerraformFile(entry.Name()) {
+			continue
+		}
+
+		tfpath := filepath.Join(dir, entry.Name())
+		mods, err := cache.ParseModules(tfpath)
+		if err != nil {
+			return errors.E(err, "parsing modules of %q", tfpath)
+		}
+
+		for _, mod := range mods {
+			if !mod.IsLocal() {
+				continue
+			}
+
+			use := LocalModuleUse{
+				File:   project.PrjAbsPath(rootdir, tfpath),
+				Source: mod.Source,
+				UsedBy: project.PrjAbsPath(rootdir, dir),
+			}
+
+			moddir, err := resolveModuleDir(filepath.Join(dir, mod.Source))
+			if err != nil {
+				use.Err = err
+				if err := fn(use); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if moddir != rootdir && !strings.HasPrefix(moddir, rootdir+string(filepath.Separator)) {
+				logger.Debug().
+					Str("module", moddir).
+					Msg("ignoring module outside of the project")
+				continue
+			}
+
+			use.Dir = project.PrjAbsPath(rootdir, moddir)
+			if err := fn(use); err != nil {
+				return err
+			}
+
+			if _, ok := visited[use.Dir]; ok {
+				continue
+			}
+			visited[use.Dir] = struct{}{}
+
+			if err := walkLocalModules(cache, rootdir, moddir, visited, fn); err != nil {
+				return errors.E(err, "module %s", use.Dir)
+			}
+		}
+	}
+	return nil
+}
+
+// resolveModuleDir resolves the symbolic links of the moddir module source
+// directory, returning an error if the source is not a directory.
+func resolveModuleDir(moddir string) (string, error) {
+	realdir, err := filepath.EvalSymlinks(moddir)
+	if err != nil {
+		if st, lerr := os.Lstat(moddir); lerr == nil && st.Mode()&fs.ModeSymlink != 0 {
+			return "", errors.E(err,
+				"\"source\" path %q is a symbolic link that can't be resolved", moddir)
+		}
+		return "", errors.E("\"source\" path %q is not a directory", moddir)
+	}
+
+	st, err := os.Stat(realdir)
+	if err != nil || !st.IsDir() {
+		return "", errors.E("\"source\" path %q is not a directory", moddir)
+	}
+	return realdir, nil
+}
+
+// listOwnedFiles lists the regular files inside dir, recursively, skipping
+// child stacks and hidden directories.
+func listOwnedFiles(root *Root, dir project.Path) ([]project.Path, error) {
+	var files []project.Path
+
+	pending := []project.Path{dir}
+	for len(pending) > 0 {
+		current := pending[0]
+		pending = pending[1:]
+
+		hostdir := current.HostPath(root.HostDir())
+		entries, err := os.ReadDir(hostdir)
+		if err != nil {
+			return nil, errors.E(err, "listing files of directory %q", hostdir)
+		}
+
+		for _, entry := range entries {
+			entrypath := current.Join(entry.Name())
+			if entry.IsDir() {
+				if Skip(entry.Name()) || IsStack(root, filepath.Join(hostdir, entry.Name())) {
+					continue
+				}
+				pending = append(pending, entrypath)
+				continue
+			}
+			if entry.Type().IsRegular() {
+				files = append(files, entrypath)
+			}
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,196 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/madlambda/spells/assert"
+	"github.com/mineiros-io/terramate/config"
+	"github.com/mineiros-io/terramate/project"
+	"github.com/mineiros-io/terramate/test/sandbox"
+)
+
+func TestStackOwnedFiles(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stack",
+		"s:stack/child",
+		`f:stack/main.tf:module "mod" {
+			source = "../modules/mod"
+		}
+		module "again" {
+			source = "../modules/mod"
+		}
+		module "remote" {
+			source = "github.com/mineiros-io/example"
+		}`,
+		"f:stack/dir/file.txt",
+		"f:stack/.terraform/cache",
+		"f:stack/child/main.tf",
+		"f:modules/mod/main.tf",
+		"f:modules/mod/variables.tf",
+		"f:modules/other/main.tf",
+	})
+
+	st := s.LoadStack(project.NewPath("/stack"))
+	got, err := st.OwnedFiles(s.Config())
+	assert.NoError(t, err)
+
+	want := []string{
+		"/modules/mod/main.tf",
+		"/modules/mod/variables.tf",
+		"/stack/dir/file.txt",
+		"/stack/main.tf",
+		"/stack/stack.tm.hcl",
+	}
+	assertPaths(t, got, want)
+
+	modules, err := st.LocalModules(s.Config())
+	assert.NoError(t, err)
+	assert.EqualInts(t, 1, len(modules), "unexpected modules: %v", modules)
+	assert.EqualStrings(t, "/modules/mod", modules[0].Dir.String())
+	assert.EqualStrings(t, "/stack", modules[0].UsedBy.String())
+}
+
+func TestStackOwnedFilesNestedModules(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stack",
+		`f:stack/main.tf:module "a" {
+			source = "../modules/a"
+		}`,
+		`f:modules/a/main.tf:module "b" {
+			source = "../b"
+		}`,
+		`f:modules/b/main.tf:module "a" {
+			source = "../a"
+		}`,
+	})
+
+	st := s.LoadStack(project.NewPath("/stack"))
+	got, err := st.OwnedFiles(s.Config())
+	assert.NoError(t, err)
+
+	assertPaths(t, got, []string{
+		"/modules/a/main.tf",
+		"/modules/b/main.tf",
+		"/stack/main.tf",
+		"/stack/stack.tm.hcl",
+	})
+}
+
+func TestStackWalkLocalModulesDoesNotWalkStackAgain(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stack",
+		`f:stack/main.tf:module "a" {
+			source = "../modules/a"
+		}`,
+		`f:modules/a/main.tf:module "stack" {
+			source = "../../stack"
+		}`,
+	})
+
+	st := s.LoadStack(project.NewPath("/stack"))
+
+	var uses []string
+	err := st.WalkLocalModules(s.Config(), nil, func(use config.LocalModuleUse) error {
+		assert.NoError(t, use.Err)
+		uses = append(uses, use.File.String()+" -> "+use.Dir.String())
+		return nil
+	})
+	assert.NoError(t, err)
+
+	want := []string{
+		"/stack/main.tf -> /modules/a",
+		"/modules/a/main.tf -> /stack",
+	}
+	if diff := cmp.Diff(want, uses); diff != "" {
+		t.Fatalf("-(want) +(got):\n%s", diff)
+	}
+}
+
+func TestStackOwnedFilesFailsOnInvalidModuleSource(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stack",
+		`f:stack/main.tf:module "mod" {
+			source = "../modules/missing"
+		}`,
+	})
+
+	st := s.LoadStack(project.NewPath("/stack"))
+	_, err := st.OwnedFiles(s.Config())
+	assert.Error(t, err)
+}
+
+func TestStackLocalModulesSymlinks(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stack",
+		`f:stack/main.tf:module "shared" {
+			source = "../shared"
+		}`,
+		`f:modules/real/main.tf:module "self" {
+			source = "./loop"
+		}`,
+		"l:modules/real:shared",
+		"l:modules/real:modules/real/loop",
+	})
+
+	st := s.LoadStack(project.NewPath("/stack"))
+	modules, err := st.LocalModules(s.Config())
+	assert.NoError(t, err)
+	assert.EqualInts(t, 1, len(modules), "unexpected modules: %v", modules)
+	assert.EqualStrings(t, "/modules/real", modules[0].Dir.String())
+	assert.EqualStrings(t, "../shared", modules[0].Source)
+	assert.EqualStrings(t, "/stack", modules[0].UsedBy.String())
+
+	got, err := st.OwnedFiles(s.Config())
+	assert.NoError(t, err)
+	assertPaths(t, got, []string{
+		"/modules/real/main.tf",
+		"/stack/main.tf",
+		"/stack/stack.tm.hcl",
+	})
+}
+
+func TestStackLocalModulesFailsOnBrokenSymlink(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stack",
+		`f:stack/main.tf:module "broken" {
+			source = "../broken"
+		}`,
+		"l:modules/missing:broken",
+	})
+
+	st := s.LoadStack(project.NewPath("/stack"))
+	_, err := st.LocalModules(s.Config())
+	assert.Error(t, err)
+	assert.IsTrue(t, strings.Contains(err.Error(), "symbolic link that can't be resolved"),
+		"unexpected error: %v", err)
+}
+
+func assertPaths(t *testing.T, got []project.Path, want []string) {
+	t.Helper()
+
+	if diff := cmp.Diff(want, project.Paths(got).Strings()); diff != "" {
+		t.Fatalf("unexpected paths (-want +got):\n%s", diff)
+	}
+}
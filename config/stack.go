@@ -19,11 +19,13 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/mineiros-io/terramate/config/tag"
 	"github.com/mineiros-io/terramate/errors"
 	"github.com/mineiros-io/terramate/hcl"
+	"github.com/mineiros-io/terramate/hcl/ast"
 	"github.com/mineiros-io/terramate/project"
 	"github.com/rs/zerolog/log"
 	"github.com/zclconf/go-cty/cty"
@@ -65,8 +67,33 @@ type (
 		// Watch is the list of files to be watched for changes.
 		Watch []project.Path
 
+		// VarFiles is the list of Terraform variables files used by the stack.
+		// Changing any of them changes the stack.
+		VarFiles []project.Path
+
+		// Owners is the list of owners of the stack (eg.: teams or people).
+		// A stack with multiple owners is owned by each one of them.
+		Owners []string
+
+		// ConcurrencyGroup is the concurrency group of the stack. Stacks in
+		// the same concurrency group never run in parallel.
+		ConcurrencyGroup string
+
 		// IsChanged tells if this is a changed stack.
 		IsChanged bool
+
+		// ChangedFiles is the list of changed files, relative to the project
+		// root, which caused the stack to be flagged as changed. It is only
+		// set by change detection.
+		ChangedFiles []string
+
+		// FeaturesAttr is the unevaluated stack.features attribute, if any.
+		FeaturesAttr *ast.Attribute
+
+		// Features are the evaluated feature flags of the stack. They depend
+		// on the stack globals, so they are only set on the stacks returned
+		// by WithFeatures (see globals.ForStack).
+		Features map[string]bool
 	}
 
 	// SortableStack is a wrapper for the Stack which implements the [DirElem] type.
@@ -85,6 +112,10 @@ const (
 	// ErrStackInvalidWatch indicates the stack.watch attribute contains invalid values.
 	ErrStackInvalidWatch errors.Kind = "invalid stack.watch attribute"
 
+	// ErrStackInvalidVarFiles indicates the stack.var_files attribute contains
+	// invalid values.
+	ErrStackInvalidVarFiles errors.Kind = "invalid stack.var_files attribute"
+
 	// ErrStackInvalidTag indicates the stack.tags is invalid.
 	ErrStackInvalidTag errors.Kind = "invalid stack.tags entry"
 
@@ -93,6 +124,12 @@ const (
 
 	// ErrStackInvalidWantedBy indicates the stack.wanted_by is invalid.
 	ErrStackInvalidWantedBy errors.Kind = "invalid stack.wanted_by entry"
+
+	// ErrStackInvalidOwner indicates the stack.owner is invalid.
+	ErrStackInvalidOwner errors.Kind = "invalid stack.owner entry"
+
+	// ErrStackInvalidFeatures indicates the stack.features is invalid.
+	ErrStackInvalidFeatures errors.Kind = "invalid stack.features attribute"
 )
 
 // NewStackFromHCL creates a new stack from raw configuration cfg.
@@ -102,22 +139,32 @@ func NewStackFromHCL(root string, cfg hcl.Config) (*Stack, error) {
 		name = filepath.Base(cfg.AbsDir())
 	}
 
-	watchFiles, err := validateWatchPaths(root, cfg.AbsDir(), cfg.Stack.Watch)
+	watchFiles, err := validateWatchPaths(root, cfg.AbsDir(), "stack.watch", cfg.Stack.Watch)
 	if err != nil {
 		return nil, errors.E(err, ErrStackInvalidWatch)
 	}
 
+	varFiles, err := validateVarFiles(root, cfg.AbsDir(), cfg.Stack.VarFiles)
+	if err != nil {
+		return nil, errors.E(err, ErrStackInvalidVarFiles)
+	}
+
 	stack := &Stack{
-		Name:        name,
-		ID:          cfg.Stack.ID,
-		Description: cfg.Stack.Description,
-		Tags:        cfg.Stack.Tags,
-		After:       cfg.Stack.After,
-		Before:      cfg.Stack.Before,
-		Wants:       cfg.Stack.Wants,
-		WantedBy:    cfg.Stack.WantedBy,
-		Watch:       watchFiles,
-		Dir:         project.PrjAbsPath(root, cfg.AbsDir()),
+		Name:             name,
+		ID:               cfg.Stack.ID,
+		Description:      cfg.Stack.Description,
+		Tags:             cfg.Stack.Tags,
+		After:            cfg.Stack.After,
+		Before:           cfg.Stack.Before,
+		Wants:            cfg.Stack.Wants,
+		WantedBy:         cfg.Stack.WantedBy,
+		Watch:            watchFiles,
+		VarFiles:         varFiles,
+		Owners:           cfg.Stack.Owners,
+		ConcurrencyGroup: cfg.Stack.ConcurrencyGroup,
+		Dir:              project.PrjAbsPath(root, cfg.AbsDir()),
+
+		FeaturesAttr: cfg.Stack.Features,
 	}
 	err = stack.Validate()
 	if err != nil {
@@ -130,9 +177,19 @@ func NewStackFromHCL(root string, cfg hcl.Config) (*Stack, error) {
 func (s Stack) Validate() error {
 	errs := errors.L()
 	errs.AppendWrap(ErrStackValidation, s.validateID(), s.ValidateSets(), s.ValidateTags())
+	errs.AppendWrap(ErrStackInvalidOwner, s.validateOwners())
 	return errs.AsError()
 }
 
+func (s Stack) validateOwners() error {
+	for _, owner := range s.Owners {
+		if strings.TrimSpace(owner) == "" {
+			return errors.E("stack.owner must not have empty entries")
+		}
+	}
+	return nil
+}
+
 // ValidateTags validates if tags are correctly used in all stack fields.
 func (s Stack) ValidateTags() error {
 	errs := errors.L()
@@ -175,6 +232,7 @@ func (s Stack) ValidateSets() error {
 		validateSet("before", s.Before),
 		validateSet("wants", s.Wants),
 		validateSet("wanted_by", s.WantedBy),
+		validateSet("owner", s.Owners),
 	)
 	return errs.AsError()
 }
@@ -206,6 +264,13 @@ func (s *Stack) AppendBefore(path string) {
 	s.Before = append(s.Before, path)
 }
 
+// WithFeatures returns a copy of the stack with the given evaluated feature
+// flags, exposed as terramate.stack.features metadata.
+func (s Stack) WithFeatures(features map[string]bool) *Stack {
+	s.Features = features
+	return &s
+}
+
 // String representation of the stack.
 func (s *Stack) String() string { return s.Dir.String() }
 
@@ -246,6 +311,9 @@ func (s *Stack) RuntimeValues(root *Root) map[string]cty.Value {
 		"description": cty.StringVal(s.Description),
 		"tags":        toCtyStringList(s.Tags),
 		"path":        stackpath,
+
+		"changed_files": toCtyStringList(s.ChangedFiles),
+		"features":      featuresToCty(s.Features),
 	}
 	if s.ID != "" {
 		logger.Trace().
@@ -254,6 +322,15 @@ func (s *Stack) RuntimeValues(root *Root) map[string]cty.Value {
 
 		stackMapVals["id"] = cty.StringVal(s.ID)
 	}
+
+	stackMapVals["parent"] = cty.NullVal(cty.DynamicPseudoType)
+	if parent, ok := s.ParentStack(root); ok {
+		logger.Trace().
+			Stringer("parent", parent.Dir()).
+			Msg("adding parent stack to metadata")
+
+		stackMapVals["parent"] = parentStackMetadata(parent)
+	}
 	stack := cty.ObjectVal(stackMapVals)
 	return map[string]cty.Value{
 		"name":        cty.StringVal(s.Name),         // DEPRECATED
@@ -263,6 +340,55 @@ func (s *Stack) RuntimeValues(root *Root) map[string]cty.Value {
 	}
 }
 
+// ParentStack returns the nearest ancestor stack of the stack, which is the
+// stack defined at the closest parent directory of the stack directory, up to
+// the project root. Stacks nested inside non-stack directories also have the
+// nearest stack found walking up the directory tree as parent.
+// It returns false if there's no stack in any of the parent directories.
+func (s *Stack) ParentStack(root *Root) (*Tree, bool) {
+	dir := s.Dir
+	for dir.String() != "/" {
+		dir = dir.Dir()
+		cfg, ok := root.Lookup(dir)
+		if ok && cfg.IsStack() {
+			return cfg, true
+		}
+	}
+	return nil, false
+}
+
+// featuresToCty returns the terramate.stack.features metadata, an object
+// with the evaluated feature flags of the stack.
+func featuresToCty(features map[string]bool) cty.Value {
+	vals := make(map[string]cty.Value, len(features))
+	for name, enabled := range features {
+		vals[name] = cty.BoolVal(enabled)
+	}
+	return cty.ObjectVal(vals)
+}
+
+// parentStackMetadata returns the terramate.stack.parent metadata of the
+// given parent stack config.
+func parentStackMetadata(parent *Tree) cty.Value {
+	dir := parent.Dir()
+	name := parent.Node.Stack.Name
+	if name == "" {
+		name = filepath.Base(parent.HostDir())
+	}
+	vals := map[string]cty.Value{
+		"name": cty.StringVal(name),
+		"path": cty.ObjectVal(map[string]cty.Value{
+			"absolute": cty.StringVal(dir.String()),
+			"relative": cty.StringVal(dir.String()[1:]),
+			"basename": cty.StringVal(path.Base(dir.String())),
+		}),
+	}
+	if id := parent.Node.Stack.ID; id != "" {
+		vals["id"] = cty.StringVal(id)
+	}
+	return cty.ObjectVal(vals)
+}
+
 // Sortable returns an implementation of stack which can be sorted by [config.List].
 func (s *Stack) Sortable() *SortableStack {
 	return &SortableStack{
@@ -270,7 +396,17 @@ func (s *Stack) Sortable() *SortableStack {
 	}
 }
 
-func validateWatchPaths(rootdir string, stackpath string, paths []string) (project.Paths, error) {
+func validateVarFiles(rootdir string, stackpath string, paths []string) (project.Paths, error) {
+	for _, pathstr := range paths {
+		if !strings.HasSuffix(pathstr, ".tfvars") && !strings.HasSuffix(pathstr, ".tfvars.json") {
+			return nil, errors.E("stack.var_files must be a list of .tfvars or "+
+				".tfvars.json files but %q was provided", pathstr)
+		}
+	}
+	return validateWatchPaths(rootdir, stackpath, "stack.var_files", paths)
+}
+
+func validateWatchPaths(rootdir string, stackpath string, attr string, paths []string) (project.Paths, error) {
 	var projectPaths project.Paths
 	for _, pathstr := range paths {
 		var abspath string
@@ -285,13 +421,13 @@ func validateWatchPaths(rootdir string, stackpath string, paths []string) (proje
 		st, err := os.Stat(abspath)
 		if err == nil {
 			if st.IsDir() {
-				return nil, errors.E("stack.watch must be a list of regular files "+
-					"but directory %q was provided", pathstr)
+				return nil, errors.E("%s must be a list of regular files "+
+					"but directory %q was provided", attr, pathstr)
 			}
 
 			if !st.Mode().IsRegular() {
-				return nil, errors.E("stack.watch must be a list of regular files "+
-					"but file %q has mode %s", pathstr, st.Mode())
+				return nil, errors.E("%s must be a list of regular files "+
+					"but file %q has mode %s", attr, pathstr, st.Mode())
 			}
 		}
 		projectPaths = append(projectPaths, project.PrjAbsPath(rootdir, abspath))
@@ -314,18 +450,36 @@ func StacksFromTrees(root string, trees List[*Tree]) (List[*SortableStack], erro
 
 // LoadAllStacks loads all stacks inside the given rootdir.
 func LoadAllStacks(cfg *Tree) (List[*SortableStack], error) {
+	stacks := List[*SortableStack]{}
+	err := WalkStacks(cfg, func(stack *Stack) error {
+		stacks = append(stacks, stack.Sortable())
+		return nil
+	})
+	if err != nil {
+		return List[*SortableStack]{}, err
+	}
+	return stacks, nil
+}
+
+// WalkStacks loads the stacks of the tree, including the tree itself, and
+// calls fn for each of them as soon as it is loaded, in the lexicographic
+// order of their directories. The walk stops at the first error returned by
+// fn, which is returned as is.
+//
+// Stacks with duplicated ids are detected when the second stack is found, so
+// fn may be called for some stacks before the error of kind
+// [ErrStackDuplicatedID] is returned.
+func WalkStacks(cfg *Tree, fn func(*Stack) error) error {
 	logger := log.With().
-		Str("action", "stack.LoadAll()").
+		Str("action", "config.WalkStacks()").
 		Str("root", cfg.RootDir()).
 		Logger()
 
-	stacks := List[*SortableStack]{}
 	stacksIDs := map[string]*Stack{}
-
-	for _, stackNode := range cfg.Stacks() {
-		stack, err := NewStackFromHCL(cfg.RootDir(), stackNode.Node)
+	return walkStackTrees(cfg, func(tree *Tree) error {
+		stack, err := NewStackFromHCL(cfg.RootDir(), tree.Node)
 		if err != nil {
-			return List[*SortableStack]{}, err
+			return err
 		}
 
 		logger := logger.With().
@@ -333,12 +487,11 @@ func LoadAllStacks(cfg *Tree) (List[*SortableStack], error) {
 			Logger()
 
 		logger.Debug().Msg("Found stack")
-		stacks = append(stacks, stack.Sortable())
 
 		if stack.ID != "" {
 			logger.Trace().Msg("stack has ID, checking for duplicate")
 			if otherStack, ok := stacksIDs[stack.ID]; ok {
-				return List[*SortableStack]{}, errors.E(ErrStackDuplicatedID,
+				return errors.E(ErrStackDuplicatedID,
 					"stack %q and %q have same ID %q",
 					stack.Dir,
 					otherStack.Dir,
@@ -347,9 +500,57 @@ func LoadAllStacks(cfg *Tree) (List[*SortableStack], error) {
 			}
 			stacksIDs[stack.ID] = stack
 		}
+		return fn(stack)
+	})
+}
+
+// walkStackTrees calls fn for the tree and each of its descendants which are
+// stacks, in the lexicographic order of their directories.
+func walkStackTrees(tree *Tree, fn func(*Tree) error) error {
+	if tree.IsStack() {
+		if err := fn(tree); err != nil {
+			return err
+		}
 	}
+	return walkChildStackTrees(tree, fn)
+}
 
-	return stacks, nil
+// walkChildStackTrees works like walkStackTrees but only for the descendants
+// of the tree. Since the child directories and their descendants are sorted
+// by their full path, a child named "a-b" comes after "a" but before any
+// descendant of "a", which are prefixed by "a/".
+func walkChildStackTrees(tree *Tree, fn func(*Tree) error) error {
+	type walkItem struct {
+		key         string
+		child       *Tree
+		descendants bool
+	}
+
+	items := make([]walkItem, 0, 2*len(tree.Children))
+	for name, child := range tree.Children {
+		items = append(items,
+			walkItem{key: name, child: child},
+			walkItem{key: name + "/", child: child, descendants: true},
+		)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].key < items[j].key
+	})
+
+	for _, item := range items {
+		if item.descendants {
+			if err := walkChildStackTrees(item.child, fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if item.child.IsStack() {
+			if err := fn(item.child); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 // LoadStack a single stack from dir.
@@ -0,0 +1,106 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/git"
+	"github.com/rs/zerolog/log"
+)
+
+// ErrTreeHash indicates that the project tree hash could not be computed.
+const ErrTreeHash errors.Kind = "computing project tree hash"
+
+// TreeHash returns a hash of the content of the project tree, which can be
+// used to key caches of data derived from the project files.
+//
+// If the project has no uncommitted or untracked changes then the hash is the
+// git tree object id of the project directory at HEAD, which is cheap to
+// obtain. Otherwise the hash is a SHA-256 of the tree object id combined with
+// the path and content of each changed file, so identical working trees
+// always have the same hash and any change to the project files changes it.
+// Files ignored by git are not part of the hash.
+func (root *Root) TreeHash(g *git.Git) (string, error) {
+	logger := log.With().
+		Str("action", "Root.TreeHash()").
+		Str("root", root.HostDir()).
+		Logger()
+
+	toplevel, err := g.Root()
+	if err != nil {
+		return "", errors.E(ErrTreeHash, err, "getting git root directory")
+	}
+
+	// the project may be a subdirectory of the repository.
+	prefix, err := filepath.Rel(toplevel, root.HostDir())
+	if err != nil || prefix == ".." || strings.HasPrefix(prefix, ".."+string(filepath.Separator)) {
+		return "", errors.E(ErrTreeHash, "project %s is outside of git repository %s",
+			root.HostDir(), toplevel)
+	}
+	prefix = filepath.ToSlash(prefix)
+
+	treeRev := "HEAD^{tree}"
+	if prefix != "." {
+		treeRev = "HEAD:" + prefix
+	}
+	treeID, err := g.RevParse(treeRev)
+	if err != nil {
+		return "", errors.E(ErrTreeHash, err, "getting project tree object")
+	}
+
+	changed, err := g.ListChangedFiles("HEAD")
+	if err != nil {
+		return "", errors.E(ErrTreeHash, err, "listing changed files")
+	}
+
+	h := sha256.New()
+	_, _ = io.WriteString(h, treeID+"\x00")
+
+	dirty := false
+	for _, file := range changed {
+		if prefix != "." && !strings.HasPrefix(file, prefix+"/") {
+			continue
+		}
+		dirty = true
+
+		logger.Trace().
+			Str("file", file).
+			Msg("Add changed file to the hash.")
+
+		_, _ = io.WriteString(h, file+"\x00")
+		content, err := os.ReadFile(filepath.Join(toplevel, filepath.FromSlash(file)))
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				return "", errors.E(ErrTreeHash, err, "reading changed file")
+			}
+			_, _ = io.WriteString(h, "deleted\x00")
+			continue
+		}
+		contentHash := sha256.Sum256(content)
+		_, _ = h.Write(contentHash[:])
+	}
+
+	if !dirty {
+		return treeID, nil
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,92 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/madlambda/spells/assert"
+	"github.com/mineiros-io/terramate/test"
+	"github.com/mineiros-io/terramate/test/sandbox"
+)
+
+func TestTreeHash(t *testing.T) {
+	layout := []string{
+		// the sandbox README has random content.
+		"f:README.md:# readme",
+		"s:stacks/a",
+		"s:stacks/b",
+		"f:stacks/a/main.tf:# a",
+		"f:modules/mod/main.tf:# mod",
+	}
+
+	newSandbox := func(t *testing.T) sandbox.S {
+		s := sandbox.New(t)
+		s.BuildTree(layout)
+		s.Git().CommitAll("initial commit")
+		return s
+	}
+
+	treeHash := func(t *testing.T, s sandbox.S) string {
+		g := test.NewGitWrapper(t, s.RootDir(), []string{})
+		hash, err := s.Config().TreeHash(g)
+		assert.NoError(t, err)
+		return hash
+	}
+
+	s1 := newSandbox(t)
+	s2 := newSandbox(t)
+
+	cleanHash := treeHash(t, s1)
+	assert.EqualStrings(t, cleanHash, treeHash(t, s1), "hash is not stable")
+	assert.EqualStrings(t, cleanHash, treeHash(t, s2), "identical trees must have the same hash")
+
+	file := filepath.Join(s1.RootDir(), "stacks", "a", "main.tf")
+	writeFile := func(content string) {
+		assert.NoError(t, os.WriteFile(file, []byte(content), 0644))
+	}
+
+	writeFile("# changed")
+	dirtyHash := treeHash(t, s1)
+	if dirtyHash == cleanHash {
+		t.Fatal("editing a file must change the hash")
+	}
+	assert.EqualStrings(t, dirtyHash, treeHash(t, s1), "dirty hash is not stable")
+
+	s2.RootEntry().CreateFile("stacks/a/main.tf", "# changed")
+	assert.EqualStrings(t, dirtyHash, treeHash(t, s2), "identical dirty trees must have the same hash")
+
+	writeFile("# a")
+	assert.EqualStrings(t, cleanHash, treeHash(t, s1), "reverting the edit must restore the hash")
+
+	s1.RootEntry().CreateFile("untracked.txt", "untracked")
+	untrackedHash := treeHash(t, s1)
+	if untrackedHash == cleanHash || untrackedHash == dirtyHash {
+		t.Fatal("untracked files must change the hash")
+	}
+
+	s1.Git().CommitAll("add untracked file")
+	committedHash := treeHash(t, s1)
+	if committedHash == cleanHash || committedHash == untrackedHash {
+		t.Fatal("committing a new file must change the hash")
+	}
+
+	assert.NoError(t, os.Remove(file))
+	if deletedHash := treeHash(t, s1); deletedHash == committedHash {
+		t.Fatal("deleting a file must change the hash")
+	}
+}
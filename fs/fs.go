@@ -57,7 +57,7 @@ func ListTerramateFiles(dir string) ([]string, error) {
 		}
 
 		filename := dirEntry.Name()
-		if isTerramateFile(filename) {
+		if IsTerramateFile(filename) {
 			logger.Trace().Msg("Found Terramate file")
 			files = append(files, filename)
 		}
@@ -106,6 +106,8 @@ func ListTerramateDirs(dir string) ([]string, error) {
 	return dirs, nil
 }
 
-func isTerramateFile(filename string) bool {
+// IsTerramateFile tells if the filename is a Terramate configuration file,
+// which are the files with the .tm or .tm.hcl extensions.
+func IsTerramateFile(filename string) bool {
 	return strings.HasSuffix(filename, ".tm") || strings.HasSuffix(filename, ".tm.hcl")
 }
@@ -285,6 +285,69 @@ func TestGenerateAssert(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "only failed assertions with error severity fail generation",
+			layout: []string{
+				"s:stacks/stack-1",
+				"s:stacks/stack-2",
+			},
+			configs: []hclconfig{
+				{
+					path: "/",
+					add: GenerateFile(
+						Labels("test.txt"),
+						Str("content", "test"),
+					),
+				},
+				{
+					path: "/stacks",
+					add: Doc(
+						Assert(
+							Bool("assertion", false),
+							Str("message", "info msg"),
+							Str("severity", "info"),
+						),
+						Assert(
+							Bool("assertion", false),
+							Str("message", "warn msg"),
+							Str("severity", "warn"),
+						),
+					),
+				},
+				{
+					path: "/stacks/stack-1",
+					add: Assert(
+						Bool("assertion", false),
+						Str("message", "error msg"),
+						Str("severity", "error"),
+					),
+				},
+			},
+			want: []generatedFile{
+				{
+					dir: "/stacks/stack-2",
+					files: map[string]fmt.Stringer{
+						"test.txt": stringer("test"),
+					},
+				},
+			},
+			wantReport: generate.Report{
+				Successes: []generate.Result{
+					{
+						Dir:     project.NewPath("/stacks/stack-2"),
+						Created: []string{"test.txt"},
+					},
+				},
+				Failures: []generate.FailureResult{
+					{
+						Result: generate.Result{
+							Dir: project.NewPath("/stacks/stack-1"),
+						},
+						Error: errors.E(generate.ErrAssertion),
+					},
+				},
+			},
+		},
 		{
 			name: "failed assertions on all levels",
 			layout: []string{
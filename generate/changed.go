@@ -0,0 +1,165 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generate
+
+import (
+	"path"
+
+	"github.com/mineiros-io/terramate/config"
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/event"
+	"github.com/mineiros-io/terramate/fs"
+	"github.com/mineiros-io/terramate/project"
+	"github.com/mineiros-io/terramate/stack"
+	"github.com/rs/zerolog/log"
+)
+
+// ErrChangeDetection indicates that the changed stacks could not be detected.
+const ErrChangeDetection errors.Kind = "detecting changed stacks"
+
+// DoChanged works like [Do] but only generates code for the stacks changed in
+// the base..head commit range, which makes it suitable for fast incremental
+// code generation.
+//
+// The changed stacks are detected by [stack.Manager.ListChangedFrom] using
+// mgr, so all the manager options are honored. Additionally, stacks are
+// regenerated if any Terramate configuration file on their parent directories
+// changed, since globals and generate blocks are inherited by child stacks.
+//
+// The unchanged stacks are still evaluated, so the conflicts between the
+// generated files are checked and the orphaned generated files are removed,
+// but their files are neither generated nor removed. The root generate_file
+// blocks are always generated.
+//
+// This is a generate function instead of a stack.Manager method because the
+// generate package imports the stack package (eg.: for the stack evaluation
+// context), so the stack package can't import the generate package back.
+//
+// Errors detecting the changed stacks are reported as the report
+// BootstrapErr and no code is generated. The project lock is handled as in
+// [Do].
+func DoChanged(
+	root *config.Root,
+	vendorDir project.Path,
+	vendorRequests chan<- event.VendorRequest,
+	mgr *stack.Manager,
+	base, head string,
+) Report {
+	logger := log.With().
+		Str("action", "generate.DoChanged()").
+		Str("base", base).
+		Str("head", head).
+		Logger()
+
+	changed, err := changedStacks(root, mgr, base, head)
+	if err != nil {
+		return Report{BootstrapErr: errors.E(ErrChangeDetection, err)}
+	}
+
+	logger.Debug().
+		Int("changed", len(changed)).
+		Msg("generating code for changed stacks")
+
+	return doFiltered(root, vendorDir, vendorRequests, changedFilter(changed), true)
+}
+
+// DoIncremental works like [Do], generating code for all stacks, but the
+// generate blocks with on_change_only = true are only generated for the
+// stacks changed in the base..head commit range, detected the same way as
+// [DoChanged]. For the unchanged stacks, the files of these blocks are
+// neither generated nor removed, so the files generated by previous runs are
+// kept as is.
+//
+// Errors detecting the changed stacks are reported as the report
+// BootstrapErr and no code is generated. The project lock is handled as in
+// [Do].
+func DoIncremental(
+	root *config.Root,
+	vendorDir project.Path,
+	vendorRequests chan<- event.VendorRequest,
+	mgr *stack.Manager,
+	base, head string,
+) Report {
+	logger := log.With().
+		Str("action", "generate.DoIncremental()").
+		Str("base", base).
+		Str("head", head).
+		Logger()
+
+	changed, err := changedStacks(root, mgr, base, head)
+	if err != nil {
+		return Report{BootstrapErr: errors.E(ErrChangeDetection, err)}
+	}
+
+	logger.Debug().
+		Int("changed", len(changed)).
+		Msg("generating code with changed stacks")
+
+	return DoWithFilter(root, vendorDir, vendorRequests, changedFilter(changed))
+}
+
+// changedFilter returns a filter accepting the stacks of the changed set.
+func changedFilter(changed map[project.Path]struct{}) StackFilter {
+	return func(stack *config.Stack) bool {
+		_, ok := changed[stack.Dir]
+		return ok
+	}
+}
+
+// changedStacks returns the set of stacks changed in the base..head commit
+// range, including the stacks whose parent directories have changed
+// Terramate configuration files.
+func changedStacks(
+	root *config.Root,
+	mgr *stack.Manager,
+	base, head string,
+) (map[project.Path]struct{}, error) {
+	report, err := mgr.ListChangedFrom(base, head)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := map[project.Path]struct{}{}
+	for _, entry := range report.Stacks {
+		selected[entry.Stack.Dir] = struct{}{}
+	}
+
+	var cfgdirs []project.Path
+	for _, file := range report.ChangedFiles {
+		if fs.IsTerramateFile(path.Base(file)) {
+			cfgdirs = append(cfgdirs, project.NewPath(path.Join("/", path.Dir(file))))
+		}
+	}
+	if len(cfgdirs) == 0 {
+		return selected, nil
+	}
+
+	for _, stackdir := range root.Stacks() {
+		for _, cfgdir := range cfgdirs {
+			if isParentDir(cfgdir, stackdir) {
+				selected[stackdir] = struct{}{}
+				break
+			}
+		}
+	}
+	return selected, nil
+}
+
+func isParentDir(parent, dir project.Path) bool {
+	if parent == dir {
+		return false
+	}
+	return parent.String() == "/" || dir.HasPrefix(parent.String()+"/")
+}
@@ -0,0 +1,222 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generate_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/madlambda/spells/assert"
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/generate"
+	"github.com/mineiros-io/terramate/generate/genhcl"
+	"github.com/mineiros-io/terramate/project"
+	"github.com/mineiros-io/terramate/stack"
+	"github.com/mineiros-io/terramate/test/sandbox"
+)
+
+func TestGenerateChanged(t *testing.T) {
+	t.Parallel()
+
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stacks/a",
+		"s:stacks/b",
+		"s:other/c",
+		"f:stacks/a/main.tf:# a",
+		`f:globals.tm:globals {
+		  value = "root"
+		}`,
+		`f:stacks/globals.tm:globals {
+		  value = "stacks"
+		}`,
+		`f:generate.tm:generate_file "file.txt" {
+		  content = global.value
+		}`,
+	})
+	s.Generate()
+
+	git := s.Git()
+	git.CommitAll("initial commit")
+	git.Push("main")
+	git.CheckoutNew("change-stack")
+
+	removeGenerated := func() {
+		t.Helper()
+		for _, stack := range []string{"stacks/a", "stacks/b", "other/c"} {
+			err := os.Remove(filepath.Join(s.RootDir(), stack, "file.txt"))
+			assert.NoError(t, err)
+		}
+	}
+
+	generated := func(stacks ...string) generate.Report {
+		report := generate.Report{}
+		for _, stack := range stacks {
+			report.Successes = append(report.Successes, generate.Result{
+				Dir:     project.NewPath(stack),
+				Created: []string{"file.txt"},
+			})
+		}
+		return report
+	}
+
+	t.Run("unchanged stacks are skipped", func(t *testing.T) {
+		s.RootEntry().CreateFile("stacks/a/main.tf", "# changed")
+		git.CommitAll("change stack a")
+		removeGenerated()
+
+		root := s.Config()
+		report := generate.DoChanged(root, project.NewPath("/modules"), nil,
+			stack.NewManager(root, "origin/main"), "origin/main", "HEAD")
+		assertEqualReports(t, report, generated("/stacks/a"))
+	})
+
+	s.Generate()
+	git.CheckoutNew("change-parent-config")
+
+	t.Run("stacks with changed parent config are regenerated", func(t *testing.T) {
+		s.RootEntry().CreateFile("stacks/globals.tm", `globals {
+		  value = "changed"
+		}`)
+		git.CommitAll("change parent config")
+		removeGenerated()
+
+		root := s.ReloadConfig()
+		report := generate.DoChanged(root, project.NewPath("/modules"), nil,
+			stack.NewManager(root, "change-stack"), "change-stack", "HEAD")
+		assertEqualReports(t, report, generated("/stacks/a", "/stacks/b"))
+
+		got, err := os.ReadFile(filepath.Join(s.RootDir(), "stacks/b/file.txt"))
+		assert.NoError(t, err)
+		assert.EqualStrings(t, "changed", string(got))
+	})
+
+	t.Run("manager options are honored", func(t *testing.T) {
+		s.Generate()
+		git.CommitAll("update generated code")
+		s.RootEntry().CreateFile("other/c/main.tf", "# uncommitted")
+		assert.NoError(t, os.Remove(filepath.Join(s.RootDir(), "other/c/file.txt")))
+
+		root := s.Config()
+		mgr := stack.NewManagerWithOptions(root, "HEAD", stack.ManagerOptions{
+			IncludeLocalChanges: true,
+		})
+		report := generate.DoChanged(root, project.NewPath("/modules"), nil,
+			mgr, "HEAD", "HEAD")
+		assertEqualReports(t, report, generated("/other/c"))
+	})
+
+	t.Run("orphaned generated files are removed", func(t *testing.T) {
+		s.RootEntry().CreateDir("orphan").CreateFile("file.hcl", genhcl.Header)
+
+		root := s.Config()
+		report := generate.DoChanged(root, project.NewPath("/modules"), nil,
+			stack.NewManager(root, "HEAD"), "HEAD", "HEAD")
+		assertEqualReports(t, report, generate.Report{
+			Successes: []generate.Result{
+				{
+					Dir:     project.NewPath("/orphan"),
+					Deleted: []string{"file.hcl"},
+				},
+			},
+		})
+	})
+
+	t.Run("invalid base is a bootstrap error", func(t *testing.T) {
+		root := s.Config()
+		report := generate.DoChanged(root, project.NewPath("/modules"), nil,
+			stack.NewManager(root, "non-existent"), "non-existent", "HEAD")
+		assertReportHasError(t, report, errors.E(generate.ErrChangeDetection))
+	})
+}
+
+func TestGenerateIncrementalOnChangeOnly(t *testing.T) {
+	t.Parallel()
+
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stacks/a",
+		"s:stacks/b",
+		"f:stacks/a/main.tf:# a",
+		"f:stacks/b/main.tf:# b",
+		`f:globals.tm:globals {
+		  version = "v1"
+		}`,
+		`f:generate.tm:generate_file "always.txt" {
+		  content = global.version
+		}
+
+		generate_file "changed.txt" {
+		  on_change_only = true
+		  content        = global.version
+		}`,
+	})
+	s.Generate()
+
+	git := s.Git()
+	git.CommitAll("initial commit")
+	git.Push("main")
+	git.CheckoutNew("change-stack")
+
+	s.RootEntry().CreateFile("stacks/a/main.tf", "# changed")
+	git.CommitAll("change stack a")
+
+	// globals change without committing, so the stacks are not seen as
+	// changed but the generated code differs.
+	s.RootEntry().CreateFile("globals.tm", `globals {
+	  version = "v2"
+	}`)
+
+	root := s.ReloadConfig()
+	report := generate.DoIncremental(root, project.NewPath("/modules"), nil,
+		stack.NewManager(root, "origin/main"), "origin/main", "HEAD")
+	assertEqualReports(t, report, generate.Report{
+		Successes: []generate.Result{
+			{
+				Dir:     project.NewPath("/stacks/a"),
+				Changed: []string{"always.txt", "changed.txt"},
+			},
+			{
+				Dir:     project.NewPath("/stacks/b"),
+				Changed: []string{"always.txt"},
+			},
+		},
+	})
+
+	assertFileContent := func(path, want string) {
+		t.Helper()
+		got, err := os.ReadFile(filepath.Join(s.RootDir(), path))
+		assert.NoError(t, err)
+		assert.EqualStrings(t, want, string(got), "file %s", path)
+	}
+
+	assertFileContent("stacks/a/always.txt", "v2")
+	assertFileContent("stacks/a/changed.txt", "v2")
+	assertFileContent("stacks/b/always.txt", "v2")
+	assertFileContent("stacks/b/changed.txt", "v1")
+
+	// without change detection, on_change_only blocks are always generated.
+	report = generate.Do(s.Config(), project.NewPath("/modules"), nil)
+	assertEqualReports(t, report, generate.Report{
+		Successes: []generate.Result{
+			{
+				Dir:     project.NewPath("/stacks/b"),
+				Changed: []string{"changed.txt"},
+			},
+		},
+	})
+	assertFileContent("stacks/b/changed.txt", "v2")
+}
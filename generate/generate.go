@@ -23,6 +23,7 @@ import (
 	"sort"
 	"strings"
 
+	hhcl "github.com/hashicorp/hcl/v2"
 	"github.com/mineiros-io/terramate/config"
 	"github.com/mineiros-io/terramate/errors"
 	"github.com/mineiros-io/terramate/event"
@@ -69,12 +70,18 @@ type GenFile interface {
 	Body() string
 	// Label is the label of the origin generate block that generated this file.
 	Label() string
+	// OutDir is the directory, relative to the stack, where the file is
+	// generated. It is empty if the file is generated inside the stack.
+	OutDir() string
 	// Context is the context of the generate block.
 	Context() string
 	// Range is the range of the origin generate block that generated this file.
 	Range() info.Range
 	// Condition is true if the origin generate block had a true condition, false otherwise.
 	Condition() bool
+	// OnChangeOnly is true if the origin generate block must only be
+	// generated for changed stacks.
+	OnChangeOnly() bool
 	// Asserts is the origin generate block assert blocks.
 	Asserts() []config.Assert
 }
@@ -116,7 +123,7 @@ func Load(root *config.Root, vendorDir project.Path) ([]LoadResult, error) {
 			continue
 		}
 
-		generated, err := loadStackCodeCfgs(root, st.Stack, loadres.Globals, vendorDir, nil)
+		generated, err := loadStackCodeCfgs(root, st.Stack.WithFeatures(loadres.Features), loadres.Globals, vendorDir, nil)
 		if err != nil {
 			res.Err = errors.E(err, "while loading configs of stack %s", st.Dir())
 			results[i] = res
@@ -139,7 +146,7 @@ func Load(root *config.Root, vendorDir project.Path) ([]LoadResult, error) {
 				continue
 			}
 
-			file, err := genfile.Eval(block, evalctx)
+			file, err := genfile.Eval(block, dircfg.HostDir(), evalctx)
 			if err != nil {
 				res.Err = errors.L(res.Err, err).AsError()
 				results = append(results, res)
@@ -183,24 +190,105 @@ func Load(root *config.Root, vendorDir project.Path) ([]LoadResult, error) {
 // failed on code generation, any failure found is added to the report but does
 // not abort the overall code generation process, so partial results can be
 // obtained and the report needs to be inspected to check.
+//
+// If the project lock is enabled with [config.Root.SetLockEnabled], the
+// project is locked with [config.Root.Lock] during the code generation, so
+// concurrent invocations don't interfere with each other. Failing to acquire
+// the lock is reported as the report BootstrapErr.
 func Do(
 	root *config.Root,
 	vendorDir project.Path,
 	vendorRequests chan<- event.VendorRequest,
 ) Report {
-	stackReport := forEachStack(root, vendorDir,
-		vendorRequests, doStackGeneration)
+	return DoWithFilter(root, vendorDir, vendorRequests, nil)
+}
+
+// DoWithFilter works like [Do], but the filter selects the stacks considered
+// changed (see [StackFilter]), which only affects the generate blocks with
+// on_change_only = true. If the filter is nil, all blocks of all stacks are
+// generated, exactly as [Do].
+func DoWithFilter(
+	root *config.Root,
+	vendorDir project.Path,
+	vendorRequests chan<- event.VendorRequest,
+	filter StackFilter,
+) Report {
+	return doFiltered(root, vendorDir, vendorRequests, filter, false)
+}
+
+// doFiltered implements [DoWithFilter]. If onChangeOnly is true, all the
+// generate blocks are handled as if they had on_change_only = true, so the
+// stacks not accepted by the filter are evaluated and checked but their files
+// are left untouched.
+func doFiltered(
+	root *config.Root,
+	vendorDir project.Path,
+	vendorRequests chan<- event.VendorRequest,
+	filter StackFilter,
+	onChangeOnly bool,
+) Report {
+	if root.LockEnabled() {
+		if err := root.Lock(); err != nil {
+			return Report{BootstrapErr: err}
+		}
+		defer unlockRoot(root)
+	}
+
+	// files generated by stacks outside of their directories, which must
+	// not be deleted as orphans.
+	outdirFiles := map[string]struct{}{}
+
+	stackReport := forEachStack(root, vendorDir, vendorRequests,
+		func(
+			root *config.Root,
+			stack *config.Stack,
+			globals *eval.Object,
+			vendorDir project.Path,
+			vendorRequests chan<- event.VendorRequest,
+		) dirReport {
+			report := doStackGeneration(root, stack, globals, vendorDir, vendorRequests, filter, onChangeOnly)
+			for _, file := range report.outdirFiles {
+				outdirFiles[file] = struct{}{}
+			}
+			return report
+		})
 	rootReport := doRootGeneration(root)
 	report := mergeReports(stackReport, rootReport)
-	return cleanupOrphaned(root, report)
+	return cleanupOrphaned(root, report, outdirFiles)
+}
+
+// StackFilter tells if the stack is considered changed by the code
+// generation. The generate blocks with on_change_only = true are only
+// generated for the stacks accepted by the filter, the files previously
+// generated by these blocks for the other stacks are neither generated nor
+// removed. A nil filter accepts all stacks.
+type StackFilter func(stack *config.Stack) bool
+
+func (filter StackFilter) accepts(stack *config.Stack) bool {
+	return filter == nil || filter(stack)
 }
 
+func unlockRoot(root *config.Root) {
+	if err := root.Unlock(); err != nil {
+		log.Warn().
+			Str("action", "generate.unlockRoot()").
+			Err(err).
+			Msg("releasing project lock")
+	}
+}
+
+// doStackGeneration generates the code of the stack. The files of generate
+// blocks with on_change_only = true (or of all blocks, if onChangeOnly is
+// true) are not generated if the stack is not accepted by the filter, which
+// means the previously generated files are left untouched.
 func doStackGeneration(
 	root *config.Root,
 	stack *config.Stack,
 	globals *eval.Object,
 	vendorDir project.Path,
 	vendorRequests chan<- event.VendorRequest,
+	filter StackFilter,
+	onChangeOnly bool,
 ) dirReport {
 	stackpath := stack.HostDir(root)
 	logger := log.With().
@@ -218,6 +306,8 @@ func doStackGeneration(
 		return report
 	}
 
+	report.outdirFiles = stackOutdirFiles(root, stackpath, generated)
+
 	errsmap := checkFileConflict(generated)
 	if len(errsmap) > 0 {
 		errs := errors.L()
@@ -243,12 +333,18 @@ func doStackGeneration(
 	logger.Debug().Msg("saving generated files")
 
 	for _, file := range generated {
-		filename := file.Label()
+		filename := targetPath(file)
 		path := filepath.Join(stackpath, filename)
 		logger := logger.With().
 			Str("filename", filename).
 			Logger()
 
+		if (onChangeOnly || file.OnChangeOnly()) && !filter.accepts(stack) {
+			logger.Debug().Msg("stack is not changed, keeping on_change_only file as is")
+			delete(allFiles, filename)
+			continue
+		}
+
 		if !file.Condition() {
 			logger.Debug().Msg("condition is false, ignoring file")
 			continue
@@ -356,7 +452,7 @@ func doRootGeneration(root *config.Root) Report {
 
 			logger.Debug().Msg("block validated successfully")
 
-			file, err := genfile.Eval(block, evalctx)
+			file, err := genfile.Eval(block, cfg.HostDir(), evalctx)
 			if err != nil {
 				report.addFailure(targetDir, err)
 				return report
@@ -392,26 +488,37 @@ func handleAsserts(rootdir string, dir string, asserts []config.Assert) error {
 		Str("action", "generate.handleAsserts()").
 		Str("dir", dir).
 		Logger()
+
+	assertRange := func(assert config.Assert) hhcl.Range {
+		r := assert.Range
+		r.Filename = project.PrjAbsPath(rootdir, assert.Range.Filename).String()
+		return r
+	}
+
+	report := config.NewAssertReport(asserts)
+	for _, assert := range report.Infos {
+		log.Info().
+			Stringer("origin", assertRange(assert)).
+			Str("msg", assert.Message).
+			Str("dir", dir).
+			Msg("assertion failed")
+	}
+	for _, assert := range report.Warnings {
+		log.Warn().
+			Stringer("origin", assertRange(assert)).
+			Str("msg", assert.Message).
+			Str("dir", dir).
+			Msg("assertion failed")
+	}
+
 	errs := errors.L()
-	for _, assert := range asserts {
-		if !assert.Assertion {
-			assertRange := assert.Range
-			assertRange.Filename = project.PrjAbsPath(rootdir, assert.Range.Filename).String()
-			if assert.Warning {
-				log.Warn().
-					Stringer("origin", assertRange).
-					Str("msg", assert.Message).
-					Str("dir", dir).
-					Msg("assertion failed")
-			} else {
-				msg := fmt.Sprintf("%s: %s", assertRange, assert.Message)
+	for _, assert := range report.Errors {
+		msg := fmt.Sprintf("%s: %s", assertRange(assert), assert.Message)
 
-				logger.Debug().Msgf("assertion failure detected: %s", msg)
+		logger.Debug().Msgf("assertion failure detected: %s", msg)
 
-				err := errors.E(ErrAssertion, msg)
-				errs.Append(err)
-			}
-		}
+		err := errors.E(ErrAssertion, msg)
+		errs.Append(err)
 	}
 	return errs.AsError()
 }
@@ -510,17 +617,22 @@ func DetectOutdated(root *config.Root, vendorDir project.Path) ([]string, error)
 	}
 
 	outdatedFiles := []string{}
+	outdirFiles := map[string]struct{}{}
 	errs := errors.L()
 
 	logger.Debug().Msg("checking outdated code inside stacks")
 
 	for _, stack := range stacks {
-		outdated, err := stackOutdated(root, stack.Stack, vendorDir)
+		outdated, owned, err := stackOutdated(root, stack.Stack, vendorDir)
 		if err != nil {
 			errs.Append(err)
 			continue
 		}
 
+		for _, file := range owned {
+			outdirFiles[file] = struct{}{}
+		}
+
 		// We want results relative to root
 		stackRelPath := stack.Dir().String()[1:]
 		for _, file := range outdated {
@@ -550,19 +662,25 @@ func DetectOutdated(root *config.Root, vendorDir project.Path) ([]string, error)
 		return nil, err
 	}
 
-	outdatedFiles = append(outdatedFiles, orphanedFiles...)
+	for _, file := range orphanedFiles {
+		if _, ok := outdirFiles[file]; ok {
+			continue
+		}
+		outdatedFiles = append(outdatedFiles, file)
+	}
 	sort.Strings(outdatedFiles)
 	return outdatedFiles, nil
 }
 
 // stackOutdated will verify if a given stack has outdated code and return a list
-// of filenames that are outdated, ordered lexicographically.
+// of filenames that are outdated, ordered lexicographically, and the files
+// generated by the stack outside of its directory (see [stackOutdirFiles]).
 // If the stack has an invalid configuration it will return an error.
 func stackOutdated(
 	root *config.Root,
 	st *config.Stack,
 	vendorDir project.Path,
-) ([]string, error) {
+) ([]string, []string, error) {
 	logger := log.With().
 		Str("action", "generate.stackOutdated").
 		Stringer("stack", st).
@@ -570,24 +688,24 @@ func stackOutdated(
 
 	report := globals.ForStack(root, st)
 	if err := report.AsError(); err != nil {
-		return nil, errors.E(err, "checking for outdated code")
+		return nil, nil, errors.E(err, "checking for outdated code")
 	}
 
 	globals := report.Globals
-	generated, err := loadStackCodeCfgs(root, st, globals, vendorDir, nil)
+	generated, err := loadStackCodeCfgs(root, st.WithFeatures(report.Features), globals, vendorDir, nil)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	stackpath := st.HostDir(root)
 	err = validateStackGeneratedFiles(root, stackpath, generated)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	genfilesOnFs, err := ListGenFiles(root, stackpath)
 	if err != nil {
-		return nil, errors.E(err, "checking for outdated code")
+		return nil, nil, errors.E(err, "checking for outdated code")
 	}
 
 	logger.Debug().Msgf("generated files detected on fs: %v", genfilesOnFs)
@@ -597,12 +715,12 @@ func stackOutdated(
 	outdatedFiles := newStringSet(genfilesOnFs...)
 	err = updateOutdatedFiles(stackpath, generated, outdatedFiles)
 	if err != nil {
-		return nil, errors.E(err, "checking for outdated files")
+		return nil, nil, errors.E(err, "checking for outdated files")
 	}
 
 	outdated := outdatedFiles.slice()
 	sort.Strings(outdated)
-	return outdated, nil
+	return outdated, stackOutdirFiles(root, stackpath, generated), nil
 }
 
 func updateOutdatedFiles(
@@ -623,7 +741,7 @@ func updateOutdatedFiles(
 			Str("label", genfile.Label()).
 			Logger()
 
-		filename := genfile.Label()
+		filename := targetPath(genfile)
 		targetpath := filepath.Join(stackpath, filename)
 
 		currentCode, codeFound, err := readFile(targetpath)
@@ -818,7 +936,8 @@ func forEachStack(
 
 		logger.Trace().Msg("Calling stack callback.")
 
-		stackReport := fn(root, elem.Stack, globalsReport.Globals, vendorDir, vendorRequests)
+		st := elem.Stack.WithFeatures(globalsReport.Features)
+		stackReport := fn(root, st, globalsReport.Globals, vendorDir, vendorRequests)
 		report.addDirReport(elem.Dir(), stackReport)
 	}
 
@@ -839,11 +958,14 @@ func allStackGeneratedFiles(
 	// WHY: not all Terramate files have headers and can be detected
 	// so we use the list of files to be generated to check for these
 	// They may or not exist.
+	// Files generated outside the stack dir are also not listed by
+	// ListGenFiles, so they are handled the same way.
 	for _, genfile := range genfiles {
 		// Files that have header or that are inside the stack dir
 		// can be detected by ListGenFiles
-		if genfile.Header() == "" {
-			files = append(files, genfile.Label())
+		target := targetPath(genfile)
+		if genfile.Header() == "" || isOutsideDir(target) {
+			files = append(files, target)
 		}
 	}
 
@@ -1022,7 +1144,7 @@ func validateStackGeneratedFiles(root *config.Root, stackpath string, generated
 
 	for _, file := range generated {
 		relpath := file.Label()
-		if !strings.Contains(relpath, "/") {
+		if !strings.Contains(relpath, "/") && file.OutDir() == "" {
 			continue
 		}
 
@@ -1047,11 +1169,35 @@ func validateStackGeneratedFiles(root *config.Root, stackpath string, generated
 			continue
 		}
 
-		abspath := filepath.Join(stackpath, relpath)
+		abspath := filepath.Join(stackpath, filepath.FromSlash(targetPath(file)))
+		if !strings.HasPrefix(abspath, root.HostDir()+string(filepath.Separator)) {
+			errs.Append(errors.E(ErrInvalidGenBlockLabel,
+				file.Range(),
+				"%s: outdir %s computes to %s which is not inside rootdir %s",
+				file.Label(),
+				file.OutDir(),
+				abspath,
+				root.HostDir()))
+			continue
+		}
+
 		destdir := filepath.Dir(abspath)
 
-		// We need to check that destdir, or any of its parents, is not a symlink or a stack.
-		for strings.HasPrefix(destdir, stackpath) && destdir != stackpath {
+		// We need to check that destdir, or any of its parents, is not a symlink
+		// or a stack. When generating outside of the stack dir (using outdir)
+		// the parents are checked up to the project root.
+		for destdir != stackpath {
+			if destdir == root.HostDir() {
+				if config.IsStack(root, destdir) {
+					errs.Append(errors.E(ErrInvalidGenBlockLabel,
+						file.Range(),
+						"%s: generates code inside another stack %s",
+						file.Label(),
+						project.NewPath("/")))
+				}
+				break
+			}
+
 			info, err := os.Lstat(destdir)
 			if err != nil {
 				if errors.Is(err, fs.ErrNotExist) {
@@ -1087,6 +1233,38 @@ func validateStackGeneratedFiles(root *config.Root, stackpath string, generated
 	return errs.AsError()
 }
 
+// targetPath returns the path of the generated file relative to the stack
+// directory, using slash (/) as the dir separator. It is the file label
+// unless the generate block has an outdir.
+func targetPath(file GenFile) string {
+	if file.OutDir() == "" {
+		return file.Label()
+	}
+	return path.Join(file.OutDir(), file.Label())
+}
+
+// isOutsideDir tells if the given slash separated relative path
+// points outside of the directory it is relative to.
+func isOutsideDir(relpath string) bool {
+	return relpath == ".." || strings.HasPrefix(relpath, "../")
+}
+
+// stackOutdirFiles returns the files generated outside of the stack directory,
+// relative to the project root. These files are owned by the stack, so they
+// are not considered orphaned, even if their generate block condition is false.
+func stackOutdirFiles(root *config.Root, stackpath string, generated []GenFile) []string {
+	var files []string
+	for _, file := range generated {
+		target := targetPath(file)
+		if !isOutsideDir(target) {
+			continue
+		}
+		abspath := filepath.Join(stackpath, filepath.FromSlash(target))
+		files = append(files, project.PrjAbsPath(root.HostDir(), abspath).String()[1:])
+	}
+	return files
+}
+
 func validateRootGenerateBlock(root *config.Root, block hcl.GenFileBlock) error {
 	target := block.Label
 	if !path.IsAbs(target) {
@@ -1174,24 +1352,30 @@ func (ss *stringSet) slice() []string {
 	return res
 }
 
+// checkFileConflict checks if any of the generated files with condition=true
+// resolve to the same target path. The labels are compared after being
+// cleaned, so labels like "dir/file" and "dir//file" are also detected as
+// conflicting.
 func checkFileConflict(generated []GenFile) map[string]error {
 	genset := map[string]GenFile{}
 	errsmap := map[string]error{}
 	for _, file := range generated {
-		if other, ok := genset[file.Label()]; ok && file.Condition() {
-			errsmap[file.Label()] = errors.E(ErrConflictingConfig,
+		if !file.Condition() {
+			continue
+		}
+		target := path.Clean(targetPath(file))
+		if other, ok := genset[target]; ok {
+			errsmap[target] = errors.E(ErrConflictingConfig,
+				file.Range(),
 				"configs from %q and %q generate a file with same name %q have "+
 					"`condition = true`",
 				file.Range().Path(),
 				other.Range().Path(),
-				file.Label(),
+				target,
 			)
 			continue
 		}
-		if !file.Condition() {
-			continue
-		}
-		genset[file.Label()] = file
+		genset[target] = file
 	}
 	return errsmap
 }
@@ -1287,7 +1471,10 @@ func loadStackCodeCfgs(
 	return genfilesConfigs, nil
 }
 
-func cleanupOrphaned(root *config.Root, report Report) Report {
+// cleanupOrphaned deletes the generated files that are not owned by any stack.
+// The outdirFiles are the files generated by stacks outside of their
+// directories, relative to the project root, which are not orphaned.
+func cleanupOrphaned(root *config.Root, report Report, outdirFiles map[string]struct{}) Report {
 	logger := log.With().
 		Str("action", "generate.cleanupOrphaned()").
 		Logger()
@@ -1311,6 +1498,13 @@ func cleanupOrphaned(root *config.Root, report Report) Report {
 	deleteFailures := map[project.Path]*errors.List{}
 
 	for _, genfile := range orphanedGenFiles {
+		if _, ok := outdirFiles[genfile]; ok {
+			logger.Debug().
+				Str("file", genfile).
+				Msg("file is generated by a stack outdir, ignoring")
+			continue
+		}
+
 		genfileAbspath := filepath.Join(root.HostDir(), genfile)
 		dir := project.NewPath("/" + filepath.ToSlash(filepath.Dir(genfile)))
 		if err := os.Remove(genfileAbspath); err != nil {
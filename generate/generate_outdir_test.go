@@ -0,0 +1,217 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generate_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/generate"
+	"github.com/mineiros-io/terramate/project"
+	. "github.com/mineiros-io/terramate/test/hclwrite/hclutils"
+)
+
+func TestGenerateStackContextOutDir(t *testing.T) {
+	t.Parallel()
+
+	testCodeGeneration(t, []testcase{
+		{
+			name: "outdir inside the stack",
+			layout: []string{
+				"s:stacks/stack",
+			},
+			configs: []hclconfig{
+				{
+					path: "/stacks/stack",
+					add: Doc(
+						GenerateHCL(
+							Labels("file.hcl"),
+							Str("outdir", "child"),
+							Content(
+								Block("block",
+									Str("data", "data"),
+								),
+							),
+						),
+						GenerateFile(
+							Labels("file.txt"),
+							Str("outdir", "child/sub"),
+							Str("content", "test"),
+						),
+					),
+				},
+			},
+			want: []generatedFile{
+				{
+					dir: "/stacks/stack",
+					files: map[string]fmt.Stringer{
+						"child/file.hcl": Doc(
+							Block("block",
+								Str("data", "data"),
+							),
+						),
+						"child/sub/file.txt": stringer("test"),
+					},
+				},
+			},
+			wantReport: generate.Report{
+				Successes: []generate.Result{
+					{
+						Dir: project.NewPath("/stacks/stack"),
+						Created: []string{
+							"child/file.hcl",
+							"child/sub/file.txt",
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "outdir on sibling dir is owned by the stack",
+			layout: []string{
+				"s:stacks/stack",
+				"d:stacks/shared",
+			},
+			configs: []hclconfig{
+				{
+					path: "/stacks/stack",
+					add: Doc(
+						GenerateHCL(
+							Labels("file.hcl"),
+							Str("outdir", "../shared"),
+							Content(
+								Block("block",
+									Str("data", "data"),
+								),
+							),
+						),
+						GenerateFile(
+							Labels("file.txt"),
+							Str("outdir", "../shared/dir"),
+							Str("content", "test"),
+						),
+					),
+				},
+			},
+			want: []generatedFile{
+				{
+					dir: "/stacks/shared",
+					files: map[string]fmt.Stringer{
+						"file.hcl": Doc(
+							Block("block",
+								Str("data", "data"),
+							),
+						),
+						"dir/file.txt": stringer("test"),
+					},
+				},
+			},
+			wantReport: generate.Report{
+				Successes: []generate.Result{
+					{
+						Dir: project.NewPath("/stacks/stack"),
+						Created: []string{
+							"../shared/dir/file.txt",
+							"../shared/file.hcl",
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "outdir outside of the project fails",
+			layout: []string{
+				"s:stacks/stack",
+			},
+			configs: []hclconfig{
+				{
+					path: "/stacks/stack",
+					add: GenerateHCL(
+						Labels("file.hcl"),
+						Str("outdir", "../../../outside"),
+						Content(
+							Block("block"),
+						),
+					),
+				},
+			},
+			wantReport: generate.Report{
+				Failures: []generate.FailureResult{
+					{
+						Result: generate.Result{
+							Dir: project.NewPath("/stacks/stack"),
+						},
+						Error: errors.E(generate.ErrInvalidGenBlockLabel),
+					},
+				},
+			},
+		},
+		{
+			name: "outdir inside another stack fails",
+			layout: []string{
+				"s:stacks/stack",
+				"s:stacks/other",
+			},
+			configs: []hclconfig{
+				{
+					path: "/stacks/stack",
+					add: GenerateFile(
+						Labels("file.txt"),
+						Str("outdir", "../other/dir"),
+						Str("content", "test"),
+					),
+				},
+			},
+			wantReport: generate.Report{
+				Failures: []generate.FailureResult{
+					{
+						Result: generate.Result{
+							Dir: project.NewPath("/stacks/stack"),
+						},
+						Error: errors.E(generate.ErrInvalidGenBlockLabel),
+					},
+				},
+			},
+		},
+		{
+			name: "outdir inside parent stack fails",
+			layout: []string{
+				"s:stacks",
+				"s:stacks/stack",
+			},
+			configs: []hclconfig{
+				{
+					path: "/stacks/stack",
+					add: GenerateFile(
+						Labels("file.txt"),
+						Str("outdir", "../shared"),
+						Str("content", "test"),
+					),
+				},
+			},
+			wantReport: generate.Report{
+				Failures: []generate.FailureResult{
+					{
+						Result: generate.Result{
+							Dir: project.NewPath("/stacks/stack"),
+						},
+						Error: errors.E(generate.ErrInvalidGenBlockLabel),
+					},
+				},
+			},
+		},
+	})
+}
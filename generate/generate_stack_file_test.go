@@ -91,6 +91,48 @@ func TestGenerateFile(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "generate_file with condition on stack features",
+			layout: []string{
+				`f:stacks/stack-1/stack.tm.hcl:stack {
+				  features = {
+				    monitoring = global.monitoring
+				  }
+				}`,
+				"s:stacks/stack-2",
+			},
+			configs: []hclconfig{
+				{
+					path: "/stacks",
+					add: Doc(
+						Globals(
+							Expr("monitoring", `terramate.stack.path.basename == "stack-1"`),
+						),
+						GenerateFile(
+							Labels("monitoring.txt"),
+							Expr("condition", `tm_try(terramate.stack.features.monitoring, false)`),
+							Str("content", "monitored"),
+						),
+					),
+				},
+			},
+			want: []generatedFile{
+				{
+					dir: "/stacks/stack-1",
+					files: map[string]fmt.Stringer{
+						"monitoring.txt": stringer("monitored"),
+					},
+				},
+			},
+			wantReport: generate.Report{
+				Successes: []generate.Result{
+					{
+						Dir:     project.NewPath("/stacks/stack-1"),
+						Created: []string{"monitoring.txt"},
+					},
+				},
+			},
+		},
 		{
 			name: "terramate.stacks.list",
 			layout: []string{
@@ -17,6 +17,7 @@ package generate_test
 import (
 	"fmt"
 	"io/fs"
+	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
@@ -593,10 +594,134 @@ func TestGenerateCleanupFailsToReadFiles(t *testing.T) {
 	assert.Error(t, report.CleanupErr)
 }
 
+func TestGenerateFailsWhenProjectIsLocked(t *testing.T) {
+	t.Parallel()
+
+	s := sandbox.New(t)
+	s.BuildTree([]string{"s:stack"})
+	s.RootEntry().CreateFile("gen.tm", GenerateFile(
+		Labels("file.txt"),
+		Str("content", "test"),
+	).String())
+
+	other, err := config.LoadRoot(s.RootDir())
+	assert.NoError(t, err)
+	assert.NoError(t, other.Lock())
+
+	root := s.Config()
+	root.SetLockEnabled(true)
+
+	report := generate.Do(root, project.NewPath("/modules"), nil)
+	assert.IsError(t, report.BootstrapErr, errors.E(config.ErrLocked))
+	assert.EqualInts(t, 0, len(report.Successes), "want no successes")
+
+	assert.NoError(t, other.Unlock())
+
+	report = s.Generate()
+	assert.NoError(t, report.BootstrapErr)
+	assert.EqualInts(t, 1, len(report.Successes), "want generated stack")
+
+	// the lock is released when the generation completes.
+	assert.NoError(t, other.Lock())
+	assert.NoError(t, other.Unlock())
+}
+
+func TestGenerateDoesNotLockProjectByDefault(t *testing.T) {
+	t.Parallel()
+
+	s := sandbox.New(t)
+	s.BuildTree([]string{"s:stack"})
+	s.RootEntry().CreateFile("gen.tm", GenerateFile(
+		Labels("file.txt"),
+		Str("content", "test"),
+	).String())
+
+	other, err := config.LoadRoot(s.RootDir())
+	assert.NoError(t, err)
+	assert.NoError(t, other.Lock())
+
+	report := s.Generate()
+	assert.NoError(t, report.BootstrapErr)
+	assert.EqualInts(t, 1, len(report.Successes), "want generated stack")
+
+	assert.NoError(t, other.Unlock())
+
+	_, err = os.Stat(filepath.Join(s.RootDir(), ".terramate"))
+	assert.IsTrue(t, os.IsNotExist(err), "lock directory must not be created")
+}
+
 func TestGenerateConflictsBetweenGenerateTypes(t *testing.T) {
 	t.Parallel()
 
 	testCodeGeneration(t, []testcase{
+		{
+			name: "stack with generate blocks with labels resolving to same file",
+			layout: []string{
+				"s:stacks/stack",
+			},
+			configs: []hclconfig{
+				{
+					path: "/stacks",
+					add: GenerateFile(
+						Labels("dir/./file.txt"),
+						Str("content", "parent"),
+					),
+				},
+				{
+					path: "/stacks/stack",
+					add: GenerateFile(
+						Labels("dir//file.txt"),
+						Str("content", "stack"),
+					),
+				},
+			},
+			wantReport: generate.Report{
+				Failures: []generate.FailureResult{
+					{
+						Result: generate.Result{
+							Dir: project.NewPath("/stacks/stack"),
+						},
+						Error: errors.E(generate.ErrConflictingConfig),
+					},
+				},
+			},
+		},
+		{
+			name: "stack with generate_hcl and generate_file labels resolving to same file",
+			layout: []string{
+				"s:stacks/stack",
+			},
+			configs: []hclconfig{
+				{
+					path: "/stacks",
+					add: GenerateHCL(
+						Labels("dir/main.tf"),
+						Content(
+							Block("block",
+								Str("data", "parent data"),
+							),
+						),
+					),
+				},
+				{
+					path: "/stacks/stack",
+					add: GenerateFile(
+						Labels("dir/./main.tf"),
+						Str("content", "test"),
+					),
+				},
+			},
+			wantReport: generate.Report{
+				Failures: []generate.FailureResult{
+					{
+						Result: generate.Result{
+							Dir: project.NewPath("/stacks/stack"),
+						},
+						Error: errors.E(generate.ErrConflictingConfig),
+					},
+				},
+			},
+		},
 		{
 			name: "stack with different generate blocks but same label",
 			layout: []string{
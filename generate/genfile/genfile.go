@@ -16,10 +16,17 @@
 package genfile
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"os"
 	"path"
+	"path/filepath"
 	"sort"
+	"strings"
 
+	hhcl "github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/mineiros-io/terramate/config"
 	"github.com/mineiros-io/terramate/errors"
 	"github.com/mineiros-io/terramate/event"
@@ -31,7 +38,9 @@ import (
 	"github.com/mineiros-io/terramate/lets"
 	"github.com/mineiros-io/terramate/project"
 	"github.com/mineiros-io/terramate/stack"
+	ctyyaml "github.com/zclconf/go-cty-yaml"
 	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
 )
 
 const (
@@ -46,9 +55,26 @@ const (
 	// ErrContentEval indicates an error when evaluating the content attribute.
 	ErrContentEval errors.Kind = "evaluating content"
 
+	// ErrInvalidSourceType indicates the source attribute has an invalid type.
+	ErrInvalidSourceType errors.Kind = "invalid source type"
+
+	// ErrInvalidTemplateType indicates the template attribute has an invalid type.
+	ErrInvalidTemplateType errors.Kind = "invalid template type"
+
+	// ErrSourceOutsideDir indicates the source attribute resolves to a file
+	// outside of the directory it is relative to.
+	ErrSourceOutsideDir errors.Kind = "source outside of directory"
+
+	// ErrSourceRead indicates an error when reading the source file.
+	ErrSourceRead errors.Kind = "reading source file"
+
 	// ErrConditionEval indicates an error when evaluating the condition attribute.
 	ErrConditionEval errors.Kind = "evaluating condition"
 
+	// ErrInvalidFormat indicates the format attribute is invalid or the
+	// content can't be encoded in the format.
+	ErrInvalidFormat errors.Kind = "invalid format"
+
 	// ErrLabelConflict indicates the two generate_file blocks
 	// have the same label.
 	ErrLabelConflict errors.Kind = "label conflict detected"
@@ -65,11 +91,14 @@ const (
 // File represents generated file from a single generate_file block.
 type File struct {
 	label     string
+	outdir    string
 	context   string
 	origin    info.Range
 	body      string
 	condition bool
 	asserts   []config.Assert
+
+	onChangeOnly bool
 }
 
 // Label of the original generate_file block.
@@ -77,6 +106,12 @@ func (f File) Label() string {
 	return f.label
 }
 
+// OutDir is the directory, relative to the stack, where the file is generated.
+// It is empty if the file is generated inside the stack directory.
+func (f File) OutDir() string {
+	return f.outdir
+}
+
 // Body returns the file body.
 func (f File) Body() string {
 	return f.body
@@ -93,6 +128,12 @@ func (f File) Condition() bool {
 	return f.condition
 }
 
+// OnChangeOnly tells if the file must only be generated for changed stacks
+// (see the on_change_only attribute of the generate_file block).
+func (f File) OnChangeOnly() bool {
+	return f.onChangeOnly
+}
+
 // Context of the generate_file block.
 func (f File) Context() string {
 	return f.context
@@ -155,7 +196,7 @@ func Load(
 
 		evalctx.SetFunction(stdlib.Name("vendor"), stdlib.VendorFunc(vendorTargetDir, vendorDir, vendorRequests))
 
-		file, err := Eval(genFileBlock, evalctx.Context)
+		file, err := Eval(genFileBlock, st.HostDir(root), evalctx.Context)
 		if err != nil {
 			return nil, err
 		}
@@ -170,9 +211,12 @@ func Load(
 }
 
 // Eval the generate_file block.
-func Eval(block hcl.GenFileBlock, evalctx *eval.Context) (File, error) {
+// The basedir is the host directory used to resolve the relative path of the
+// source attribute, which is the stack directory for context=stack blocks and
+// the directory where the block is defined for context=root blocks.
+func Eval(block hcl.GenFileBlock, basedir string, evalctx *eval.Context) (File, error) {
 	name := block.Label
-	err := lets.Load(block.Lets, evalctx)
+	_, err := lets.Load(block.Lets, evalctx)
 	if err != nil {
 		return File{}, err
 	}
@@ -195,10 +239,12 @@ func Eval(block hcl.GenFileBlock, evalctx *eval.Context) (File, error) {
 
 	if !condition {
 		return File{
-			label:     name,
-			origin:    block.Range,
-			condition: condition,
-			context:   block.Context,
+			label:        name,
+			outdir:       block.OutDir,
+			onChangeOnly: block.OnChangeOnly,
+			origin:       block.Range,
+			condition:    condition,
+			context:      block.Context,
 		}, nil
 	}
 
@@ -213,7 +259,7 @@ func Eval(block hcl.GenFileBlock, evalctx *eval.Context) (File, error) {
 			continue
 		}
 		asserts[i] = assert
-		if !assert.Assertion && !assert.Warning {
+		if assert.Failed() {
 			assertFailed = true
 		}
 	}
@@ -224,17 +270,32 @@ func Eval(block hcl.GenFileBlock, evalctx *eval.Context) (File, error) {
 
 	if assertFailed {
 		return File{
-			label:     name,
-			origin:    block.Range,
-			condition: condition,
-			context:   block.Context,
-			asserts:   asserts,
+			label:        name,
+			outdir:       block.OutDir,
+			onChangeOnly: block.OnChangeOnly,
+			origin:       block.Range,
+			condition:    condition,
+			context:      block.Context,
+			asserts:      asserts,
 		}, nil
 	}
 
-	value, err := evalctx.Eval(block.Content.Expr)
-	if err != nil {
-		return File{}, errors.E(ErrContentEval, err)
+	var value cty.Value
+	if block.Source != nil {
+		value, err = evalSource(block, basedir, evalctx)
+		if err != nil {
+			return File{}, err
+		}
+	} else {
+		value, err = evalctx.Eval(block.Content.Expr)
+		if err != nil {
+			return File{}, errors.E(ErrContentEval, err)
+		}
+
+		value, err = encodeContent(block, value, evalctx)
+		if err != nil {
+			return File{}, err
+		}
 	}
 
 	if value.Type() != cty.String {
@@ -246,15 +307,201 @@ func Eval(block hcl.GenFileBlock, evalctx *eval.Context) (File, error) {
 	}
 
 	return File{
-		label:     name,
-		origin:    block.Range,
-		body:      value.AsString(),
-		condition: condition,
-		context:   block.Context,
-		asserts:   asserts,
+		label:        name,
+		outdir:       block.OutDir,
+		onChangeOnly: block.OnChangeOnly,
+		origin:       block.Range,
+		body:         value.AsString(),
+		condition:    condition,
+		context:      block.Context,
+		asserts:      asserts,
 	}, nil
 }
 
+// evalSource reads the file referenced by the source attribute of the block
+// and evaluates it as a template if the template attribute is true.
+func evalSource(block hcl.GenFileBlock, basedir string, evalctx *eval.Context) (cty.Value, error) {
+	srcval, err := evalctx.Eval(block.Source.Expr)
+	if err != nil {
+		return cty.NilVal, errors.E(ErrContentEval, err)
+	}
+	if srcval.Type() != cty.String {
+		return cty.NilVal, errors.E(
+			ErrInvalidSourceType,
+			block.Source.Expr.Range(),
+			"source has type %s but must be string",
+			srcval.Type().FriendlyName(),
+		)
+	}
+
+	src := srcval.AsString()
+	if filepath.IsAbs(src) {
+		return cty.NilVal, errors.E(
+			ErrInvalidSourceType,
+			block.Source.Expr.Range(),
+			"source must be a relative path but given %q", src,
+		)
+	}
+
+	istemplate := false
+	if block.Template != nil {
+		tmplval, err := evalctx.Eval(block.Template.Expr)
+		if err != nil {
+			return cty.NilVal, errors.E(ErrContentEval, err)
+		}
+		if tmplval.Type() != cty.Bool {
+			return cty.NilVal, errors.E(
+				ErrInvalidTemplateType,
+				block.Template.Expr.Range(),
+				"template has type %s but must be boolean",
+				tmplval.Type().FriendlyName(),
+			)
+		}
+		istemplate = tmplval.True()
+	}
+
+	srcpath, err := sourcePath(basedir, src)
+	if err != nil {
+		return cty.NilVal, errors.E(ErrSourceOutsideDir, block.Source.Expr.Range(), err)
+	}
+	data, err := os.ReadFile(srcpath)
+	if err != nil {
+		return cty.NilVal, errors.E(ErrSourceRead, block.Source.Expr.Range(), err)
+	}
+
+	if !istemplate {
+		return cty.StringVal(string(data)), nil
+	}
+
+	tmpl, diags := hclsyntax.ParseTemplate(data, srcpath, hhcl.InitialPos)
+	if diags.HasErrors() {
+		return cty.NilVal, errors.E(ErrContentEval, diags)
+	}
+
+	value, err := evalctx.Eval(tmpl)
+	if err != nil {
+		return cty.NilVal, errors.E(ErrContentEval, err)
+	}
+	return value, nil
+}
+
+// sourcePath returns the host path of the relative source path, failing if it
+// resolves to a file outside of basedir, directly (eg.: ../../etc/passwd) or
+// through symbolic links.
+func sourcePath(basedir, src string) (string, error) {
+	relpath := filepath.Clean(filepath.FromSlash(src))
+	if relpath == ".." || strings.HasPrefix(relpath, ".."+string(filepath.Separator)) {
+		return "", errors.E("source %q is outside of directory %q", src, basedir)
+	}
+
+	srcpath := filepath.Join(basedir, relpath)
+	realsrc, err := filepath.EvalSymlinks(srcpath)
+	if err != nil {
+		// a missing file is reported when reading it.
+		return srcpath, nil
+	}
+	realbase, err := filepath.EvalSymlinks(basedir)
+	if err != nil {
+		return "", errors.E(err, "resolving directory %q", basedir)
+	}
+	if realsrc != realbase && !strings.HasPrefix(realsrc, realbase+string(filepath.Separator)) {
+		return "", errors.E("source %q resolves to %q, outside of directory %q",
+			src, realsrc, basedir)
+	}
+	return srcpath, nil
+}
+
+// Formats supported to encode non-string content.
+const (
+	formatJSON = "json"
+	formatYAML = "yaml"
+)
+
+// encodeContent encodes the evaluated content value in the format given by
+// the format attribute of the block or, if absent, inferred from the
+// extension of the block label (.json, .yaml or .yml). Only structured values
+// (objects, maps, lists, tuples and sets) are encoded, any other value is
+// returned unchanged, including structured values without a format.
+func encodeContent(block hcl.GenFileBlock, value cty.Value, evalctx *eval.Context) (cty.Value, error) {
+	format, err := contentFormat(block, evalctx)
+	if err != nil {
+		return cty.NilVal, err
+	}
+
+	typ := value.Type()
+	structured := typ.IsObjectType() || typ.IsMapType() || typ.IsTupleType() ||
+		typ.IsListType() || typ.IsSetType()
+	if format == "" || !structured {
+		return value, nil
+	}
+
+	var data []byte
+	switch format {
+	case formatJSON:
+		data, err = encodeJSON(value)
+	case formatYAML:
+		data, err = ctyyaml.Standard.Marshal(value)
+	}
+	if err != nil {
+		return cty.NilVal, errors.E(ErrInvalidFormat, block.Content.Expr.Range(), err)
+	}
+	return cty.StringVal(string(data)), nil
+}
+
+// encodeJSON encodes the value as indented JSON. The object keys are sorted,
+// so the result is deterministic.
+func encodeJSON(value cty.Value) ([]byte, error) {
+	data, err := ctyjson.Marshal(value, value.Type())
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, data, "", "  "); err != nil {
+		return nil, err
+	}
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+// contentFormat returns the format used to encode non-string content, which
+// is empty if the content must not be encoded.
+func contentFormat(block hcl.GenFileBlock, evalctx *eval.Context) (string, error) {
+	if block.Format == nil {
+		switch strings.ToLower(path.Ext(block.Label)) {
+		case ".json":
+			return formatJSON, nil
+		case ".yaml", ".yml":
+			return formatYAML, nil
+		}
+		return "", nil
+	}
+
+	val, err := evalctx.Eval(block.Format.Expr)
+	if err != nil {
+		return "", errors.E(ErrContentEval, err)
+	}
+	if val.Type() != cty.String {
+		return "", errors.E(
+			ErrInvalidFormat,
+			block.Format.Expr.Range(),
+			"format has type %s but must be string",
+			val.Type().FriendlyName(),
+		)
+	}
+
+	switch format := val.AsString(); format {
+	case formatJSON, formatYAML:
+		return format, nil
+	default:
+		return "", errors.E(
+			ErrInvalidFormat,
+			block.Format.Expr.Range(),
+			"format must be %q or %q but given %q",
+			formatJSON, formatYAML, format,
+		)
+	}
+}
+
 // loadGenFileBlocks will load all generate_file blocks.
 // The returned map maps the name of the block (its label)
 // to the original block and the path (relative to project root) of the config
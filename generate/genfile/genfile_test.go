@@ -933,6 +933,406 @@ stack_id=stack-id
 			},
 			wantErr: errors.E(genfile.ErrContentEval),
 		},
+		{
+			name:  "source reads content from file relative to stack",
+			stack: "/stack",
+			configs: []hclconfig{
+				{
+					path: "/stack/files/source.txt",
+					add:  rawContent("name=${terramate.stack.name}\n"),
+				},
+				{
+					path: "/test.tm",
+					add: GenerateFile(
+						Labels("test"),
+						Str("source", "files/source.txt"),
+					),
+				},
+			},
+			want: []result{
+				{
+					name: "test",
+					file: genFile{
+						body:      "\nname=${terramate.stack.name}\n",
+						condition: true,
+					},
+				},
+			},
+		},
+		{
+			name:  "source evaluated as template",
+			stack: "/stack",
+			configs: []hclconfig{
+				{
+					path: "/stack/source.tmpl",
+					add:  rawContent("name=${terramate.stack.name} ${global.data}\n"),
+				},
+				{
+					path: "/test.tm",
+					add: Doc(
+						Globals(
+							Str("data", "global data"),
+						),
+						GenerateFile(
+							Labels("test"),
+							Str("source", "source.tmpl"),
+							Bool("template", true),
+						),
+					),
+				},
+			},
+			want: []result{
+				{
+					name: "test",
+					file: genFile{
+						body:      "\nname=stack global data\n",
+						condition: true,
+					},
+				},
+			},
+		},
+		{
+			name:  "source file not found",
+			stack: "/stack",
+			configs: []hclconfig{
+				{
+					path: "/test.tm",
+					add: GenerateFile(
+						Labels("test"),
+						Str("source", "not-found.txt"),
+					),
+				},
+			},
+			wantErr: errors.E(genfile.ErrSourceRead),
+		},
+		{
+			name:  "source with absolute path fails",
+			stack: "/stack",
+			configs: []hclconfig{
+				{
+					path: "/test.tm",
+					add: GenerateFile(
+						Labels("test"),
+						Str("source", "/source.txt"),
+					),
+				},
+			},
+			wantErr: errors.E(genfile.ErrInvalidSourceType),
+		},
+		{
+			name:  "source outside of the stack fails",
+			stack: "/stack",
+			configs: []hclconfig{
+				{
+					path: "/secret.txt",
+					add:  rawContent("secret"),
+				},
+				{
+					path: "/test.tm",
+					add: GenerateFile(
+						Labels("test"),
+						Str("source", "../secret.txt"),
+					),
+				},
+			},
+			wantErr: errors.E(genfile.ErrSourceOutsideDir),
+		},
+		{
+			name:  "source escaping the project fails",
+			stack: "/stack",
+			configs: []hclconfig{
+				{
+					path: "/test.tm",
+					add: GenerateFile(
+						Labels("test"),
+						Str("source", "../../etc/passwd"),
+					),
+				},
+			},
+			wantErr: errors.E(genfile.ErrSourceOutsideDir),
+		},
+		{
+			name:  "source with inner parent dir inside the stack",
+			stack: "/stack",
+			configs: []hclconfig{
+				{
+					path: "/stack/files/source.txt",
+					add:  rawContent("data"),
+				},
+				{
+					path: "/test.tm",
+					add: GenerateFile(
+						Labels("test"),
+						Str("source", "other/../files/source.txt"),
+					),
+				},
+			},
+			want: []result{
+				{
+					name: "test",
+					file: genFile{
+						body:      "\ndata",
+						condition: true,
+					},
+				},
+			},
+		},
+		{
+			name:  "template with invalid type fails",
+			stack: "/stack",
+			configs: []hclconfig{
+				{
+					path: "/stack/source.txt",
+					add:  rawContent("data"),
+				},
+				{
+					path: "/test.tm",
+					add: GenerateFile(
+						Labels("test"),
+						Str("source", "source.txt"),
+						Str("template", "true"),
+					),
+				},
+			},
+			wantErr: errors.E(genfile.ErrInvalidTemplateType),
+		},
+		{
+			name:  "content and source both set fails",
+			stack: "/stack",
+			configs: []hclconfig{
+				{
+					path: "/test.tm",
+					add: GenerateFile(
+						Labels("test"),
+						Str("content", "data"),
+						Str("source", "source.txt"),
+					),
+				},
+			},
+			wantErr: errors.E(hcl.ErrTerramateSchema),
+		},
+		{
+			name:  "template without source fails",
+			stack: "/stack",
+			configs: []hclconfig{
+				{
+					path: "/test.tm",
+					add: GenerateFile(
+						Labels("test"),
+						Str("content", "data"),
+						Bool("template", true),
+					),
+				},
+			},
+			wantErr: errors.E(hcl.ErrTerramateSchema),
+		},
+		{
+			name:  "structured content encoded as json from label extension",
+			stack: "/stack",
+			configs: []hclconfig{
+				{
+					path: "/stack/test.tm",
+					add: Doc(
+						Globals(
+							Number("number", 1),
+							Str("str", "text"),
+						),
+						GenerateFile(
+							Labels("data.json"),
+							Expr("content", `{
+							b = [global.number, 2]
+							a = global.str
+							c = {
+								enabled = true
+							}
+						}`),
+						),
+					),
+				},
+			},
+			want: []result{
+				{
+					name: "data.json",
+					file: genFile{
+						body: `{
+  "a": "text",
+  "b": [
+    1,
+    2
+  ],
+  "c": {
+    "enabled": true
+  }
+}
+`,
+						condition: true,
+					},
+				},
+			},
+		},
+		{
+			name:  "structured content encoded as yaml from label extension",
+			stack: "/stack",
+			configs: []hclconfig{
+				{
+					path: "/stack/test.tm",
+					add: Doc(
+						Globals(
+							Number("number", 1),
+							Str("str", "text"),
+						),
+						GenerateFile(
+							Labels("data.yml"),
+							Expr("content", `{
+							b = [global.number, 2]
+							a = global.str
+							c = {
+								enabled = true
+							}
+						}`),
+						),
+					),
+				},
+			},
+			want: []result{
+				{
+					name: "data.yml",
+					file: genFile{
+						body: `"a": "text"
+"b":
+- 1
+- 2
+"c":
+  "enabled": true
+`,
+						condition: true,
+					},
+				},
+			},
+		},
+		{
+			name:  "structured content encoded with explicit format",
+			stack: "/stack",
+			configs: []hclconfig{
+				{
+					path: "/stack/test.tm",
+					add: Doc(
+						Globals(
+							Number("number", 1),
+							Str("str", "text"),
+						),
+						GenerateFile(
+							Labels("data.conf"),
+							Str("format", "yaml"),
+							Expr("content", `{
+							b = [global.number, 2]
+							a = global.str
+							c = {
+								enabled = true
+							}
+						}`),
+						),
+					),
+				},
+			},
+			want: []result{
+				{
+					name: "data.conf",
+					file: genFile{
+						body: `"a": "text"
+"b":
+- 1
+- 2
+"c":
+  "enabled": true
+`,
+						condition: true,
+					},
+				},
+			},
+		},
+		{
+			name:  "string content is not encoded",
+			stack: "/stack",
+			configs: []hclconfig{
+				{
+					path: "/stack/test.tm",
+					add: GenerateFile(
+						Labels("data.json"),
+						Str("format", "json"),
+						Expr("content", `tm_jsonencode({a = 1})`),
+					),
+				},
+			},
+			want: []result{
+				{
+					name: "data.json",
+					file: genFile{
+						body:      `{"a":1}`,
+						condition: true,
+					},
+				},
+			},
+		},
+		{
+			name:  "structured content without format fails",
+			stack: "/stack",
+			configs: []hclconfig{
+				{
+					path: "/stack/test.tm",
+					add: GenerateFile(
+						Labels("data.txt"),
+						Expr("content", `{a = 1}`),
+					),
+				},
+			},
+			wantErr: errors.E(genfile.ErrInvalidContentType),
+		},
+		{
+			name:  "unsupported format fails",
+			stack: "/stack",
+			configs: []hclconfig{
+				{
+					path: "/stack/test.tm",
+					add: GenerateFile(
+						Labels("data.toml"),
+						Str("format", "toml"),
+						Expr("content", `{a = 1}`),
+					),
+				},
+			},
+			wantErr: errors.E(genfile.ErrInvalidFormat),
+		},
+		{
+			name:  "format with invalid type fails",
+			stack: "/stack",
+			configs: []hclconfig{
+				{
+					path: "/stack/test.tm",
+					add: GenerateFile(
+						Labels("data.json"),
+						Bool("format", true),
+						Expr("content", `{a = 1}`),
+					),
+				},
+			},
+			wantErr: errors.E(genfile.ErrInvalidFormat),
+		},
+		{
+			name:  "format without content fails",
+			stack: "/stack",
+			configs: []hclconfig{
+				{
+					path: "/test.tm",
+					add: GenerateFile(
+						Labels("data.json"),
+						Str("source", "source.txt"),
+						Str("format", "json"),
+					),
+				},
+			},
+			wantErr: errors.E(hcl.ErrTerramateSchema),
+		},
 	}
 
 	for _, tcase := range tcases {
@@ -962,8 +1362,13 @@ type (
 		want    []result
 		wantErr error
 	}
+
+	// rawContent is the content of a non-HCL file.
+	rawContent string
 )
 
+func (c rawContent) String() string { return string(c) }
+
 func testGenfile(t *testing.T, tcase testcase) {
 	t.Run(tcase.name, func(t *testing.T) {
 		t.Parallel()
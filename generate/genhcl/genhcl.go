@@ -45,10 +45,13 @@ import (
 // about the origin of the generated code.
 type HCL struct {
 	label     string
+	outdir    string
 	origin    info.Range
 	body      string
 	condition bool
 	asserts   []config.Assert
+
+	onChangeOnly bool
 }
 
 const (
@@ -95,6 +98,12 @@ func (h HCL) Label() string {
 	return h.label
 }
 
+// OutDir is the directory, relative to the stack, where the file is generated.
+// It is empty if the file is generated inside the stack directory.
+func (h HCL) OutDir() string {
+	return h.outdir
+}
+
 // Asserts returns all (if any) of the evaluated assert configs of the
 // generate_hcl block. If [HCL.Condition] returns false then assert configs
 // will always be empty since they are not evaluated at all in that case.
@@ -123,6 +132,12 @@ func (h HCL) Condition() bool {
 	return h.condition
 }
 
+// OnChangeOnly tells if the file must only be generated for changed stacks
+// (see the on_change_only attribute of the generate_hcl block).
+func (h HCL) OnChangeOnly() bool {
+	return h.onChangeOnly
+}
+
 // Context of the generate_hcl block.
 func (h HCL) Context() string {
 	return "stack"
@@ -179,7 +194,7 @@ func Load(
 			stdlib.VendorFunc(vendorTargetDir, vendorDir, vendorRequests),
 		)
 
-		err := lets.Load(hclBlock.Lets, evalctx.Context)
+		_, err := lets.Load(hclBlock.Lets, evalctx.Context)
 		if err != nil {
 			return nil, err
 		}
@@ -202,9 +217,11 @@ func Load(
 
 		if !condition {
 			hcls = append(hcls, HCL{
-				label:     name,
-				origin:    hclBlock.Range,
-				condition: condition,
+				label:        name,
+				outdir:       hclBlock.OutDir,
+				onChangeOnly: hclBlock.OnChangeOnly,
+				origin:       hclBlock.Range,
+				condition:    condition,
 			})
 
 			continue
@@ -221,7 +238,7 @@ func Load(
 				continue
 			}
 			asserts[i] = assert
-			if !assert.Assertion && !assert.Warning {
+			if assert.Failed() {
 				assertFailed = true
 			}
 		}
@@ -232,10 +249,12 @@ func Load(
 
 		if assertFailed {
 			hcls = append(hcls, HCL{
-				label:     name,
-				origin:    hclBlock.Range,
-				condition: condition,
-				asserts:   asserts,
+				label:        name,
+				outdir:       hclBlock.OutDir,
+				onChangeOnly: hclBlock.OnChangeOnly,
+				origin:       hclBlock.Range,
+				condition:    condition,
+				asserts:      asserts,
 			})
 			continue
 		}
@@ -254,11 +273,13 @@ func Load(
 			))
 		}
 		hcls = append(hcls, HCL{
-			label:     name,
-			origin:    hclBlock.Range,
-			body:      formatted,
-			condition: condition,
-			asserts:   asserts,
+			label:        name,
+			outdir:       hclBlock.OutDir,
+			onChangeOnly: hclBlock.OnChangeOnly,
+			origin:       hclBlock.Range,
+			body:         formatted,
+			condition:    condition,
+			asserts:      asserts,
 		})
 	}
 
@@ -272,6 +272,79 @@ func TestGenHCLLetsMap(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:  "lets map block with unset values",
+			stack: "/stack",
+			configs: []hclconfig{
+				{
+					path: "/stack",
+					add: GenerateHCL(
+						Labels("test.tf"),
+						Lets(
+							Map(
+								Labels("var"),
+								Expr("for_each", `["a", "b", "c"]`),
+								Expr("key", "element.new"),
+								Expr("value", `element.new == "b" ? unset : element.new`),
+							),
+						),
+						Content(
+							Str("keys", `${tm_join(",", tm_keys(let.var))}`),
+						),
+					),
+				},
+			},
+			want: []result{
+				{
+					name: "test.tf",
+					hcl: genHCL{
+						condition: true,
+						body: Doc(
+							Str("keys", "a,c"),
+						),
+					},
+				},
+			},
+		},
+		{
+			name:  "lets map value block with unset attributes",
+			stack: "/stack",
+			configs: []hclconfig{
+				{
+					path: "/stack",
+					add: GenerateHCL(
+						Labels("test.tf"),
+						Lets(
+							Map(
+								Labels("var"),
+								Expr("for_each", `["a", "b"]`),
+								Expr("key", "element.new"),
+								Value(
+									Expr("name", "element.new"),
+									Expr("extra", `element.new == "a" ? unset : "extra"`),
+								),
+							),
+						),
+						Content(
+							Str("a", `${tm_join(",", tm_keys(let.var.a))}`),
+							Str("b", `${tm_join(",", tm_keys(let.var.b))}`),
+						),
+					),
+				},
+			},
+			want: []result{
+				{
+					name: "test.tf",
+					hcl: genHCL{
+						condition: true,
+						body: Doc(
+							Str("a", "name"),
+							Str("b", "extra,name"),
+						),
+					},
+				},
+			},
+		},
 		{
 			name:  "lets map unknowns are postponed in the evaluator",
 			stack: "/stack",
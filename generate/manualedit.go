@@ -0,0 +1,276 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generate
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mineiros-io/terramate/config"
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/generate/genhcl"
+	"github.com/mineiros-io/terramate/git"
+	"github.com/mineiros-io/terramate/globals"
+	"github.com/mineiros-io/terramate/project"
+	"github.com/rs/zerolog/log"
+)
+
+// ManualEdit is a generated file of a stack whose content differs from the
+// code generated from the current configuration.
+type ManualEdit struct {
+	// Stack is the stack which generates the file.
+	Stack project.Path
+
+	// File is the generated file.
+	File project.Path
+
+	// Stale tells if the file content is the code generated from the
+	// configuration of the last commit (HEAD), in which case the file was not
+	// edited by hand but is just outdated because the configuration changed
+	// after it was generated. It is always false outside of git repositories.
+	Stale bool
+}
+
+// DetectManualEdits generates the code of each stack in memory and compares
+// it with the generated files found on the file system, reporting the files
+// with different content, which usually means that someone edited the
+// generated code by hand. The Terramate header of generate_hcl files is
+// ignored in the comparison.
+//
+// Generated code which is just outdated because the configuration changed
+// after it was generated also differs, so the files matching the code
+// generated from the configuration of the last commit are reported as Stale.
+// Missing files and files of blocks with a false condition are not reported,
+// use [DetectOutdated] to find those. The result is ordered by stack and file.
+func DetectManualEdits(root *config.Root, vendorDir project.Path) ([]ManualEdit, error) {
+	logger := log.With().
+		Str("action", "generate.DetectManualEdits()").
+		Logger()
+
+	stacks, err := config.LoadAllStacks(root.Tree())
+	if err != nil {
+		return nil, err
+	}
+
+	edits := []ManualEdit{}
+	errs := errors.L()
+
+	for _, st := range stacks {
+		logger.Debug().
+			Stringer("stack", st.Dir()).
+			Msg("checking manual edits of generated code")
+
+		stackEdits, err := stackManualEdits(root, st.Stack, vendorDir)
+		if err != nil {
+			errs.Append(err)
+			continue
+		}
+		edits = append(edits, stackEdits...)
+	}
+
+	if err := errs.AsError(); err != nil {
+		return nil, err
+	}
+
+	if len(edits) > 0 {
+		if err := markStaleEdits(root, vendorDir, edits); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(edits, func(i, j int) bool {
+		if edits[i].Stack != edits[j].Stack {
+			return edits[i].Stack.String() < edits[j].Stack.String()
+		}
+		return edits[i].File.String() < edits[j].File.String()
+	})
+	return edits, nil
+}
+
+func stackManualEdits(
+	root *config.Root,
+	st *config.Stack,
+	vendorDir project.Path,
+) ([]ManualEdit, error) {
+	generated, err := stackGenFiles(root, st, vendorDir)
+	if err != nil {
+		return nil, err
+	}
+
+	stackpath := st.HostDir(root)
+	var edits []ManualEdit
+
+	for _, genfile := range generated {
+		if !genfile.Condition() {
+			continue
+		}
+
+		filename := targetPath(genfile)
+		targetpath := filepath.Join(stackpath, filename)
+
+		currentCode, found, err := readFile(targetpath)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			continue
+		}
+
+		generatedCode := genfile.Header() + genfile.Body()
+		if stripGenHCLHeader(currentCode) == stripGenHCLHeader(generatedCode) {
+			continue
+		}
+
+		edits = append(edits, ManualEdit{
+			Stack: st.Dir,
+			File:  project.NewPath(path.Join(st.Dir.String(), filename)),
+		})
+	}
+	return edits, nil
+}
+
+// markStaleEdits sets the Stale flag of the edits whose file content is the
+// code generated from the configuration of the HEAD commit. The HEAD commit is
+// checked out in a temporary worktree, so its configuration is loaded exactly
+// as it was committed. Nothing is marked if the project is not a git
+// repository or the configuration of HEAD can't be loaded.
+func markStaleEdits(root *config.Root, vendorDir project.Path, edits []ManualEdit) error {
+	logger := log.With().
+		Str("action", "generate.markStaleEdits()").
+		Logger()
+
+	g, err := git.WithConfig(git.Config{
+		WorkingDir:     root.HostDir(),
+		AllowPorcelain: true,
+	})
+	if err != nil || !g.IsRepository() {
+		logger.Trace().Err(err).Msg("no git repository, ignoring stale code")
+		return nil
+	}
+	if _, err := g.RevParse("HEAD"); err != nil {
+		logger.Trace().Err(err).Msg("no HEAD commit, ignoring stale code")
+		return nil
+	}
+
+	gitroot, err := g.Root()
+	if err != nil {
+		return errors.E(err, "checking for stale generated code")
+	}
+	rootdir, err := filepath.EvalSymlinks(root.HostDir())
+	if err != nil {
+		return errors.E(err, "checking for stale generated code")
+	}
+	relroot, err := filepath.Rel(gitroot, rootdir)
+	if err != nil {
+		return errors.E(err, "checking for stale generated code")
+	}
+
+	tmpdir, err := os.MkdirTemp("", "terramate-stale-code")
+	if err != nil {
+		return errors.E(err, "checking for stale generated code")
+	}
+	defer func() { _ = os.RemoveAll(tmpdir) }()
+
+	worktree := filepath.Join(tmpdir, "head")
+	if err := g.WorktreeAdd(worktree, "HEAD"); err != nil {
+		return errors.E(err, "checking out HEAD to check for stale generated code")
+	}
+	defer func() {
+		if err := g.WorktreeRemove(worktree); err != nil {
+			logger.Warn().Err(err).Msg("removing HEAD worktree")
+		}
+	}()
+
+	headRoot, err := config.LoadRoot(filepath.Join(worktree, relroot))
+	if err != nil {
+		logger.Debug().Err(err).Msg("loading HEAD configuration, ignoring stale code")
+		return nil
+	}
+
+	headCode := map[project.Path]map[string]string{}
+	for i := range edits {
+		edit := &edits[i]
+
+		files, ok := headCode[edit.Stack]
+		if !ok {
+			files = headStackCode(headRoot, edit.Stack, vendorDir)
+			headCode[edit.Stack] = files
+		}
+
+		code, ok := files[edit.File.String()]
+		if !ok {
+			continue
+		}
+		currentCode, _, err := readFile(edit.File.HostPath(root.HostDir()))
+		if err != nil {
+			return err
+		}
+		edit.Stale = stripGenHCLHeader(currentCode) == stripGenHCLHeader(code)
+	}
+	return nil
+}
+
+// headStackCode returns the code generated for the stack at dir of the HEAD
+// configuration, indexed by the project path of the generated files. It
+// returns no code if the stack doesn't exist or fails to generate at HEAD.
+func headStackCode(
+	headRoot *config.Root,
+	dir project.Path,
+	vendorDir project.Path,
+) map[string]string {
+	code := map[string]string{}
+	st, found, err := config.TryLoadStack(headRoot, dir)
+	if err != nil || !found {
+		return code
+	}
+	generated, err := stackGenFiles(headRoot, st, vendorDir)
+	if err != nil {
+		log.Debug().Err(err).
+			Stringer("stack", dir).
+			Msg("generating HEAD code, ignoring stale code")
+		return code
+	}
+	for _, genfile := range generated {
+		if !genfile.Condition() {
+			continue
+		}
+		filename := path.Join(dir.String(), targetPath(genfile))
+		code[filename] = genfile.Header() + genfile.Body()
+	}
+	return code
+}
+
+// stackGenFiles generates the code of the stack in memory.
+func stackGenFiles(root *config.Root, st *config.Stack, vendorDir project.Path) ([]GenFile, error) {
+	report := globals.ForStack(root, st)
+	if err := report.AsError(); err != nil {
+		return nil, errors.E(err, "checking for manual edits")
+	}
+	return loadStackCodeCfgs(root, st.WithFeatures(report.Features), report.Globals, vendorDir, nil)
+}
+
+// stripGenHCLHeader removes the generate_hcl header of the code, if any,
+// together with the blank lines following it.
+func stripGenHCLHeader(code string) string {
+	for _, header := range []string{genhcl.Header, genhcl.HeaderV0} {
+		if strings.HasPrefix(code, header) {
+			return strings.TrimLeft(code[len(header):], "\n")
+		}
+	}
+	return code
+}
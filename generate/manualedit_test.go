@@ -0,0 +1,139 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generate_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/madlambda/spells/assert"
+	"github.com/mineiros-io/terramate/generate"
+	"github.com/mineiros-io/terramate/project"
+	"github.com/mineiros-io/terramate/test/sandbox"
+)
+
+func TestDetectManualEdits(t *testing.T) {
+	t.Parallel()
+
+	s := sandbox.NoGit(t)
+	s.BuildTree([]string{
+		"s:stacks/a",
+		"s:stacks/b",
+		`f:stacks/generate.tm:generate_hcl "main.tf" {
+		  content {
+		    locals {
+		      name = terramate.stack.name
+		    }
+		  }
+		}
+		generate_file "file.txt" {
+		  content = terramate.stack.path.absolute
+		}`,
+	})
+	s.Generate()
+
+	root := s.Config()
+	vendorDir := project.NewPath("/modules")
+
+	t.Run("unchanged generated code", func(t *testing.T) {
+		edits, err := generate.DetectManualEdits(root, vendorDir)
+		assert.NoError(t, err)
+		assertManualEdits(t, []generate.ManualEdit{}, edits)
+	})
+
+	t.Run("header changes are ignored", func(t *testing.T) {
+		mainTF := filepath.Join(s.RootDir(), "stacks/b/main.tf")
+		data, err := os.ReadFile(mainTF)
+		assert.NoError(t, err)
+		defer writeFile(t, mainTF, string(data))
+
+		writeFile(t, mainTF, "// GENERATED BY TERRAMATE: DO NOT EDIT\n"+
+			string(data[len("// TERRAMATE: GENERATED AUTOMATICALLY DO NOT EDIT\n"):]))
+
+		edits, err := generate.DetectManualEdits(root, vendorDir)
+		assert.NoError(t, err)
+		assertManualEdits(t, []generate.ManualEdit{}, edits)
+	})
+
+	t.Run("hand edited generated code", func(t *testing.T) {
+		s.RootEntry().CreateFile("stacks/a/file.txt", "edited by hand")
+		s.RootEntry().CreateFile("stacks/b/main.tf",
+			"// TERRAMATE: GENERATED AUTOMATICALLY DO NOT EDIT\n\nlocals {}\n")
+
+		edits, err := generate.DetectManualEdits(root, vendorDir)
+		assert.NoError(t, err)
+		assertManualEdits(t, []generate.ManualEdit{
+			{
+				Stack: project.NewPath("/stacks/a"),
+				File:  project.NewPath("/stacks/a/file.txt"),
+			},
+			{
+				Stack: project.NewPath("/stacks/b"),
+				File:  project.NewPath("/stacks/b/main.tf"),
+			},
+		}, edits)
+	})
+}
+
+func TestDetectManualEditsStale(t *testing.T) {
+	t.Parallel()
+
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stacks/a",
+		"s:stacks/b",
+		`f:stacks/generate.tm:generate_file "file.txt" {
+		  content = "v1"
+		}`,
+	})
+	s.Generate()
+	s.Git().CommitAll("generated code")
+
+	// the configuration changed, but the code was not generated again.
+	s.RootEntry().CreateFile("stacks/generate.tm", `generate_file "file.txt" {
+	  content = "v2"
+	}`)
+	s.RootEntry().CreateFile("stacks/b/file.txt", "edited by hand")
+
+	edits, err := generate.DetectManualEdits(s.Config(), project.NewPath("/modules"))
+	assert.NoError(t, err)
+	assertManualEdits(t, []generate.ManualEdit{
+		{
+			Stack: project.NewPath("/stacks/a"),
+			File:  project.NewPath("/stacks/a/file.txt"),
+			Stale: true,
+		},
+		{
+			Stack: project.NewPath("/stacks/b"),
+			File:  project.NewPath("/stacks/b/file.txt"),
+		},
+	}, edits)
+}
+
+func assertManualEdits(t *testing.T, want, got []generate.ManualEdit) {
+	t.Helper()
+
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(project.Path{})); diff != "" {
+		t.Fatalf("unexpected manual edits (-want +got):\n%s", diff)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+}
@@ -1217,6 +1217,64 @@ func TestOutdatedDetection(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "detection on stack outdir",
+			steps: []step{
+				{
+					layout: []string{
+						"s:stack",
+						"d:shared",
+					},
+					files: []file{
+						{
+							path: "globals.tm",
+							body: Globals(
+								Bool("condition", true),
+							),
+						},
+						{
+							path: "stack/config.tm",
+							body: Doc(
+								GenerateFile(
+									Labels("test.txt"),
+									Str("outdir", "../shared"),
+									Expr("condition", "global.condition"),
+									Str("content", "code"),
+								),
+								GenerateHCL(
+									Labels("test.hcl"),
+									Str("outdir", "../shared"),
+									Expr("condition", "global.condition"),
+									Content(
+										Str("content", "tm is awesome"),
+									),
+								),
+							),
+						},
+					},
+					want: []string{
+						"shared/test.hcl",
+						"shared/test.txt",
+					},
+				},
+				{
+					files: []file{
+						{
+							path: "stack/globals.tm",
+							body: Doc(
+								Globals(
+									Bool("condition", false),
+								),
+							),
+						},
+					},
+					want: []string{
+						"shared/test.hcl",
+						"shared/test.txt",
+					},
+				},
+			},
+		},
 	}
 
 	for _, tc := range tcases {
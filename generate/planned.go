@@ -0,0 +1,144 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generate
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/mineiros-io/terramate/config"
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/project"
+	"github.com/mineiros-io/terramate/tf"
+	"github.com/rs/zerolog/log"
+)
+
+// ErrListPlanned indicates that the stacks couldn't be checked against the
+// files planned for generation.
+const ErrListPlanned errors.Kind = "checking planned generation"
+
+// StacksMissingGeneration lists the stacks which have files planned for
+// generation (see [Load]) but none of them on the file system, which usually
+// means the stack was never generated. The stacks are sorted by path.
+//
+// Blocks with a condition evaluating to false are not expected to generate
+// files, so stacks whose blocks are all disabled are not reported.
+func StacksMissingGeneration(root *config.Root, vendorDir project.Path) ([]project.Path, error) {
+	logger := log.With().
+		Str("action", "generate.StacksMissingGeneration()").
+		Logger()
+
+	missing := []project.Path{}
+	err := forEachPlannedStack(root, vendorDir, func(stack *config.Tree, planned []GenFile) error {
+		if len(planned) == 0 {
+			return nil
+		}
+
+		logger.Trace().
+			Stringer("stack", stack.Dir()).
+			Int("files", len(planned)).
+			Msg("Check generated files.")
+
+		for _, file := range planned {
+			target := filepath.Join(stack.HostDir(), filepath.FromSlash(targetPath(file)))
+			if _, err := os.Lstat(target); err == nil {
+				return nil
+			}
+		}
+		missing = append(missing, stack.Dir())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return missing, nil
+}
+
+// EmptyStacks lists the stacks that do nothing, which usually are leftover
+// or accidental stack declarations. The stacks are sorted by path. A stack is
+// empty if:
+//
+//   - its directory has no Terraform files (.tf or .tf.json), ignoring
+//     subdirectories, so child stacks don't make their parents non-empty.
+//   - no file is planned for generation on the stack (see [Load]). Blocks
+//     with a condition evaluating to false don't generate files.
+func EmptyStacks(root *config.Root, vendorDir project.Path) ([]project.Path, error) {
+	logger := log.With().
+		Str("action", "generate.EmptyStacks()").
+		Logger()
+
+	empty := []project.Path{}
+	err := forEachPlannedStack(root, vendorDir, func(stack *config.Tree, planned []GenFile) error {
+		logger.Trace().
+			Stringer("stack", stack.Dir()).
+			Msg("Check if stack is empty.")
+
+		if len(planned) > 0 {
+			return nil
+		}
+
+		entries, err := os.ReadDir(stack.HostDir())
+		if err != nil {
+			return errors.E(ErrListPlanned, err, "listing files of stack %s", stack.Dir())
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() && tf.IsTerraformFile(entry.Name()) {
+				return nil
+			}
+		}
+		empty = append(empty, stack.Dir())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return empty, nil
+}
+
+// forEachPlannedStack calls fn for each stack, sorted by path, with the files
+// planned for generation on it, which are the loaded files whose condition is
+// true.
+func forEachPlannedStack(
+	root *config.Root,
+	vendorDir project.Path,
+	fn func(stack *config.Tree, planned []GenFile) error,
+) error {
+	results, err := Load(root, vendorDir)
+	if err != nil {
+		return errors.E(ErrListPlanned, err)
+	}
+
+	for _, res := range results {
+		if res.Err != nil {
+			return errors.E(ErrListPlanned, res.Err)
+		}
+
+		stack, ok := root.Lookup(res.Dir)
+		if !ok || !stack.IsStack() {
+			continue
+		}
+
+		var planned []GenFile
+		for _, file := range res.Files {
+			if file.Condition() {
+				planned = append(planned, file)
+			}
+		}
+		if err := fn(stack, planned); err != nil {
+			return err
+		}
+	}
+	return nil
+}
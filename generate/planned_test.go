@@ -0,0 +1,116 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generate_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/madlambda/spells/assert"
+	"github.com/mineiros-io/terramate/generate"
+	"github.com/mineiros-io/terramate/project"
+	"github.com/mineiros-io/terramate/test/sandbox"
+)
+
+func TestStacksMissingGeneration(t *testing.T) {
+	t.Parallel()
+
+	s := sandbox.NoGit(t)
+	s.BuildTree([]string{
+		`f:/stacks/generate.tm:generate_hcl "main.tf" {
+			condition = tm_try(global.enabled, true)
+			content {
+				a = 1
+			}
+		}`,
+		"s:/stacks/generated",
+		"f:/stacks/generated/main.tf:a = 1",
+		"s:/stacks/missing",
+		"s:/stacks/disabled",
+		`f:/stacks/disabled/globals.tm:globals {
+			enabled = false
+		}`,
+		"s:/no-generate",
+		`f:/local/generate.tm:generate_file "file.txt" {
+			content = "data"
+		}`,
+		"s:/local/missing",
+		`f:/root-context/generate.tm:generate_file "/root.txt" {
+			context = root
+			content = "data"
+		}`,
+		"s:/root-context/stack",
+	})
+
+	missing, err := generate.StacksMissingGeneration(s.Config(), project.NewPath("/modules"))
+	assert.NoError(t, err)
+	assertStackPaths(t, []string{"/local/missing", "/stacks/missing"}, missing)
+}
+
+func TestEmptyStacks(t *testing.T) {
+	t.Parallel()
+
+	s := sandbox.NoGit(t)
+	s.BuildTree([]string{
+		"s:/empty",
+		"f:/empty/README.md:# not terraform",
+		"s:/terraform",
+		"f:/terraform/main.tf:# terraform",
+		"s:/parent",
+		"s:/parent/child",
+		"f:/parent/child/main.tf:# terraform",
+		"s:/local-generate",
+		`f:/local-generate/generate.tm:generate_hcl "main.tf" {
+			content {
+				a = 1
+			}
+		}`,
+		`f:/inherited/generate.tm:generate_file "file.txt" {
+			condition = tm_try(global.enabled, true)
+			content   = "data"
+		}`,
+		"s:/inherited/stack",
+		"s:/inherited/disabled",
+		`f:/inherited/disabled/globals.tm:globals {
+			enabled = false
+		}`,
+		`f:/root-context/generate.tm:generate_file "/root.txt" {
+			context = root
+			content = "data"
+		}`,
+		"s:/root-context/stack",
+	})
+
+	empty, err := generate.EmptyStacks(s.Config(), project.NewPath("/modules"))
+	assert.NoError(t, err)
+	assertStackPaths(t, []string{
+		"/empty",
+		"/inherited/disabled",
+		"/parent",
+		"/root-context/stack",
+	}, empty)
+}
+
+func assertStackPaths(t *testing.T, want []string, got []project.Path) {
+	t.Helper()
+
+	gotStrs := []string{}
+	for _, dir := range got {
+		gotStrs = append(gotStrs, dir.String())
+	}
+	if diff := cmp.Diff(want, gotStrs); diff != "" {
+		t.Fatalf("-(want) +(got):\n%s", diff)
+	}
+}
@@ -287,6 +287,10 @@ type dirReport struct {
 	changed []string
 	deleted []string
 	err     error
+
+	// outdirFiles are the files generated outside of the stack dir,
+	// relative to the project root.
+	outdirFiles []string
 }
 
 func (s *dirReport) addCreatedFile(filename string) {
@@ -0,0 +1,42 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package git
+
+// Limiter limits how many git commands can run at the same time.
+// A single Limiter can be shared by any number of wrappers (see
+// [Config.Limiter]), in which case the limit applies to all of them
+// together. It is safe for concurrent use.
+type Limiter struct {
+	slots chan struct{}
+}
+
+// NewLimiter creates a limiter allowing at most max git commands running at
+// the same time. If max is less than 1, it allows a single command.
+func NewLimiter(max int) *Limiter {
+	if max < 1 {
+		max = 1
+	}
+	return &Limiter{
+		slots: make(chan struct{}, max),
+	}
+}
+
+func (l *Limiter) acquire() {
+	l.slots <- struct{}{}
+}
+
+func (l *Limiter) release() {
+	<-l.slots
+}
@@ -0,0 +1,119 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package git
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// RetryPolicy configures the retries of git commands failing with transient
+// errors (see [IsTransientError]). Only idempotent commands are retried
+// (eg.: [Git.RevParse], [Git.Fetch], [Git.FetchRemoteRev] and [Git.Clone]),
+// commands changing the repository are never retried.
+// The zero value disables the retries.
+type RetryPolicy struct {
+	// MaxRetries is the maximum number of times a failed command is
+	// retried.
+	MaxRetries int
+
+	// Backoff is the time waited before the first retry, which is doubled
+	// on each subsequent retry. Zero means DefaultRetryBackoff.
+	Backoff time.Duration
+
+	// MaxBackoff limits the time waited between retries. Zero means no limit.
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryBackoff is the default time waited before the first retry.
+const DefaultRetryBackoff = 500 * time.Millisecond
+
+// transientErrors are messages printed by git on failures that are expected
+// to go away by just running the command again, like network failures and
+// lock contention with concurrent git processes.
+var transientErrors = []string{
+	// lock contention
+	".lock': File exists",
+	"cannot lock ref",
+
+	// network
+	"Could not resolve host",
+	"Temporary failure in name resolution",
+	"Connection timed out",
+	"Operation timed out",
+	"Connection reset by peer",
+	"The remote end hung up unexpectedly",
+	"early EOF",
+	"RPC failed",
+	"The requested URL returned error: 429",
+	"The requested URL returned error: 500",
+	"The requested URL returned error: 502",
+	"The requested URL returned error: 503",
+	"The requested URL returned error: 504",
+}
+
+// IsTransientError tells if err is a failure of a git command that may
+// succeed if the command is retried, like network and lock contention
+// failures.
+func IsTransientError(err error) bool {
+	var cmdErr *CmdError
+	if !errors.As(err, &cmdErr) {
+		return false
+	}
+	stderr := strings.ToLower(string(cmdErr.Stderr()))
+	for _, msg := range transientErrors {
+		if strings.Contains(stderr, strings.ToLower(msg)) {
+			return true
+		}
+	}
+	return false
+}
+
+// execRetry executes the command like exec, retrying it on transient
+// errors as configured by the retry policy. It must only be used by
+// idempotent commands.
+func (git *Git) execRetry(command string, args ...string) (string, error) {
+	policy := git.config.Retry
+	backoff := policy.Backoff
+	if backoff <= 0 {
+		backoff = DefaultRetryBackoff
+	}
+
+	for attempt := 0; ; attempt++ {
+		out, err := git.exec(command, args...)
+		if err == nil || attempt >= policy.MaxRetries || !IsTransientError(err) {
+			return out, err
+		}
+
+		log.Debug().
+			Str("action", "Git.execRetry()").
+			Str("workingDir", git.config.WorkingDir).
+			Str("command", command).
+			Int("attempt", attempt+1).
+			Dur("backoff", backoff).
+			Err(err).
+			Msg("Retrying git command after transient error.")
+
+		time.Sleep(backoff)
+
+		backoff *= 2
+		if policy.MaxBackoff > 0 && backoff > policy.MaxBackoff {
+			backoff = policy.MaxBackoff
+		}
+	}
+}
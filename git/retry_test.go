@@ -0,0 +1,205 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package git_test
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/madlambda/spells/assert"
+	"github.com/mineiros-io/terramate/git"
+)
+
+const transientStderr = "fatal: unable to access 'https://example.com/repo.git/': Could not resolve host: example.com"
+
+func TestRetryTransientFailures(t *testing.T) {
+	repodir := mkOneCommitRepo(t)
+	fake := newFlakyGit(t, "rev-parse", 2, transientStderr)
+
+	g := newRetryGit(t, repodir, fake.path, git.RetryPolicy{
+		MaxRetries: 3,
+		Backoff:    time.Millisecond,
+	})
+	out, err := g.RevParse("main")
+	assert.NoError(t, err, "rev-parse must recover from transient failures")
+	assert.EqualStrings(t, CookedCommitID, out, "commit mismatch")
+	assert.EqualInts(t, 3, fake.calls(), "rev-parse calls")
+}
+
+func TestRetryGivesUpAfterMaxRetries(t *testing.T) {
+	repodir := mkOneCommitRepo(t)
+	fake := newFlakyGit(t, "rev-parse", 5, transientStderr)
+
+	g := newRetryGit(t, repodir, fake.path, git.RetryPolicy{
+		MaxRetries: 2,
+		Backoff:    time.Millisecond,
+	})
+	_, err := g.RevParse("main")
+	assert.Error(t, err)
+	assert.IsTrue(t, git.IsTransientError(err), "unexpected error: %v", err)
+	assert.EqualInts(t, 3, fake.calls(), "rev-parse calls")
+}
+
+func TestRetryIsDisabledByDefault(t *testing.T) {
+	repodir := mkOneCommitRepo(t)
+	fake := newFlakyGit(t, "rev-parse", 1, transientStderr)
+
+	g := newRetryGit(t, repodir, fake.path, git.RetryPolicy{})
+	_, err := g.RevParse("main")
+	assert.Error(t, err)
+	assert.EqualInts(t, 1, fake.calls(), "rev-parse calls")
+}
+
+func TestRetryIgnoresNonTransientFailures(t *testing.T) {
+	repodir := mkOneCommitRepo(t)
+	fake := newFlakyGit(t, "rev-parse", 1, "fatal: ambiguous argument 'main'")
+
+	g := newRetryGit(t, repodir, fake.path, git.RetryPolicy{
+		MaxRetries: 3,
+		Backoff:    time.Millisecond,
+	})
+	_, err := g.RevParse("main")
+	assert.Error(t, err)
+	assert.IsTrue(t, !git.IsTransientError(err), "unexpected transient error: %v", err)
+	assert.EqualInts(t, 1, fake.calls(), "rev-parse calls")
+}
+
+func TestRetryDoesNotRetryMutatingCommands(t *testing.T) {
+	repodir := mkOneCommitRepo(t)
+	fake := newFlakyGit(t, "commit", 1,
+		"fatal: Unable to create '"+repodir+"/.git/index.lock': File exists.")
+
+	g := newRetryGit(t, repodir, fake.path, git.RetryPolicy{
+		MaxRetries: 3,
+		Backoff:    time.Millisecond,
+	})
+	err := g.Commit("message", "--allow-empty")
+	assert.Error(t, err)
+	assert.IsTrue(t, git.IsTransientError(err), "unexpected error: %v", err)
+	assert.EqualInts(t, 1, fake.calls(), "commit calls")
+}
+
+func TestRetryFetchAndClone(t *testing.T) {
+	repodir := mkOneCommitRepo(t)
+
+	fake := newFlakyGit(t, "clone", 1, "error: RPC failed; curl 56 Recv failure")
+	g := newRetryGit(t, t.TempDir(), fake.path, git.RetryPolicy{
+		MaxRetries: 1,
+		Backoff:    time.Millisecond,
+	})
+	clonedir := filepath.Join(t.TempDir(), "clone")
+	assert.NoError(t, g.Clone("file://"+repodir, clonedir))
+	assert.EqualInts(t, 2, fake.calls(), "clone calls")
+
+	fake = newFlakyGit(t, "fetch", 2, "fatal: the remote end hung up unexpectedly")
+	g = newRetryGit(t, clonedir, fake.path, git.RetryPolicy{
+		MaxRetries: 2,
+		Backoff:    time.Millisecond,
+	})
+	assert.NoError(t, g.Fetch("origin"))
+	assert.EqualInts(t, 3, fake.calls(), "fetch calls")
+}
+
+func TestIsTransientError(t *testing.T) {
+	for stderr, want := range map[string]bool{
+		transientStderr: true,
+		"fatal: Unable to create '/repo/.git/index.lock': File exists.":  true,
+		"error: cannot lock ref 'refs/heads/main'":                       true,
+		"fatal: unable to access: The requested URL returned error: 503": true,
+		"fatal: The remote end hung up unexpectedly":                     true,
+		"fatal: ambiguous argument 'main': unknown revision":             false,
+		"fatal: Authentication failed":                                   false,
+	} {
+		err := git.NewCmdError("git cmd", nil, []byte(stderr))
+		assert.IsTrue(t, git.IsTransientError(err) == want,
+			"IsTransientError(%q) must be %t", stderr, want)
+	}
+	assert.IsTrue(t, !git.IsTransientError(fmt.Errorf("other error")))
+}
+
+type flakyGit struct {
+	path      string
+	callsfile string
+}
+
+// newFlakyGit creates a fake git binary which fails the first failures
+// executions of the given command printing stderr, then runs the real git.
+// The other commands are always run by the real git.
+func newFlakyGit(t *testing.T, command string, failures int, stderr string) flakyGit {
+	t.Helper()
+
+	realgit, err := exec.LookPath("git")
+	assert.NoError(t, err)
+
+	bindir := t.TempDir()
+	callsfile := filepath.Join(bindir, "calls")
+	stderrfile := filepath.Join(bindir, "stderr")
+	assert.NoError(t, os.WriteFile(stderrfile, []byte(stderr), 0600))
+
+	script := fmt.Sprintf(`#!/bin/sh
+if [ "$1" = %[1]q ]; then
+	echo call >> %[2]q
+	calls=$(wc -l < %[2]q)
+	if [ "$calls" -le %[3]d ]; then
+		cat %[4]q >&2
+		exit 128
+	fi
+fi
+exec %[5]q "$@"
+`, command, callsfile, failures, stderrfile, realgit)
+
+	path := filepath.Join(bindir, "git")
+	assert.NoError(t, os.WriteFile(path, []byte(script), 0700))
+	return flakyGit{
+		path:      path,
+		callsfile: callsfile,
+	}
+}
+
+func (f flakyGit) calls() int {
+	data, err := os.ReadFile(f.callsfile)
+	if err != nil {
+		return 0
+	}
+	return len(strings.Fields(string(data)))
+}
+
+func newRetryGit(t *testing.T, dir, binary string, policy git.RetryPolicy) *git.Git {
+	t.Helper()
+
+	g, err := git.WithConfig(git.Config{
+		Username:       "Terramate Test",
+		Email:          "terramate@mineiros.io",
+		WorkingDir:     dir,
+		BinaryPath:     binary,
+		AllowPorcelain: true,
+		Env: []string{
+			"GIT_AUTHOR_NAME=Terramate Test",
+			"GIT_AUTHOR_EMAIL=terramate@mineiros.io",
+			"GIT_COMMITTER_NAME=Terramate Test",
+			"GIT_COMMITTER_EMAIL=terramate@mineiros.io",
+		},
+		Retry: policy,
+	})
+	assert.NoError(t, err)
+	return g
+}
@@ -0,0 +1,198 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package globals_test
+
+import (
+	"testing"
+
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/globals"
+	"github.com/mineiros-io/terramate/hcl"
+	"github.com/mineiros-io/terramate/test/hclwrite"
+	. "github.com/mineiros-io/terramate/test/hclwrite/hclutils"
+)
+
+func TestLoadConditionalGlobals(t *testing.T) {
+	t.Parallel()
+
+	tcases := []testcase{
+		{
+			name: "tags select the conditional globals",
+			layout: []string{
+				`s:stacks/prod:tags=["prod"]`,
+				`s:stacks/prod-eu:tags=["prod", "eu"]`,
+				`s:stacks/dev:tags=["dev"]`,
+			},
+			configs: []hclconfig{
+				{
+					path: "/",
+					add: Globals(
+						Str("env", "default"),
+						Str("region", "us"),
+					),
+				},
+				{
+					path: "/",
+					add: ConditionalGlobals(
+						Expr("condition", `tm_contains(terramate.stack.tags, "prod")`),
+						Str("env", "prod"),
+					),
+				},
+				{
+					path: "/",
+					add: ConditionalGlobals(
+						Labels("cluster"),
+						Expr("condition", `tm_contains(terramate.stack.tags, "prod")`),
+						Number("replicas", 3),
+					),
+				},
+				{
+					path: "/",
+					add: ConditionalGlobals(
+						Expr("condition", `tm_contains(terramate.stack.tags, "eu")`),
+						Str("env", "prod-eu"),
+						Str("region", "eu"),
+					),
+				},
+			},
+			want: map[string]*hclwrite.Block{
+				"/stacks/prod": Globals(
+					Str("env", "prod"),
+					Str("region", "us"),
+					EvalExpr(t, "cluster", `{
+						replicas = 3
+					}`),
+				),
+				"/stacks/prod-eu": Globals(
+					Str("env", "prod-eu"),
+					Str("region", "eu"),
+					EvalExpr(t, "cluster", `{
+						replicas = 3
+					}`),
+				),
+				"/stacks/dev": Globals(
+					Str("env", "default"),
+					Str("region", "us"),
+				),
+			},
+		},
+		{
+			name: "child globals override matching conditional parent globals",
+			layout: []string{
+				`s:stacks/prod:tags=["prod"]`,
+			},
+			configs: []hclconfig{
+				{
+					path: "/",
+					add: ConditionalGlobals(
+						Expr("condition", `tm_contains(terramate.stack.tags, "prod")`),
+						Str("env", "prod"),
+						Str("owner", "platform"),
+					),
+				},
+				{
+					path: "/stacks/prod",
+					add: Globals(
+						Str("env", "stack"),
+					),
+				},
+			},
+			want: map[string]*hclwrite.Block{
+				"/stacks/prod": Globals(
+					Str("env", "stack"),
+					Str("owner", "platform"),
+				),
+			},
+		},
+		{
+			name: "condition must be boolean",
+			layout: []string{
+				`s:stack:tags=["prod"]`,
+			},
+			configs: []hclconfig{
+				{
+					path: "/",
+					add: ConditionalGlobals(
+						Expr("condition", `terramate.stack.tags`),
+						Str("env", "prod"),
+					),
+				},
+			},
+			wantErr: errors.E(globals.ErrCondition),
+		},
+		{
+			name: "condition cannot reference globals",
+			layout: []string{
+				`s:stack:tags=["prod"]`,
+			},
+			configs: []hclconfig{
+				{
+					path: "/",
+					add: Globals(
+						Bool("enabled", true),
+					),
+				},
+				{
+					path: "/",
+					add: ConditionalGlobals(
+						Expr("condition", `global.enabled`),
+						Str("env", "prod"),
+					),
+				},
+			},
+			wantErr: errors.E(globals.ErrCondition),
+		},
+		{
+			name: "conditional globals require a condition",
+			layout: []string{
+				`s:stack`,
+			},
+			configs: []hclconfig{
+				{
+					path: "/",
+					add: ConditionalGlobals(
+						Str("env", "prod"),
+					),
+				},
+			},
+			wantErr: errors.E(hcl.ErrTerramateSchema),
+		},
+		{
+			name: "condition is a regular global in globals blocks",
+			layout: []string{
+				`s:stack:tags=["prod"]`,
+			},
+			configs: []hclconfig{
+				{
+					path: "/",
+					add: Globals(
+						Bool("condition", false),
+						Str("env", "dev"),
+					),
+				},
+			},
+			want: map[string]*hclwrite.Block{
+				"/stack": Globals(
+					Bool("condition", false),
+					Str("env", "dev"),
+				),
+			},
+		},
+	}
+
+	for _, tcase := range tcases {
+		testGlobals(t, tcase)
+	}
+}
@@ -32,6 +32,10 @@ type (
 
 		// Errors is a map of errors for each global.
 		Errors map[GlobalPathKey]EvalError // map of GlobalPath to its EvalError.
+
+		// Features are the evaluated stack.features of the stack, if the
+		// globals were evaluated for a stack (see ForStack).
+		Features map[string]bool
 	}
 
 	// EvalError carries the error and the expression which resulted in it.
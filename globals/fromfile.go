@@ -0,0 +1,105 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package globals
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/mineiros-io/terramate/config"
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/hcl"
+	"github.com/mineiros-io/terramate/hcl/ast"
+	ctyyaml "github.com/zclconf/go-cty-yaml"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// ErrFromFile indicates that globals could not be loaded from a file.
+const ErrFromFile errors.Kind = "loading globals from file"
+
+// loadFromFile loads the globals defined in the file referenced by the
+// path attribute of a from_file block inside a globals block of the tree. Absolute paths are
+// relative to the project root and relative paths are relative to the tree
+// directory. The file must contain an object, whose attributes are the
+// globals, and is decoded as JSON or YAML according to its extension.
+func loadFromFile(tree *config.Tree, attr ast.Attribute) (map[string]cty.Value, error) {
+	val, diags := attr.Expr.Value(nil)
+	if diags.HasErrors() || val.Type() != cty.String || val.IsNull() {
+		return nil, errors.E(hcl.ErrTerramateSchema, attr.Range,
+			"%s.%s must be a literal string",
+			hcl.GlobalsFromFileBlockType, hcl.GlobalsFromFilePathAttr)
+	}
+
+	filename := val.AsString()
+	if !path.IsAbs(filename) {
+		filename = path.Join(tree.Dir().String(), filename)
+	}
+	hostpath := filepath.Join(tree.RootDir(), filepath.FromSlash(filename))
+
+	data, err := os.ReadFile(hostpath)
+	if err != nil {
+		return nil, errors.E(ErrFromFile, attr.Range, err, "reading %s", filename)
+	}
+
+	var decoded cty.Value
+	switch ext := path.Ext(filename); ext {
+	case ".json":
+		decoded, err = decodeJSON(data)
+	case ".yaml", ".yml":
+		decoded, err = decodeYAML(data)
+	default:
+		return nil, errors.E(ErrFromFile, attr.Range,
+			"unsupported file extension %q: must be .json, .yaml or .yml", ext)
+	}
+	if err != nil {
+		return nil, errors.E(ErrFromFile, attr.Range, err, "decoding %s", filename)
+	}
+
+	if !decoded.Type().IsObjectType() && !decoded.Type().IsMapType() {
+		return nil, errors.E(ErrFromFile, attr.Range,
+			"%s must contain an object but got %s", filename, decoded.Type().FriendlyName())
+	}
+	if decoded.IsNull() {
+		return map[string]cty.Value{}, nil
+	}
+	return decoded.AsValueMap(), nil
+}
+
+func decodeJSON(data []byte) (cty.Value, error) {
+	typ, err := ctyjson.ImpliedType(data)
+	if err != nil {
+		return cty.NilVal, err
+	}
+	return ctyjson.Unmarshal(data, typ)
+}
+
+func decodeYAML(data []byte) (cty.Value, error) {
+	typ, err := ctyyaml.ImpliedType(data)
+	if err != nil {
+		return cty.NilVal, err
+	}
+	return ctyyaml.Unmarshal(data, typ)
+}
+
+// literalExpr returns an expression evaluating to val.
+func literalExpr(val cty.Value, attr ast.Attribute) hclsyntax.Expression {
+	return &hclsyntax.LiteralValueExpr{
+		Val:      val,
+		SrcRange: attr.Range.ToHCLRange(),
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/mineiros-io/terramate/config"
 	"github.com/mineiros-io/terramate/errors"
 	"github.com/mineiros-io/terramate/hcl"
+	"github.com/mineiros-io/terramate/hcl/ast"
 	"github.com/mineiros-io/terramate/mapexpr"
 
 	"github.com/mineiros-io/terramate/hcl/eval"
@@ -36,6 +37,7 @@ import (
 const (
 	ErrEval      errors.Kind = "global eval"
 	ErrRedefined errors.Kind = "global redefined"
+	ErrCondition errors.Kind = "global condition"
 )
 
 type (
@@ -85,6 +87,10 @@ func (a GlobalPathKey) name() string {
 // More specific globals (closer or at the current dir) have precedence over
 // less specific globals (closer or at the root dir).
 func ForDir(root *config.Root, cfgdir project.Path, ctx *eval.Context) EvalReport {
+	return forDir(root, cfgdir, ctx, nil)
+}
+
+func forDir(root *config.Root, cfgdir project.Path, ctx *eval.Context, cache *ExprsCache) EvalReport {
 	logger := log.With().
 		Str("action", "globals.Load()").
 		Str("root", root.HostDir()).
@@ -98,7 +104,7 @@ func ForDir(root *config.Root, cfgdir project.Path, ctx *eval.Context) EvalRepor
 
 	logger.Trace().Msg("loading expressions")
 
-	exprs, err := LoadExprs(tree)
+	exprs, err := cache.LoadExprs(tree)
 	if err != nil {
 		report := NewEvalReport()
 		report.BootstrapErr = err
@@ -113,6 +119,17 @@ func ForDir(root *config.Root, cfgdir project.Path, ctx *eval.Context) EvalRepor
 type ExprSet struct {
 	origin      project.Path
 	expressions map[GlobalPathKey]Expr
+
+	// conditional are the expressions of the conditional_globals blocks of
+	// the dir, in the order they are defined.
+	conditional []conditionalExprSet
+}
+
+// conditionalExprSet is the set of expressions of a conditional_globals block,
+// which are only loaded if the block condition evaluates to true.
+type conditionalExprSet struct {
+	condition   ast.Attribute
+	expressions map[GlobalPathKey]Expr
 }
 
 // HierarchicalExprs contains all loaded global expressions from multiple
@@ -133,94 +150,193 @@ func newExprSet(origin project.Path) *ExprSet {
 // More specific globals (closer or at the dir) have precedence over less
 // specific globals (closer or at the root dir).
 func LoadExprs(tree *config.Tree) (HierarchicalExprs, error) {
+	return loadExprs(tree, nil)
+}
+
+// ExprsCache caches the globals expressions loaded from each directory, so
+// the expressions of parent directories shared by multiple stacks are loaded
+// only once. The expressions loaded using a cache share the expressions of
+// each directory, so they must not be modified
+// (eg.: with [HierarchicalExprs.SetOverride]).
+//
+// An ExprsCache is not safe for concurrent use. A nil *ExprsCache is valid
+// and just loads the expressions without caching.
+type ExprsCache struct {
+	dirs map[project.Path]*ExprSet
+}
+
+// NewExprsCache creates a new empty globals expressions cache.
+func NewExprsCache() *ExprsCache {
+	return &ExprsCache{
+		dirs: map[project.Path]*ExprSet{},
+	}
+}
+
+// LoadExprs works like [LoadExprs] but reuses the cached expressions of the
+// directories already loaded.
+func (c *ExprsCache) LoadExprs(tree *config.Tree) (HierarchicalExprs, error) {
+	return loadExprs(tree, c)
+}
+
+func loadExprs(tree *config.Tree, cache *ExprsCache) (HierarchicalExprs, error) {
 	logger := log.With().
 		Str("action", "globals.LoadExprs()").
 		Stringer("dir", tree.Dir()).
 		Logger()
 
-	exprs := newExprSet(tree.Dir())
-
-	globalsBlocks := tree.Node.Globals.AsList()
-	for _, block := range globalsBlocks {
-		if len(block.Labels) > 0 && !hclsyntax.ValidIdentifier(block.Labels[0]) {
-			return nil, errors.E(
-				hcl.ErrTerramateSchema,
-				"first global label must be a valid identifier but got %s",
-				block.Labels[0],
-			)
+	globals := HierarchicalExprs{}
+	for ; tree != nil; tree = tree.NonEmptyGlobalsParent() {
+		if cache != nil {
+			if exprs, ok := cache.dirs[tree.Dir()]; ok {
+				globals[tree.Dir()] = exprs
+				continue
+			}
 		}
 
-		attrs := block.Attributes.SortedList()
-		if len(block.Labels) > 0 && len(attrs) == 0 {
-			expr := &hclsyntax.ObjectConsExpr{
-				SrcRange: block.RawOrigins[0].Range.ToHCLRange(),
-			}
-			key := NewGlobalExtendPath(block.Labels)
-			exprs.expressions[key] = Expr{
-				Origin:     block.RawOrigins[0].Range,
-				ConfigDir:  tree.Dir(),
-				LabelPath:  key.Path(),
-				Expression: expr,
-			}
+		logger.Trace().
+			Stringer("cfgdir", tree.Dir()).
+			Msg("Loading globals of directory.")
+
+		exprs, err := loadDirExprs(tree)
+		if err != nil {
+			return nil, err
 		}
+		if cache != nil {
+			cache.dirs[tree.Dir()] = exprs
+		}
+		globals[tree.Dir()] = exprs
+	}
+	return globals, nil
+}
 
-		for _, varsBlock := range block.Blocks {
-			varName := varsBlock.Labels[0]
-			if _, ok := block.Attributes[varName]; ok {
-				return HierarchicalExprs{}, errors.E(
-					ErrRedefined,
-					"map label %s conflicts with global.%s attribute", varName, varName)
-			}
+// loadDirExprs loads the globals expressions defined in the tree directory,
+// without the ones of its parent directories.
+func loadDirExprs(tree *config.Tree) (*ExprSet, error) {
+	logger := log.With().
+		Str("action", "globals.loadDirExprs()").
+		Stringer("dir", tree.Dir()).
+		Logger()
 
-			logger.Trace().Msgf("Add map.%s to globals", varName)
+	exprs := newExprSet(tree.Dir())
 
-			key := NewGlobalAttrPath(block.Labels, varName)
-			expr, err := mapexpr.NewMapExpr(varsBlock)
-			if err != nil {
-				return HierarchicalExprs{}, errors.E(err, "failed to interpret map block")
-			}
-			exprs.expressions[key] = Expr{
-				Origin:     varsBlock.RawOrigins[0].Range,
-				LabelPath:  key.Path(),
-				Expression: expr,
-			}
+	for _, block := range tree.Node.Globals.AsList() {
+		if err := loadBlockExprs(tree, block, exprs.expressions); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, block := range tree.Node.ConditionalGlobals {
+		logger.Trace().Msg("Add conditional globals block.")
+
+		conditional := conditionalExprSet{
+			condition:   block.Attributes[hcl.GlobalsConditionAttr],
+			expressions: map[GlobalPathKey]Expr{},
+		}
+		if err := loadBlockExprs(tree, block, conditional.expressions); err != nil {
+			return nil, err
 		}
+		exprs.conditional = append(exprs.conditional, conditional)
+	}
+	return exprs, nil
+}
 
-		logger.Trace().Msg("Range over attributes.")
+// loadBlockExprs loads the global expressions defined by the globals block
+// into expressions.
+func loadBlockExprs(tree *config.Tree, block *ast.MergedBlock, expressions map[GlobalPathKey]Expr) error {
+	logger := log.With().
+		Str("action", "globals.loadBlockExprs()").
+		Stringer("dir", tree.Dir()).
+		Logger()
 
-		for _, attr := range attrs {
-			logger.Trace().Msg("Add attribute to globals.")
+	if len(block.Labels) > 0 && !hclsyntax.ValidIdentifier(block.Labels[0]) {
+		return errors.E(
+			hcl.ErrTerramateSchema,
+			"first global label must be a valid identifier but got %s",
+			block.Labels[0],
+		)
+	}
 
-			key := NewGlobalAttrPath(block.Labels, attr.Name)
-			exprs.expressions[key] = Expr{
-				Origin:     attr.Range,
+	var attrs ast.AttributeSlice
+	for _, attr := range block.Attributes.SortedList() {
+		if attr.Name == hcl.GlobalsConditionAttr &&
+			block.Type == hcl.ConditionalGlobalsBlockType {
+			continue
+		}
+		attrs = append(attrs, attr)
+	}
+
+	// globals loaded from a file are added first, so the ones defined
+	// in the block attributes override them.
+	fromFileType := ast.NewEmptyLabelBlockType(hcl.GlobalsFromFileBlockType)
+	if fromFile, ok := block.Blocks[fromFileType]; ok {
+		pathAttr := fromFile.Attributes[hcl.GlobalsFromFilePathAttr]
+		values, err := loadFromFile(tree, pathAttr)
+		if err != nil {
+			return err
+		}
+		for name, val := range values {
+			key := NewGlobalAttrPath(block.Labels, name)
+			expressions[key] = Expr{
+				Origin:     pathAttr.Range,
 				ConfigDir:  tree.Dir(),
 				LabelPath:  key.Path(),
-				Expression: attr.Expr,
+				Expression: literalExpr(val, pathAttr),
 			}
 		}
 	}
 
-	globals := HierarchicalExprs{
-		tree.Dir(): exprs,
+	if len(block.Labels) > 0 && len(attrs) == 0 {
+		expr := &hclsyntax.ObjectConsExpr{
+			SrcRange: block.RawOrigins[0].Range.ToHCLRange(),
+		}
+		key := NewGlobalExtendPath(block.Labels)
+		expressions[key] = Expr{
+			Origin:     block.RawOrigins[0].Range,
+			ConfigDir:  tree.Dir(),
+			LabelPath:  key.Path(),
+			Expression: expr,
+		}
 	}
 
-	parent := tree.NonEmptyGlobalsParent()
-	if parent == nil {
-		return globals, nil
-	}
+	for _, varsBlock := range block.Blocks {
+		if varsBlock.Type == hcl.GlobalsFromFileBlockType {
+			continue
+		}
+		varName := varsBlock.Labels[0]
+		if _, ok := block.Attributes[varName]; ok {
+			return errors.E(
+				ErrRedefined,
+				"map label %s conflicts with global.%s attribute", varName, varName)
+		}
 
-	logger.Trace().Msg("Loading stack globals from parent dir.")
+		logger.Trace().Msgf("Add map.%s to globals", varName)
 
-	parentGlobals, err := LoadExprs(parent)
-	if err != nil {
-		return nil, err
+		key := NewGlobalAttrPath(block.Labels, varName)
+		expr, err := mapexpr.NewMapExpr(varsBlock)
+		if err != nil {
+			return errors.E(err, "failed to interpret map block")
+		}
+		expressions[key] = Expr{
+			Origin:     varsBlock.RawOrigins[0].Range,
+			LabelPath:  key.Path(),
+			Expression: expr,
+		}
 	}
 
-	logger.Trace().Msg("Merging globals with parent.")
+	logger.Trace().Msg("Range over attributes.")
 
-	globals.merge(parentGlobals)
-	return globals, nil
+	for _, attr := range attrs {
+		logger.Trace().Msg("Add attribute to globals.")
+
+		key := NewGlobalAttrPath(block.Labels, attr.Name)
+		expressions[key] = Expr{
+			Origin:     attr.Range,
+			ConfigDir:  tree.Dir(),
+			LabelPath:  key.Path(),
+			Expression: attr.Expr,
+		}
+	}
+	return nil
 }
 
 // SetOverride sets a custom global at the specified directory, using the given
@@ -298,6 +414,15 @@ func (dirExprs HierarchicalExprs) Eval(ctx *eval.Context) EvalReport {
 	pendingExprsErrs := map[GlobalPathKey]*errors.List{}
 
 	sortedLoadedExprs := dirExprs.sort()
+	for i, exprset := range sortedLoadedExprs {
+		resolved, err := exprset.resolveConditions(ctx)
+		if err != nil {
+			report.BootstrapErr = err
+			return report
+		}
+		sortedLoadedExprs[i] = resolved
+	}
+
 	pendingExprs := map[GlobalPathKey]Expr{}
 
 	// Here we will override values, but since
@@ -551,17 +676,47 @@ func (dirExprs HierarchicalExprs) Eval(ctx *eval.Context) EvalReport {
 	return report
 }
 
-func (dirExprs HierarchicalExprs) merge(other HierarchicalExprs) {
-	for k, v := range other {
-		if _, ok := dirExprs[k]; !ok {
-			dirExprs[k] = v
-		} else {
-			panic(errors.E(
-				errors.ErrInternal,
-				"cant merge duplicated configuration %q",
-				k))
+// resolveConditions returns the expressions of the set, including the ones
+// of the conditional globals blocks whose condition is true. The conditional
+// blocks override the unconditional globals of the set and, when multiple
+// conditional blocks define the same global, the last one defined wins.
+// The conditions are evaluated with ctx and can't reference globals.
+func (dirExprs *ExprSet) resolveConditions(ctx *eval.Context) (*ExprSet, error) {
+	if len(dirExprs.conditional) == 0 {
+		return dirExprs, nil
+	}
+
+	resolved := newExprSet(dirExprs.origin)
+	for accessor, expr := range dirExprs.expressions {
+		resolved.expressions[accessor] = expr
+	}
+
+	for _, conditional := range dirExprs.conditional {
+		cond := conditional.condition
+		for _, namespace := range cond.Expr.Variables() {
+			if namespace.RootName() == "global" {
+				return nil, errors.E(ErrCondition, cond.Expr.Range(),
+					"globals condition can't reference globals")
+			}
+		}
+
+		val, err := ctx.Eval(cond.Expr)
+		if err != nil {
+			return nil, errors.E(ErrCondition, err)
+		}
+		if val.Type() != cty.Bool || val.IsNull() || !val.IsKnown() {
+			return nil, errors.E(ErrCondition, cond.Expr.Range(),
+				"globals condition must be a boolean but has type %s",
+				val.Type().FriendlyName())
+		}
+		if val.False() {
+			continue
+		}
+		for accessor, expr := range conditional.expressions {
+			resolved.expressions[accessor] = expr
 		}
 	}
+	return resolved, nil
 }
 
 func isSameObjectPath(a, b eval.ObjectPath) bool {
@@ -3941,6 +3941,86 @@ func TestLoadGlobals(t *testing.T) {
 				),
 			},
 		},
+		{
+			name: "globals loaded from yaml file with hcl override",
+			layout: []string{
+				"s:stacks/stack",
+				`f:config/globals.yaml:
+region: eu-west-1
+replicas: 2
+network:
+  cidr: 10.0.0.0/16
+`,
+			},
+			configs: []hclconfig{
+				{
+					path: "/",
+					add: Globals(
+						Block("from_file",
+							Str("path", "/config/globals.yaml"),
+						),
+						Number("replicas", 3),
+					),
+				},
+				{
+					path: "/stacks/stack",
+					add: Globals(
+						Str("region", "us-east-1"),
+					),
+				},
+			},
+			want: map[string]*hclwrite.Block{
+				"/stacks/stack": Globals(
+					Str("region", "us-east-1"),
+					Number("replicas", 3),
+					EvalExpr(t, "network", `{
+						cidr = "10.0.0.0/16"
+					}`),
+				),
+			},
+		},
+		{
+			name: "globals loaded from json file relative to config dir",
+			layout: []string{
+				"s:stack",
+				`f:stack/globals.json:{"name": "stack", "zones": ["a", "b"]}`,
+			},
+			configs: []hclconfig{
+				{
+					path: "/stack",
+					add: Globals(
+						Block("from_file",
+							Str("path", "globals.json"),
+						),
+						Expr("zone", "global.zones[0]"),
+					),
+				},
+			},
+			want: map[string]*hclwrite.Block{
+				"/stack": Globals(
+					Str("name", "stack"),
+					Str("zone", "a"),
+					EvalExpr(t, "zones", `["a", "b"]`),
+				),
+			},
+		},
+		{
+			name:   "from_file is a regular global attribute",
+			layout: []string{"s:stack"},
+			configs: []hclconfig{
+				{
+					path: "/stack",
+					add: Globals(
+						Str("from_file", "globals.json"),
+					),
+				},
+			},
+			want: map[string]*hclwrite.Block{
+				"/stack": Globals(
+					Str("from_file", "globals.json"),
+				),
+			},
+		},
 	}
 
 	for _, tcase := range tcases {
@@ -4062,6 +4142,79 @@ func TestLoadGlobalsErrors(t *testing.T) {
 			},
 			want: errors.E(globals.ErrRedefined),
 		},
+		{
+			name:   "globals from missing file",
+			layout: []string{"s:stack"},
+			configs: []cfg{
+				{
+					path: "/",
+					body: `
+					  globals {
+					    from_file {
+					      path = "/globals.yaml"
+					    }
+					  }
+					`,
+				},
+			},
+			want: errors.E(globals.ErrFromFile),
+		},
+		{
+			name: "globals from invalid yaml file",
+			layout: []string{
+				"s:stack",
+				"f:globals.yaml:a: [",
+			},
+			configs: []cfg{
+				{
+					path: "/",
+					body: `
+					  globals {
+					    from_file {
+					      path = "/globals.yaml"
+					    }
+					  }
+					`,
+				},
+			},
+			want: errors.E(globals.ErrFromFile),
+		},
+		{
+			name: "globals from file not containing an object",
+			layout: []string{
+				"s:stack",
+				`f:globals.json:["a"]`,
+			},
+			configs: []cfg{
+				{
+					path: "/",
+					body: `
+					  globals {
+					    from_file {
+					      path = "/globals.json"
+					    }
+					  }
+					`,
+				},
+			},
+			want: errors.E(globals.ErrFromFile),
+		},
+		{
+			name:   "globals from file without path",
+			layout: []string{"s:stack"},
+			configs: []cfg{
+				{
+					path: "/",
+					body: `
+					  globals {
+					    from_file {
+					    }
+					  }
+					`,
+				},
+			},
+			want: errors.E(hcl.ErrTerramateSchema),
+		},
 	}
 
 	for _, tc := range tcases {
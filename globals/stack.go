@@ -15,18 +15,76 @@
 package globals
 
 import (
+	"sort"
+
 	"github.com/mineiros-io/terramate/config"
+	"github.com/mineiros-io/terramate/errors"
 	"github.com/mineiros-io/terramate/hcl/eval"
 	"github.com/mineiros-io/terramate/stdlib"
+	"github.com/zclconf/go-cty/cty"
 )
 
 // ForStack loads from the config tree all globals defined for a given stack.
+// The stack.features of the stack are evaluated with the loaded globals and
+// returned in the report, so they can be made available as
+// terramate.stack.features metadata with [config.Stack.WithFeatures].
 func ForStack(root *config.Root, stack *config.Stack) EvalReport {
+	return ForStackWithCache(root, stack, nil)
+}
+
+// ForStackWithCache works like [ForStack] but loads the globals expressions
+// using the given cache, so the expressions of parent directories are loaded
+// only once when evaluating the globals of multiple stacks. The values are
+// still evaluated for each stack, since they can depend on the stack
+// metadata. The cache can be nil.
+func ForStackWithCache(root *config.Root, stack *config.Stack, cache *ExprsCache) EvalReport {
 	ctx := eval.NewContext(
 		stdlib.Functions(stack.HostDir(root)),
 	)
 	runtime := root.Runtime()
 	runtime.Merge(stack.RuntimeValues(root))
 	ctx.SetNamespace("terramate", runtime)
-	return ForDir(root, stack.Dir, ctx)
+	report := forDir(root, stack.Dir, ctx, cache)
+	if stack.FeaturesAttr == nil || report.AsError() != nil {
+		return report
+	}
+
+	ctx.SetNamespace("global", report.Globals.AsValueMap())
+	features, err := evalFeatures(ctx, stack)
+	if err != nil {
+		report.BootstrapErr = err
+		return report
+	}
+	report.Features = features
+	return report
+}
+
+func evalFeatures(ctx *eval.Context, stack *config.Stack) (map[string]bool, error) {
+	attr := stack.FeaturesAttr
+	val, err := ctx.Eval(attr.Expr)
+	if err != nil {
+		return nil, errors.E(config.ErrStackInvalidFeatures, attr.Range, err)
+	}
+	if val.IsNull() || (!val.Type().IsObjectType() && !val.Type().IsMapType()) {
+		return nil, errors.E(config.ErrStackInvalidFeatures, attr.Range,
+			"stack.features must be an object but given %q", val.Type().FriendlyName())
+	}
+
+	vals := val.AsValueMap()
+	names := make([]string, 0, len(vals))
+	for name := range vals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	features := make(map[string]bool, len(vals))
+	for _, name := range names {
+		v := vals[name]
+		if v.Type() != cty.Bool || v.IsNull() {
+			return nil, errors.E(config.ErrStackInvalidFeatures, attr.Range,
+				"stack.features.%s must be a bool but given %q", name, v.Type().FriendlyName())
+		}
+		features[name] = v.True()
+	}
+	return features, nil
 }
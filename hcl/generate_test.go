@@ -17,6 +17,7 @@ package hcl_test
 import (
 	"testing"
 
+	"github.com/mineiros-io/terramate/errors"
 	"github.com/mineiros-io/terramate/hcl"
 
 	. "github.com/mineiros-io/terramate/test/hclutils"
@@ -121,3 +122,226 @@ func TestHCLParserGenerateBlocksRange(t *testing.T) {
 		testParser(t, tcase)
 	}
 }
+
+func TestHCLParserGenerateOutDir(t *testing.T) {
+	tcases := []testcase{
+		{
+			name: "outdir is cleaned",
+			input: []cfgfile{
+				{
+					filename: "generates.tm",
+					body: Doc(
+						GenerateFile(
+							Labels("file.txt"),
+							Str("outdir", "../shared/"),
+							Str("content", "terramate is awesome"),
+						),
+						GenerateHCL(
+							Labels("file.hcl"),
+							Str("outdir", "./child"),
+							Content(),
+						),
+					).String(),
+				},
+			},
+			want: want{
+				config: hcl.Config{
+					Generate: hcl.GenerateConfig{
+						Files: []hcl.GenFileBlock{
+							{
+								Label:  "file.txt",
+								OutDir: "../shared",
+								Range: Range(
+									"generates.tm",
+									Start(1, 1, 0),
+									End(4, 2, 88),
+								),
+							},
+						},
+						HCLs: []hcl.GenHCLBlock{
+							{
+								Label:  "file.hcl",
+								OutDir: "child",
+								Range: Range(
+									"generates.tm",
+									Start(5, 1, 89),
+									End(9, 2, 153),
+								),
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "absolute outdir fails",
+			input: []cfgfile{
+				{
+					filename: "genfile.tm",
+					body: GenerateFile(
+						Labels("file.txt"),
+						Str("outdir", "/shared"),
+						Str("content", "terramate is awesome"),
+					).String(),
+				},
+			},
+			want: want{
+				errs: []error{
+					errors.E(hcl.ErrTerramateSchema),
+				},
+			},
+		},
+		{
+			name: "non-literal outdir fails",
+			input: []cfgfile{
+				{
+					filename: "genhcl.tm",
+					body: GenerateHCL(
+						Labels("file.hcl"),
+						Expr("outdir", "global.dir"),
+						Content(),
+					).String(),
+				},
+			},
+			want: want{
+				errs: []error{
+					errors.E(hcl.ErrTerramateSchema),
+				},
+			},
+		},
+		{
+			name: "outdir with context=root fails",
+			input: []cfgfile{
+				{
+					filename: "genfile.tm",
+					body: GenerateFile(
+						Labels("/file.txt"),
+						Expr("context", "root"),
+						Str("outdir", "shared"),
+						Str("content", "terramate is awesome"),
+					).String(),
+				},
+			},
+			want: want{
+				errs: []error{
+					errors.E(hcl.ErrTerramateSchema),
+				},
+			},
+		},
+	}
+
+	for _, tcase := range tcases {
+		testParser(t, tcase)
+	}
+}
+
+func TestHCLParserGenerateOnChangeOnly(t *testing.T) {
+	tcases := []testcase{
+		{
+			name: "on_change_only is parsed",
+			input: []cfgfile{
+				{
+					filename: "generates.tm",
+					body: Doc(
+						GenerateFile(
+							Labels("file.txt"),
+							Bool("on_change_only", true),
+							Str("content", "terramate is awesome"),
+						),
+						GenerateHCL(
+							Labels("file.hcl"),
+							Bool("on_change_only", false),
+							Content(),
+						),
+					).String(),
+				},
+			},
+			want: want{
+				config: hcl.Config{
+					Generate: hcl.GenerateConfig{
+						Files: []hcl.GenFileBlock{
+							{
+								Label:        "file.txt",
+								OnChangeOnly: true,
+								Range: Range(
+									"generates.tm",
+									Start(1, 1, 0),
+									End(4, 2, 94),
+								),
+							},
+						},
+						HCLs: []hcl.GenHCLBlock{
+							{
+								Label: "file.hcl",
+								Range: Range(
+									"generates.tm",
+									Start(5, 1, 95),
+									End(9, 2, 163),
+								),
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "non-boolean on_change_only fails",
+			input: []cfgfile{
+				{
+					filename: "genfile.tm",
+					body: GenerateFile(
+						Labels("file.txt"),
+						Str("on_change_only", "true"),
+						Str("content", "terramate is awesome"),
+					).String(),
+				},
+			},
+			want: want{
+				errs: []error{
+					errors.E(hcl.ErrTerramateSchema),
+				},
+			},
+		},
+		{
+			name: "non-literal on_change_only fails",
+			input: []cfgfile{
+				{
+					filename: "genhcl.tm",
+					body: GenerateHCL(
+						Labels("file.hcl"),
+						Expr("on_change_only", "global.changed"),
+						Content(),
+					).String(),
+				},
+			},
+			want: want{
+				errs: []error{
+					errors.E(hcl.ErrTerramateSchema),
+				},
+			},
+		},
+		{
+			name: "on_change_only with context=root fails",
+			input: []cfgfile{
+				{
+					filename: "genfile.tm",
+					body: GenerateFile(
+						Labels("/file.txt"),
+						Expr("context", "root"),
+						Bool("on_change_only", true),
+						Str("content", "terramate is awesome"),
+					).String(),
+				},
+			},
+			want: want{
+				errs: []error{
+					errors.E(hcl.ErrTerramateSchema),
+				},
+			},
+		},
+	}
+
+	for _, tcase := range tcases {
+		testParser(t, tcase)
+	}
+}
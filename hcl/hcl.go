@@ -46,6 +46,22 @@ const (
 const (
 	// StackBlockType name of the stack block type
 	StackBlockType = "stack"
+
+	// ConditionalGlobalsBlockType is the name of the block type which defines
+	// globals that are only loaded when its condition is true.
+	ConditionalGlobalsBlockType = "conditional_globals"
+
+	// GlobalsConditionAttr is the name of the conditional globals block
+	// attribute which defines the condition for the block globals to be loaded.
+	GlobalsConditionAttr = "condition"
+
+	// GlobalsFromFileBlockType is the name of the globals sub block which
+	// loads globals from a JSON or YAML file.
+	GlobalsFromFileBlockType = "from_file"
+
+	// GlobalsFromFilePathAttr is the name of the from_file block attribute
+	// which defines the path of the file to be loaded.
+	GlobalsFromFilePathAttr = "path"
 )
 
 // Config represents a Terramate configuration.
@@ -57,6 +73,11 @@ type Config struct {
 	Asserts   []AssertConfig
 	Generate  GenerateConfig
 
+	// ConditionalGlobals are the conditional_globals blocks, in the order they
+	// are defined. They are not merged with other globals
+	// blocks since each block has its own condition.
+	ConditionalGlobals []*ast.MergedBlock
+
 	Imported RawConfig
 
 	// absdir is the absolute path to the configuration directory.
@@ -74,6 +95,7 @@ type GenerateConfig struct {
 type AssertConfig struct {
 	Range     info.Range
 	Warning   hcl.Expression
+	Severity  hcl.Expression
 	Assertion hcl.Expression
 	Message   hcl.Expression
 }
@@ -85,6 +107,11 @@ type RunConfig struct {
 
 	// Env contains environment definitions for run.
 	Env *RunEnv
+
+	// Command is the command executed on each stack when no command is
+	// given to the run command. It is evaluated in the context of each
+	// stack, so it is kept unevaluated.
+	Command *ast.Attribute
 }
 
 // RunEnv represents Terramate run environment.
@@ -114,10 +141,25 @@ type GitConfig struct {
 	CheckRemote bool
 }
 
+// TriggersConfig represents Terramate triggers configuration.
+type TriggersConfig struct {
+	// Dir is the project path of the directory where trigger files are stored.
+	Dir string
+}
+
+// ChangeDetectionConfig represents Terramate change detection configuration.
+type ChangeDetectionConfig struct {
+	// Ignore is a list of gitignore-style patterns, relative to the project
+	// root, of changed files which must not mark any stack as changed.
+	Ignore []string
+}
+
 // RootConfig represents the root config block of a Terramate configuration.
 type RootConfig struct {
-	Git *GitConfig
-	Run *RunConfig
+	Git             *GitConfig
+	Run             *RunConfig
+	Triggers        *TriggersConfig
+	ChangeDetection *ChangeDetectionConfig
 }
 
 // ManifestDesc represents a parsed manifest description.
@@ -148,6 +190,9 @@ type Terramate struct {
 	// RequiredVersion contains the terramate version required by the stack.
 	RequiredVersion string
 
+	// RequiredVersionRange is the range of the required_version expression.
+	RequiredVersionRange hcl.Range
+
 	// RequiredVersionAllowPreReleases allows pre-release to be matched if true.
 	RequiredVersionAllowPreReleases bool
 
@@ -187,6 +232,22 @@ type Stack struct {
 
 	// Watch is a list of files to be watched for changes.
 	Watch []string
+
+	// VarFiles is a list of Terraform variables files used by the stack.
+	VarFiles []string
+
+	// Owners is a non-duplicated list of owners of the stack.
+	// The stack.owner attribute can be either a string or a set(string).
+	Owners []string
+
+	// ConcurrencyGroup is the stack.concurrency_group attribute. Stacks in
+	// the same concurrency group never run in parallel.
+	ConcurrencyGroup string
+
+	// Features is the stack.features attribute, an object whose attributes
+	// are boolean expressions over globals. It is evaluated after the
+	// globals of the stack, so it is kept unevaluated.
+	Features *ast.Attribute
 }
 
 // GenHCLBlock represents a parsed generate_hcl block.
@@ -195,10 +256,16 @@ type GenHCLBlock struct {
 	Range info.Range
 	// Label of the block.
 	Label string
+	// OutDir is the directory, relative to the stack, where the file is
+	// generated. It is empty if the file is generated inside the stack.
+	OutDir string
 	// Lets is a block of local variables.
 	Lets *ast.MergedBlock
 	// Condition attribute of the block, if any.
 	Condition *hclsyntax.Attribute
+	// OnChangeOnly tells if the block must only be generated for changed
+	// stacks, when the generation is change aware.
+	OnChangeOnly bool
 	// Content block.
 	Content *hclsyntax.Block
 	// Asserts represents all assert blocks
@@ -211,12 +278,24 @@ type GenFileBlock struct {
 	Range info.Range
 	// Label of the block
 	Label string
+	// OutDir is the directory, relative to the stack, where the file is
+	// generated. It is empty if the file is generated inside the stack.
+	OutDir string
 	// Lets is a block of local variables.
 	Lets *ast.MergedBlock
 	// Condition attribute of the block, if any.
 	Condition *hclsyntax.Attribute
-	// Content attribute of the block
+	// OnChangeOnly tells if the block must only be generated for changed
+	// stacks, when the generation is change aware.
+	OnChangeOnly bool
+	// Content attribute of the block, if any.
 	Content *hclsyntax.Attribute
+	// Source attribute of the block, if any. It conflicts with Content.
+	Source *hclsyntax.Attribute
+	// Template attribute of the block, if any. Only allowed with Source.
+	Template *hclsyntax.Attribute
+	// Format attribute of the block, if any. Only allowed with Content.
+	Format *hclsyntax.Attribute
 	// Context of the generation (stack by default).
 	Context string
 	// Asserts represents all assert blocks
@@ -625,9 +704,17 @@ func (p *TerramateParser) parseStack(stackblock *ast.Block) (*Stack, error) {
 
 	stack := &Stack{}
 
+	if features, ok := stackblock.Attributes["features"]; ok {
+		stack.Features = &features
+	}
+
 	logger.Debug().Msg("Get stack attributes.")
 	attrs := ast.AsHCLAttributes(stackblock.Body.Attributes)
 	for _, attr := range ast.SortRawAttributes(attrs) {
+		if attr.Name == "features" {
+			continue
+		}
+
 		logger.Trace().Msg("Get attribute value.")
 
 		attrVal, err := p.evalctx.Eval(attr.Expr)
@@ -674,8 +761,19 @@ func (p *TerramateParser) parseStack(stackblock *ast.Block) (*Stack, error) {
 			}
 			stack.Description = attrVal.AsString()
 
-			// The `tags`, `after`, `before`, `wants`, `wanted_by` and `watch`
-			// have all the same parsing rules.
+		case "concurrency_group":
+			logger.Trace().Msg("parsing stack concurrency group.")
+			if attrVal.Type() != cty.String {
+				errs.Append(hclAttrErr(attr,
+					"field stack.concurrency_group must be a string but given %q",
+					attrVal.Type().FriendlyName(),
+				))
+				continue
+			}
+			stack.ConcurrencyGroup = attrVal.AsString()
+
+			// The `tags`, `after`, `before`, `wants`, `wanted_by`, `watch` and
+			// `var_files` have all the same parsing rules.
 			// By the spec, they must be a `set(string)`.
 
 			// In order to speed up the tests, only the `after` attribute is
@@ -701,6 +799,23 @@ func (p *TerramateParser) parseStack(stackblock *ast.Block) (*Stack, error) {
 		case "watch":
 			errs.Append(assignSet(attr.Name, &stack.Watch, attrVal))
 
+		case "var_files":
+			errs.Append(assignSet(attr.Name, &stack.VarFiles, attrVal))
+
+		case "owner":
+			if attrVal.Type() == cty.String {
+				stack.Owners = []string{attrVal.AsString()}
+				continue
+			}
+			if !attrVal.Type().IsTupleType() && !attrVal.Type().IsListType() {
+				errs.Append(hclAttrErr(attr,
+					"field stack.owner must be a string or a set(string) but given %q",
+					attrVal.Type().FriendlyName(),
+				))
+				continue
+			}
+			errs.Append(assignSet(attr.Name, &stack.Owners, attrVal))
+
 		default:
 			errs.Append(errors.E(
 				attr.NameRange, "unrecognized attribute stack.%q", attr.Name,
@@ -747,13 +862,13 @@ func (c Config) AbsDir() string { return c.absdir }
 func (c Config) IsEmpty() bool {
 	return c.Stack == nil && c.Terramate == nil &&
 		c.Vendor == nil && len(c.Asserts) == 0 &&
-		len(c.Globals) == 0 &&
+		len(c.Globals) == 0 && len(c.ConditionalGlobals) == 0 &&
 		len(c.Generate.Files) == 0 && len(c.Generate.HCLs) == 0
 }
 
 // HasGlobals tells if the configuration has any globals defined.
 func (c Config) HasGlobals() bool {
-	return len(c.Globals) > 0
+	return len(c.Globals) > 0 || len(c.ConditionalGlobals) > 0
 }
 
 // Save the configuration file using filename inside config directory.
@@ -852,6 +967,12 @@ func parseGenerateHCLBlock(block *ast.Block) (GenHCLBlock, error) {
 		}
 	}
 
+	outdir, err := parseGenerateOutDir("generate_hcl", block)
+	errs.Append(err)
+
+	onChangeOnly, err := parseGenerateOnChangeOnly("generate_hcl", block)
+	errs.Append(err)
+
 	if err := errs.AsError(); err != nil {
 		return GenHCLBlock{}, err
 	}
@@ -864,10 +985,13 @@ func parseGenerateHCLBlock(block *ast.Block) (GenHCLBlock, error) {
 	return GenHCLBlock{
 		Range:     block.Range,
 		Label:     block.Labels[0],
+		OutDir:    outdir,
 		Lets:      lets,
 		Asserts:   asserts,
 		Content:   content,
 		Condition: block.Body.Attributes["condition"],
+
+		OnChangeOnly: onChangeOnly,
 	}, nil
 }
 
@@ -913,6 +1037,24 @@ func parseGenerateFileBlock(block *ast.Block) (GenFileBlock, error) {
 		}
 	}
 
+	outdir, err := parseGenerateOutDir("generate_file", block)
+	errs.Append(err)
+
+	if outdir != "" && context != "stack" {
+		errs.Append(errors.E(ErrTerramateSchema,
+			block.Body.Attributes["outdir"].NameRange,
+			"generate_file.outdir is only supported with context=stack"))
+	}
+
+	onChangeOnly, err := parseGenerateOnChangeOnly("generate_file", block)
+	errs.Append(err)
+
+	if onChangeOnly && context != "stack" {
+		errs.Append(errors.E(ErrTerramateSchema,
+			block.Body.Attributes["on_change_only"].NameRange,
+			"generate_file.on_change_only is only supported with context=stack"))
+	}
+
 	mergedLets := ast.MergedLabelBlocks{}
 	for labelType, mergedBlock := range letsConfig.MergedLabelBlocks {
 		if labelType.Type == "lets" {
@@ -934,14 +1076,76 @@ func parseGenerateFileBlock(block *ast.Block) (GenFileBlock, error) {
 	return GenFileBlock{
 		Range:     block.Range,
 		Label:     block.Labels[0],
+		OutDir:    outdir,
 		Lets:      lets,
 		Asserts:   asserts,
 		Content:   block.Body.Attributes["content"],
+		Source:    block.Body.Attributes["source"],
+		Template:  block.Body.Attributes["template"],
+		Format:    block.Body.Attributes["format"],
 		Condition: block.Body.Attributes["condition"],
 		Context:   context,
+
+		OnChangeOnly: onChangeOnly,
 	}, nil
 }
 
+// parseGenerateOutDir parses the optional outdir attribute of generate blocks.
+// The outdir must be a literal string with a path relative to the stack
+// directory, which is returned cleaned. It returns an empty string if the
+// attribute is not set.
+func parseGenerateOutDir(blockname string, block *ast.Block) (string, error) {
+	attr, ok := block.Body.Attributes["outdir"]
+	if !ok {
+		return "", nil
+	}
+
+	val, diags := attr.Expr.Value(nil)
+	if diags.HasErrors() {
+		return "", errors.E(ErrTerramateSchema, attr.Expr.Range(),
+			"%s.outdir must be a literal string", blockname)
+	}
+	if val.Type() != cty.String || val.IsNull() {
+		return "", errors.E(ErrTerramateSchema, attr.Expr.Range(),
+			"%s.outdir must be a string but given %s",
+			blockname, val.Type().FriendlyName())
+	}
+
+	outdir := val.AsString()
+	switch {
+	case outdir == "":
+		return "", errors.E(ErrTerramateSchema, attr.Expr.Range(),
+			"%s.outdir can't be empty", blockname)
+	case path.IsAbs(outdir):
+		return "", errors.E(ErrTerramateSchema, attr.Expr.Range(),
+			"%s.outdir must be relative to the stack directory but given %q",
+			blockname, outdir)
+	}
+	return path.Clean(outdir), nil
+}
+
+// parseGenerateOnChangeOnly parses the optional on_change_only attribute of
+// generate blocks, which must be a literal boolean. It returns false if the
+// attribute is not set.
+func parseGenerateOnChangeOnly(blockname string, block *ast.Block) (bool, error) {
+	attr, ok := block.Body.Attributes["on_change_only"]
+	if !ok {
+		return false, nil
+	}
+
+	val, diags := attr.Expr.Value(nil)
+	if diags.HasErrors() {
+		return false, errors.E(ErrTerramateSchema, attr.Expr.Range(),
+			"%s.on_change_only must be a literal boolean", blockname)
+	}
+	if val.Type() != cty.Bool || val.IsNull() {
+		return false, errors.E(ErrTerramateSchema, attr.Expr.Range(),
+			"%s.on_change_only must be a boolean but given %s",
+			blockname, val.Type().FriendlyName())
+	}
+	return val.True(), nil
+}
+
 func validateImportBlock(block *ast.Block) error {
 	errs := errors.L()
 	if len(block.Labels) != 0 {
@@ -992,6 +1196,14 @@ func validateGenerateHCLBlock(block *ast.Block) error {
 				Name:     "condition",
 				Required: false,
 			},
+			{
+				Name:     "outdir",
+				Required: false,
+			},
+			{
+				Name:     "on_change_only",
+				Required: false,
+			},
 		},
 		Blocks: []hcl.BlockHeaderSchema{
 			{
@@ -1050,7 +1262,19 @@ func validateGenerateFileBlock(block *ast.Block) error {
 		Attributes: []hcl.AttributeSchema{
 			{
 				Name:     "content",
-				Required: true,
+				Required: false,
+			},
+			{
+				Name:     "source",
+				Required: false,
+			},
+			{
+				Name:     "template",
+				Required: false,
+			},
+			{
+				Name:     "format",
+				Required: false,
 			},
 			{
 				Name:     "condition",
@@ -1060,6 +1284,14 @@ func validateGenerateFileBlock(block *ast.Block) error {
 				Name:     "context",
 				Required: false,
 			},
+			{
+				Name:     "outdir",
+				Required: false,
+			},
+			{
+				Name:     "on_change_only",
+				Required: false,
+			},
 		},
 		Blocks: []hcl.BlockHeaderSchema{
 			{
@@ -1077,6 +1309,31 @@ func validateGenerateFileBlock(block *ast.Block) error {
 	if diags.HasErrors() {
 		errs.Append(errors.E(ErrTerramateSchema, diags))
 	}
+
+	content, hasContent := block.Body.Attributes["content"]
+	_, hasSource := block.Body.Attributes["source"]
+	template, hasTemplate := block.Body.Attributes["template"]
+	format, hasFormat := block.Body.Attributes["format"]
+
+	switch {
+	case hasContent && hasSource:
+		errs.Append(errors.E(ErrTerramateSchema, content.NameRange,
+			"generate_file.content conflicts with generate_file.source"))
+	case !hasContent && !hasSource:
+		errs.Append(errors.E(ErrTerramateSchema, block.OpenBraceRange,
+			"generate_file requires either a content or a source attribute"))
+	}
+
+	if hasTemplate && !hasSource {
+		errs.Append(errors.E(ErrTerramateSchema, template.NameRange,
+			"generate_file.template requires the source attribute"))
+	}
+
+	if hasFormat && !hasContent {
+		errs.Append(errors.E(ErrTerramateSchema, format.NameRange,
+			"generate_file.format requires the content attribute"))
+	}
+
 	err := errs.AsError()
 	if err != nil {
 		return err
@@ -1268,6 +1525,8 @@ func parseAssertConfig(assert *ast.Block) (AssertConfig, error) {
 			cfg.Message = attr.Expr
 		case "warning":
 			cfg.Warning = attr.Expr
+		case "severity":
+			cfg.Severity = attr.Expr
 		default:
 			errs.Append(errors.E(ErrTerramateSchema, attr.NameRange,
 				"unrecognized attribute %s.%s", assert.Type, attr.Name,
@@ -1285,6 +1544,11 @@ func parseAssertConfig(assert *ast.Block) (AssertConfig, error) {
 			"assert.message is required"))
 	}
 
+	if cfg.Warning != nil && cfg.Severity != nil {
+		errs.Append(errors.E(ErrTerramateSchema, assert.Range,
+			"assert.warning and assert.severity are mutually exclusive"))
+	}
+
 	if err := errs.AsError(); err != nil {
 		return AssertConfig{}, err
 	}
@@ -1392,7 +1656,7 @@ func parseRootConfig(cfg *RootConfig, block *ast.MergedBlock) error {
 		))
 	}
 
-	errs.AppendWrap(ErrTerramateSchema, block.ValidateSubBlocks("git", "run"))
+	errs.AppendWrap(ErrTerramateSchema, block.ValidateSubBlocks("git", "run", "triggers", "change_detection"))
 
 	gitBlock, ok := block.Blocks[ast.NewEmptyLabelBlockType("git")]
 	if ok {
@@ -1418,6 +1682,143 @@ func parseRootConfig(cfg *RootConfig, block *ast.MergedBlock) error {
 		errs.Append(parseRunConfig(cfg.Run, runBlock))
 	}
 
+	triggersBlock, ok := block.Blocks[ast.NewEmptyLabelBlockType("triggers")]
+	if ok {
+		logger.Trace().Msg("Type is 'triggers'")
+
+		cfg.Triggers = &TriggersConfig{}
+
+		logger.Trace().Msg("Parse triggers config.")
+
+		errs.Append(parseTriggersConfig(cfg.Triggers, triggersBlock))
+	}
+
+	changeDetectionBlock, ok := block.Blocks[ast.NewEmptyLabelBlockType("change_detection")]
+	if ok {
+		logger.Trace().Msg("Type is 'change_detection'")
+
+		cfg.ChangeDetection = &ChangeDetectionConfig{}
+
+		logger.Trace().Msg("Parse change_detection config.")
+
+		errs.Append(parseChangeDetectionConfig(cfg.ChangeDetection, changeDetectionBlock))
+	}
+
+	return errs.AsError()
+}
+
+func parseChangeDetectionConfig(changeDetection *ChangeDetectionConfig, block *ast.MergedBlock) error {
+	errs := errors.L()
+
+	errs.AppendWrap(ErrTerramateSchema, block.ValidateSubBlocks())
+
+	for _, attr := range block.Attributes.SortedList() {
+		value, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() {
+			errs.Append(errors.E(diags,
+				"failed to evaluate terramate.config.change_detection.%s attribute", attr.Name,
+			))
+			continue
+		}
+
+		switch attr.Name {
+		case "ignore":
+			if !value.Type().IsTupleType() && !value.Type().IsListType() {
+				errs.Append(attrErr(attr,
+					"terramate.config.change_detection.ignore must be a list(string) but given %q",
+					value.Type().FriendlyName(),
+				))
+				continue
+			}
+
+			var patterns []string
+			it := value.ElementIterator()
+			for it.Next() {
+				_, elem := it.Element()
+				if elem.Type() != cty.String {
+					errs.Append(attrErr(attr,
+						"terramate.config.change_detection.ignore must be a list(string) "+
+							"but element has type %q",
+						elem.Type().FriendlyName(),
+					))
+					continue
+				}
+				if strings.TrimSpace(elem.AsString()) == "" {
+					errs.Append(attrErr(attr,
+						"terramate.config.change_detection.ignore must not have empty patterns",
+					))
+					continue
+				}
+				patterns = append(patterns, elem.AsString())
+			}
+			changeDetection.Ignore = patterns
+
+		default:
+			errs.Append(errors.E(
+				attr.NameRange,
+				"unrecognized attribute terramate.config.change_detection.%s",
+				attr.Name,
+			))
+		}
+	}
+	return errs.AsError()
+}
+
+func parseTriggersConfig(triggers *TriggersConfig, triggersBlock *ast.MergedBlock) error {
+	errs := errors.L()
+
+	errs.AppendWrap(ErrTerramateSchema, triggersBlock.ValidateSubBlocks())
+
+	for _, attr := range triggersBlock.Attributes.SortedList() {
+		value, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() {
+			errs.Append(errors.E(diags,
+				"failed to evaluate terramate.config.triggers.%s attribute", attr.Name,
+			))
+			continue
+		}
+
+		switch attr.Name {
+		case "dir":
+			if value.Type() != cty.String {
+				errs.Append(attrErr(attr,
+					"terramate.config.triggers.dir is not a string but %q",
+					value.Type().FriendlyName(),
+				))
+				continue
+			}
+
+			// the dir is always relative to the project root.
+			dir := path.Clean("/" + value.AsString())
+			if dir == "/" {
+				errs.Append(attrErr(attr,
+					"terramate.config.triggers.dir must not be the project root",
+				))
+				continue
+			}
+
+			// trigger files are not Terramate configuration, then the
+			// directory must be ignored when loading the configuration.
+			components := strings.Split(dir[1:], "/")
+			if !strings.HasPrefix(components[0], ".") {
+				errs.Append(attrErr(attr,
+					"terramate.config.triggers.dir must be inside a hidden "+
+						"directory (starting with a dot) but given %q",
+					value.AsString(),
+				))
+				continue
+			}
+
+			triggers.Dir = dir
+
+		default:
+			errs.Append(errors.E(
+				attr.NameRange,
+				"unrecognized attribute terramate.config.triggers.%s",
+				attr.Name,
+			))
+		}
+	}
 	return errs.AsError()
 }
 
@@ -1430,6 +1831,12 @@ func parseRunConfig(runCfg *RunConfig, runBlock *ast.MergedBlock) error {
 
 	errs := errors.L()
 	for _, attr := range runBlock.Attributes.SortedList() {
+		if attr.Name == "command" {
+			attr := attr
+			runCfg.Command = &attr
+			continue
+		}
+
 		value, diags := attr.Expr.Value(nil)
 		if diags.HasErrors() {
 			errs.Append(errors.E(diags,
@@ -1658,6 +2065,15 @@ func (p *TerramateParser) parseTerramateSchema() (Config, error) {
 				config.Generate.HCLs = append(config.Generate.HCLs, genhcl)
 			}
 
+		case "conditional_globals":
+			logger.Trace().Msg("Found \"conditional_globals\" block")
+
+			globals, err := parseConditionalGlobals(block)
+			errs.Append(err)
+			if err == nil {
+				config.ConditionalGlobals = append(config.ConditionalGlobals, globals)
+			}
+
 		case "generate_file":
 			logger.Trace().Msg("Found \"generate_file\" block")
 
@@ -1764,21 +2180,68 @@ func (p *TerramateParser) checkConfigSanity(cfg Config) error {
 	return nil
 }
 
+// parseConditionalGlobals parses a conditional_globals block as a standalone
+// merged block.
+func parseConditionalGlobals(block *ast.Block) (*ast.MergedBlock, error) {
+	if _, ok := block.Attributes[GlobalsConditionAttr]; !ok {
+		return nil, errors.E(ErrTerramateSchema, block.DefRange(),
+			"%s.%s attribute is required", block.Type, GlobalsConditionAttr)
+	}
+	if _, err := ast.NewLabelBlockType(block.Type, block.Labels); err != nil {
+		return nil, errors.E(ErrTerramateSchema, err)
+	}
+	merged := ast.NewMergedBlock(block.Type, block.Labels)
+	if err := merged.MergeBlock(block, true); err != nil {
+		return nil, errors.E(ErrTerramateSchema, err)
+	}
+	if err := validateGlobals(merged); err != nil {
+		return nil, errors.E(ErrTerramateSchema, err)
+	}
+	return merged, nil
+}
+
 func validateGlobals(block *ast.MergedBlock) error {
 	errs := errors.L()
-	if block.Type != "globals" {
+	if block.Type != "globals" && block.Type != ConditionalGlobalsBlockType {
 		return errors.E(ErrTerramateSchema,
 			block.RawOrigins[0].TypeRange, "unexpected block type %q", block.Type)
 	}
-	errs.Append(block.ValidateSubBlocks("map"))
+	errs.Append(block.ValidateSubBlocks("map", GlobalsFromFileBlockType))
 	for _, raw := range block.RawOrigins {
 		for _, subBlock := range raw.Blocks {
+			if subBlock.Type == GlobalsFromFileBlockType {
+				errs.Append(validateFromFile(subBlock))
+				continue
+			}
 			errs.Append(validateMap(subBlock))
 		}
 	}
 	return errs.AsError()
 }
 
+func validateFromFile(block *ast.Block) error {
+	errs := errors.L()
+	if len(block.Labels) > 0 {
+		errs.Append(errors.E(ErrTerramateSchema, block.LabelRanges(),
+			"%s block does not support labels", block.Type))
+	}
+	if _, ok := block.Attributes[GlobalsFromFilePathAttr]; !ok {
+		errs.Append(errors.E(ErrTerramateSchema, block.DefRange(),
+			"%s.%s attribute is required", block.Type, GlobalsFromFilePathAttr))
+	}
+	for _, attr := range block.Attributes.SortedList() {
+		if attr.Name != GlobalsFromFilePathAttr {
+			errs.Append(errors.E(ErrTerramateSchema, attr.NameRange,
+				"unrecognized attribute %s.%s", block.Type, attr.Name))
+		}
+	}
+	for _, subBlock := range block.Blocks {
+		errs.Append(errors.E(ErrTerramateSchema, subBlock.DefRange(),
+			"unrecognized block %q inside %s block", subBlock.Type, block.Type))
+	}
+	return errs.AsError()
+}
+
 func validateMap(block *ast.Block) (err error) {
 	if block.Type != "map" {
 		return errors.E(block.TypeRange,
@@ -1863,6 +2326,7 @@ func parseTerramateBlock(block *ast.MergedBlock) (Terramate, error) {
 			}
 			foundReqVersion = true
 			tm.RequiredVersion = value.AsString()
+			tm.RequiredVersionRange = attr.Expr.Range()
 
 		case "required_version_allow_prereleases":
 			logger.Trace().Msg("Parsing  attribute 'required_version_allow_prereleases'.")
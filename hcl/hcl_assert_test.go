@@ -73,6 +73,51 @@ func TestHCLParserAssert(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "assert with severity",
+			input: []cfgfile{
+				{
+					filename: "assert.tm",
+					body: Assert(
+						Str("severity", "info"),
+						Expr("assertion", "1 == 1"),
+						Expr("message", "global.message"),
+					).String(),
+				},
+			},
+			want: want{
+				config: hcl.Config{
+					Asserts: []hcl.AssertConfig{
+						{
+							Assertion: expr(t, "1 == 1"),
+							Message:   expr(t, "global.message"),
+							Severity:  expr(t, `"info"`),
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "warning and severity are mutually exclusive",
+			input: []cfgfile{
+				{
+					filename: "assert.tm",
+					body: Assert(
+						Expr("warning", "true"),
+						Str("severity", "warn"),
+						Expr("assertion", "1 == 1"),
+						Expr("message", "global.message"),
+					).String(),
+				},
+			},
+			want: want{
+				errs: []error{
+					errors.E(hcl.ErrTerramateSchema,
+						Mkrange("assert.tm", Start(1, 1, 0), End(6, 2, 100)),
+					),
+				},
+			},
+		},
 		{
 			name: "multiple asserts on same file",
 			input: []cfgfile{
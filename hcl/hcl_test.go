@@ -560,6 +560,177 @@ func TestHCLParserRootConfig(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "config.triggers.dir is set",
+			input: []cfgfile{
+				{
+					filename: "cfg.tm",
+					body: `
+						terramate {
+							config {
+								triggers {
+									dir = ".ci/triggers/"
+								}
+							}
+						}
+					`,
+				},
+			},
+			want: want{
+				config: hcl.Config{
+					Terramate: &hcl.Terramate{
+						Config: &hcl.RootConfig{
+							Triggers: &hcl.TriggersConfig{
+								Dir: "/.ci/triggers",
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "config.triggers.dir must be a hidden dir",
+			input: []cfgfile{
+				{
+					filename: "cfg.tm",
+					body: `
+						terramate {
+							config {
+								triggers {
+									dir = "ci/.triggers"
+								}
+							}
+						}
+					`,
+				},
+			},
+			want: want{
+				errs: []error{
+					errors.E(hcl.ErrTerramateSchema,
+						Mkrange("cfg.tm", Start(5, 16, 69), End(5, 30, 83))),
+				},
+			},
+		},
+		{
+			name: "config.triggers.dir must be a string",
+			input: []cfgfile{
+				{
+					filename: "cfg.tm",
+					body: `
+						terramate {
+							config {
+								triggers {
+									dir = 1
+								}
+							}
+						}
+					`,
+				},
+			},
+			want: want{
+				errs: []error{
+					errors.E(hcl.ErrTerramateSchema,
+						Mkrange("cfg.tm", Start(5, 16, 69), End(5, 17, 70))),
+				},
+			},
+		},
+		{
+			name: "config.change_detection.ignore is set",
+			input: []cfgfile{
+				{
+					filename: "cfg.tm",
+					body: `
+						terramate {
+							config {
+								change_detection {
+									ignore = ["**/.terraform/", "*.zip"]
+								}
+							}
+						}
+					`,
+				},
+			},
+			want: want{
+				config: hcl.Config{
+					Terramate: &hcl.Terramate{
+						Config: &hcl.RootConfig{
+							ChangeDetection: &hcl.ChangeDetectionConfig{
+								Ignore: []string{"**/.terraform/", "*.zip"},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "config.change_detection.ignore must be a list",
+			input: []cfgfile{
+				{
+					filename: "cfg.tm",
+					body: `
+						terramate {
+							config {
+								change_detection {
+									ignore = 1
+								}
+							}
+						}
+					`,
+				},
+			},
+			want: want{
+				errs: []error{
+					errors.E(hcl.ErrTerramateSchema,
+						Mkrange("cfg.tm", Start(5, 19, 80), End(5, 20, 81))),
+				},
+			},
+		},
+		{
+			name: "config.change_detection.ignore must be a list of strings",
+			input: []cfgfile{
+				{
+					filename: "cfg.tm",
+					body: `
+						terramate {
+							config {
+								change_detection {
+									ignore = ["a", 1]
+								}
+							}
+						}
+					`,
+				},
+			},
+			want: want{
+				errs: []error{
+					errors.E(hcl.ErrTerramateSchema,
+						Mkrange("cfg.tm", Start(5, 19, 80), End(5, 27, 88))),
+				},
+			},
+		},
+		{
+			name: "config.change_detection.ignore must not have empty patterns",
+			input: []cfgfile{
+				{
+					filename: "cfg.tm",
+					body: `
+						terramate {
+							config {
+								change_detection {
+									ignore = [" "]
+								}
+							}
+						}
+					`,
+				},
+			},
+			want: want{
+				errs: []error{
+					errors.E(hcl.ErrTerramateSchema,
+						Mkrange("cfg.tm", Start(5, 19, 80), End(5, 24, 85))),
+				},
+			},
+		},
 	} {
 		testParser(t, tc)
 	}
@@ -795,6 +966,7 @@ func TestHCLParserTerramateBlocksMerging(t *testing.T) {
 							wants = ["wants"]
 							wanted_by = ["wanted"]
 							watch = ["watch"]
+							var_files = ["vars.tfvars"]
 						}
 					`,
 				},
@@ -820,6 +992,7 @@ func TestHCLParserTerramateBlocksMerging(t *testing.T) {
 						Wants:       []string{"wants"},
 						WantedBy:    []string{"wanted"},
 						Watch:       []string{"watch"},
+						VarFiles:    []string{"vars.tfvars"},
 					},
 				},
 			},
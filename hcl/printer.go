@@ -110,6 +110,20 @@ func PrintConfig(w io.Writer, cfg Config) error {
 			stackBody.SetAttributeValue("watch", cty.SetVal(listToValue(stack.Watch)))
 		}
 
+		if len(stack.VarFiles) > 0 {
+			stackBody.SetAttributeValue("var_files", cty.SetVal(listToValue(stack.VarFiles)))
+		}
+
+		if len(stack.Owners) == 1 {
+			stackBody.SetAttributeValue("owner", cty.StringVal(stack.Owners[0]))
+		} else if len(stack.Owners) > 1 {
+			stackBody.SetAttributeValue("owner", cty.SetVal(listToValue(stack.Owners)))
+		}
+
+		if stack.ConcurrencyGroup != "" {
+			stackBody.SetAttributeValue("concurrency_group", cty.StringVal(stack.ConcurrencyGroup))
+		}
+
 		if stack.ID != "" {
 			stackBody.SetAttributeValue("id", cty.StringVal(stack.ID))
 		}
@@ -16,6 +16,9 @@
 package lets
 
 import (
+	"sort"
+	"strings"
+
 	hhcl "github.com/hashicorp/hcl/v2"
 	"github.com/mineiros-io/terramate/errors"
 	"github.com/mineiros-io/terramate/hcl/ast"
@@ -29,8 +32,9 @@ import (
 
 // Errors returned when parsing and evaluating lets.
 const (
-	ErrEval      errors.Kind = "lets eval"
-	ErrRedefined errors.Kind = "lets redefined"
+	ErrEval          errors.Kind = "lets eval"
+	ErrRedefined     errors.Kind = "lets redefined"
+	ErrMaxIterations errors.Kind = "lets max iterations exceeded"
 )
 
 type (
@@ -57,20 +61,53 @@ type (
 	Map map[string]Value
 )
 
-// Load loads all the lets from the hcl blocks.
-func Load(letblock *ast.MergedBlock, ctx *eval.Context) error {
+// unsetMark marks the value of the unset keyword while evaluating lets.
+type unsetMark struct{}
+
+// unsetVal is the value of the unset keyword while evaluating lets. It is a
+// marked dynamic null, so it can be used in any expression accepting values of
+// any type (eg.: conditionals). Object attributes and map entries set to it
+// are removed from the evaluated lets.
+var unsetVal = cty.NullVal(cty.DynamicPseudoType).Mark(unsetMark{})
+
+// Load loads all the lets from the hcl blocks. The evaluated lets are set in
+// the let namespace of ctx and also returned, so the origin of each let can be
+// inspected.
+func Load(letblock *ast.MergedBlock, ctx *eval.Context) (Map, error) {
 	exprs, err := loadExprs(letblock)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return exprs.Eval(ctx)
+	return exprs.evalMaxIterations(ctx, DefaultMaxIterations(len(exprs)))
+}
+
+// DefaultMaxIterations returns the default maximum number of evaluation
+// iterations for the given number of lets. Each iteration evaluates at least
+// one let or stops the evaluation, then n lets never need more than n+1
+// iterations.
+func DefaultMaxIterations(n int) int {
+	return n + 1
 }
 
-// Eval evaluates all lets expressions and returns an EvalReport..
+// Eval evaluates all lets expressions using the [DefaultMaxIterations] cap.
 func (letExprs Exprs) Eval(ctx *eval.Context) error {
+	return letExprs.EvalMaxIterations(ctx, DefaultMaxIterations(len(letExprs)))
+}
+
+// EvalMaxIterations evaluates all lets expressions, failing with an error of
+// kind [ErrMaxIterations] if the evaluation doesn't finish in maxIterations
+// iterations. The lets are evaluated in alphabetical order on each iteration
+// and the returned errors are reported in the same order.
+func (letExprs Exprs) EvalMaxIterations(ctx *eval.Context, maxIterations int) error {
+	_, err := letExprs.evalMaxIterations(ctx, maxIterations)
+	return err
+}
+
+func (letExprs Exprs) evalMaxIterations(ctx *eval.Context, maxIterations int) (Map, error) {
 	logger := log.With().
 		Str("action", "Exprs.Eval()").
+		Int("maxIterations", maxIterations).
 		Logger()
 
 	lets := Map{}
@@ -84,13 +121,25 @@ func (letExprs Exprs) Eval(ctx *eval.Context) error {
 		ctx.SetNamespace("let", map[string]cty.Value{})
 	}
 
+	ctx.Unwrap().Variables["unset"] = unsetVal
+	defer ctx.DeleteNamespace("unset")
+
+	iterations := 0
 	for len(pendingExprs) > 0 {
+		iterations++
+		if iterations > maxIterations {
+			return nil, errors.E(ErrMaxIterations,
+				"lets evaluation exceeded %d iterations with %d pending lets",
+				maxIterations, len(pendingExprs))
+		}
+
 		amountEvaluated := 0
 
 		logger.Trace().Msg("evaluating pending expressions")
 
 	pendingExpression:
-		for name, expr := range pendingExprs {
+		for _, name := range pendingExprs.sortedNames() {
+			expr := pendingExprs[name]
 			logger := logger.With().
 				Stringer("origin", expr.Origin.Path()).
 				Str("let", name).
@@ -101,6 +150,9 @@ func (letExprs Exprs) Eval(ctx *eval.Context) error {
 
 			logger.Trace().Msg("checking var access inside expression")
 
+			// any namespace already set on the context (eg.: global and
+			// terramate) can be used, only the let namespace is evaluated
+			// here, then only references to pending lets defer evaluation.
 			for _, namespace := range vars {
 				if !ctx.HasNamespace(namespace.RootName()) {
 					pendingExprsErrs[name].Append(errors.E(
@@ -108,39 +160,50 @@ func (letExprs Exprs) Eval(ctx *eval.Context) error {
 						namespace.SourceRange(),
 						"unknown variable namespace: %s", namespace.RootName(),
 					))
-
-					continue
 				}
+			}
 
-				if namespace.RootName() != "let" {
-					continue
-				}
+			if err := pendingExprsErrs[name].AsError(); err != nil {
+				continue
+			}
 
-				switch attr := namespace[1].(type) {
-				case hhcl.TraverseAttr:
-					if _, isPending := pendingExprs[attr.Name]; isPending {
-						continue pendingExpression
-					}
-				default:
-					panic("unexpected type of traversal - this is a BUG")
-				}
+			deps, all, err := letReferences(expr)
+			if err != nil {
+				pendingExprsErrs[name].Append(err)
+				continue
 			}
 
-			if err := pendingExprsErrs[name].AsError(); err != nil {
+			// the whole let namespace can only be used after all the other
+			// lets are evaluated.
+			if all && len(pendingExprs) > 1 {
 				continue
 			}
 
+			for _, dep := range deps {
+				if _, isPending := pendingExprs[dep]; isPending {
+					continue pendingExpression
+				}
+			}
+
 			logger.Trace().Msg("evaluating expression")
 
 			val, err := ctx.Eval(expr)
+			if err == nil && !isUnset(val) {
+				val, err = removeUnsetValues(val)
+			}
 			if err != nil {
 				pendingExprsErrs[name].Append(errors.E(ErrEval, err, "let.%s", name))
 				continue
 			}
 
-			lets[name] = Value{
-				Origin: expr.Origin,
-				Value:  val,
+			if isUnset(val) {
+				logger.Trace().Msg("let evaluated to unset")
+				delete(lets, name)
+			} else {
+				lets[name] = Value{
+					Origin: expr.Origin,
+					Value:  val,
+				}
 			}
 
 			amountEvaluated++
@@ -159,15 +222,165 @@ func (letExprs Exprs) Eval(ctx *eval.Context) error {
 	}
 
 	errs := errors.L()
-	for name, expr := range pendingExprs {
+
+	inCycle := map[string]bool{}
+	for _, cycle := range pendingCycles(pendingExprs, pendingExprsErrs) {
+		names := make([]string, len(cycle))
+		for i, name := range cycle {
+			inCycle[name] = true
+			names[i] = "let." + name
+		}
+		expr := pendingExprs[cycle[0]]
+		if len(names) == 1 {
+			errs.Append(errors.E(ErrEval, expr.Range(),
+				"cycle detected: %s references itself", names[0]))
+			continue
+		}
+		errs.Append(errors.E(ErrEval, expr.Range(),
+			"cycle detected between %s and %s",
+			strings.Join(names[:len(names)-1], ", "), names[len(names)-1]))
+	}
+
+	for _, name := range pendingExprs.sortedNames() {
+		if inCycle[name] {
+			continue
+		}
 		err := pendingExprsErrs[name].AsError()
 		if err == nil {
-			err = errors.E(expr.Range(), "undefined let %s", name)
+			err = errors.E(pendingExprs[name].Range(), "undefined let %s", name)
 		}
 		errs.AppendWrap(ErrEval, err)
 	}
 
-	return errs.AsError()
+	if err := errs.AsError(); err != nil {
+		return nil, err
+	}
+	return lets, nil
+}
+
+// pendingCycles returns the dependency cycles of the pending lets which
+// failed to evaluate only because they depend on other pending lets. Each
+// cycle is the sorted list of its lets and the cycles are sorted by their
+// first let.
+func pendingCycles(pending Exprs, pendingErrs map[string]*errors.List) [][]string {
+	deps := map[string][]string{}
+	for _, name := range pending.sortedNames() {
+		if err := pendingErrs[name].AsError(); err != nil {
+			continue
+		}
+		refs, all, err := letReferences(pending[name])
+		if err != nil {
+			continue
+		}
+		if all {
+			// the whole let namespace waits for all the other lets.
+			refs = nil
+			for _, other := range pending.sortedNames() {
+				if other != name {
+					refs = append(refs, other)
+				}
+			}
+		}
+		for _, ref := range refs {
+			if _, ok := pending[ref]; ok {
+				deps[name] = append(deps[name], ref)
+			}
+		}
+	}
+
+	reaches := func(from, to string) bool {
+		visited := map[string]bool{}
+		stack := append([]string{}, deps[from]...)
+		for len(stack) > 0 {
+			name := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if name == to {
+				return true
+			}
+			if visited[name] {
+				continue
+			}
+			visited[name] = true
+			stack = append(stack, deps[name]...)
+		}
+		return false
+	}
+
+	var cycles [][]string
+	visited := map[string]bool{}
+	for _, name := range pending.sortedNames() {
+		if visited[name] || !reaches(name, name) {
+			continue
+		}
+		cycle := []string{name}
+		visited[name] = true
+		for _, other := range pending.sortedNames() {
+			if !visited[other] && reaches(name, other) && reaches(other, name) {
+				cycle = append(cycle, other)
+				visited[other] = true
+			}
+		}
+		cycles = append(cycles, cycle)
+	}
+	return cycles
+}
+
+// UnusedLets returns the names of the lets which are not referenced by the
+// usedBy expressions (eg.: the content of a generate block), directly or
+// through other lets referenced by them. Unset lets are never reported and
+// a reference to the whole let namespace (eg.: a dynamic index) marks all
+// lets as used. The names are returned sorted.
+func (letExprs Exprs) UnusedLets(usedBy ...hhcl.Expression) ([]string, error) {
+	exprs := make(Exprs)
+	copyexprs(exprs, letExprs)
+	removeUnset(exprs)
+
+	used := map[string]struct{}{}
+	var pending []string
+
+	markUsed := func(expr hhcl.Expression) error {
+		names, all, err := letReferences(expr)
+		if err != nil {
+			return err
+		}
+		if all {
+			names = exprs.sortedNames()
+		}
+		for _, name := range names {
+			if _, ok := used[name]; ok {
+				continue
+			}
+			if _, ok := exprs[name]; !ok {
+				// undefined lets are reported when evaluating.
+				continue
+			}
+			used[name] = struct{}{}
+			pending = append(pending, name)
+		}
+		return nil
+	}
+
+	for _, expr := range usedBy {
+		if err := markUsed(expr); err != nil {
+			return nil, err
+		}
+	}
+
+	for len(pending) > 0 {
+		name := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if err := markUsed(exprs[name]); err != nil {
+			return nil, errors.E(err, "let.%s", name)
+		}
+	}
+
+	var unused []string
+	for _, name := range exprs.sortedNames() {
+		if _, ok := used[name]; !ok {
+			unused = append(unused, name)
+		}
+	}
+	return unused, nil
 }
 
 // String provides a string representation of the evaluated lets.
@@ -185,6 +398,53 @@ func (lets Map) Attributes() map[string]cty.Value {
 	return attrcopy
 }
 
+// OriginOf returns the range where the let with the given name is defined.
+// It returns false if there's no such let.
+func (lets Map) OriginOf(name string) (info.Range, bool) {
+	v, ok := lets[name]
+	if !ok {
+		return info.Range{}, false
+	}
+	return v.Origin, true
+}
+
+func (letExprs Exprs) sortedNames() []string {
+	names := make([]string, 0, len(letExprs))
+	for name := range letExprs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// letReferences returns the names of the lets referenced by expr. If expr
+// references the whole let namespace, all is true.
+func letReferences(expr hhcl.Expression) (names []string, all bool, err error) {
+	for _, traversal := range expr.Variables() {
+		if traversal.RootName() != "let" {
+			continue
+		}
+		if len(traversal) == 1 {
+			all = true
+			continue
+		}
+		switch step := traversal[1].(type) {
+		case hhcl.TraverseAttr:
+			names = append(names, step.Name)
+		case hhcl.TraverseIndex:
+			if !step.Key.IsKnown() || step.Key.Type() != cty.String {
+				return nil, false, errors.E(ErrEval, traversal.SourceRange(),
+					"invalid let reference: index must be a string")
+			}
+			names = append(names, step.Key.AsString())
+		default:
+			return nil, false, errors.E(ErrEval, traversal.SourceRange(),
+				"invalid let reference")
+		}
+	}
+	return names, all, nil
+}
+
 func removeUnset(exprs Exprs) {
 	for name, expr := range exprs {
 		traversal, diags := hhcl.AbsTraversalForExpr(expr.Expression)
@@ -200,6 +460,107 @@ func removeUnset(exprs Exprs) {
 	}
 }
 
+// isUnset tells if val is the value of the unset keyword, possibly converted
+// to another type (eg.: an element of a map).
+func isUnset(val cty.Value) bool {
+	if !val.HasMark(unsetMark{}) {
+		return false
+	}
+	val, _ = val.Unmark()
+	return val.IsNull()
+}
+
+// removeUnsetValues removes the object attributes and map entries set to
+// unset from val, recursively. Using unset as an element of lists, tuples or
+// sets is an error. Maps and lists whose elements end up with different
+// types are converted to objects and tuples. The unset marks propagated by
+// operations on values containing unset (eg.: function calls) are removed.
+func removeUnsetValues(val cty.Value) (cty.Value, error) {
+	val, _ = val.Unmark()
+	if !val.IsKnown() || val.IsNull() {
+		return val, nil
+	}
+
+	ty := val.Type()
+	switch {
+	case ty.IsObjectType() || ty.IsMapType():
+		vals := map[string]cty.Value{}
+		it := val.ElementIterator()
+		for it.Next() {
+			key, elem := it.Element()
+			if isUnset(elem) {
+				continue
+			}
+			elem, err := removeUnsetValues(elem)
+			if err != nil {
+				return cty.NilVal, err
+			}
+			vals[key.AsString()] = elem
+		}
+		if ty.IsObjectType() {
+			return cty.ObjectVal(vals), nil
+		}
+		if len(vals) == 0 {
+			return cty.MapValEmpty(ty.ElementType()), nil
+		}
+		elems := make([]cty.Value, 0, len(vals))
+		for _, elem := range vals {
+			elems = append(elems, elem)
+		}
+		if !sameTypes(elems) {
+			// removing unset attributes from the elements of a map of
+			// objects can leave them with different types.
+			return cty.ObjectVal(vals), nil
+		}
+		return cty.MapVal(vals), nil
+
+	case ty.IsTupleType() || ty.IsListType() || ty.IsSetType():
+		var vals []cty.Value
+		it := val.ElementIterator()
+		for it.Next() {
+			_, elem := it.Element()
+			if isUnset(elem) {
+				return cty.NilVal, errors.E("unset is not allowed inside %s values",
+					ty.FriendlyName())
+			}
+			elem, err := removeUnsetValues(elem)
+			if err != nil {
+				return cty.NilVal, err
+			}
+			vals = append(vals, elem)
+		}
+		switch {
+		case len(vals) == 0:
+			return val, nil
+		case ty.IsTupleType():
+			return cty.TupleVal(vals), nil
+		case ty.IsListType():
+			if !sameTypes(vals) {
+				return cty.TupleVal(vals), nil
+			}
+			return cty.ListVal(vals), nil
+		default:
+			if !sameTypes(vals) {
+				return cty.NilVal, errors.E(
+					"removing unset values results in inconsistent %s element types",
+					ty.FriendlyName())
+			}
+			return cty.SetVal(vals), nil
+		}
+	}
+	return val, nil
+}
+
+// sameTypes tells if all the given values have the same type.
+func sameTypes(vals []cty.Value) bool {
+	for _, val := range vals[1:] {
+		if !val.Type().Equals(vals[0].Type()) {
+			return false
+		}
+	}
+	return true
+}
+
 func copyexprs(dst, src Exprs) {
 	for k, v := range src {
 		dst[k] = v
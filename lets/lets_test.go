@@ -0,0 +1,552 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lets_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	hhcl "github.com/hashicorp/hcl/v2"
+	"github.com/madlambda/spells/assert"
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/hcl"
+	"github.com/mineiros-io/terramate/hcl/eval"
+	"github.com/mineiros-io/terramate/lets"
+	"github.com/mineiros-io/terramate/stdlib"
+	"github.com/mineiros-io/terramate/test"
+	"github.com/zclconf/go-cty-debug/ctydebug"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestLetsEvalMaxIterations(t *testing.T) {
+	newExprs := func() lets.Exprs {
+		// each let depends on the next one, then the evaluation requires
+		// one iteration per let.
+		return lets.Exprs{
+			"a": {Expression: test.NewExpr(t, `let.b`)},
+			"b": {Expression: test.NewExpr(t, `let.c`)},
+			"c": {Expression: test.NewExpr(t, `"c"`)},
+		}
+	}
+
+	ctx := eval.NewContext(nil)
+	err := newExprs().EvalMaxIterations(ctx, 2)
+	assert.IsError(t, err, errors.E(lets.ErrMaxIterations))
+
+	ctx = eval.NewContext(nil)
+	assert.NoError(t, newExprs().EvalMaxIterations(ctx, 3))
+
+	ctx = eval.NewContext(nil)
+	assert.NoError(t, newExprs().Eval(ctx))
+
+	got, ok := ctx.GetNamespace("let")
+	assert.IsTrue(t, ok)
+	assert.IsTrue(t, got.GetAttr("a").RawEquals(cty.StringVal("c")))
+}
+
+func TestLetsEvalNamespaces(t *testing.T) {
+	type testcase struct {
+		name    string
+		lets    map[string]string
+		want    map[string]cty.Value
+		wantErr error
+	}
+
+	for _, tc := range []testcase{
+		{
+			name: "lets referencing globals and terramate",
+			lets: map[string]string{
+				"env":  `global.env`,
+				"name": `"${let.env}-${terramate.stack.name}"`,
+			},
+			want: map[string]cty.Value{
+				"env":  cty.StringVal("prod"),
+				"name": cty.StringVal("prod-stack"),
+			},
+		},
+		{
+			name: "lets referenced by index",
+			lets: map[string]string{
+				"a": `let["b"]`,
+				"b": `global.env`,
+			},
+			want: map[string]cty.Value{
+				"a": cty.StringVal("prod"),
+				"b": cty.StringVal("prod"),
+			},
+		},
+		{
+			name: "whole let namespace is evaluated last",
+			lets: map[string]string{
+				"a":   `"a"`,
+				"all": `tm_keys(let)`,
+				"z":   `"z"`,
+			},
+			want: map[string]cty.Value{
+				"a":   cty.StringVal("a"),
+				"all": cty.TupleVal([]cty.Value{cty.StringVal("a"), cty.StringVal("z")}),
+				"z":   cty.StringVal("z"),
+			},
+		},
+		{
+			name: "unknown namespace",
+			lets: map[string]string{
+				"a": `unknown.value`,
+			},
+			wantErr: errors.E(lets.ErrEval),
+		},
+		{
+			name: "invalid let reference",
+			lets: map[string]string{
+				"a": `let[0]`,
+			},
+			wantErr: errors.E(lets.ErrEval),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			exprs := lets.Exprs{}
+			for name, expr := range tc.lets {
+				exprs[name] = lets.Expr{Expression: test.NewExpr(t, expr)}
+			}
+
+			ctx := eval.NewContext(stdlib.Functions(t.TempDir()))
+			ctx.SetNamespace("global", map[string]cty.Value{
+				"env": cty.StringVal("prod"),
+			})
+			ctx.SetNamespace("terramate", map[string]cty.Value{
+				"stack": cty.ObjectVal(map[string]cty.Value{
+					"name": cty.StringVal("stack"),
+				}),
+			})
+
+			err := exprs.Eval(ctx)
+			assert.IsError(t, err, tc.wantErr)
+			if tc.wantErr != nil {
+				return
+			}
+
+			got, ok := ctx.GetNamespace("let")
+			assert.IsTrue(t, ok)
+			for name, want := range tc.want {
+				if !got.GetAttr(name).RawEquals(want) {
+					t.Errorf("let.%s: want %#v but got %#v", name, want, got.GetAttr(name))
+				}
+			}
+		})
+	}
+}
+
+func TestLetsEvalUnset(t *testing.T) {
+	type testcase struct {
+		name    string
+		lets    map[string]string
+		want    map[string]cty.Value
+		wantErr error
+	}
+
+	for _, tc := range []testcase{
+		{
+			name: "unset object attributes are removed",
+			lets: map[string]string{
+				"obj": `{
+					a = "a"
+					b = unset
+					c = global.env == "prod" ? unset : "c"
+				}`,
+			},
+			want: map[string]cty.Value{
+				"obj": cty.ObjectVal(map[string]cty.Value{
+					"a": cty.StringVal("a"),
+				}),
+			},
+		},
+		{
+			name: "unset nested object attributes are removed",
+			lets: map[string]string{
+				"obj": `{
+					a = {
+						b = unset
+						c = {
+							d = unset
+							e = "e"
+						}
+					}
+				}`,
+				"ref": `let.obj.a`,
+			},
+			want: map[string]cty.Value{
+				"obj": cty.ObjectVal(map[string]cty.Value{
+					"a": cty.ObjectVal(map[string]cty.Value{
+						"c": cty.ObjectVal(map[string]cty.Value{
+							"e": cty.StringVal("e"),
+						}),
+					}),
+				}),
+				"ref": cty.ObjectVal(map[string]cty.Value{
+					"c": cty.ObjectVal(map[string]cty.Value{
+						"e": cty.StringVal("e"),
+					}),
+				}),
+			},
+		},
+		{
+			name: "unset map entries are removed",
+			lets: map[string]string{
+				"map": `tm_tomap({
+					a = "a"
+					b = unset
+				})`,
+			},
+			want: map[string]cty.Value{
+				"map": cty.MapVal(map[string]cty.Value{
+					"a": cty.StringVal("a"),
+				}),
+			},
+		},
+		{
+			name: "unset inside map of objects",
+			lets: map[string]string{
+				"map": `tm_tomap({
+					a = { x = unset }
+					b = { x = 1 }
+				})`,
+			},
+			want: map[string]cty.Value{
+				"map": cty.ObjectVal(map[string]cty.Value{
+					"a": cty.EmptyObjectVal,
+					"b": cty.ObjectVal(map[string]cty.Value{
+						"x": cty.NumberIntVal(1),
+					}),
+				}),
+			},
+		},
+		{
+			name: "unset inside list of objects",
+			lets: map[string]string{
+				"list": `tm_tolist([
+					{ x = unset, y = 1 },
+					{ x = 1, y = 2 },
+				])`,
+			},
+			want: map[string]cty.Value{
+				"list": cty.TupleVal([]cty.Value{
+					cty.ObjectVal(map[string]cty.Value{
+						"y": cty.NumberIntVal(1),
+					}),
+					cty.ObjectVal(map[string]cty.Value{
+						"x": cty.NumberIntVal(1),
+						"y": cty.NumberIntVal(2),
+					}),
+				}),
+			},
+		},
+		{
+			name: "let evaluated to unset is not defined",
+			lets: map[string]string{
+				"a": `global.env == "prod" ? unset : "a"`,
+				"b": `tm_try(let.a, "undefined")`,
+			},
+			want: map[string]cty.Value{
+				"b": cty.StringVal("undefined"),
+			},
+		},
+		{
+			name: "unset inside lists fails",
+			lets: map[string]string{
+				"list": `["a", unset]`,
+			},
+			wantErr: errors.E(lets.ErrEval),
+		},
+		{
+			name: "interpolating unset fails",
+			lets: map[string]string{
+				"str": `"value: ${unset}"`,
+			},
+			wantErr: errors.E(lets.ErrEval),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			exprs := lets.Exprs{}
+			for name, expr := range tc.lets {
+				exprs[name] = lets.Expr{Expression: test.NewExpr(t, expr)}
+			}
+
+			ctx := eval.NewContext(stdlib.Functions(t.TempDir()))
+			ctx.SetNamespace("global", map[string]cty.Value{
+				"env": cty.StringVal("prod"),
+			})
+
+			err := exprs.Eval(ctx)
+			assert.IsError(t, err, tc.wantErr)
+			if tc.wantErr != nil {
+				return
+			}
+
+			assert.IsTrue(t, !ctx.HasNamespace("unset"), "unset must not leak")
+
+			got, ok := ctx.GetNamespace("let")
+			assert.IsTrue(t, ok)
+			if diff := cmp.Diff(tc.want, got.AsValueMap(), ctydebug.CmpOptions); diff != "" {
+				t.Fatalf("-(want) +(got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLetsEvalCycles(t *testing.T) {
+	type testcase struct {
+		name string
+		lets map[string]string
+		want []string
+	}
+
+	for _, tc := range []testcase{
+		{
+			name: "lets referencing each other",
+			lets: map[string]string{
+				"a": `let.b`,
+				"b": `let.a`,
+			},
+			want: []string{"cycle detected between let.a and let.b"},
+		},
+		{
+			name: "let referencing itself",
+			lets: map[string]string{
+				"a": `"${let.a}-suffix"`,
+			},
+			want: []string{"cycle detected: let.a references itself"},
+		},
+		{
+			name: "multiple cycles and dependent lets",
+			lets: map[string]string{
+				"a":   `let.b`,
+				"b":   `let["c"]`,
+				"c":   `let.a`,
+				"d":   `let.e`,
+				"e":   `let.d`,
+				"f":   `let.a`,
+				"g":   `"ok"`,
+				"all": `let`,
+			},
+			want: []string{
+				"cycle detected between let.a, let.b and let.c",
+				"cycle detected between let.d and let.e",
+				"undefined let all",
+				"undefined let f",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			exprs := lets.Exprs{}
+			for name, expr := range tc.lets {
+				exprs[name] = lets.Expr{Expression: test.NewExpr(t, expr)}
+			}
+
+			err := exprs.Eval(eval.NewContext(nil))
+			assert.IsError(t, err, errors.E(lets.ErrEval))
+
+			var errs *errors.List
+			if !errors.As(err, &errs) {
+				t.Fatalf("expected an error list but got %v", err)
+			}
+			got := errs.Errors()
+			assert.EqualInts(t, len(tc.want), len(got), "errors: %v", got)
+			for i, want := range tc.want {
+				assert.IsTrue(t, strings.Contains(got[i].Error(), want),
+					"error %q does not contain %q", got[i], want)
+			}
+		})
+	}
+}
+
+func TestLetsEvalErrorsOrder(t *testing.T) {
+	letsCode := map[string]string{
+		"e": `tm_undefined_func()`,
+		"b": `1 + "not a number"`,
+		"d": `let.c + 1`,
+		"a": `global.undefined`,
+		"c": `"ok"`,
+		"f": `let.undefined`,
+	}
+	want := []string{
+		"unknown variable namespace: global",
+		"let.b",
+		"let.d",
+		"let.e",
+		"let.f",
+	}
+
+	// map iteration order is random, then evaluating multiple times catches
+	// non-deterministic reporting.
+	for i := 0; i < 20; i++ {
+		exprs := lets.Exprs{}
+		for name, expr := range letsCode {
+			exprs[name] = lets.Expr{Expression: test.NewExpr(t, expr)}
+		}
+
+		err := exprs.Eval(eval.NewContext(nil))
+		assert.IsError(t, err, errors.E(lets.ErrEval))
+
+		var errs *errors.List
+		if !errors.As(err, &errs) {
+			t.Fatalf("expected an error list but got %v", err)
+		}
+		got := errs.Errors()
+		assert.EqualInts(t, len(want), len(got), "errors: %v", got)
+		for i, want := range want {
+			assert.IsTrue(t, strings.Contains(got[i].Error(), want),
+				"error %q does not contain %q", got[i], want)
+		}
+	}
+}
+
+func TestLetsLoadOrigins(t *testing.T) {
+	rootdir := t.TempDir()
+	test.WriteFile(t, rootdir, "gen.tm", `generate_hcl "file.tf" {
+  lets {
+    a = "a"
+    b = let.a
+  }
+
+  lets {
+    map c {
+      for_each = ["x"]
+      key      = element.new
+      value    = element.new
+    }
+  }
+
+  content {
+    a = let.a
+  }
+}
+`)
+
+	cfg, err := hcl.ParseDir(rootdir, rootdir)
+	assert.NoError(t, err)
+	assert.EqualInts(t, 1, len(cfg.Generate.HCLs))
+
+	ctx := eval.NewContext(stdlib.Functions(rootdir))
+	got, err := lets.Load(cfg.Generate.HCLs[0].Lets, ctx)
+	assert.NoError(t, err)
+
+	for name, wantLine := range map[string]int{"a": 3, "b": 4, "c": 8} {
+		origin, ok := got.OriginOf(name)
+		assert.IsTrue(t, ok, "let.%s origin not found", name)
+		assert.EqualStrings(t, "/gen.tm", origin.Path().String())
+		assert.EqualInts(t, wantLine, origin.Start().Line(), "let.%s line", name)
+	}
+
+	_, ok := got.OriginOf("undefined")
+	assert.IsTrue(t, !ok, "undefined let must have no origin")
+}
+
+func TestLetsUnusedLets(t *testing.T) {
+	type testcase struct {
+		name    string
+		lets    map[string]string
+		usedBy  []string
+		want    []string
+		wantErr error
+	}
+
+	for _, tc := range []testcase{
+		{
+			name: "let used by the content",
+			lets: map[string]string{
+				"a": `"a"`,
+			},
+			usedBy: []string{`{ a = let.a }`},
+		},
+		{
+			name: "let used only by another let",
+			lets: map[string]string{
+				"a": `let.b`,
+				"b": `let.c["key"]`,
+				"c": `{ key = "c" }`,
+			},
+			usedBy: []string{`let.a`},
+		},
+		{
+			name: "unused let",
+			lets: map[string]string{
+				"a":      `"a"`,
+				"b":      `let.unused`,
+				"unused": `"unused"`,
+			},
+			usedBy: []string{`"${let.a}-${global.b}"`},
+			want:   []string{"b", "unused"},
+		},
+		{
+			name: "let referenced by index",
+			lets: map[string]string{
+				"a": `"a"`,
+				"b": `"b"`,
+			},
+			usedBy: []string{`let["a"]`},
+			want:   []string{"b"},
+		},
+		{
+			name: "whole let namespace marks all lets as used",
+			lets: map[string]string{
+				"a": `"a"`,
+				"b": `"b"`,
+			},
+			usedBy: []string{`let[global.name]`},
+		},
+		{
+			name: "unset and undefined lets are not reported",
+			lets: map[string]string{
+				"a": `unset`,
+				"b": `let.undefined`,
+			},
+			usedBy: []string{`let.b`},
+		},
+		{
+			name: "no expressions using lets",
+			lets: map[string]string{
+				"a": `let.b`,
+				"b": `"b"`,
+			},
+			want: []string{"a", "b"},
+		},
+		{
+			name: "invalid let reference",
+			lets: map[string]string{
+				"a": `"a"`,
+			},
+			usedBy:  []string{`let[0]`},
+			wantErr: errors.E(lets.ErrEval),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			exprs := lets.Exprs{}
+			for name, expr := range tc.lets {
+				exprs[name] = lets.Expr{Expression: test.NewExpr(t, expr)}
+			}
+			var usedBy []hhcl.Expression
+			for _, expr := range tc.usedBy {
+				usedBy = append(usedBy, test.NewExpr(t, expr))
+			}
+
+			got, err := exprs.UnusedLets(usedBy...)
+			assert.IsError(t, err, tc.wantErr)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Fatalf("-(want) +(got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package run
+
+import (
+	"github.com/mineiros-io/terramate/config"
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/hcl/ast"
+	"github.com/mineiros-io/terramate/hcl/eval"
+	"github.com/rs/zerolog/log"
+	"github.com/zclconf/go-cty/cty"
+)
+
+const (
+	// ErrEvalCommand indicates that an error happened while evaluating the
+	// terramate.config.run.command attribute.
+	ErrEvalCommand errors.Kind = "evaluating terramate.config.run.command attribute"
+
+	// ErrInvalidCommandType indicates that the terramate.config.run.command
+	// attribute doesn't evaluate to a non-empty list of strings.
+	ErrInvalidCommandType errors.Kind = "invalid command type"
+)
+
+// HasCommand returns true if the project configures a default run command
+// with the terramate.config.run.command attribute.
+func HasCommand(root *config.Root) bool {
+	return command(root) != nil
+}
+
+// LoadCommand evaluates the terramate.config.run.command attribute in the
+// context of the stack (its globals and metadata) and returns the resolved
+// command, with the program name as the first element. It returns nil if the
+// project doesn't configure a run command.
+func LoadCommand(root *config.Root, st *config.Stack) ([]string, error) {
+	logger := log.With().
+		Str("action", "run.LoadCommand()").
+		Stringer("stack", st).
+		Logger()
+
+	attr := command(root)
+	if attr == nil {
+		logger.Trace().Msg("no run command config found, nothing to do")
+		return nil, nil
+	}
+
+	evalctx, err := newEvalCtx(root, st)
+	if err != nil {
+		return nil, err
+	}
+	return loadCommand(attr, evalctx)
+}
+
+// loadCommand evaluates the run command attribute with the given evaluation
+// context (see newEvalCtx).
+func loadCommand(attr *ast.Attribute, evalctx *eval.Context) ([]string, error) {
+	val, err := evalctx.Eval(attr.Expr)
+	if err != nil {
+		return nil, errors.E(ErrEvalCommand, attr.Range, err)
+	}
+
+	typ := val.Type()
+	if val.IsNull() || !(typ.IsListType() || typ.IsTupleType()) || val.LengthInt() == 0 {
+		return nil, errors.E(ErrInvalidCommandType, attr.Range,
+			"command must be a non-empty list of strings but got %s", typ.FriendlyName())
+	}
+
+	var cmd []string
+	for it := val.ElementIterator(); it.Next(); {
+		_, elem := it.Element()
+		if elem.IsNull() || elem.Type() != cty.String {
+			return nil, errors.E(ErrInvalidCommandType, attr.Range,
+				"command elements must be strings but got %s", elem.Type().FriendlyName())
+		}
+		cmd = append(cmd, elem.AsString())
+	}
+	return cmd, nil
+}
+
+func command(root *config.Root) *ast.Attribute {
+	cfg := root.Tree().Node
+	if cfg.Terramate == nil ||
+		cfg.Terramate.Config == nil ||
+		cfg.Terramate.Config.Run == nil {
+		return nil
+	}
+	return cfg.Terramate.Config.Run.Command
+}
@@ -0,0 +1,137 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package run_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/madlambda/spells/assert"
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/project"
+	"github.com/mineiros-io/terramate/run"
+	"github.com/mineiros-io/terramate/test/sandbox"
+)
+
+func TestLoadRunCommand(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stacks/prod",
+		"s:stacks/dev",
+		`f:terramate.tm:terramate {
+			config {
+				run {
+					command = [
+						"terraform", "plan",
+						"-var-file=${global.env}.tfvars",
+						"-var=stack=${terramate.stack.path.absolute}",
+					]
+				}
+			}
+		}
+		globals {
+			env = "default"
+		}`,
+		`f:stacks/prod/globals.tm:globals {
+			env = "prod"
+		}`,
+	})
+
+	root := s.Config()
+	assert.IsTrue(t, run.HasCommand(root))
+
+	for _, tc := range []struct {
+		stack string
+		want  []string
+	}{
+		{
+			stack: "/stacks/prod",
+			want: []string{
+				"terraform", "plan", "-var-file=prod.tfvars", "-var=stack=/stacks/prod",
+			},
+		},
+		{
+			stack: "/stacks/dev",
+			want: []string{
+				"terraform", "plan", "-var-file=default.tfvars", "-var=stack=/stacks/dev",
+			},
+		},
+	} {
+		st := s.LoadStack(project.NewPath(tc.stack))
+		got, err := run.LoadCommand(root, st)
+		assert.NoError(t, err)
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Fatalf("unexpected command for %s (-want +got):\n%s", tc.stack, diff)
+		}
+	}
+}
+
+func TestLoadRunCommandNotConfigured(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{"s:stack"})
+
+	root := s.Config()
+	assert.IsTrue(t, !run.HasCommand(root))
+
+	got, err := run.LoadCommand(root, s.LoadStack(project.NewPath("/stack")))
+	assert.NoError(t, err)
+	assert.EqualInts(t, 0, len(got))
+}
+
+func TestLoadRunCommandErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		command string
+		want    error
+	}{
+		{
+			name:    "undefined global",
+			command: `["echo", global.undefined]`,
+			want:    errors.E(run.ErrEvalCommand),
+		},
+		{
+			name:    "not a list",
+			command: `"echo hello"`,
+			want:    errors.E(run.ErrInvalidCommandType),
+		},
+		{
+			name:    "empty list",
+			command: `[]`,
+			want:    errors.E(run.ErrInvalidCommandType),
+		},
+		{
+			name:    "non string element",
+			command: `["echo", 1]`,
+			want:    errors.E(run.ErrInvalidCommandType),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := sandbox.New(t)
+			s.BuildTree([]string{
+				"s:stack",
+				`f:terramate.tm:terramate {
+					config {
+						run {
+							command = ` + tc.command + `
+						}
+					}
+				}`,
+			})
+
+			_, err := run.LoadCommand(s.Config(), s.LoadStack(project.NewPath("/stack")))
+			assert.IsError(t, err, tc.want)
+		})
+	}
+}
@@ -37,14 +37,38 @@ type (
 		values map[ID]interface{}
 		cycles map[ID]bool
 
+		// origins tells how each descendant -> ancestor edge was declared.
+		origins map[edge]EdgeOrigin
+
+		// cycle is the path of the cycle found by the last validation.
+		cycle []ID
+
 		validated bool
 	}
 
+	// EdgeOrigin tells how an edge between a node and its ancestor was
+	// declared. An edge can be declared by both nodes.
+	EdgeOrigin uint8
+
+	edge struct {
+		descendant, ancestor ID
+	}
+
 	// Visited in a map of visited dag nodes by id.
 	// Note: it's not concurrent-safe.
 	Visited map[ID]struct{}
 )
 
+const (
+	// DeclaredAsAncestor means the edge was declared by the descendant node,
+	// listing the other node as one of its ancestors.
+	DeclaredAsAncestor EdgeOrigin = 1 << iota
+
+	// DeclaredAsDescendant means the edge was declared by the ancestor node,
+	// listing the other node as one of its descendants.
+	DeclaredAsDescendant
+)
+
 // Errors returned by operations on the DAG.
 const (
 	ErrDuplicateNode errors.Kind = "duplicate node"
@@ -55,8 +79,9 @@ const (
 // New creates a new empty Directed-Acyclic-Graph.
 func New() *DAG {
 	return &DAG{
-		dag:    make(map[ID][]ID),
-		values: make(map[ID]interface{}),
+		dag:     make(map[ID][]ID),
+		values:  make(map[ID]interface{}),
+		origins: make(map[edge]EdgeOrigin),
 	}
 }
 
@@ -85,6 +110,7 @@ func (d *DAG) AddNode(id ID, value interface{}, descendants, ancestors []ID) err
 			Str("to", string(id)).
 			Msg("Add edge.")
 		d.addAncestor(bid, id)
+		d.origins[edge{bid, id}] |= DeclaredAsDescendant
 	}
 
 	if _, ok := d.dag[id]; !ok {
@@ -108,6 +134,7 @@ func (d *DAG) addAncestors(node ID, ancestorIDs []ID) {
 			Str("ancestor", string(ancestor)).
 			Msg("Add edges.")
 		d.addAncestor(node, ancestor)
+		d.origins[edge{node, ancestor}] |= DeclaredAsAncestor
 	}
 }
 
@@ -132,6 +159,7 @@ func (d *DAG) addAncestor(node, ancestor ID) {
 // Validate the DAG looking for cycles.
 func (d *DAG) Validate() (reason string, err error) {
 	d.cycles = make(map[ID]bool)
+	d.cycle = nil
 	d.validated = true
 
 	for _, id := range d.IDs() {
@@ -152,9 +180,10 @@ func (d *DAG) validateNode(id ID, children []ID) (string, error) {
 		Str("action", "validateNode()").
 		Str("id", string(id)).
 		Msg("Check if has cycle.")
-	found, reason := d.hasCycle([]ID{id}, children, fmt.Sprintf("%s ->", id))
+	found, reason, path := d.hasCycle([]ID{id}, children, fmt.Sprintf("%s ->", id))
 	if found {
 		d.cycles[id] = true
+		d.cycle = path
 		return reason, errors.E(
 			ErrCycleDetected,
 			fmt.Sprintf("checking node id %q", id),
@@ -164,15 +193,19 @@ func (d *DAG) validateNode(id ID, children []ID) (string, error) {
 	return "", nil
 }
 
-func (d *DAG) hasCycle(branch []ID, children []ID, reason string) (bool, string) {
-	for _, id := range branch {
+// hasCycle returns true if any of the children is in the branch. The reason
+// and the path of the cycle, starting at the repeated node, are also returned.
+func (d *DAG) hasCycle(branch []ID, children []ID, reason string) (bool, string, []ID) {
+	for i, id := range branch {
 		log.Trace().
 			Str("action", "hasCycle()").
 			Str("id", string(id)).
 			Msg("Check if id is present in children.")
 		if idList(children).contains(id) {
 			d.cycles[id] = true
-			return true, fmt.Sprintf("%s %s", reason, id)
+			path := make([]ID, 0, len(branch)-i+1)
+			path = append(path, branch[i:]...)
+			return true, fmt.Sprintf("%s %s", reason, id), append(path, id)
 		}
 	}
 
@@ -182,13 +215,27 @@ func (d *DAG) hasCycle(branch []ID, children []ID, reason string) (bool, string)
 			Str("action", "hasCycle()").
 			Str("id", string(tid)).
 			Msg("Check if id has cycle.")
-		found, reason := d.hasCycle(append(branch, tid), tlist, fmt.Sprintf("%s %s ->", reason, tid))
+		found, reason, path := d.hasCycle(append(branch, tid), tlist, fmt.Sprintf("%s %s ->", reason, tid))
 		if found {
-			return true, reason
+			return true, reason, path
 		}
 	}
 
-	return false, ""
+	return false, "", nil
+}
+
+// Cycle returns the path of nodes of the cycle found by the last call to
+// [DAG.Validate], where each node is a descendant of the next one. The path
+// starts and ends at the same node, so nodes leading to the cycle but not
+// part of it are not included. It returns nil if no cycle was found.
+func (d *DAG) Cycle() []ID {
+	return d.cycle
+}
+
+// EdgeOrigin returns how the edge between the node and its ancestor was
+// declared. It returns zero if there's no such edge.
+func (d *DAG) EdgeOrigin(node, ancestor ID) EdgeOrigin {
+	return d.origins[edge{node, ancestor}]
 }
 
 // IDs returns the sorted list of node ids.
@@ -219,6 +266,19 @@ func (d *DAG) AncestorsOf(id ID) []ID {
 	return d.dag[id]
 }
 
+// DescendantsOf returns the sorted list of descendant node ids of the given
+// id, ie. the nodes which have it as an ancestor.
+func (d *DAG) DescendantsOf(id ID) []ID {
+	var descendants idList
+	for node, ancestors := range d.dag {
+		if idList(ancestors).contains(id) {
+			descendants = append(descendants, node)
+		}
+	}
+	sort.Sort(descendants)
+	return descendants
+}
+
 // HasCycle returns true if the DAG has a cycle.
 func (d *DAG) HasCycle(id ID) bool {
 	if !d.validated {
@@ -310,6 +310,55 @@ func TestValidatedDAG(t *testing.T) {
 	}
 }
 
+func TestDAGCycleEdgeOrigins(t *testing.T) {
+	d := dag.New()
+	assert.NoError(t, d.AddNode("A", nil, nil, []dag.ID{"B"}))
+	assert.NoError(t, d.AddNode("B", nil, nil, nil))
+	assert.NoError(t, d.AddNode("C", nil, []dag.ID{"B"}, []dag.ID{"A"}))
+
+	_, err := d.Validate()
+	assert.IsError(t, err, errors.E(dag.ErrCycleDetected))
+	assertOrder(t, []dag.ID{"A", "B", "C", "A"}, d.Cycle())
+
+	assert.EqualInts(t, int(dag.DeclaredAsAncestor), int(d.EdgeOrigin("A", "B")))
+	assert.EqualInts(t, int(dag.DeclaredAsDescendant), int(d.EdgeOrigin("B", "C")))
+	assert.EqualInts(t, int(dag.DeclaredAsAncestor), int(d.EdgeOrigin("C", "A")))
+	assert.EqualInts(t, 0, int(d.EdgeOrigin("B", "A")))
+
+	d = dag.New()
+	assert.NoError(t, d.AddNode("A", nil, nil, nil))
+	assert.NoError(t, d.AddNode("B", nil, nil, []dag.ID{"A"}))
+	_, err = d.Validate()
+	assert.NoError(t, err)
+	assertOrder(t, nil, d.Cycle())
+}
+
+func TestDAGCycleStartsAtRepeatedNode(t *testing.T) {
+	d := dag.New()
+	assert.NoError(t, d.AddNode("A", nil, nil, []dag.ID{"B"}))
+	assert.NoError(t, d.AddNode("B", nil, nil, []dag.ID{"C"}))
+	assert.NoError(t, d.AddNode("C", nil, nil, []dag.ID{"B"}))
+
+	_, err := d.Validate()
+	assert.IsError(t, err, errors.E(dag.ErrCycleDetected))
+	assertOrder(t, []dag.ID{"B", "C", "B"}, d.Cycle())
+}
+
+func TestDAGDescendantsOf(t *testing.T) {
+	d := dag.New()
+	assert.NoError(t, d.AddNode("A", nil, nil, nil))
+	assert.NoError(t, d.AddNode("C", nil, nil, []dag.ID{"A"}))
+	assert.NoError(t, d.AddNode("B", nil, nil, []dag.ID{"A"}))
+	assert.NoError(t, d.AddNode("D", nil, []dag.ID{"E"}, []dag.ID{"B"}))
+	assert.NoError(t, d.AddNode("E", nil, nil, nil))
+
+	assertOrder(t, []dag.ID{"B", "C"}, d.DescendantsOf("A"))
+	assertOrder(t, []dag.ID{"D"}, d.DescendantsOf("B"))
+	assertOrder(t, []dag.ID{"E"}, d.DescendantsOf("D"))
+	assertOrder(t, nil, d.DescendantsOf("E"))
+	assertOrder(t, nil, d.DescendantsOf("unknown"))
+}
+
 func assertOrder(t *testing.T, want, got []dag.ID) {
 	t.Helper()
 	assert.EqualInts(t, len(want), len(got), "length mismatch")
@@ -29,7 +29,7 @@ import (
 
 const (
 	// ErrLoadingGlobals indicates that an error happened while loading globals.
-	ErrLoadingGlobals errors.Kind = "loading globals to evaluate terramate.config.run.env configuration"
+	ErrLoadingGlobals errors.Kind = "loading globals to evaluate terramate.config.run configuration"
 
 	// ErrEval indicates that an error happened while evaluating one of the
 	// terramate.config.run.env attributes.
@@ -64,17 +64,21 @@ func LoadEnv(root *config.Root, st *config.Stack) (EnvVars, error) {
 
 	logger.Trace().Msg("loading globals")
 
-	globalsReport := globals.ForStack(root, st)
-	if err := globalsReport.AsError(); err != nil {
-		return nil, errors.E(ErrLoadingGlobals, err)
+	evalctx, err := newEvalCtx(root, st)
+	if err != nil {
+		return nil, err
 	}
+	return loadEnv(root, evalctx)
+}
 
-	evalctx := eval.NewContext(stdlib.Functions(st.HostDir(root)))
-	runtime := root.Runtime()
-	runtime.Merge(st.RuntimeValues(root))
-	evalctx.SetNamespace("terramate", runtime)
-	evalctx.SetNamespace("global", globalsReport.Globals.AsValueMap())
-	evalctx.SetEnv(os.Environ())
+// loadEnv works like [LoadEnv] but evaluates the env vars with the given
+// evaluation context (see newEvalCtx). The project must have a run env
+// config.
+func loadEnv(root *config.Root, evalctx *eval.Context) (EnvVars, error) {
+	logger := log.With().
+		Str("action", "run.loadEnv()").
+		Str("root", root.HostDir()).
+		Logger()
 
 	envVars := EnvVars{}
 
@@ -109,3 +113,20 @@ func LoadEnv(root *config.Root, st *config.Stack) (EnvVars, error) {
 
 	return envVars, nil
 }
+
+// newEvalCtx creates the context used to evaluate the run configuration of
+// the stack, with its globals, metadata and the environment variables.
+func newEvalCtx(root *config.Root, st *config.Stack) (*eval.Context, error) {
+	globalsReport := globals.ForStack(root, st)
+	if err := globalsReport.AsError(); err != nil {
+		return nil, errors.E(ErrLoadingGlobals, err)
+	}
+
+	evalctx := eval.NewContext(stdlib.Functions(st.HostDir(root)))
+	runtime := root.Runtime()
+	runtime.Merge(st.WithFeatures(globalsReport.Features).RuntimeValues(root))
+	evalctx.SetNamespace("terramate", runtime)
+	evalctx.SetNamespace("global", globalsReport.Globals.AsValueMap())
+	evalctx.SetEnv(os.Environ())
+	return evalctx, nil
+}
@@ -27,7 +27,10 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
-// Exec will execute the given command on the given stack list
+// Exec will execute the given command on the given stack list.
+// If the command is empty, the command configured by the
+// terramate.config.run.command attribute is evaluated and executed on each
+// stack (see [LoadCommand]).
 // During the execution of this function the default behavior
 // for signal handling will be changed so we can wait for the child
 // process to exit before exiting Terramate.
@@ -54,12 +57,40 @@ func Exec(
 
 	errs := errors.L()
 	stackEnvs := map[project.Path]EnvVars{}
+	stackCmds := map[project.Path][]string{}
+
+	hasEnv := root.Tree().Node.HasRunEnv()
+	cmdAttr := command(root)
+	if len(cmd) == 0 && cmdAttr == nil && len(stacks) > 0 {
+		return errors.E("no command given and terramate.config.run.command is not set")
+	}
 
 	logger.Trace().Msg("loading stacks run environment variables")
 	for _, elem := range stacks {
-		env, err := LoadEnv(root, elem.Stack)
-		errs.Append(err)
-		stackEnvs[elem.Dir()] = env
+		stackCmds[elem.Dir()] = cmd
+		if !hasEnv && len(cmd) > 0 {
+			continue
+		}
+
+		// the globals of the stack are evaluated once for both the env
+		// vars and the command.
+		evalctx, err := newEvalCtx(root, elem.Stack)
+		if err != nil {
+			errs.Append(err)
+			continue
+		}
+
+		if hasEnv {
+			env, err := loadEnv(root, evalctx)
+			errs.Append(err)
+			stackEnvs[elem.Dir()] = env
+		}
+
+		if len(cmd) == 0 {
+			stackCmd, err := loadCommand(cmdAttr, evalctx)
+			errs.Append(err)
+			stackCmds[elem.Dir()] = stackCmd
+		}
 	}
 
 	if errs.AsError() != nil {
@@ -78,12 +109,14 @@ func Exec(
 	results := startCmdRunner(cmds)
 
 	for _, stack := range stacks {
+		stackCmd := stackCmds[stack.Dir()]
+
 		logger := log.With().
-			Str("cmd", strings.Join(cmd, " ")).
+			Str("cmd", strings.Join(stackCmd, " ")).
 			Stringer("stack", stack).
 			Logger()
 
-		cmd := exec.Command(cmd[0], cmd[1:]...)
+		cmd := exec.Command(stackCmd[0], stackCmd[1:]...)
 		cmd.Dir = stack.HostDir(root)
 		cmd.Env = append(os.Environ(), stackEnvs[stack.Dir()]...)
 		cmd.Stdin = stdin
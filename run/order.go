@@ -32,10 +32,66 @@ import (
 // In the case of multiple possible orders, it returns the lexicographic sorted
 // path.
 func Sort(root *config.Root, stacks config.List[*config.SortableStack]) (config.List[*config.SortableStack], string, error) {
+	logger := log.With().
+		Str("action", "run.Sort()").
+		Str("root", root.HostDir()).
+		Logger()
+
+	d, reason, err := buildRunOrderDAG(root, stacks)
+	if err != nil {
+		return nil, reason, err
+	}
+
+	logger.Trace().Msg("Get topologically order DAG.")
+
+	order := d.Order()
+
+	orderedStacks := make(config.List[*config.SortableStack], 0, len(order))
+
+	logger.Trace().Msg("Get ordered stacks.")
+
+	isSelectedStack := func(s *config.Stack) bool {
+		// Stacks may be added on the DAG from after/before references
+		// but they should not be on the final order if they are not part
+		// of the previously selected stacks passed as a parameter.
+		// This is important for change detection to work on ordering and
+		// also for filtering by working dir.
+		for _, stack := range stacks {
+			if s.Dir == stack.Dir() {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, id := range order {
+		val, err := d.Node(id)
+		if err != nil {
+			return nil, "", fmt.Errorf("calculating run-order: %w", err)
+		}
+		s := val.(*config.Stack)
+		if !isSelectedStack(s) {
+			logger.Trace().
+				Stringer("stack", s.Dir).
+				Msg("ignoring since not part of selected stacks")
+			continue
+		}
+		orderedStacks = append(orderedStacks, s.Sortable())
+	}
+
+	return orderedStacks, "", nil
+}
+
+// buildRunOrderDAG builds and validates the run order DAG of the given
+// stacks, which also contains the stacks referenced by their before/after
+// attributes, even if they are not part of the given list. Parent stacks
+// always run before their child stacks. The stacks are sorted in place.
+// If the DAG has a cycle, the cycle reason is returned with the error.
+func buildRunOrderDAG(root *config.Root, stacks config.List[*config.SortableStack]) (*dag.DAG, string, error) {
 	d := dag.New()
 
 	logger := log.With().
-		Str("action", "run.Sort()").
+		Str("action", "run.buildRunOrderDAG()").
 		Str("root", root.HostDir()).
 		Logger()
 
@@ -60,7 +116,7 @@ func Sort(root *config.Root, stacks config.List[*config.SortableStack]) (config.
 		}
 	}
 
-	logger.Trace().Msg("Sorting stacks.")
+	logger.Trace().Msg("Build DAG.")
 
 	visited := dag.Visited{}
 	for _, elem := range stacks {
@@ -90,49 +146,11 @@ func Sort(root *config.Root, stacks config.List[*config.SortableStack]) (config.
 
 	logger.Trace().Msg("Validate DAG.")
 
-	reason, err := d.Validate()
+	_, err := d.Validate()
 	if err != nil {
-		return nil, reason, err
+		return nil, cycleReason(d, "before", "after"), err
 	}
-
-	logger.Trace().Msg("Get topologically order DAG.")
-
-	order := d.Order()
-
-	orderedStacks := make(config.List[*config.SortableStack], 0, len(order))
-
-	logger.Trace().Msg("Get ordered stacks.")
-
-	isSelectedStack := func(s *config.Stack) bool {
-		// Stacks may be added on the DAG from after/before references
-		// but they should not be on the final order if they are not part
-		// of the previously selected stacks passed as a parameter.
-		// This is important for change detection to work on ordering and
-		// also for filtering by working dir.
-		for _, stack := range stacks {
-			if s.Dir == stack.Dir() {
-				return true
-			}
-		}
-		return false
-	}
-
-	for _, id := range order {
-		val, err := d.Node(id)
-		if err != nil {
-			return nil, "", fmt.Errorf("calculating run-order: %w", err)
-		}
-		s := val.(*config.Stack)
-		if !isSelectedStack(s) {
-			logger.Trace().
-				Stringer("stack", s.Dir).
-				Msg("ignoring since not part of selected stacks")
-			continue
-		}
-		orderedStacks = append(orderedStacks, s.Sortable())
-	}
-
-	return orderedStacks, "", nil
+	return d, "", nil
 }
 
 // BuildDAG builds a run order DAG for the given stack.
@@ -261,6 +279,41 @@ func BuildDAG(
 	return nil
 }
 
+// cycleReason describes the cycle found in the validated DAG, naming the
+// stacks in the cycle and the stack attribute declaring each of the edges,
+// since an edge can be declared by any of its stacks (eg.: "/a after /b" and
+// "/b before /a" are the same edge). Eg.:
+//
+//	/a -> /b -> /a (/a after /b, /a before /b)
+func cycleReason(d *dag.DAG, descendantsName, ancestorsName string) string {
+	cycle := d.Cycle()
+	if len(cycle) == 0 {
+		return ""
+	}
+
+	path := make([]string, 0, len(cycle))
+	for _, id := range cycle {
+		path = append(path, string(id))
+	}
+
+	var edges []string
+	for i := 0; i+1 < len(cycle); i++ {
+		node, ancestor := cycle[i], cycle[i+1]
+		origin := d.EdgeOrigin(node, ancestor)
+
+		var declared []string
+		if origin&dag.DeclaredAsAncestor != 0 {
+			declared = append(declared, fmt.Sprintf("%s %s %s", node, ancestorsName, ancestor))
+		}
+		if origin&dag.DeclaredAsDescendant != 0 {
+			declared = append(declared, fmt.Sprintf("%s %s %s", ancestor, descendantsName, node))
+		}
+		edges = append(edges, strings.Join(declared, " and "))
+	}
+
+	return fmt.Sprintf("%s (%s)", strings.Join(path, " -> "), strings.Join(edges, ", "))
+}
+
 func toids(values config.List[*config.SortableStack]) []dag.ID {
 	ids := make([]dag.ID, 0, len(values))
 	for _, v := range values {
@@ -0,0 +1,103 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package run_test
+
+import (
+	"testing"
+
+	"github.com/madlambda/spells/assert"
+	"github.com/mineiros-io/terramate/config"
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/run"
+	"github.com/mineiros-io/terramate/run/dag"
+	"github.com/mineiros-io/terramate/test/sandbox"
+)
+
+func TestSortCycleReason(t *testing.T) {
+	type testcase struct {
+		name   string
+		layout []string
+		reason string
+	}
+
+	for _, tc := range []testcase{
+		{
+			name: "no cycle",
+			layout: []string{
+				`s:a:after=["/b"]`,
+				`s:b:before=["/c"]`,
+				`s:c`,
+			},
+		},
+		{
+			name: "a after a",
+			layout: []string{
+				`s:a:after=["/a"]`,
+			},
+			reason: "/a -> /a (/a after /a)",
+		},
+		{
+			name: "a after b, b after a",
+			layout: []string{
+				`s:a:after=["/b"]`,
+				`s:b:after=["/a"]`,
+			},
+			reason: "/a -> /b -> /a (/a after /b, /b after /a)",
+		},
+		{
+			name: "a after b, a before b",
+			layout: []string{
+				`s:a:after=["/b"];before=["/b"]`,
+				`s:b`,
+			},
+			reason: "/a -> /b -> /a (/a after /b, /a before /b)",
+		},
+		{
+			name: "a after b, c before b, c after a",
+			layout: []string{
+				`s:a:after=["/b"]`,
+				`s:b`,
+				`s:c:before=["/b"];after=["/a"]`,
+			},
+			reason: "/a -> /b -> /c -> /a (/a after /b, /c before /b, /c after /a)",
+		},
+		{
+			name: "edge declared by both stacks",
+			layout: []string{
+				`s:a:after=["/b"]`,
+				`s:b:before=["/a"];after=["/a"]`,
+			},
+			reason: "/a -> /b -> /a (/a after /b and /b before /a, /b after /a)",
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			s := sandbox.NoGit(t)
+			s.BuildTree(tc.layout)
+			root := s.Config()
+
+			stacks, err := config.LoadAllStacks(root.Tree())
+			assert.NoError(t, err)
+
+			_, reason, err := run.Sort(root, stacks)
+			if tc.reason == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.IsError(t, err, errors.E(dag.ErrCycleDetected))
+			assert.EqualStrings(t, tc.reason, reason)
+		})
+	}
+}
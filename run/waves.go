@@ -0,0 +1,127 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package run
+
+import (
+	"sort"
+
+	"github.com/mineiros-io/terramate/config"
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/run/dag"
+	"github.com/rs/zerolog/log"
+)
+
+// Waves computes the parallel execution plan of the given list of stacks.
+// The stacks are split in waves: the stacks of a wave can run in parallel
+// and a wave only starts after all stacks of the previous waves finished.
+//
+// A stack is placed in the first wave after all the selected stacks it must
+// run after (directly, by its parent stacks or transitively through stacks
+// not selected) and at most one stack of each concurrency group is placed in
+// a wave. When multiple stacks of the same concurrency group are ready to
+// run, the one with the lexicographic lower path is placed in the wave and
+// the others are postponed to the next waves. The stacks of each wave are
+// lexicographic sorted.
+//
+// If the stacks have an ordering cycle, the cycle reason is returned with an
+// error of kind [dag.ErrCycleDetected], the same as [Sort].
+func Waves(root *config.Root, stacks config.List[*config.SortableStack]) ([]config.List[*config.SortableStack], string, error) {
+	logger := log.With().
+		Str("action", "run.Waves()").
+		Str("root", root.HostDir()).
+		Logger()
+
+	d, reason, err := buildRunOrderDAG(root, stacks)
+	if err != nil {
+		return nil, reason, err
+	}
+
+	selected := map[dag.ID]*config.Stack{}
+	for _, elem := range stacks {
+		selected[dag.ID(elem.Dir().String())] = elem.Stack
+	}
+
+	logger.Trace().Msg("Compute selected ancestors of each stack.")
+
+	// selectedAncestors walks the ancestors of id, skipping over the stacks
+	// not selected, so the order between selected stacks is kept even when
+	// it is imposed by a stack outside of the selection.
+	var selectedAncestors func(id dag.ID, found map[dag.ID]struct{}, visited dag.Visited)
+	selectedAncestors = func(id dag.ID, found map[dag.ID]struct{}, visited dag.Visited) {
+		for _, ancestor := range d.AncestorsOf(id) {
+			if _, ok := visited[ancestor]; ok {
+				continue
+			}
+			visited[ancestor] = struct{}{}
+			if _, ok := selected[ancestor]; ok {
+				found[ancestor] = struct{}{}
+				continue
+			}
+			selectedAncestors(ancestor, found, visited)
+		}
+	}
+
+	pending := map[dag.ID]map[dag.ID]struct{}{}
+	for id := range selected {
+		ancestors := map[dag.ID]struct{}{}
+		selectedAncestors(id, ancestors, dag.Visited{})
+		pending[id] = ancestors
+	}
+
+	logger.Trace().Msg("Compute waves.")
+
+	var waves []config.List[*config.SortableStack]
+	for len(pending) > 0 {
+		var ready []dag.ID
+		for id, ancestors := range pending {
+			if len(ancestors) == 0 {
+				ready = append(ready, id)
+			}
+		}
+		if len(ready) == 0 {
+			// unreachable: the DAG is validated to have no cycles.
+			return nil, "", errors.E(dag.ErrCycleDetected, "computing waves")
+		}
+
+		sort.Slice(ready, func(i, j int) bool { return ready[i] < ready[j] })
+
+		wave := config.List[*config.SortableStack]{}
+		groups := map[string]struct{}{}
+		for _, id := range ready {
+			st := selected[id]
+			if group := st.ConcurrencyGroup; group != "" {
+				if _, ok := groups[group]; ok {
+					logger.Trace().
+						Stringer("stack", st.Dir).
+						Str("concurrency_group", group).
+						Msg("Postpone stack to next wave.")
+					continue
+				}
+				groups[group] = struct{}{}
+			}
+			wave = append(wave, st.Sortable())
+		}
+
+		for _, elem := range wave {
+			id := dag.ID(elem.Dir().String())
+			delete(pending, id)
+			for _, ancestors := range pending {
+				delete(ancestors, id)
+			}
+		}
+		waves = append(waves, wave)
+	}
+	return waves, "", nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/hcl"
+	"github.com/mineiros-io/terramate/project"
+	"github.com/mineiros-io/terramate/run"
+	"github.com/rs/zerolog/log"
+)
+
+// StacksWithoutChecks returns the stacks with no checks configured, sorted by
+// path. A stack has checks if any of the conditions below is true:
+//
+//   - An assert block is defined in the stack directory or in any of its
+//     parent directories, since these asserts are evaluated for the stack.
+//   - A generate_hcl or generate_file block with assert blocks is defined in
+//     the stack directory or in any of its parent directories, except
+//     generate_file blocks with the root context.
+//   - The project configures the terramate.config.run.command attribute,
+//     which applies to every stack.
+//
+// The conditions of the blocks are not evaluated, so a stack whose asserts
+// are all disabled by conditions still has checks.
+func (m *Manager) StacksWithoutChecks() ([]Entry, error) {
+	logger := log.With().
+		Str("action", "Manager.StacksWithoutChecks()").
+		Logger()
+
+	if run.HasCommand(m.root) {
+		logger.Debug().Msg("Run command configured, all stacks have checks.")
+		return []Entry{}, nil
+	}
+
+	entries, err := List(m.root.Tree())
+	if err != nil {
+		return nil, errors.E(errList, err)
+	}
+
+	unchecked := []Entry{}
+	for _, entry := range entries {
+		if m.hasAsserts(entry.Stack.Dir) {
+			continue
+		}
+
+		logger.Trace().
+			Stringer("stack", entry.Stack.Dir).
+			Msg("Stack has no checks.")
+
+		unchecked = append(unchecked, entry)
+	}
+	return unchecked, nil
+}
+
+// hasAsserts returns true if asserts applying to stacks at dir are defined
+// on dir or any of its parent directories.
+func (m *Manager) hasAsserts(dir project.Path) bool {
+	for {
+		if tree, ok := m.root.Lookup(dir); ok && configHasAsserts(tree.Node) {
+			return true
+		}
+		parent := dir.Dir()
+		if parent == dir {
+			return false
+		}
+		dir = parent
+	}
+}
+
+func configHasAsserts(cfg hcl.Config) bool {
+	if len(cfg.Asserts) > 0 {
+		return true
+	}
+	for _, block := range cfg.Generate.HCLs {
+		if len(block.Asserts) > 0 {
+			return true
+		}
+	}
+	for _, block := range cfg.Generate.Files {
+		// root context blocks are not evaluated for stacks.
+		if block.Context != "root" && len(block.Asserts) > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack_test
+
+import (
+	"testing"
+
+	"github.com/madlambda/spells/assert"
+	"github.com/mineiros-io/terramate/stack"
+	"github.com/mineiros-io/terramate/test/sandbox"
+)
+
+func TestStacksWithoutChecks(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:bare",
+		"s:asserted",
+		"s:checked/child",
+		"s:gen-asserted",
+		"s:root-gen",
+		`f:asserted/asserts.tm:assert {
+			assertion = true
+			message   = "always true"
+		}`,
+		`f:checked/asserts.tm:assert {
+			assertion = true
+			message   = "parent assert"
+		}`,
+		`f:gen-asserted/gen.tm:generate_hcl "file.hcl" {
+			assert {
+				assertion = true
+				message   = "generate assert"
+			}
+			content {
+				a = 1
+			}
+		}`,
+		`f:root-gen/gen.tm:generate_file "/file.txt" {
+			context = root
+			assert {
+				assertion = true
+				message   = "root context assert"
+			}
+			content = "root"
+		}`,
+	})
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+	entries, err := m.StacksWithoutChecks()
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/bare", "/root-gen"}, entries, false)
+}
+
+func TestStacksWithoutChecksRunCommand(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:bare",
+		`f:terramate.tm:terramate {
+			config {
+				run {
+					command = ["terraform", "plan"]
+				}
+			}
+		}`,
+	})
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+	entries, err := m.StacksWithoutChecks()
+	assert.NoError(t, err)
+	assert.EqualInts(t, 0, len(entries))
+}
@@ -0,0 +1,293 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"bufio"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// ErrInvalidDiff indicates that a diff given to [Manager.ListChangedFromDiff]
+// could not be parsed.
+const ErrInvalidDiff errors.Kind = "invalid diff"
+
+const devNull = "/dev/null"
+
+// ListChangedFromDiff lists the stacks changed by the files touched in the
+// diff read from r, without running any git command, which is useful when
+// the diff is computed elsewhere (eg.: on air-gapped CI). The stacks are
+// attributed the same way as [Manager.ListChanged], except that the
+// repository checks, the root config changes and the moved stacks are not
+// reported, since they require git.
+//
+// The diff can be the output of "git diff --name-status" or a unified diff
+// (eg.: "git diff"), with paths relative to the project root. For renamed
+// files, both the old and the new paths are considered changed. The "a/"
+// and "b/" prefixes of the unified diff paths are removed, if present.
+// An error of kind [ErrInvalidDiff] is returned if the diff can't be parsed.
+func (m *Manager) ListChangedFromDiff(r io.Reader) (*Report, error) {
+	logger := log.With().
+		Str("action", "Manager.ListChangedFromDiff()").
+		Logger()
+
+	touched, err := parseDiffFiles(r)
+	if err != nil {
+		return nil, errors.E(errListChanged, err)
+	}
+
+	logger.Debug().
+		Int("files", len(touched)).
+		Msg("Files touched by diff.")
+
+	diffManager := m.clone()
+	diffManager.touchedFiles = touched
+	diffManager.withoutGit = true
+	return diffManager.ListChanged()
+}
+
+// parseDiffFiles parses the files touched by a unified or name-status diff.
+// The files are returned sorted and without duplicates.
+func parseDiffFiles(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimSuffix(scanner.Text(), "\r"))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.E(ErrInvalidDiff, err, "reading diff")
+	}
+
+	files := map[string]struct{}{}
+	add := func(lineno int, file string) error {
+		file, err := cleanDiffPath(file)
+		if err != nil {
+			return errors.E(ErrInvalidDiff, err, "line %d", lineno)
+		}
+		if file != "" {
+			files[file] = struct{}{}
+		}
+		return nil
+	}
+
+	var err error
+	if isUnifiedDiff(lines) {
+		err = parseUnifiedDiff(lines, add)
+	} else {
+		err = parseNameStatus(lines, add)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	touched := make([]string, 0, len(files))
+	for file := range files {
+		touched = append(touched, file)
+	}
+	sort.Strings(touched)
+	return touched, nil
+}
+
+// isUnifiedDiff tells if the first non-empty line starts an unified diff,
+// otherwise the lines are parsed as name-status output.
+func isUnifiedDiff(lines []string) bool {
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+		return strings.HasPrefix(line, "diff ") || strings.HasPrefix(line, "--- ")
+	}
+	return false
+}
+
+// parseNameStatus parses the output of "git diff --name-status", which has
+// one line per file with a status letter (with an optional similarity score
+// for renames and copies) followed by the tab separated paths, eg.:
+//
+//	M	stack/main.tf
+//	R087	old/main.tf	new/main.tf
+func parseNameStatus(lines []string, add func(lineno int, file string) error) error {
+	for i, line := range lines {
+		lineno := i + 1
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		status := fields[0]
+		if status == "" || !strings.ContainsRune("ACDMRTUXB", rune(status[0])) {
+			return errors.E(ErrInvalidDiff, "line %d: unknown status %q", lineno, status)
+		}
+
+		var paths []string
+		switch status[0] {
+		case 'R':
+			// both the old and new paths changed.
+			if len(fields) != 3 {
+				return errors.E(ErrInvalidDiff,
+					"line %d: rename entry must have the old and new paths", lineno)
+			}
+			paths = fields[1:]
+		case 'C':
+			// the copy source is not changed.
+			if len(fields) != 3 {
+				return errors.E(ErrInvalidDiff,
+					"line %d: copy entry must have the source and destination paths", lineno)
+			}
+			paths = fields[2:]
+		default:
+			if len(fields) != 2 {
+				return errors.E(ErrInvalidDiff,
+					"line %d: entry must have a single path", lineno)
+			}
+			paths = fields[1:]
+		}
+
+		for _, p := range paths {
+			if err := add(lineno, p); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// parseUnifiedDiff parses the paths of the file headers of an unified diff.
+// The paths are taken from the "---" and "+++" lines and, for renames and
+// files without content changes (eg.: empty new files), from the git
+// extended headers.
+func parseUnifiedDiff(lines []string, add func(lineno int, file string) error) error {
+	inHunk := false
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		lineno := i + 1
+
+		switch {
+		case strings.HasPrefix(line, "diff "):
+			inHunk = false
+			if strings.HasPrefix(line, "diff --git ") {
+				if p, ok := gitDiffHeaderPath(strings.TrimPrefix(line, "diff --git ")); ok {
+					if err := add(lineno, p); err != nil {
+						return err
+					}
+				}
+			}
+		case strings.HasPrefix(line, "@@"):
+			inHunk = true
+		case inHunk:
+			continue
+		case strings.HasPrefix(line, "rename from "):
+			if err := add(lineno, unquoteDiffPath(strings.TrimPrefix(line, "rename from "))); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "rename to "):
+			if err := add(lineno, unquoteDiffPath(strings.TrimPrefix(line, "rename to "))); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "--- "):
+			if i+1 >= len(lines) || !strings.HasPrefix(lines[i+1], "+++ ") {
+				return errors.E(ErrInvalidDiff, "line %d: \"---\" header without \"+++\" header", lineno)
+			}
+			oldpath := headerPath(strings.TrimPrefix(line, "--- "), "a/")
+			newpath := headerPath(strings.TrimPrefix(lines[i+1], "+++ "), "b/")
+			for _, p := range []string{oldpath, newpath} {
+				if p == devNull {
+					continue
+				}
+				if err := add(lineno, p); err != nil {
+					return err
+				}
+			}
+			i++
+		}
+	}
+	return nil
+}
+
+// gitDiffHeaderPath returns the path of a "diff --git a/<path> b/<path>"
+// header, if the old and new paths are the same. Headers of renamed files
+// are ambiguous when the paths have spaces, so they are ignored in favor of
+// the rename extended headers.
+func gitDiffHeaderPath(paths string) (string, bool) {
+	if strings.HasPrefix(paths, `"`) {
+		return "", false
+	}
+	if len(paths)%2 == 0 {
+		return "", false
+	}
+	half := len(paths) / 2
+	oldpath, newpath := paths[:half], paths[half+1:]
+	if paths[half] != ' ' || !strings.HasPrefix(oldpath, "a/") || !strings.HasPrefix(newpath, "b/") {
+		return "", false
+	}
+	if oldpath[2:] != newpath[2:] {
+		return "", false
+	}
+	return oldpath[2:], true
+}
+
+// headerPath returns the path of a "---" or "+++" header, without the
+// trailing timestamp added by some diff tools and without the prefix.
+func headerPath(header string, prefix string) string {
+	header = unquoteDiffPath(header)
+	if i := strings.IndexByte(header, '\t'); i != -1 {
+		header = header[:i]
+	}
+	if header == devNull {
+		return header
+	}
+	return strings.TrimPrefix(header, prefix)
+}
+
+// unquoteDiffPath unquotes the paths quoted by git because they have special
+// characters, returning other paths unchanged.
+func unquoteDiffPath(p string) string {
+	if !strings.HasPrefix(p, `"`) {
+		return p
+	}
+	end := strings.LastIndexByte(p, '"')
+	if end <= 0 {
+		return p
+	}
+	unquoted, err := strconv.Unquote(p[:end+1])
+	if err != nil {
+		return p
+	}
+	return unquoted + p[end+1:]
+}
+
+// cleanDiffPath cleans a path of the diff, which must be relative to the
+// project root and inside of it.
+func cleanDiffPath(p string) (string, error) {
+	p = unquoteDiffPath(p)
+	if p == "" {
+		return "", errors.E("empty path")
+	}
+	if path.IsAbs(p) {
+		return "", errors.E("path %q must be relative to the project root", p)
+	}
+	p = path.Clean(p)
+	if p == ".." || strings.HasPrefix(p, "../") {
+		return "", errors.E("path %q is outside of the project", p)
+	}
+	return p, nil
+}
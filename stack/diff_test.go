@@ -0,0 +1,185 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/madlambda/spells/assert"
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/stack"
+	"github.com/mineiros-io/terramate/test/sandbox"
+)
+
+func TestListChangedFromDiff(t *testing.T) {
+	type testcase struct {
+		name  string
+		diff  string
+		want  []string
+		files map[string][]string
+	}
+
+	for _, tc := range []testcase{
+		{
+			name: "empty diff",
+			diff: "",
+			want: []string{},
+		},
+		{
+			name: "name-status with rename",
+			diff: strings.Join([]string{
+				"M\tstacks/c/main.tf",
+				"R087\tstacks/a/old.tf\tstacks/b/new.tf",
+				"C075\tstacks/d/src.tf\tstacks/e/copy.tf",
+				"D\tstacks/f/gone.tf",
+				"A\tdocs/guide.md",
+				"",
+			}, "\n"),
+			want: []string{
+				"/stacks/a",
+				"/stacks/b",
+				"/stacks/c",
+				"/stacks/e",
+				"/stacks/f",
+			},
+			files: map[string][]string{
+				"/stacks/a": {"stacks/a/old.tf"},
+				"/stacks/b": {"stacks/b/new.tf"},
+				"/stacks/c": {"stacks/c/main.tf"},
+				"/stacks/e": {"stacks/e/copy.tf"},
+				"/stacks/f": {"stacks/f/gone.tf"},
+			},
+		},
+		{
+			name: "name-status with module change",
+			diff: "M\tmodules/mod/main.tf\n",
+			want: []string{"/stacks/d"},
+			files: map[string][]string{
+				"/stacks/d": {"modules/mod/main.tf"},
+			},
+		},
+		{
+			name: "unified diff",
+			diff: `diff --git a/stacks/c/main.tf b/stacks/c/main.tf
+index 3b18e51..a042389 100644
+--- a/stacks/c/main.tf
++++ b/stacks/c/main.tf
+@@ -1 +1 @@
+--- not a header
++++ not a header
+diff --git a/stacks/a/old.tf b/stacks/b/new.tf
+similarity index 100%
+rename from stacks/a/old.tf
+rename to stacks/b/new.tf
+diff --git a/stacks/e/empty.tf b/stacks/e/empty.tf
+new file mode 100644
+index 0000000..e69de29
+diff --git a/stacks/f/gone.tf b/stacks/f/gone.tf
+deleted file mode 100644
+index 3b18e51..0000000
+--- a/stacks/f/gone.tf
++++ /dev/null
+@@ -1 +0,0 @@
+-# gone
+`,
+			want: []string{
+				"/stacks/a",
+				"/stacks/b",
+				"/stacks/c",
+				"/stacks/e",
+				"/stacks/f",
+			},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			// no git repository, since the diff is the only source of changes.
+			s := sandbox.NoGit(t)
+			s.BuildTree([]string{
+				"s:stacks/a",
+				"s:stacks/b",
+				"s:stacks/c",
+				"s:stacks/d",
+				"s:stacks/e",
+				"s:stacks/f",
+				`f:stacks/d/main.tf:module "mod" {
+					source = "../../modules/mod"
+				}`,
+				"f:modules/mod/main.tf:# module",
+				"f:docs/guide.md:# guide",
+			})
+
+			m := stack.NewManager(s.Config(), defaultBranch)
+			report, err := m.ListChangedFromDiff(strings.NewReader(tc.diff))
+			assert.NoError(t, err)
+			assertStacks(t, tc.want, report.Stacks, true)
+
+			if tc.files == nil {
+				return
+			}
+			got := map[string][]string{}
+			for _, entry := range report.Stacks {
+				got[entry.Stack.Dir.String()] = entry.Stack.ChangedFiles
+			}
+			if diff := cmp.Diff(tc.files, got); diff != "" {
+				t.Fatalf("-(want) +(got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestListChangedFromDiffFailures(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		diff string
+	}{
+		{
+			name: "unknown status",
+			diff: "Z\tstacks/a/main.tf\n",
+		},
+		{
+			name: "rename without new path",
+			diff: "R100\tstacks/a/main.tf\n",
+		},
+		{
+			name: "modification with many paths",
+			diff: "M\tstacks/a/main.tf\tstacks/b/main.tf\n",
+		},
+		{
+			name: "absolute path",
+			diff: "M\t/stacks/a/main.tf\n",
+		},
+		{
+			name: "path outside project",
+			diff: "M\t../stacks/a/main.tf\n",
+		},
+		{
+			name: "unified diff header without new file",
+			diff: "--- a/stacks/a/main.tf\n",
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			s := sandbox.NoGit(t)
+			s.BuildTree([]string{"s:stacks/a"})
+
+			m := stack.NewManager(s.Config(), defaultBranch)
+			_, err := m.ListChangedFromDiff(strings.NewReader(tc.diff))
+			assert.IsError(t, err, errors.E(stack.ErrInvalidDiff))
+		})
+	}
+}
@@ -15,11 +15,23 @@
 package stack
 
 import (
+	hhcl "github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/mineiros-io/terramate/config"
+	"github.com/mineiros-io/terramate/errors"
 	"github.com/mineiros-io/terramate/hcl/eval"
 	"github.com/mineiros-io/terramate/stdlib"
+	"github.com/zclconf/go-cty/cty"
 )
 
+// ErrReservedNamespace indicates an attempt to set an extra namespace with a
+// name reserved by Terramate.
+const ErrReservedNamespace errors.Kind = "reserved namespace"
+
+// reservedNamespaces are the namespaces managed by Terramate itself, which
+// can't be set as extra namespaces.
+var reservedNamespaces = []string{"global", "let", "terramate"}
+
 // EvalCtx represents the evaluation context of a stack.
 type EvalCtx struct {
 	*eval.Context
@@ -30,6 +42,8 @@ type EvalCtx struct {
 // NewEvalCtx creates a new stack evaluation context.
 func NewEvalCtx(root *config.Root, stack *config.Stack, globals *eval.Object) *EvalCtx {
 	evalctx := eval.NewContext(stdlib.Functions(stack.HostDir(root)))
+	evalctx.SetFunction(stdlib.Name("stack_outputs"), StackOutputsFunc(root, stack))
+	evalctx.SetFunction(stdlib.Name("stackpath_rel"), StackPathRelFunc(stack))
 	evalwrapper := &EvalCtx{
 		Context: evalctx,
 		root:    root,
@@ -44,9 +58,104 @@ func (e *EvalCtx) SetGlobals(g *eval.Object) {
 	e.SetNamespace("global", g.AsValueMap())
 }
 
+// SetExtraNamespace sets the namespace name with the given values on the stack
+// evaluation context, so tools can expose extra data to the evaluated
+// expressions (eg.: plugin.name). Setting a namespace again replaces its
+// values.
+//
+// It returns an error of kind [ErrReservedNamespace] if name is one of the
+// namespaces managed by Terramate (global, let and terramate) or if it's not
+// a valid identifier.
+func (e *EvalCtx) SetExtraNamespace(name string, values map[string]cty.Value) error {
+	if !hclsyntax.ValidIdentifier(name) {
+		return errors.E(ErrReservedNamespace, "%q is not a valid namespace name", name)
+	}
+	for _, reserved := range reservedNamespaces {
+		if name == reserved {
+			return errors.E(ErrReservedNamespace,
+				"namespace %q is reserved by terramate and can't be overridden", name)
+		}
+	}
+	e.SetNamespace(name, values)
+	return nil
+}
+
 // SetMetadata sets the given metadata on the stack evaluation context.
 func (e *EvalCtx) SetMetadata(st *config.Stack) {
 	runtime := e.root.Runtime()
 	runtime.Merge(st.RuntimeValues(e.root))
 	e.SetNamespace("terramate", runtime)
 }
+
+// Metadata returns the values of the terramate namespace of the stack
+// evaluation context, which are the runtime values of the project (eg.:
+// terramate.version) and of the stack (eg.: terramate.stack.name), so they can
+// be inspected without evaluating expressions. The returned map is a copy.
+func (e *EvalCtx) Metadata() map[string]cty.Value {
+	metadata := map[string]cty.Value{}
+	ns, ok := e.GetNamespace("terramate")
+	if !ok {
+		return metadata
+	}
+	for name, val := range ns.AsValueMap() {
+		metadata[name] = val
+	}
+	return metadata
+}
+
+// EvalPartial evaluates the expression preserving unknown values instead of
+// failing. It's useful for analyzing partially specified configurations.
+// Unlike [EvalCtx.PartialEvalExpr], it returns a value, where everything
+// depending on undefined namespaces is unknown.
+//
+// References to namespaces not defined in the context (eg.: module.name)
+// evaluate to unknown values, and so does any expression depending on them or
+// on unknown values set in the context. References to undefined attributes of
+// defined namespaces (eg.: global.undefined), invalid function calls and type
+// errors are still hard errors.
+func (e *EvalCtx) EvalPartial(expr hhcl.Expression) (cty.Value, error) {
+	ctx := e.Context.Copy()
+	for _, traversal := range expr.Variables() {
+		if !ctx.HasNamespace(traversal.RootName()) {
+			ctx.Unwrap().Variables[traversal.RootName()] = cty.DynamicVal
+		}
+	}
+	return ctx.Eval(expr)
+}
+
+// PartialEvalExpr partially evaluates the expression. References to the
+// namespaces defined in the context (eg.: global.name and terramate.stack.name)
+// and Terramate functions are substituted by their values, while references to
+// undefined namespaces (eg.: module.vpc.id) are preserved verbatim in the
+// returned expression.
+//
+// Unlike [EvalCtx.EvalPartial], it returns an expression instead of a value,
+// so it can be formatted back into code (eg.: to preview generated code).
+func (e *EvalCtx) PartialEvalExpr(expr hhcl.Expression) (hhcl.Expression, error) {
+	return e.Context.PartialEval(expr)
+}
+
+// WithGlobalsOverride returns a copy of the evaluation context with the given
+// globals replacing the current ones, leaving the other globals and the
+// original context intact.
+//
+// The override is shallow: each key replaces the whole value of the top-level
+// global with the same name (eg.: overriding an object global replaces the
+// entire object instead of merging its attributes).
+func (e *EvalCtx) WithGlobalsOverride(overrides map[string]cty.Value) *EvalCtx {
+	ctx := e.Context.Copy()
+	globals := map[string]cty.Value{}
+	if current, ok := ctx.GetNamespace("global"); ok {
+		for name, val := range current.AsValueMap() {
+			globals[name] = val
+		}
+	}
+	for name, val := range overrides {
+		globals[name] = val
+	}
+	ctx.SetNamespace("global", globals)
+	return &EvalCtx{
+		Context: ctx,
+		root:    e.root,
+	}
+}
@@ -0,0 +1,420 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack_test
+
+import (
+	"testing"
+
+	"github.com/madlambda/spells/assert"
+	"github.com/mineiros-io/terramate/config"
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/globals"
+	"github.com/mineiros-io/terramate/hcl/ast"
+	"github.com/mineiros-io/terramate/hcl/eval"
+	"github.com/mineiros-io/terramate/project"
+	"github.com/mineiros-io/terramate/stack"
+	"github.com/mineiros-io/terramate/test"
+	"github.com/mineiros-io/terramate/test/sandbox"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestEvalCtxEvalPartial(t *testing.T) {
+	type testcase struct {
+		name    string
+		expr    string
+		want    cty.Value
+		unknown bool
+		wantErr bool
+	}
+
+	for _, tc := range []testcase{
+		{
+			name: "known global",
+			expr: `global.b`,
+			want: cty.StringVal("b"),
+		},
+		{
+			name: "known metadata",
+			expr: `terramate.stack.path.absolute`,
+			want: cty.StringVal("/stack"),
+		},
+		{
+			name:    "unknown global",
+			expr:    `global.a`,
+			unknown: true,
+		},
+		{
+			name:    "interpolation of unknown global",
+			expr:    `"${global.a}-${global.b}"`,
+			unknown: true,
+		},
+		{
+			name:    "function call with unknown global",
+			expr:    `tm_upper(global.a)`,
+			unknown: true,
+		},
+		{
+			name:    "undefined namespace",
+			expr:    `module.vpc.id`,
+			unknown: true,
+		},
+		{
+			name:    "undefined global is an error",
+			expr:    `global.undefined`,
+			wantErr: true,
+		},
+		{
+			name:    "undefined function is an error",
+			expr:    `undefined(global.b)`,
+			wantErr: true,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			s := sandbox.New(t)
+			s.BuildTree([]string{"s:stack"})
+
+			root := s.Config()
+			st := s.LoadStack(project.NewPath("/stack"))
+			evalctx := stack.NewEvalCtx(root, st, eval.NewObject(eval.Info{}))
+			evalctx.SetNamespace("global", map[string]cty.Value{
+				"a": cty.UnknownVal(cty.String),
+				"b": cty.StringVal("b"),
+			})
+
+			got, err := evalctx.EvalPartial(test.NewExpr(t, tc.expr))
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			if tc.unknown {
+				assert.IsTrue(t, !got.IsKnown(), "want unknown value, got %s", got.GoString())
+				return
+			}
+			assert.IsTrue(t, got.RawEquals(tc.want), "want %s, got %s", tc.want.GoString(), got.GoString())
+		})
+	}
+}
+
+func TestEvalCtxPartialEvalExpr(t *testing.T) {
+	type testcase struct {
+		name    string
+		expr    string
+		want    string
+		wantErr error
+	}
+
+	for _, tc := range []testcase{
+		{
+			name: "global",
+			expr: `global.b`,
+			want: `"a-b"`,
+		},
+		{
+			name: "metadata",
+			expr: `terramate.stack.name`,
+			want: `"stack"`,
+		},
+		{
+			name: "undefined namespace is preserved",
+			expr: `module.vpc.id`,
+			want: `module.vpc.id`,
+		},
+		{
+			name: "interpolation with undefined namespace",
+			expr: `"${global.a}-${module.vpc.id}-${terramate.stack.name}"`,
+			want: `"a-${module.vpc.id}-stack"`,
+		},
+		{
+			name: "list with undefined namespace",
+			expr: `[local.id, tm_upper(terramate.stack.path.basename)]`,
+			want: `[local.id,"STACK"]`,
+		},
+		{
+			name:    "undefined global is an error",
+			expr:    `"${global.undefined}-${module.vpc.id}"`,
+			wantErr: errors.E(eval.ErrPartial),
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			s := sandbox.New(t)
+			s.BuildTree([]string{
+				"s:stack",
+				`f:globals.tm:globals {
+				  a = "a"
+				  b = "${global.a}-b"
+				}`,
+			})
+
+			root := s.Config()
+			st := s.LoadStack(project.NewPath("/stack"))
+			evalctx := stack.NewEvalCtx(root, st, s.LoadStackGlobals(root, st))
+
+			got, err := evalctx.PartialEvalExpr(test.NewExpr(t, tc.expr))
+			assert.IsError(t, err, tc.wantErr)
+			if tc.wantErr != nil {
+				return
+			}
+			assert.EqualStrings(t, tc.want, string(ast.TokensForExpression(got).Bytes()))
+		})
+	}
+}
+
+func TestEvalCtxRootPathMetadata(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{"s:stacks/stack"})
+
+	root := s.Config()
+	st := s.LoadStack(project.NewPath("/stacks/stack"))
+	evalctx := stack.NewEvalCtx(root, st, eval.NewObject(eval.Info{}))
+
+	got, err := evalctx.Eval(test.NewExpr(t, `terramate.root.path.absolute`))
+	assert.NoError(t, err)
+	assert.EqualStrings(t, s.RootDir(), got.AsString())
+
+	got, err = evalctx.Eval(test.NewExpr(t, `terramate.root.path.fs.absolute`))
+	assert.NoError(t, err)
+	assert.EqualStrings(t, s.RootDir(), got.AsString())
+}
+
+func TestEvalCtxParentStackMetadata(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		`s:parent:id=parent-id`,
+		"d:parent/dir",
+		"s:parent/dir/child",
+		"s:top",
+	})
+
+	root := s.Config()
+	evalStack := func(dir, expr string) (cty.Value, error) {
+		st := s.LoadStack(project.NewPath(dir))
+		evalctx := stack.NewEvalCtx(root, st, eval.NewObject(eval.Info{}))
+		return evalctx.Eval(test.NewExpr(t, expr))
+	}
+
+	got, err := evalStack("/parent/dir/child", `terramate.stack.parent.path.absolute`)
+	assert.NoError(t, err)
+	assert.EqualStrings(t, "/parent", got.AsString())
+
+	got, err = evalStack("/parent/dir/child", `terramate.stack.parent.id`)
+	assert.NoError(t, err)
+	assert.EqualStrings(t, "parent-id", got.AsString())
+
+	got, err = evalStack("/parent/dir/child", `terramate.stack.parent.name`)
+	assert.NoError(t, err)
+	assert.EqualStrings(t, "parent", got.AsString())
+
+	got, err = evalStack("/top", `terramate.stack.parent == null`)
+	assert.NoError(t, err)
+	assert.IsTrue(t, got.True(), "top-level stack must have no parent")
+
+	got, err = evalStack("/parent", `terramate.stack.parent == null`)
+	assert.NoError(t, err)
+	assert.IsTrue(t, got.True(), "top-level stack must have no parent")
+}
+
+func TestEvalCtxChangedFilesMetadata(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{"s:stack"})
+
+	root := s.Config()
+	st := s.LoadStack(project.NewPath("/stack"))
+
+	evalctx := stack.NewEvalCtx(root, st, eval.NewObject(eval.Info{}))
+	got, err := evalctx.Eval(test.NewExpr(t, `tm_length(terramate.stack.changed_files)`))
+	assert.NoError(t, err)
+	assert.IsTrue(t, got.RawEquals(cty.NumberIntVal(0)), "want no changed files but got %v", got)
+
+	st.ChangedFiles = []string{"stack/main.tf", "stack/README.md"}
+	evalctx = stack.NewEvalCtx(root, st, eval.NewObject(eval.Info{}))
+	got, err = evalctx.Eval(test.NewExpr(t,
+		`[for f in terramate.stack.changed_files : f if tm_can(tm_regex("\\.tf$", f))]`))
+	assert.NoError(t, err)
+	want := cty.TupleVal([]cty.Value{cty.StringVal("stack/main.tf")})
+	assert.IsTrue(t, got.RawEquals(want), "want %v but got %v", want, got)
+}
+
+func TestEvalCtxFeaturesMetadata(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		`f:globals.tm:globals {
+		  env      = "prod"
+		  replicas = 3
+		}`,
+		`f:stack/terramate.tm.hcl:stack {
+		  features = {
+		    monitoring = global.env == "prod"
+		    autoscale  = global.replicas > 5
+		  }
+		}`,
+		"s:plain",
+	})
+
+	root := s.Config()
+	st := s.LoadStack(project.NewPath("/stack"))
+	report := globals.ForStack(root, st)
+	assert.NoError(t, report.AsError())
+	assert.IsTrue(t, st.Features == nil, "the stack must not be changed")
+
+	evalctx := stack.NewEvalCtx(root, st.WithFeatures(report.Features), report.Globals)
+	got, err := evalctx.Eval(test.NewExpr(t,
+		`terramate.stack.features.monitoring && !terramate.stack.features.autoscale ? "monitored" : "unmonitored"`))
+	assert.NoError(t, err)
+	assert.IsTrue(t, got.RawEquals(cty.StringVal("monitored")), "unexpected result: %v", got)
+
+	plain := s.LoadStack(project.NewPath("/plain"))
+	report = globals.ForStack(root, plain)
+	assert.NoError(t, report.AsError())
+
+	evalctx = stack.NewEvalCtx(root, plain.WithFeatures(report.Features), report.Globals)
+	got, err = evalctx.Eval(test.NewExpr(t, `tm_length(terramate.stack.features)`))
+	assert.NoError(t, err)
+	assert.IsTrue(t, got.RawEquals(cty.NumberIntVal(0)), "want no features but got %v", got)
+}
+
+func TestEvalCtxFeaturesMustBeBool(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		`f:globals.tm:globals {
+		  env = "prod"
+		}`,
+		`f:stack/terramate.tm.hcl:stack {
+		  features = {
+		    monitoring = global.env
+		  }
+		}`,
+	})
+
+	report := globals.ForStack(s.Config(), s.LoadStack(project.NewPath("/stack")))
+	assert.IsError(t, report.AsError(), errors.E(config.ErrStackInvalidFeatures))
+}
+
+func TestEvalCtxWithGlobalsOverride(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{"s:stack"})
+
+	root := s.Config()
+	st := s.LoadStack(project.NewPath("/stack"))
+	evalctx := stack.NewEvalCtx(root, st, eval.NewObject(eval.Info{}))
+	evalctx.SetNamespace("global", map[string]cty.Value{
+		"a": cty.StringVal("a"),
+		"b": cty.StringVal("b"),
+		"obj": cty.ObjectVal(map[string]cty.Value{
+			"x": cty.StringVal("x"),
+			"y": cty.StringVal("y"),
+		}),
+	})
+
+	overridden := evalctx.WithGlobalsOverride(map[string]cty.Value{
+		"a": cty.StringVal("overridden"),
+		"obj": cty.ObjectVal(map[string]cty.Value{
+			"x": cty.StringVal("overridden"),
+		}),
+	})
+
+	assertEval := func(evalctx *stack.EvalCtx, expr string, want cty.Value) {
+		t.Helper()
+
+		got, err := evalctx.Eval(test.NewExpr(t, expr))
+		assert.NoError(t, err)
+		assert.IsTrue(t, got.RawEquals(want), "%s: want %s, got %s", expr, want.GoString(), got.GoString())
+	}
+
+	assertEval(overridden, `global.a`, cty.StringVal("overridden"))
+	assertEval(overridden, `global.b`, cty.StringVal("b"))
+	assertEval(overridden, `global.obj.x`, cty.StringVal("overridden"))
+	assertEval(overridden, `terramate.stack.path.absolute`, cty.StringVal("/stack"))
+
+	// override is shallow
+	_, err := overridden.Eval(test.NewExpr(t, `global.obj.y`))
+	assert.Error(t, err)
+
+	// original context is unaffected
+	assertEval(evalctx, `global.a`, cty.StringVal("a"))
+	assertEval(evalctx, `global.obj.y`, cty.StringVal("y"))
+}
+
+func TestEvalCtxExtraNamespace(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{"s:stack"})
+
+	root := s.Config()
+	st := s.LoadStack(project.NewPath("/stack"))
+	evalctx := stack.NewEvalCtx(root, st, eval.NewObject(eval.Info{}))
+
+	assert.NoError(t, evalctx.SetExtraNamespace("plugin", map[string]cty.Value{
+		"name": cty.StringVal("myplugin"),
+	}))
+
+	got, err := evalctx.Eval(test.NewExpr(t, `"${plugin.name}-${terramate.stack.name}"`))
+	assert.NoError(t, err)
+	assert.IsTrue(t, got.RawEquals(cty.StringVal("myplugin-stack")), "got %s", got.GoString())
+
+	for _, name := range []string{"global", "let", "terramate", "", "not valid"} {
+		err := evalctx.SetExtraNamespace(name, map[string]cty.Value{})
+		assert.IsError(t, err, errors.E(stack.ErrReservedNamespace), "namespace %q", name)
+	}
+
+	got, err = evalctx.Eval(test.NewExpr(t, `terramate.stack.path.absolute`))
+	assert.NoError(t, err)
+	assert.IsTrue(t, got.RawEquals(cty.StringVal("/stack")), "reserved namespace changed")
+
+	// extra namespaces are kept by the globals override.
+	overridden := evalctx.WithGlobalsOverride(map[string]cty.Value{})
+	got, err = overridden.Eval(test.NewExpr(t, `plugin.name`))
+	assert.NoError(t, err)
+	assert.IsTrue(t, got.RawEquals(cty.StringVal("myplugin")), "got %s", got.GoString())
+}
+
+func TestEvalCtxMetadata(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		`s:parent:id=parent-id`,
+		`s:parent/child:id=child-id;tags=["a", "b"];description=desc`,
+	})
+
+	root := s.Config()
+	st := s.LoadStack(project.NewPath("/parent/child"))
+	evalctx := stack.NewEvalCtx(root, st, eval.NewObject(eval.Info{}))
+
+	metadata := evalctx.Metadata()
+	stackmeta := metadata["stack"]
+	assert.IsTrue(t, stackmeta.Type().IsObjectType(), "stack metadata is %s", stackmeta.GoString())
+
+	assertAttr := func(val cty.Value, want cty.Value, path ...string) {
+		t.Helper()
+		for _, attr := range path {
+			val = val.GetAttr(attr)
+		}
+		assert.IsTrue(t, val.RawEquals(want), "%v: want %s, got %s", path, want.GoString(), val.GoString())
+	}
+
+	assertAttr(stackmeta, cty.StringVal("child"), "name")
+	assertAttr(stackmeta, cty.StringVal("child-id"), "id")
+	assertAttr(stackmeta, cty.StringVal("desc"), "description")
+	assertAttr(stackmeta, cty.StringVal("/parent/child"), "path", "absolute")
+	assertAttr(stackmeta, cty.StringVal("parent-id"), "parent", "id")
+	assertAttr(stackmeta, cty.ListVal([]cty.Value{cty.StringVal("a"), cty.StringVal("b")}), "tags")
+	assertAttr(metadata["root"], cty.StringVal(s.RootDir()), "path", "fs", "absolute")
+
+	// the returned metadata is a copy.
+	delete(metadata, "stack")
+	_, ok := evalctx.Metadata()["stack"]
+	assert.IsTrue(t, ok, "metadata of the context must not change")
+}
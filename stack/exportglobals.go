@@ -0,0 +1,61 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/globals"
+	"github.com/rs/zerolog/log"
+	"github.com/zclconf/go-cty/cty"
+)
+
+const errExportGlobals errors.Kind = "exporting globals error"
+
+// ExportGlobals evaluates the globals of all stacks of the project and
+// returns them keyed by stack path, which is useful for auditing and
+// comparing the configuration of different stacks (eg.: environments).
+// The globals of each stack are keyed by name and are fully evaluated, so
+// they can be serialized (eg.: with the go-cty json package).
+//
+// The globals expressions of directories shared by multiple stacks are
+// loaded only once. The first stack whose globals fail to evaluate aborts
+// the export.
+func (m *Manager) ExportGlobals() (map[string]map[string]cty.Value, error) {
+	logger := log.With().
+		Str("action", "Manager.ExportGlobals()").
+		Logger()
+
+	stacks, err := List(m.root.Tree())
+	if err != nil {
+		return nil, errors.E(errExportGlobals, err)
+	}
+
+	cache := globals.NewExprsCache()
+	exported := make(map[string]map[string]cty.Value, len(stacks))
+	for _, entry := range stacks {
+		st := entry.Stack
+
+		logger.Trace().
+			Stringer("stack", st.Dir).
+			Msg("Evaluate stack globals.")
+
+		report := globals.ForStackWithCache(m.root, st, cache)
+		if err := report.AsError(); err != nil {
+			return nil, errors.E(errExportGlobals, err, "stack %s", st.Dir)
+		}
+		exported[st.Dir.String()] = report.Globals.AsValueMap()
+	}
+	return exported, nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/madlambda/spells/assert"
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/globals"
+	"github.com/mineiros-io/terramate/stack"
+	"github.com/mineiros-io/terramate/test/sandbox"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+func TestExportGlobals(t *testing.T) {
+	s := sandbox.NoGit(t)
+	s.BuildTree([]string{
+		`f:globals.tm:globals {
+			env    = "default"
+			region = "eu-west-1"
+		}`,
+		"s:envs/dev",
+		"s:envs/prod",
+		`f:envs/prod/globals.tm:globals {
+			env = "prod"
+		}`,
+		`f:envs/globals.tm:globals {
+			name = "${global.env}-${terramate.stack.name}"
+		}`,
+	})
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+	got, err := m.ExportGlobals()
+	assert.NoError(t, err)
+
+	want := map[string]map[string]cty.Value{
+		"/envs/dev": {
+			"env":    cty.StringVal("default"),
+			"region": cty.StringVal("eu-west-1"),
+			"name":   cty.StringVal("default-dev"),
+		},
+		"/envs/prod": {
+			"env":    cty.StringVal("prod"),
+			"region": cty.StringVal("eu-west-1"),
+			"name":   cty.StringVal("prod-prod"),
+		},
+	}
+
+	assert.EqualInts(t, len(want), len(got), "got %v", got)
+	for stackpath, wantGlobals := range want {
+		gotGlobals, ok := got[stackpath]
+		assert.IsTrue(t, ok, "stack %s missing", stackpath)
+		assertCtyObject(t, cty.ObjectVal(wantGlobals), cty.ObjectVal(gotGlobals))
+	}
+}
+
+func TestExportGlobalsNoStacks(t *testing.T) {
+	s := sandbox.NoGit(t)
+	s.BuildTree([]string{
+		`f:globals.tm:globals {
+			a = 1
+		}`,
+	})
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+	got, err := m.ExportGlobals()
+	assert.NoError(t, err)
+	assert.EqualInts(t, 0, len(got), "got %v", got)
+}
+
+func TestExportGlobalsFailsOnEvalError(t *testing.T) {
+	s := sandbox.NoGit(t)
+	s.BuildTree([]string{
+		"s:stack",
+		`f:stack/globals.tm:globals {
+			a = global.undefined
+		}`,
+	})
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+	_, err := m.ExportGlobals()
+	assert.IsError(t, err, errors.E(globals.ErrEval))
+}
+
+func assertCtyObject(t *testing.T, want, got cty.Value) {
+	t.Helper()
+
+	wantJSON, err := ctyjson.Marshal(want, want.Type())
+	assert.NoError(t, err)
+	gotJSON, err := ctyjson.Marshal(got, got.Type())
+	assert.NoError(t, err)
+
+	if diff := cmp.Diff(string(wantJSON), string(gotJSON)); diff != "" {
+		t.Fatalf("-(want) +(got):\n%s", diff)
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/git"
+	"github.com/rs/zerolog/log"
+)
+
+// ListChangedSinceForkPoint lists the stacks that have changed on the current
+// branch since it forked from the manager git base ref.
+//
+// Unlike [Manager.ListChanged], which compares HEAD with the git base ref
+// itself, the comparison base is the fork point of HEAD, found using the
+// reflog of the git base ref (see git merge-base --fork-point). This gives
+// the right result even if the git base ref history was rewritten after the
+// branch was created (eg.: it was rebased and force-pushed), in which case
+// changes made only on the old history of the base ref would be attributed
+// to the current branch.
+//
+// If the fork point can't be determined (eg.: the reflog is not available in
+// a fresh clone), the merge base of the git base ref and HEAD is used instead.
+func (m *Manager) ListChangedSinceForkPoint() (*Report, error) {
+	if m.useDefaultBranch() {
+		baseManager, err := m.withBaseRef()
+		if err != nil {
+			return nil, errors.E(errListChanged, err)
+		}
+		return baseManager.ListChangedSinceForkPoint()
+	}
+
+	logger := log.With().
+		Str("action", "Manager.ListChangedSinceForkPoint()").
+		Str("baseRef", m.gitBaseRef).
+		Logger()
+
+	g, err := m.newGit(m.root.HostDir())
+	if err != nil {
+		return nil, errors.E(errListChanged, err)
+	}
+
+	base, err := g.ForkPoint(m.gitBaseRef)
+	if err != nil {
+		if !errors.Is(err, git.ErrForkPointNotFound) {
+			return nil, errors.E(errListChanged, err, "finding fork point")
+		}
+
+		logger.Debug().Msg("Fork point not found, falling back to merge base.")
+
+		base, err = g.MergeBase(m.gitBaseRef, "HEAD")
+		if err != nil {
+			return nil, errors.E(errListChanged, err, "finding merge base")
+		}
+	}
+
+	logger.Debug().
+		Str("forkPoint", base).
+		Msg("List changed stacks since fork point.")
+
+	forkManager := m.clone()
+	forkManager.gitBaseRef = base
+	return forkManager.ListChanged()
+}
@@ -0,0 +1,228 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/mineiros-io/terramate/config"
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/project"
+	"github.com/mineiros-io/terramate/run"
+	"github.com/mineiros-io/terramate/run/dag"
+	"github.com/mineiros-io/terramate/tf"
+	"github.com/rs/zerolog/log"
+)
+
+const errDependencyGraph errors.Kind = "building dependency graph error"
+
+const (
+	// GraphNodeStack is the type of graph nodes representing stacks.
+	GraphNodeStack = "stack"
+
+	// GraphNodeModule is the type of graph nodes representing local
+	// Terraform modules.
+	GraphNodeModule = "module"
+)
+
+const (
+	// GraphEdgeAfter is the type of edges from a stack to a stack that must
+	// run before it, as defined by the before and after stack attributes.
+	GraphEdgeAfter = "after"
+
+	// GraphEdgeWants is the type of edges from a stack to a stack it wants,
+	// as defined by the wants and wanted_by stack attributes.
+	GraphEdgeWants = "wants"
+
+	// GraphEdgeModule is the type of edges from a stack or module to a local
+	// module it uses.
+	GraphEdgeModule = "module"
+)
+
+type (
+	// Graph is the dependency graph of the project stacks.
+	Graph struct {
+		Nodes []GraphNode `json:"nodes"`
+		Edges []GraphEdge `json:"edges"`
+	}
+
+	// GraphNode is a node of the dependency graph. Nodes are identified by
+	// their project path, which is unique among stacks and modules.
+	GraphNode struct {
+		Type string `json:"type"`
+		Path string `json:"path"`
+		ID   string `json:"id,omitempty"` // ID is the stack id, if any.
+	}
+
+	// GraphEdge is a typed edge of the dependency graph. The From and To
+	// fields are project paths of nodes.
+	GraphEdge struct {
+		Type string `json:"type"`
+		From string `json:"from"`
+		To   string `json:"to"`
+	}
+)
+
+// DependencyGraphJSON returns the JSON representation of the dependency
+// graph of all the project stacks, with module, ordering and wants edges.
+// The implicit ordering of nested stacks is not represented.
+func (m *Manager) DependencyGraphJSON() ([]byte, error) {
+	graph, err := m.DependencyGraph()
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(graph)
+	if err != nil {
+		return nil, errors.E(errDependencyGraph, err)
+	}
+	return data, nil
+}
+
+// DependencyGraph returns the dependency graph of all the project stacks.
+// Nodes and edges are sorted by path.
+func (m *Manager) DependencyGraph() (*Graph, error) {
+	logger := log.With().
+		Str("action", "Manager.DependencyGraph()").
+		Logger()
+
+	allstacks, err := config.LoadAllStacks(m.root.Tree())
+	if err != nil {
+		return nil, errors.E(errDependencyGraph, err)
+	}
+	sort.Sort(allstacks)
+
+	graph := &Graph{
+		Nodes: []GraphNode{},
+		Edges: []GraphEdge{},
+	}
+
+	logger.Trace().Msg("Add stack nodes.")
+
+	for _, elem := range allstacks {
+		graph.Nodes = append(graph.Nodes, GraphNode{
+			Type: GraphNodeStack,
+			Path: elem.Dir().String(),
+			ID:   elem.Stack.ID,
+		})
+	}
+
+	logger.Trace().Msg("Add ordering edges.")
+
+	orderDag, err := m.buildDAG(allstacks,
+		"before", func(s config.Stack) []string { return s.Before },
+		"after", func(s config.Stack) []string { return s.After },
+	)
+	if err != nil {
+		return nil, errors.E(errDependencyGraph, err)
+	}
+	graph.addDAGEdges(GraphEdgeAfter, orderDag)
+
+	logger.Trace().Msg("Add wants edges.")
+
+	wantsDag, err := m.buildDAG(allstacks,
+		"wanted_by", func(s config.Stack) []string { return s.WantedBy },
+		"wants", func(s config.Stack) []string { return s.Wants },
+	)
+	if err != nil {
+		return nil, errors.E(errDependencyGraph, err)
+	}
+	graph.addDAGEdges(GraphEdgeWants, wantsDag)
+
+	logger.Trace().Msg("Add module nodes and edges.")
+
+	cache := tf.NewModuleCache()
+	modules := map[project.Path]struct{}{}
+	moduleEdges := map[GraphEdge]struct{}{}
+	for _, elem := range allstacks {
+		err := elem.Stack.WalkLocalModules(m.root, cache, func(use config.LocalModuleUse) error {
+			if use.Err != nil {
+				return use.Err
+			}
+
+			edge := GraphEdge{
+				Type: GraphEdgeModule,
+				From: use.UsedBy.String(),
+				To:   use.Dir.String(),
+			}
+			if _, ok := moduleEdges[edge]; !ok {
+				moduleEdges[edge] = struct{}{}
+				graph.Edges = append(graph.Edges, edge)
+			}
+
+			if _, ok := modules[use.Dir]; !ok {
+				modules[use.Dir] = struct{}{}
+				graph.Nodes = append(graph.Nodes, GraphNode{
+					Type: GraphNodeModule,
+					Path: use.Dir.String(),
+				})
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, errors.E(errDependencyGraph, err, "stack %s", elem.Dir())
+		}
+	}
+
+	sort.Slice(graph.Nodes, func(i, j int) bool {
+		return graph.Nodes[i].Path < graph.Nodes[j].Path
+	})
+	sort.Slice(graph.Edges, func(i, j int) bool {
+		a, b := graph.Edges[i], graph.Edges[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		if a.To != b.To {
+			return a.To < b.To
+		}
+		return a.Type < b.Type
+	})
+	return graph, nil
+}
+
+func (m *Manager) buildDAG(
+	stacks config.List[*config.SortableStack],
+	descendantsName string,
+	getDescendants func(config.Stack) []string,
+	ancestorsName string,
+	getAncestors func(config.Stack) []string,
+) (*dag.DAG, error) {
+	d := dag.New()
+	visited := dag.Visited{}
+	for _, elem := range stacks {
+		err := run.BuildDAG(d, m.root, elem.Stack,
+			descendantsName, getDescendants,
+			ancestorsName, getAncestors,
+			visited,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// addDAGEdges adds an edge from each DAG node to each of its ancestors.
+func (g *Graph) addDAGEdges(edgeType string, d *dag.DAG) {
+	for _, id := range d.IDs() {
+		for _, ancestor := range d.AncestorsOf(id) {
+			g.Edges = append(g.Edges, GraphEdge{
+				Type: edgeType,
+				From: string(id),
+				To:   string(ancestor),
+			})
+		}
+	}
+}
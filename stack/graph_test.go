@@ -0,0 +1,134 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/madlambda/spells/assert"
+	"github.com/mineiros-io/terramate/stack"
+	"github.com/mineiros-io/terramate/test/sandbox"
+)
+
+func TestDependencyGraphJSON(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		`s:stack-a:id=a`,
+		`s:stack-b:id=b;after=["/stack-a"]`,
+		`s:stack-c:before=["/stack-b"];wants=["/stack-a"]`,
+		`f:stack-b/main.tf:module "mod" {
+			source = "../modules/mod"
+		}
+		module "remote" {
+			source = "github.com/mineiros-io/example"
+		}`,
+		`f:stack-c/main.tf:module "mod" {
+			source = "../modules/mod"
+		}`,
+		`f:modules/mod/main.tf:module "nested" {
+			source = "../nested"
+		}`,
+		`f:modules/nested/main.tf:# nested module`,
+	})
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+	data, err := m.DependencyGraphJSON()
+	assert.NoError(t, err)
+
+	var got stack.Graph
+	assert.NoError(t, json.Unmarshal(data, &got), "invalid JSON: %s", data)
+
+	want := stack.Graph{
+		Nodes: []stack.GraphNode{
+			{Type: stack.GraphNodeModule, Path: "/modules/mod"},
+			{Type: stack.GraphNodeModule, Path: "/modules/nested"},
+			{Type: stack.GraphNodeStack, Path: "/stack-a", ID: "a"},
+			{Type: stack.GraphNodeStack, Path: "/stack-b", ID: "b"},
+			{Type: stack.GraphNodeStack, Path: "/stack-c"},
+		},
+		Edges: []stack.GraphEdge{
+			{Type: stack.GraphEdgeModule, From: "/modules/mod", To: "/modules/nested"},
+			{Type: stack.GraphEdgeModule, From: "/stack-b", To: "/modules/mod"},
+			{Type: stack.GraphEdgeAfter, From: "/stack-b", To: "/stack-a"},
+			{Type: stack.GraphEdgeAfter, From: "/stack-b", To: "/stack-c"},
+			{Type: stack.GraphEdgeModule, From: "/stack-c", To: "/modules/mod"},
+			{Type: stack.GraphEdgeWants, From: "/stack-c", To: "/stack-a"},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("graph mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDependencyGraphModuleCycles(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		`s:stack`,
+		`f:stack/main.tf:module "a" {
+			source = "../modules/a"
+		}
+		module "link" {
+			source = "../modules/link"
+		}`,
+		`f:modules/a/main.tf:module "b" {
+			source = "../b"
+		}
+		module "self" {
+			source = "./self"
+		}`,
+		`f:modules/b/main.tf:module "a" {
+			source = "../a"
+		}`,
+	})
+	assert.NoError(t, os.Symlink("a", filepath.Join(s.RootDir(), "modules", "link")))
+	assert.NoError(t, os.Symlink(".", filepath.Join(s.RootDir(), "modules", "a", "self")))
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+	got, err := m.DependencyGraph()
+	assert.NoError(t, err)
+
+	want := &stack.Graph{
+		Nodes: []stack.GraphNode{
+			{Type: stack.GraphNodeModule, Path: "/modules/a"},
+			{Type: stack.GraphNodeModule, Path: "/modules/b"},
+			{Type: stack.GraphNodeStack, Path: "/stack"},
+		},
+		Edges: []stack.GraphEdge{
+			{Type: stack.GraphEdgeModule, From: "/modules/a", To: "/modules/a"},
+			{Type: stack.GraphEdgeModule, From: "/modules/a", To: "/modules/b"},
+			{Type: stack.GraphEdgeModule, From: "/modules/b", To: "/modules/a"},
+			{Type: stack.GraphEdgeModule, From: "/stack", To: "/modules/a"},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("graph mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDependencyGraphNoStacks(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		`f:modules/mod/main.tf:# module`,
+	})
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+	data, err := m.DependencyGraphJSON()
+	assert.NoError(t, err)
+	assert.EqualStrings(t, `{"nodes":[],"edges":[]}`, string(data))
+}
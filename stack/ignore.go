@@ -0,0 +1,83 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+	"github.com/mineiros-io/terramate/config"
+)
+
+// defaultIgnorePattern ignores the changed files inside hidden files or
+// directories of the project root. It is always the first rule, so it can be
+// negated by the terramate.config.change_detection.ignore patterns.
+const defaultIgnorePattern = "/.*"
+
+// ignoreRule is a gitignore-style pattern of changed files which must not be
+// attributed to any stack.
+type ignoreRule struct {
+	pattern gitignore.Pattern
+	reason  string
+}
+
+// ignoreRules is an ordered list of ignore rules. As in gitignore files, the
+// last rule matching a file decides if it is ignored or not.
+type ignoreRules []ignoreRule
+
+// loadIgnoreRules loads the default ignore rule followed by the patterns of
+// the terramate.config.change_detection.ignore attribute, if any.
+func loadIgnoreRules(root *config.Root) ignoreRules {
+	rules := ignoreRules{
+		{
+			pattern: gitignore.ParsePattern(defaultIgnorePattern, nil),
+			reason:  "file starts with .",
+		},
+	}
+
+	cfg := root.Tree().Node
+	if cfg.Terramate == nil ||
+		cfg.Terramate.Config == nil ||
+		cfg.Terramate.Config.ChangeDetection == nil {
+		return rules
+	}
+
+	for _, rawPattern := range cfg.Terramate.Config.ChangeDetection.Ignore {
+		rules = append(rules, ignoreRule{
+			pattern: gitignore.ParsePattern(rawPattern, nil),
+			reason:  fmt.Sprintf("file matches ignore pattern %q", rawPattern),
+		})
+	}
+	return rules
+}
+
+// match tells if the file, relative to the project root, must be ignored and
+// the reason for it.
+func (rules ignoreRules) match(file string) (string, bool) {
+	components := strings.Split(file, "/")
+
+	reason := ""
+	ignored := false
+	for _, rule := range rules {
+		switch rule.pattern.Match(components, false) {
+		case gitignore.Exclude:
+			reason, ignored = rule.reason, true
+		case gitignore.Include:
+			reason, ignored = "", false
+		}
+	}
+	return reason, ignored
+}
@@ -0,0 +1,99 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack_test
+
+import (
+	"testing"
+
+	"github.com/madlambda/spells/assert"
+	"github.com/mineiros-io/terramate/project"
+	"github.com/mineiros-io/terramate/stack"
+	"github.com/mineiros-io/terramate/stack/trigger"
+	"github.com/mineiros-io/terramate/test/sandbox"
+)
+
+func TestListChangedIgnorePatterns(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stack-cache",
+		"s:stack-zip",
+		"s:stack-keep",
+		"s:stack-triggered",
+		"s:stack-changed",
+		`f:root.tm:terramate {
+			config {
+				change_detection {
+					ignore = [
+						"**/.terraform/",
+						"*.zip",
+						"!keep.zip",
+						"/.tmtriggers/",
+					]
+				}
+			}
+		}`,
+	})
+
+	git := s.Git()
+	git.CommitAll("first commit")
+	git.Push("main")
+	git.CheckoutNew("change-stacks")
+
+	root := s.Config()
+	assert.NoError(t, trigger.Create(root, project.NewPath("/stack-triggered"), "reason"))
+
+	s.BuildTree([]string{
+		"f:stack-cache/.terraform/providers/provider.lock:lock",
+		"f:stack-zip/lambda.zip:zip",
+		"f:stack-keep/keep.zip:zip",
+		"f:stack-changed/main.tf:# changed",
+	})
+	git.CommitAll("change stacks")
+
+	m := stack.NewManager(root, defaultBranch)
+	report, err := m.ListChanged()
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/stack-changed", "/stack-keep", "/stack-triggered"}, report.Stacks, true)
+
+	for _, entry := range report.Stacks {
+		if entry.Stack.Dir.String() == "/stack-triggered" {
+			assert.EqualStrings(t, string(stack.ChangeKindTrigger), string(entry.Kind))
+		}
+	}
+}
+
+func TestListChangedIgnoresHiddenFilesByDefault(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:/",
+		"s:stack",
+	})
+
+	git := s.Git()
+	git.CommitAll("first commit")
+	git.Push("main")
+	git.CheckoutNew("change-hidden")
+
+	s.BuildTree([]string{
+		"f:.github/workflows/ci.yml:# ci",
+		"f:stack/.hidden:not ignored",
+	})
+	git.CommitAll("change hidden files")
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+	report, err := m.ListChanged()
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/stack"}, report.Stacks, true)
+}
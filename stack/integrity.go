@@ -0,0 +1,184 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/mineiros-io/terramate/config"
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/project"
+	"github.com/rs/zerolog/log"
+)
+
+// IntegrityIssueKind is the kind of a configuration integrity issue.
+type IntegrityIssueKind string
+
+const (
+	// IntegrityDanglingAfter means a stack.after entry doesn't match any stack.
+	IntegrityDanglingAfter IntegrityIssueKind = "dangling_after"
+
+	// IntegrityDanglingBefore means a stack.before entry doesn't match any
+	// stack.
+	IntegrityDanglingBefore IntegrityIssueKind = "dangling_before"
+
+	// IntegrityDanglingWants means a stack.wants entry doesn't match any stack.
+	IntegrityDanglingWants IntegrityIssueKind = "dangling_wants"
+
+	// IntegrityDanglingWantedBy means a stack.wanted_by entry doesn't match
+	// any stack.
+	IntegrityDanglingWantedBy IntegrityIssueKind = "dangling_wanted_by"
+
+	// IntegrityDuplicateID means the stack has the same id of another stack.
+	IntegrityDuplicateID IntegrityIssueKind = "duplicate_id"
+)
+
+// integrityKindOrder defines the order of the issues returned by
+// [Manager.ConfigIntegrity], so issues of the same kind are grouped together.
+var integrityKindOrder = map[IntegrityIssueKind]int{
+	IntegrityDanglingAfter:    0,
+	IntegrityDanglingBefore:   1,
+	IntegrityDanglingWants:    2,
+	IntegrityDanglingWantedBy: 3,
+	IntegrityDuplicateID:      4,
+}
+
+// IntegrityIssue is an inconsistency found in the configuration of a stack.
+type IntegrityIssue struct {
+	Kind IntegrityIssueKind
+
+	// Stack is the stack with the inconsistent configuration.
+	Stack project.Path
+
+	// Ref is the offending value (eg.: the dangling path or the duplicated id).
+	Ref string
+
+	// Reason describes the issue.
+	Reason string
+}
+
+// ConfigIntegrity checks the configuration of all stacks of the project for
+// references that don't match any stack, which usually happens after stacks
+// are moved or deleted without updating the stacks referencing them:
+//
+//   - stack.after and stack.before entries that are not stacks or
+//     directories with stacks, or tag filters not matching any stack.
+//   - stack.wants and stack.wanted_by entries that are not stacks or
+//     directories with stacks.
+//   - stack ids used by more than one stack.
+//
+// The issues are grouped by kind, then sorted by stack path.
+// An error is only returned if the stacks can't be loaded.
+func (m *Manager) ConfigIntegrity() ([]IntegrityIssue, error) {
+	logger := log.With().
+		Str("action", "Manager.ConfigIntegrity()").
+		Logger()
+
+	var issues []IntegrityIssue
+
+	// stacks are loaded individually because config.LoadAllStacks fails
+	// on duplicated ids.
+	idOwners := map[string]project.Path{}
+	for _, tree := range m.root.Tree().Stacks() {
+		st, err := config.NewStackFromHCL(m.root.HostDir(), tree.Node)
+		if err != nil {
+			return nil, errors.E(err, "loading stack %s", tree.Dir())
+		}
+
+		logger.Trace().
+			Stringer("stack", st.Dir).
+			Msg("Check stack references.")
+
+		for _, ref := range []struct {
+			kind  IntegrityIssueKind
+			field string
+			paths []string
+		}{
+			{IntegrityDanglingAfter, "after", st.After},
+			{IntegrityDanglingBefore, "before", st.Before},
+			{IntegrityDanglingWants, "wants", st.Wants},
+			{IntegrityDanglingWantedBy, "wanted_by", st.WantedBy},
+		} {
+			for _, pathstr := range ref.paths {
+				reason, ok := m.danglingReason(st, pathstr)
+				if !ok {
+					continue
+				}
+				issues = append(issues, IntegrityIssue{
+					Kind:   ref.kind,
+					Stack:  st.Dir,
+					Ref:    pathstr,
+					Reason: fmt.Sprintf("stack.%s entry %q %s", ref.field, pathstr, reason),
+				})
+			}
+		}
+
+		if st.ID == "" {
+			continue
+		}
+		if other, ok := idOwners[st.ID]; ok {
+			issues = append(issues, IntegrityIssue{
+				Kind:   IntegrityDuplicateID,
+				Stack:  st.Dir,
+				Ref:    st.ID,
+				Reason: fmt.Sprintf("stack id %q is also used by stack %s", st.ID, other),
+			})
+			continue
+		}
+		idOwners[st.ID] = st.Dir
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		a, b := issues[i], issues[j]
+		if a.Kind != b.Kind {
+			return integrityKindOrder[a.Kind] < integrityKindOrder[b.Kind]
+		}
+		return a.Stack.String() < b.Stack.String()
+	})
+	return issues, nil
+}
+
+// danglingReason returns why the stack reference is dangling or false if the
+// reference matches stacks. Relative paths are relative to the stack dir and,
+// as in the run order (see config.Root.StacksByPaths), a directory matches all
+// the stacks inside it.
+func (m *Manager) danglingReason(st *config.Stack, ref string) (string, bool) {
+	if strings.HasPrefix(ref, "tag:") {
+		filter := strings.TrimPrefix(ref, "tag:")
+		paths, err := m.root.StacksByTagsFilters([]string{filter})
+		if err != nil {
+			return fmt.Sprintf("is an invalid tag filter: %v", err), true
+		}
+		if len(paths) == 0 {
+			return "matches no stacks", true
+		}
+		return "", false
+	}
+
+	target := ref
+	if !path.IsAbs(target) {
+		target = path.Join(st.Dir.String(), target)
+	}
+	if _, found := m.root.Lookup(project.NewPath(path.Clean(target))); !found {
+		return "does not exist", true
+	}
+	if len(m.root.StacksByPaths(st.Dir, ref)) == 0 {
+		return "has no stacks", true
+	}
+	return "", false
+}
@@ -0,0 +1,86 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/madlambda/spells/assert"
+	"github.com/mineiros-io/terramate/project"
+	"github.com/mineiros-io/terramate/stack"
+	"github.com/mineiros-io/terramate/test/sandbox"
+)
+
+func TestConfigIntegrity(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		`s:stack-a:id=a;after=["/removed", "../stack-b", "tag:unknown"]`,
+		`s:stack-b:id=b;tags=["net"];before=["tag:net"];wants=["/stack-a"]`,
+		`s:stack-c:wanted_by=["/dir"];after=["/stack-b"]`,
+		`s:dup:id=a`,
+		`d:dir`,
+	})
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+	issues, err := m.ConfigIntegrity()
+	assert.NoError(t, err)
+
+	want := []stack.IntegrityIssue{
+		{
+			Kind:   stack.IntegrityDanglingAfter,
+			Stack:  project.NewPath("/stack-a"),
+			Ref:    "/removed",
+			Reason: `stack.after entry "/removed" does not exist`,
+		},
+		{
+			Kind:   stack.IntegrityDanglingAfter,
+			Stack:  project.NewPath("/stack-a"),
+			Ref:    "tag:unknown",
+			Reason: `stack.after entry "tag:unknown" matches no stacks`,
+		},
+		{
+			Kind:   stack.IntegrityDanglingWantedBy,
+			Stack:  project.NewPath("/stack-c"),
+			Ref:    "/dir",
+			Reason: `stack.wanted_by entry "/dir" has no stacks`,
+		},
+		{
+			Kind:   stack.IntegrityDuplicateID,
+			Stack:  project.NewPath("/stack-a"),
+			Ref:    "a",
+			Reason: `stack id "a" is also used by stack /dup`,
+		},
+	}
+	if diff := cmp.Diff(want, issues, cmp.AllowUnexported(project.Path{})); diff != "" {
+		t.Fatalf("unexpected issues (-want +got):\n%s", diff)
+	}
+}
+
+func TestConfigIntegrityNoIssues(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		`s:stack-a:id=a`,
+		`s:stack-b:id=b;after=["/stack-a"];wanted_by=["../stack-a"]`,
+		`s:stack-c:before=["/parent"];wants=["../parent/child-a"]`,
+		`s:parent/child-a`,
+		`s:parent/dir/child-b`,
+	})
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+	issues, err := m.ConfigIntegrity()
+	assert.NoError(t, err)
+	assert.EqualInts(t, 0, len(issues))
+}
@@ -20,10 +20,13 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"runtime"
 	"sort"
 	"strings"
+	"sync"
 
 	"github.com/mineiros-io/terramate/config"
+	"github.com/mineiros-io/terramate/config/filter"
 	"github.com/mineiros-io/terramate/errors"
 	"github.com/mineiros-io/terramate/git"
 	"github.com/mineiros-io/terramate/project"
@@ -36,9 +39,87 @@ import (
 
 type (
 	// Manager is the terramate stacks manager.
+	// A Manager holds no mutable state, so it can be used by multiple
+	// goroutines concurrently (eg.: calling List and ListChanged in parallel),
+	// as long as its config root is not modified (see [config.Root]).
 	Manager struct {
 		root       *config.Root // whole config
 		gitBaseRef string       // gitBaseRef is the git ref where we compare changes.
+
+		// gitHeadRef is the git ref compared with gitBaseRef. If empty,
+		// HEAD is used.
+		gitHeadRef string
+
+		// touchedFiles, if not nil, is used as the set of changed files
+		// (relative to the project root) instead of the git diff from
+		// gitBaseRef to HEAD.
+		touchedFiles []string
+
+		// withoutGit, if true, makes the change detection rely only on
+		// touchedFiles, without running any git command, so the repository
+		// checks, the root config changes and the moved stacks are not
+		// reported.
+		withoutGit bool
+
+		// gitLimiter limits the git commands run by the manager, shared by
+		// all its operations (see ManagerOptions.GitConcurrency).
+		gitLimiter *git.Limiter
+
+		opts ManagerOptions
+	}
+
+	// ManagerOptions are the optional settings of a Manager.
+	ManagerOptions struct {
+		// MaxStacks is the maximum number of stacks the listing methods
+		// (eg.: List and ListChanged) can return. If the result has more
+		// stacks, an error of kind ErrTooManyStacks is returned instead.
+		// Zero (the default) means unlimited.
+		MaxStacks int
+
+		// TraceFile, if not empty, is the path of a file where the
+		// ListChanged family of methods writes a trace of every change
+		// attribution decision (see TraceEntry), which is useful to debug the
+		// change detection (eg.: on CI). The file is overwritten on each
+		// call, so concurrent calls must not share the same trace file.
+		TraceFile string
+
+		// GitConcurrency is the maximum number of git commands the manager
+		// runs at the same time, across all its operations, even when they
+		// are called concurrently. Zero (the default) means
+		// DefaultGitConcurrency.
+		GitConcurrency int
+
+		// ModuleConcurrency is the maximum number of stacks whose local
+		// modules are checked concurrently by the ListChanged family of
+		// methods. Zero (the default) means the number of CPUs.
+		ModuleConcurrency int
+
+		// IncludeLocalChanges, if true, makes the ListChanged family of
+		// methods also consider the untracked and uncommitted files of the
+		// repository (see RepoChecks) as changed files, so stacks with local
+		// changes not committed yet are reported as changed. It has no
+		// effect when comparing against a head ref other than HEAD or when
+		// the changed files are given explicitly (eg.: ListChangedFromDiff).
+		IncludeLocalChanges bool
+
+		// MergeBase, if true, makes the ListChanged family of methods
+		// compare the head ref with the merge base of the git base ref and
+		// the head ref (ie.: git diff base...HEAD) instead of the git base
+		// ref itself (ie.: git diff base..HEAD), so changes made on the base
+		// ref after the branch point are not attributed to the current
+		// branch. It has no effect when the changed files are given
+		// explicitly (eg.: ListChangedFromDiff).
+		MergeBase bool
+
+		// ModuleVersionChanges, if true, makes the ListChanged family of
+		// methods report the stacks whose Terraform files changed the version
+		// constraint of a remote module (eg.: a registry module pinned with
+		// version = "~> 3.0") as changed by a module (see ChangeKindModule)
+		// instead of a direct change. It only relabels stacks which are
+		// already changed directly, so it never adds stacks to the result.
+		// It has no effect when the changed files are given explicitly
+		// (eg.: ListChangedFromDiff).
+		ModuleVersionChanges bool
 	}
 
 	// Report is the report of project's stacks and the result of its default checks.
@@ -47,6 +128,19 @@ type (
 
 		// Checks contains the result info of default checks.
 		Checks RepoChecks
+
+		// RootConfigChanges lists the root configuration attributes that
+		// changed compared to the git base ref (eg.: RootConfigGitDefaultBranch).
+		// These changes can affect every stack of the project, so if it is not
+		// empty the whole project must be re-evaluated.
+		// It is only filled by the ListChanged family of methods.
+		RootConfigChanges []string
+
+		// ChangedFiles lists the changed files compared to the git base ref,
+		// relative to the project root and sorted, including the files not
+		// belonging to any stack (eg.: Terramate files of parent directories).
+		// It is only filled by the ListChanged family of methods.
+		ChangedFiles []string
 	}
 
 	// RepoChecks contains the info of default checks.
@@ -58,19 +152,50 @@ type (
 	// Entry is a stack entry result.
 	Entry struct {
 		Stack  *config.Stack
-		Reason string // Reason why this entry was returned.
+		Reason string     // Reason why this entry was returned.
+		Kind   ChangeKind // Kind of change, if the entry is a changed stack.
+
+		// MovedFrom is the previous directory of the stack, if the stack
+		// was moved since the git base ref (see ChangeKindMoved).
+		MovedFrom project.Path
 	}
 )
 
 const errList errors.Kind = "listing stacks error"
 const errListChanged errors.Kind = "listing changed stacks error"
 
+// ErrTooManyStacks indicates that the stacks listed exceed the
+// ManagerOptions.MaxStacks limit.
+const ErrTooManyStacks errors.Kind = "too many stacks"
+
+// ErrShallowRepo indicates that a git revision needed for change detection is
+// not available because the repository is a shallow clone.
+const ErrShallowRepo errors.Kind = "revision not available in shallow repository"
+
+// DefaultGitConcurrency is the default maximum number of git commands a
+// Manager runs at the same time.
+const DefaultGitConcurrency = 16
+
 // NewManager creates a new stack manager.The root is the project root config
-// and and gitBaseRef is the git reference to compare for changes.
+// and and gitBaseRef is the git reference to compare for changes. If gitBaseRef
+// is empty, the default branch of the origin remote is detected and used
+// instead (see [git.Git.DefaultBranch]).
 func NewManager(root *config.Root, gitBaseRef string) *Manager {
+	return NewManagerWithOptions(root, gitBaseRef, ManagerOptions{})
+}
+
+// NewManagerWithOptions creates a new stack manager like [NewManager] but
+// with the given options.
+func NewManagerWithOptions(root *config.Root, gitBaseRef string, opts ManagerOptions) *Manager {
+	concurrency := opts.GitConcurrency
+	if concurrency <= 0 {
+		concurrency = DefaultGitConcurrency
+	}
 	return &Manager{
 		root:       root,
 		gitBaseRef: gitBaseRef,
+		gitLimiter: git.NewLimiter(concurrency),
+		opts:       opts,
 	}
 }
 
@@ -87,6 +212,52 @@ func (m *Manager) List() (*Report, error) {
 	if err != nil {
 		return nil, err
 	}
+	return m.listReport(entries)
+}
+
+// ListByTags works like [Manager.List] but only returns the stacks whose tags
+// match the filters, with the same semantics as the --tags and --no-tags
+// command line options: the tags filters use ":" as the AND operator and ","
+// as the OR operator, multiple filters are ORed and the stacks containing any
+// of the noTags are excluded. If no filter is given, all stacks are returned.
+// Malformed filters and invalid tag names are reported as errors.
+func (m *Manager) ListByTags(tags []string, noTags []string) (*Report, error) {
+	logger := log.With().
+		Str("action", "Manager.ListByTags()").
+		Strs("tags", tags).
+		Strs("noTags", noTags).
+		Logger()
+
+	clauses, hasFilter, err := filter.ParseTagFilters(tags, noTags)
+	if err != nil {
+		return nil, errors.E(errList, err, "parsing tag filters")
+	}
+
+	logger.Debug().Msg("List stacks.")
+
+	entries := []Entry{}
+	err = ListWith(m.root.Tree(), func(entry Entry) error {
+		if !hasFilter || filter.MatchTags(clauses, entry.Stack.Tags) {
+			entries = append(entries, entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m.listReport(entries)
+}
+
+// listReport creates the report of the listed stacks, with the repository
+// checks if the project is a git repository.
+func (m *Manager) listReport(entries []Entry) (*Report, error) {
+	logger := log.With().
+		Str("action", "Manager.listReport()").
+		Logger()
+
+	if err := m.checkMaxStacks(len(entries)); err != nil {
+		return nil, err
+	}
 
 	report := &Report{
 		Stacks: entries,
@@ -95,9 +266,7 @@ func (m *Manager) List() (*Report, error) {
 	logger.Trace().Str("repo", m.root.HostDir()).
 		Msg("Create git wrapper for repo.")
 
-	g, err := git.WithConfig(git.Config{
-		WorkingDir: m.root.HostDir(),
-	})
+	g, err := m.newGit(m.root.HostDir())
 	if err != nil {
 		return nil, errors.E(errList, err)
 	}
@@ -115,61 +284,251 @@ func (m *Manager) List() (*Report, error) {
 	return report, nil
 }
 
+// ListWith calls fn for each stack of the project as soon as it is found,
+// in the same order as [Manager.List], which is useful to show the stacks
+// while the project is still being walked. The walk stops at the first error
+// returned by fn, which is returned as is. Unlike List, no repository checks
+// are done.
+func (m *Manager) ListWith(fn func(Entry) error) error {
+	count := 0
+	return ListWith(m.root.Tree(), func(entry Entry) error {
+		count++
+		if err := m.checkMaxStacks(count); err != nil {
+			return err
+		}
+		return fn(entry)
+	})
+}
+
 // ListChanged lists the stacks that have changed on the current branch,
 // compared to the main branch. This method assumes a version control
 // system in place and that you are working on a branch that is not main.
 // It's an error to call this method in a directory that's not
 // inside a repository or a repository with no commits in it.
 func (m *Manager) ListChanged() (*Report, error) {
+	return m.ListChangedFrom(m.gitBaseRef, m.headRef())
+}
+
+// ListChangedFrom works like [Manager.ListChanged] but compares the baseRef
+// and headRef git refs instead of the git base ref of the manager and HEAD,
+// so any two commits can be compared (eg.: two release tags). The changed
+// files are matched against the stacks of the current project tree.
+func (m *Manager) ListChangedFrom(baseRef, headRef string) (*Report, error) {
+	fromManager := m.clone()
+	fromManager.gitBaseRef = baseRef
+	fromManager.gitHeadRef = headRef
+	return fromManager.listChanged(project.NewPath("/"))
+}
+
+// ListChangedUnder works like [Manager.ListChanged] but only returns the
+// changed stacks located at or below the scope directory. Stacks outside the
+// scope are never inspected. The detection for the stacks inside the scope is
+// the same as ListChanged, which means that watched files and local modules
+// located outside the scope still mark in-scope stacks as changed.
+func (m *Manager) ListChangedUnder(scope project.Path) (*Report, error) {
+	return m.listChanged(scope)
+}
+
+// StacksChangedByAuthor lists the stacks changed by the commits from the
+// given author in the base..head commit range. Only the files touched by
+// commits whose author email matches authorEmail (case insensitive) are
+// considered, then stacks are attributed the same way as
+// [Manager.ListChanged]. If the author has no commits in the range, no stacks
+// are returned.
+func (m *Manager) StacksChangedByAuthor(base, head, authorEmail string) ([]Entry, error) {
 	logger := log.With().
-		Str("action", "ListChanged()").
+		Str("action", "StacksChangedByAuthor()").
+		Str("base", base).
+		Str("head", head).
+		Str("author", authorEmail).
 		Logger()
 
-	logger.Trace().Msg("Create git wrapper on project root.")
+	g, err := m.newGit(m.root.HostDir())
+	if err != nil {
+		return nil, errors.E(errListChanged, err)
+	}
+
+	commits, err := g.CommitsBetween(base, head)
+	if err != nil {
+		return nil, errors.E(errListChanged, err, "listing commits")
+	}
 
-	g, err := git.WithConfig(git.Config{
-		WorkingDir: m.root.HostDir(),
-	})
+	touched := []string{}
+	visited := map[string]struct{}{}
+	for _, commit := range commits {
+		if !strings.EqualFold(commit.AuthorEmail, authorEmail) {
+			continue
+		}
+		for _, file := range commit.Files {
+			if _, ok := visited[file]; !ok {
+				visited[file] = struct{}{}
+				touched = append(touched, file)
+			}
+		}
+	}
+
+	logger.Debug().
+		Int("files", len(touched)).
+		Msg("Files touched by author.")
+
+	if len(touched) == 0 {
+		return []Entry{}, nil
+	}
 
+	authorManager := m.clone()
+	authorManager.gitBaseRef = base
+	authorManager.gitHeadRef = head
+	authorManager.touchedFiles = touched
+	report, err := authorManager.ListChanged()
 	if err != nil {
-		return nil, errors.E(errListChanged, err)
+		return nil, err
 	}
+	return report.Stacks, nil
+}
 
-	logger.Trace().Msg("Check if path is git repo.")
+func (m *Manager) listChanged(scope project.Path) (*Report, error) {
+	return m.listChangedTraced(scope, &changeTracer{})
+}
 
-	if !g.IsRepository() {
+// listChangedTraced works like listChanged but records the decisions of the
+// change detection in the tracer.
+func (m *Manager) listChangedTraced(scope project.Path, tracer *changeTracer) (report *Report, err error) {
+	logger := log.With().
+		Str("action", "ListChanged()").
+		Stringer("scope", scope).
+		Logger()
+
+	if m.needsBaseRef() {
+		baseManager, err := m.withBaseRef()
+		if err != nil {
+			return nil, errors.E(errListChanged, err)
+		}
+		return baseManager.listChangedTraced(scope, tracer)
+	}
+
+	if m.opts.TraceFile != "" {
+		if err := tracer.open(m.opts.TraceFile); err != nil {
+			return nil, errors.E(errListChanged, err)
+		}
+		defer func() {
+			if closeErr := tracer.close(); closeErr != nil && err == nil {
+				report, err = nil, errors.E(errListChanged, closeErr)
+			}
+		}()
+	}
+
+	scopeTree, found := m.root.Lookup(scope)
+	if !found {
 		return nil, errors.E(
 			errListChanged,
-			"the path \"%s\" is not a git repository",
-			m.root.HostDir(),
+			"scope directory %s not found in the project",
+			scope,
 		)
 	}
 
-	checks, err := checkRepoIsClean(g)
-	if err != nil {
-		return nil, errors.E(errListChanged, err)
+	// g is nil if the manager doesn't use git at all (see withoutGit).
+	var (
+		g      *git.Git
+		checks RepoChecks
+	)
+	if !m.withoutGit {
+		logger.Trace().Msg("Create git wrapper on project root.")
+
+		g, err = m.newGit(m.root.HostDir())
+
+		if err != nil {
+			return nil, errors.E(errListChanged, err)
+		}
+
+		logger.Trace().Msg("Check if path is git repo.")
+
+		if !g.IsRepository() {
+			return nil, errors.E(
+				errListChanged,
+				"the path \"%s\" is not a git repository",
+				m.root.HostDir(),
+			)
+		}
+
+		checks, err = checkRepoIsClean(g)
+		if err != nil {
+			return nil, errors.E(errListChanged, err)
+		}
 	}
 
 	logger.Debug().Msg("List changed files.")
 
-	changedFiles, err := listChangedFiles(m.root.HostDir(), m.gitBaseRef)
+	changes, err := m.listFileChanges(m.root.HostDir())
 	if err != nil {
 		return nil, errors.E(errListChanged, err)
 	}
+	if g != nil && m.touchedFiles == nil {
+		if err := m.warnBaseNotAncestor(g); err != nil {
+			return nil, errors.E(errListChanged, err)
+		}
+	}
+	changedFiles := changedPaths(changes)
+	deletedFiles := deletedPaths(changes)
+
+	// localFiles is the set of changed files which are only changed
+	// locally, ie. untracked or uncommitted (see IncludeLocalChanges).
+	var localFiles map[string]struct{}
+	if m.includeLocalChanges() {
+		logger.Debug().Msg("Add untracked and uncommitted files.")
+
+		changedFiles, localFiles = addLocalChanges(changedFiles, checks)
+	}
+
+	var rootChanges []string
+	if g != nil {
+		rootChanges, err = m.rootConfigChanges(g, changedFiles)
+		if err != nil {
+			return nil, errors.E(errListChanged, err)
+		}
+	}
 
 	stackSet := map[project.Path]Entry{}
+	ignores := loadIgnoreRules(m.root)
+
+	// deletedStacks caches if the directories missing in the project were
+	// stacks on the git base ref.
+	deletedStacks := map[project.Path]bool{}
+
+	// changedFilesOf maps each changed stack to the files, relative to the
+	// project root, which caused it to be flagged as changed.
+	changedFilesOf := map[project.Path][]string{}
+
+	// ignoredBy maps the stacks marked as unchanged by ignore triggers to
+	// the trigger file, relative to the project root.
+	ignoredBy := map[project.Path]string{}
+
+	// lfsFiles are the changed files tracked by git LFS, whose changes are
+	// seen as changes on their pointer files.
+	lfsFiles := map[string]struct{}{}
+	if g != nil {
+		tracked, err := g.LFSTracked(changedFiles...)
+		if err != nil {
+			return nil, errors.E(errListChanged, err, "checking git attributes of changed files")
+		}
+		for _, file := range tracked {
+			lfsFiles[file] = struct{}{}
+		}
+	}
 
 	for _, path := range changedFiles {
 		abspath := filepath.Join(m.root.HostDir(), path)
 		projpath := project.PrjAbsPath(m.root.HostDir(), abspath)
-		triggeredStack, isTriggerFile := trigger.StackPath(projpath)
+		triggeredStack, isTriggerFile := trigger.StackPathFor(m.root, projpath)
 
 		logger = logger.With().
 			Stringer("path", projpath).
 			Logger()
 
-		if strings.HasPrefix(path, ".") && !isTriggerFile {
-			logger.Debug().Msg("ignoring changed file starting with .")
+		if reason, ignored := ignores.match(path); ignored && !isTriggerFile {
+			logger.Debug().
+				Str("reason", reason).
+				Msg("ignoring changed file")
+			tracer.ignored(path, "", reason)
 			continue
 		}
 
@@ -183,13 +542,73 @@ func (m *Manager) ListChanged() (*Report, error) {
 			if _, err := os.Stat(abspath); err != nil {
 				if errors.Is(err, fs.ErrNotExist) {
 					logger.Debug().Msg("ignoring deleted trigger file")
+					tracer.ignored(path, triggeredStack.String(), "trigger file was deleted")
+					continue
+				}
+			}
+
+			info, err := parseTrigger(abspath)
+			if err != nil {
+				return nil, errors.E(errListChanged, err)
+			}
+
+			if info.Type == trigger.IgnoredType {
+				ignored, err := m.ignoreTriggerStacks(info, triggeredStack, scope)
+				if err != nil {
+					return nil, errors.E(errListChanged, err)
+				}
+
+				logger.Debug().
+					Int("stacks", len(ignored)).
+					Msg("ignore trigger file change detected")
+
+				if len(ignored) == 0 {
+					tracer.ignored(path, "", "ignore trigger matches no stack in scope")
+				}
+				for _, dir := range ignored {
+					ignoredBy[dir] = path
+				}
+				continue
+			}
+
+			tags := info.Tags
+			if tags != "" {
+				entries, err := m.stacksMatchingTags(tags, scope)
+				if err != nil {
+					return nil, errors.E(errListChanged, err)
+				}
+
+				logger.Debug().
+					Str("tags", tags).
+					Int("stacks", len(entries)).
+					Msg("tags trigger file change detected")
+
+				if len(entries) == 0 {
+					tracer.ignored(path, "", "no stack in scope matches the trigger tags")
 					continue
 				}
+
+				for _, entry := range entries {
+					entry.Reason = fmt.Sprintf("stack has been triggered by tag %s via %s",
+						tags, projpath)
+					entry.Kind = ChangeKindTrigger
+					stackSet[entry.Stack.Dir] = entry
+					tracer.changed(path, entry)
+					changedFilesOf[entry.Stack.Dir] = append(changedFilesOf[entry.Stack.Dir], path)
+				}
+				continue
+			}
+
+			if !isInScope(triggeredStack, scope) {
+				logger.Debug().Msg("triggered stack is out of scope, ignoring")
+				tracer.ignored(path, triggeredStack.String(), "triggered stack is out of scope")
+				continue
 			}
 
 			cfg, found := m.root.Lookup(triggeredStack)
 			if !found || !cfg.IsStack() {
 				logger.Debug().Msg("trigger path is not a stack, nothing to do")
+				tracer.ignored(path, triggeredStack.String(), "trigger path is not a stack")
 				continue
 			}
 
@@ -201,13 +620,25 @@ func (m *Manager) ListChanged() (*Report, error) {
 			stackSet[s.Dir] = Entry{
 				Stack:  s,
 				Reason: "stack has been triggered by: " + projpath.String(),
+				Kind:   ChangeKindTrigger,
 			}
+			tracer.changed(path, stackSet[s.Dir])
+			changedFilesOf[s.Dir] = append(changedFilesOf[s.Dir], path)
+			continue
+		}
+
+		if !isInScope(projpath, scope) {
+			logger.Debug().Msg("ignoring changed file out of scope")
+			tracer.ignored(path, "", "file is out of scope")
 			continue
 		}
 
 		dirname := filepath.Dir(abspath)
 
-		if _, ok := stackSet[project.PrjAbsPath(m.root.HostDir(), dirname)]; ok {
+		if entry, ok := stackSet[project.PrjAbsPath(m.root.HostDir(), dirname)]; ok {
+			dirpath := project.PrjAbsPath(m.root.HostDir(), dirname)
+			tracer.changed(path, entry)
+			changedFilesOf[dirpath] = append(changedFilesOf[dirpath], path)
 			continue
 		}
 
@@ -217,6 +648,17 @@ func (m *Manager) ListChanged() (*Report, error) {
 
 		cfgpath := project.PrjAbsPath(m.root.HostDir(), dirname)
 		stackTree, found := m.root.Lookup(cfgpath)
+
+		if _, isDeleted := deletedFiles[path]; isDeleted && !found && g != nil {
+			if olddir, ok := m.deletedStackDir(g, cfgpath, deletedStacks); ok {
+				logger.Debug().
+					Stringer("stack", olddir).
+					Msg("ignoring file of deleted stack")
+				tracer.ignored(path, olddir.String(), "stack directory was deleted")
+				continue
+			}
+		}
+
 		if !found || !stackTree.IsStack() {
 			logger.Debug().
 				Str("path", dirname).
@@ -231,31 +673,102 @@ func (m *Manager) ListChanged() (*Report, error) {
 				}
 			}
 			if !found || !stackTree.IsStack() {
+				tracer.ignored(path, "", "file does not belong to any stack")
 				continue
 			}
 		}
 
+		if !isInScope(stackTree.Dir(), scope) {
+			logger.Debug().
+				Stringer("stack", stackTree.Dir()).
+				Msg("ignoring stack out of scope")
+			tracer.ignored(path, stackTree.Dir().String(), "stack is out of scope")
+			continue
+		}
+
 		s, err := config.NewStackFromHCL(m.root.HostDir(), stackTree.Node)
 		if err != nil {
 			return nil, errors.E(errListChanged, err)
 		}
 
+		if _, isLocal := localFiles[path]; isLocal {
+			if entry, ok := stackSet[s.Dir]; ok {
+				tracer.changed(path, entry)
+				changedFilesOf[s.Dir] = append(changedFilesOf[s.Dir], path)
+				continue
+			}
+
+			logger.Debug().Msg("changed file is untracked or uncommitted")
+
+			stackSet[s.Dir] = Entry{
+				Stack:  s,
+				Reason: "stack has untracked changes",
+				Kind:   ChangeKindDirect,
+			}
+			tracer.changed(path, stackSet[s.Dir])
+			changedFilesOf[s.Dir] = append(changedFilesOf[s.Dir], path)
+			continue
+		}
+
+		reason := "stack has unmerged changes"
+
+		_, isLFS := lfsFiles[path]
+		switch {
+		case isLFS:
+			logger.Debug().Msg("changed file is tracked by git LFS")
+			reason = fmt.Sprintf("stack has unmerged changes in git LFS file %q", projpath)
+		case isTfVarsFile(path):
+			logger.Debug().Msg("changed file is a Terraform variables file")
+			reason = fmt.Sprintf("stack has unmerged changes in Terraform variables file %q", projpath)
+		}
+
 		stackSet[s.Dir] = Entry{
 			Stack:  s,
-			Reason: "stack has unmerged changes",
+			Reason: reason,
+			Kind:   ChangeKindDirect,
+		}
+		tracer.changed(path, stackSet[s.Dir])
+		changedFilesOf[s.Dir] = append(changedFilesOf[s.Dir], path)
+	}
+
+	if m.touchedFiles == nil {
+		logger.Debug().Msg("Detect moved stacks.")
+
+		if err := m.detectMovedStacks(g, stackSet); err != nil {
+			return nil, errors.E(errListChanged, err)
+		}
+
+		var moved []Entry
+		for _, entry := range stackSet {
+			if entry.Kind == ChangeKindMoved {
+				moved = append(moved, entry)
+			}
+		}
+		sort.Sort(EntrySlice(moved))
+		for _, entry := range moved {
+			tracer.changed("", entry)
+		}
+
+		if m.opts.ModuleVersionChanges {
+			logger.Debug().Msg("Detect module version changes.")
+
+			changed := m.detectModuleVersionChanges(g, stackSet, changedFilesOf, localFiles)
+			for _, entry := range changed {
+				tracer.changed("", entry)
+			}
 		}
 	}
 
 	logger.Debug().Msg("Get list of all stacks.")
 
-	allstacks, err := List(m.root.Tree())
+	allstacks, err := List(scopeTree)
 	if err != nil {
 		return nil, errors.E(errListChanged, "searching for stacks", err)
 	}
 
 	logger.Trace().Msg("Range over all stacks.")
 
-rangeStacks:
+	var moduleCandidates []*config.Stack
 	for _, stackEntry := range allstacks {
 		stack := stackEntry.Stack
 		if _, ok := stackSet[stack.Dir]; ok {
@@ -279,80 +792,99 @@ rangeStacks:
 					"stack changed because watched file %q changed",
 					changed,
 				),
+				Kind: ChangeKindWatch,
+			}
+			changedFilesOf[stack.Dir] = changedWatchedFiles(stack, changedFiles)
+			for _, file := range changedFilesOf[stack.Dir] {
+				tracer.changed(file, stackSet[stack.Dir])
 			}
-			continue rangeStacks
+			continue
 		}
 
 		logger.Debug().
 			Stringer("stack", stack).
-			Msg("Apply function to stack.")
-
-		err := m.filesApply(stack.HostDir(m.root), func(file fs.DirEntry) error {
-			if path.Ext(file.Name()) != ".tf" {
-				return nil
-			}
+			Msg("Check for changed var files.")
 
+		if files := changedProjectFiles(stack.VarFiles, changedFiles); len(files) > 0 {
 			logger.Debug().
 				Stringer("stack", stack).
-				Msg("Get tf file path.")
-
-			tfpath := filepath.Join(stack.HostDir(m.root), file.Name())
-
-			logger.Trace().
-				Stringer("stack", stack).
-				Str("configFile", tfpath).
-				Msg("Parse modules.")
+				Str("varfile", files[0]).
+				Msg("changed.")
 
-			modules, err := tf.ParseModules(tfpath)
-			if err != nil {
-				return errors.E(errListChanged, "parsing modules", err)
+			stack.IsChanged = true
+			stackSet[stack.Dir] = Entry{
+				Stack: stack,
+				Reason: fmt.Sprintf(
+					"stack changed because Terraform variables file %q changed",
+					"/"+files[0],
+				),
+				Kind: ChangeKindVarFile,
+			}
+			changedFilesOf[stack.Dir] = files
+			for _, file := range files {
+				tracer.changed(file, stackSet[stack.Dir])
 			}
+			continue
+		}
 
-			logger.Trace().
-				Stringer("stack", stack).
-				Str("configFile", tfpath).
-				Msg("Range over modules.")
+		moduleCandidates = append(moduleCandidates, stack)
+	}
 
-			for _, mod := range modules {
-				logger.Trace().
-					Stringer("stack", stack).
-					Str("configFile", tfpath).
-					Msg("Check if module changed.")
+	logger.Debug().
+		Int("stacks", len(moduleCandidates)).
+		Msg("Check local modules of stacks.")
 
-				changed, why, err := m.moduleChanged(mod, stack.HostDir(m.root), make(map[string]bool))
-				if err != nil {
-					return errors.E(errListChanged, err, "checking module %q", mod.Source)
-				}
+	moduleChanges, err := m.moduleChanges(moduleCandidates, newModuleChangeCache(localFiles))
+	if err != nil {
+		return nil, errors.E(errListChanged, err)
+	}
 
-				if changed {
-					logger.Debug().
-						Stringer("stack", stack).
-						Str("configFile", tfpath).
-						Msg("Module changed.")
-
-					stack.IsChanged = true
-					stackSet[stack.Dir] = Entry{
-						Stack: stack,
-						Reason: fmt.Sprintf(
-							"stack changed because %q changed because %s",
-							mod.Source, why,
-						),
-					}
-					return nil
-				}
-			}
-			return nil
-		})
+	// the changes are applied in the stacks order, so the trace is
+	// deterministic.
+	for _, change := range moduleChanges {
+		if change == nil {
+			continue
+		}
+		stack := change.entry.Stack
+		stack.IsChanged = true
+		stackSet[stack.Dir] = change.entry
+		for _, file := range change.files {
+			tracer.changed(file, change.entry)
+		}
+		changedFilesOf[stack.Dir] = append(changedFilesOf[stack.Dir], change.files...)
+	}
 
-		if err != nil {
-			return nil, errors.E(errListChanged, "checking module changes", err)
+	// ignore triggers take precedence over the changed files but not over
+	// the stacks explicitly triggered as changed.
+	ignoredStacks := make([]project.Path, 0, len(ignoredBy))
+	for dir := range ignoredBy {
+		ignoredStacks = append(ignoredStacks, dir)
+	}
+	sort.Slice(ignoredStacks, func(i, j int) bool {
+		return ignoredStacks[i].String() < ignoredStacks[j].String()
+	})
+	for _, dir := range ignoredStacks {
+		entry, ok := stackSet[dir]
+		if !ok || entry.Kind == ChangeKindTrigger {
+			continue
 		}
+
+		logger.Debug().
+			Stringer("stack", dir).
+			Str("trigger", ignoredBy[dir]).
+			Msg("ignoring changed stack because of ignore trigger")
+
+		delete(stackSet, dir)
+		delete(changedFilesOf, dir)
+		tracer.ignored(ignoredBy[dir], dir.String(), "stack is ignored by trigger")
 	}
 
 	logger.Trace().Msg("Make set of changed stacks.")
 
 	changedStacks := make([]Entry, 0, len(stackSet))
 	for _, stack := range stackSet {
+		stack.Stack.IsChanged = true
+		stack.Stack.ChangedFiles = uniqSortedStrings(changedFilesOf[stack.Stack.Dir])
 		changedStacks = append(changedStacks, stack)
 	}
 
@@ -360,16 +892,255 @@ rangeStacks:
 
 	sort.Sort(EntrySlice(changedStacks))
 
+	if err := m.checkMaxStacks(len(changedStacks)); err != nil {
+		return nil, err
+	}
+
 	return &Report{
-		Checks: checks,
-		Stacks: changedStacks,
+		Checks:            checks,
+		Stacks:            changedStacks,
+		RootConfigChanges: rootChanges,
+		ChangedFiles:      uniqSortedStrings(changedFiles),
 	}, nil
 }
 
+// moduleChange is a stack changed because of one of its local modules.
+type moduleChange struct {
+	entry Entry
+	files []string // files is the changed files of the module.
+}
+
+// moduleChangeCache caches the work shared by the stacks using the same
+// local modules during a single change detection: the parsed Terraform files
+// and the changed files of each module directory.
+// It is safe for concurrent use.
+type moduleChangeCache struct {
+	parsed *tf.ModuleCache
+
+	// local is the set of files, relative to the project root, which are
+	// only changed locally (see ManagerOptions.IncludeLocalChanges).
+	local map[string]struct{}
+
+	mu      sync.Mutex
+	changed map[project.Path]*changedModule
+}
+
+// changedModule is the result of listing the changed files of a module,
+// which is computed once even if requested concurrently.
+type changedModule struct {
+	once  sync.Once
+	files []string
+	err   error
+}
+
+func newModuleChangeCache(local map[string]struct{}) *moduleChangeCache {
+	return &moduleChangeCache{
+		parsed:  tf.NewModuleCache(),
+		local:   local,
+		changed: map[project.Path]*changedModule{},
+	}
+}
+
+// changedFiles returns the changed files of the module directory, relative
+// to the module directory, listing them only once per module. The locally
+// changed files of the module are included.
+func (c *moduleChangeCache) changedFiles(m *Manager, moddir project.Path) ([]string, error) {
+	c.mu.Lock()
+	mod, ok := c.changed[moddir]
+	if !ok {
+		mod = &changedModule{}
+		c.changed[moddir] = mod
+	}
+	c.mu.Unlock()
+
+	mod.once.Do(func() {
+		mod.files, mod.err = m.listChangedFiles(moddir.HostPath(m.root.HostDir()))
+		if mod.err != nil || len(c.local) == 0 {
+			return
+		}
+
+		prefix := strings.TrimPrefix(moddir.String(), "/") + "/"
+		for file := range c.local {
+			if strings.HasPrefix(file, prefix) {
+				mod.files = append(mod.files, strings.TrimPrefix(file, prefix))
+			}
+		}
+		mod.files = uniqSortedStrings(mod.files)
+	})
+	return mod.files, mod.err
+}
+
+// moduleChanges checks the local modules of the stacks concurrently, using
+// up to ManagerOptions.ModuleConcurrency workers. The returned slice has the
+// change of each stack in the same position of the stacks slice, or nil if
+// none of the stack modules changed. The first error stops the check of the
+// remaining stacks.
+func (m *Manager) moduleChanges(stacks []*config.Stack, cache *moduleChangeCache) ([]*moduleChange, error) {
+	workers := m.opts.ModuleConcurrency
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > len(stacks) {
+		workers = len(stacks)
+	}
+
+	changes := make([]*moduleChange, len(stacks))
+
+	var (
+		mu       sync.Mutex
+		firstErr error
+		wg       sync.WaitGroup
+	)
+
+	failed := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return firstErr != nil
+	}
+
+	indexes := make(chan int)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if failed() {
+					continue
+				}
+				change, err := m.moduleChange(stacks[i], cache)
+				if err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+					continue
+				}
+				changes[i] = change
+			}
+		}()
+	}
+
+	for i := range stacks {
+		if failed() {
+			break
+		}
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return changes, nil
+}
+
+// moduleChange checks if any local module of the stack changed, returning
+// nil if none changed.
+func (m *Manager) moduleChange(stack *config.Stack, cache *moduleChangeCache) (*moduleChange, error) {
+	logger := log.With().
+		Str("action", "Manager.moduleChange()").
+		Stringer("stack", stack).
+		Logger()
+
+	logger.Debug().Msg("Check local modules of stack.")
+
+	modules, err := stack.LocalModulesWithCache(m.root, cache.parsed)
+	if err != nil {
+		return nil, errors.E(err, "checking module changes")
+	}
+
+	// the owned files are only listed if some module has changed files.
+	var owned map[project.Path]struct{}
+
+	for _, mod := range modules {
+		logger.Trace().
+			Stringer("module", mod.Dir).
+			Msg("Check if module changed.")
+
+		changedFiles, err := cache.changedFiles(m, mod.Dir)
+		if err != nil {
+			return nil, errors.E(err,
+				"listing changes in the module %q", mod.Source)
+		}
+
+		if len(changedFiles) > 0 && owned == nil {
+			files, err := stack.OwnedFilesWithCache(m.root, cache.parsed)
+			if err != nil {
+				return nil, errors.E(err, "listing files owned by the stack")
+			}
+			owned = make(map[project.Path]struct{}, len(files))
+			for _, file := range files {
+				owned[file] = struct{}{}
+			}
+		}
+
+		changedFiles = m.ownedChanges(owned, mod.Dir, changedFiles)
+		if len(changedFiles) == 0 {
+			continue
+		}
+
+		logger.Debug().
+			Stringer("module", mod.Dir).
+			Msg("Module changed.")
+
+		change := &moduleChange{
+			entry: Entry{
+				Stack:  stack,
+				Reason: moduleChangedReason(stack.Dir, modules, mod),
+				Kind:   ChangeKindModule,
+			},
+		}
+		for _, file := range changedFiles {
+			change.files = append(change.files, path.Join(mod.Dir.String()[1:], file))
+		}
+		return change, nil
+	}
+	return nil, nil
+}
+
+// ownedChanges returns the changed files of the moddir module directory,
+// relative to moddir, which are owned by the stack (see
+// [config.Stack.OwnedFiles]). Deleted files can't be listed as owned, so they
+// are always considered owned.
+func (m *Manager) ownedChanges(owned map[project.Path]struct{}, moddir project.Path, changedFiles []string) []string {
+	var files []string
+	for _, file := range changedFiles {
+		prjpath := moddir.Join(file)
+		if _, ok := owned[prjpath]; !ok {
+			_, err := os.Lstat(prjpath.HostPath(m.root.HostDir()))
+			if !os.IsNotExist(err) {
+				continue
+			}
+		}
+		files = append(files, file)
+	}
+	return files
+}
+
 // AddWantedOf returns all wanted stacks from the given stacks.
 func (m *Manager) AddWantedOf(scopeStacks config.List[*config.SortableStack]) (config.List[*config.SortableStack], error) {
+	return m.addWanted("manager.AddWantedOf", scopeStacks, (*dag.DAG).AncestorsOf)
+}
+
+// AddWantedByOf works like [Manager.AddWantedOf] but traverses the wants
+// graph in the reverse direction, returning the given stacks and all the
+// stacks which want them, directly or transitively (eg.: the downstream
+// consumers of a base stack).
+func (m *Manager) AddWantedByOf(scopeStacks config.List[*config.SortableStack]) (config.List[*config.SortableStack], error) {
+	return m.addWanted("manager.AddWantedByOf", scopeStacks, (*dag.DAG).DescendantsOf)
+}
+
+// addWanted returns the scope stacks and all the stacks reachable from them
+// in the wants DAG, following the edges returned by next.
+func (m *Manager) addWanted(
+	action string,
+	scopeStacks config.List[*config.SortableStack],
+	next func(d *dag.DAG, id dag.ID) []dag.ID,
+) (config.List[*config.SortableStack], error) {
 	logger := log.With().
-		Str("action", "manager.AddWantedOf").
+		Str("action", action).
 		Logger()
 
 	wantsDag := dag.New()
@@ -441,8 +1212,7 @@ func (m *Manager) AddWantedOf(scopeStacks config.List[*config.SortableStack]) (c
 		addStack(s)
 		pending = pending[1:]
 
-		ancestors := wantsDag.AncestorsOf(id)
-		for _, id := range ancestors {
+		for _, id := range next(wantsDag, id) {
 			if _, ok := visited[id]; !ok {
 				pending = append(pending, id)
 			}
@@ -482,116 +1252,247 @@ func (m *Manager) filesApply(dir string, apply func(file fs.DirEntry) error) err
 	return nil
 }
 
-// moduleChanged recursively check if the module mod or any of the modules it
-// uses has changed. All .tf files of the module are parsed and this function is
-// called recursively. The visited keep track of the modules already parsed to
-// avoid infinite loops.
-func (m *Manager) moduleChanged(
-	mod tf.Module, basedir string, visited map[string]bool,
-) (changed bool, why string, err error) {
-	logger := log.With().
-		Str("action", "moduleChanged()").
-		Logger()
-
-	if _, ok := visited[mod.Source]; ok {
-		return false, "", nil
+// moduleChangedReason returns the reason of a stack change caused by the
+// changed module, including the chain of modules from the stack to it.
+func moduleChangedReason(stackdir project.Path, modules []config.LocalModule, changed config.LocalModule) string {
+	byDir := map[project.Path]config.LocalModule{}
+	for _, mod := range modules {
+		byDir[mod.Dir] = mod
 	}
 
-	logger.Trace().
-		Str("path", basedir).
-		Msg("Check if module source is local directory.")
-	if !mod.IsLocal() {
-		// if the source is a remote path (URL, VCS path, S3 bucket, etc) then
-		// we assume it's not changed.
-		return false, "", nil
+	mod := changed
+	reason := fmt.Sprintf("module %q has unmerged changes", mod.Source)
+	for mod.UsedBy != stackdir {
+		parent, ok := byDir[mod.UsedBy]
+		if !ok {
+			break
+		}
+		mod = parent
+		reason = fmt.Sprintf("module %q changed because %s", mod.Source, reason)
 	}
+	return fmt.Sprintf("stack changed because %q changed because %s", mod.Source, reason)
+}
 
-	logger.Trace().
-		Str("path", basedir).
-		Msg("Get module path.")
-	modPath := filepath.Join(basedir, mod.Source)
+// isTfVarsFile tells if the file is a Terraform variables file (eg.:
+// terraform.tfvars or prod.auto.tfvars.json). The variables files are not
+// parsed as Terraform modules, but changing them changes the stack inputs.
+func isTfVarsFile(file string) bool {
+	return strings.HasSuffix(file, ".tfvars") || strings.HasSuffix(file, ".tfvars.json")
+}
 
-	logger.Trace().
-		Str("path", modPath).
-		Msg("Get module path info.")
-	st, err := os.Stat(modPath)
+// listChangedFiles lists all changed files in the dir directory, relative to
+// dir. If the manager has a touched files set, only files from the set are
+// returned.
+func (m *Manager) listChangedFiles(dir string) ([]string, error) {
+	changes, err := m.listFileChanges(dir)
+	if err != nil {
+		return nil, err
+	}
+	return changedPaths(changes), nil
+}
 
-	// TODO(i4k): resolve symlinks
+// listFileChanges lists all file changes in the dir directory, relative to
+// dir. If the manager has a touched files set, only files from the set are
+// returned and they are all reported as modified.
+func (m *Manager) listFileChanges(dir string) ([]git.FileChange, error) {
+	if m.touchedFiles == nil {
+		return listFileChanges(dir, m.gitBaseRef, m.headRef(), m.gitLimiter)
+	}
 
-	if err != nil || !st.IsDir() {
-		return false, "", errors.E("\"source\" path %q is not a directory", modPath)
+	var changes []git.FileChange
+	for _, file := range m.touchedFiles {
+		abspath := filepath.Join(m.root.HostDir(), filepath.FromSlash(file))
+		relpath, err := filepath.Rel(dir, abspath)
+		if err != nil || relpath == ".." || strings.HasPrefix(relpath, ".."+string(filepath.Separator)) {
+			continue
+		}
+		changes = append(changes, git.FileChange{
+			Path: filepath.ToSlash(relpath),
+			Kind: git.FileModified,
+		})
 	}
+	return changes, nil
+}
 
-	logger.Debug().
-		Str("path", modPath).
-		Msg("Get list of changed files.")
-	changedFiles, err := listChangedFiles(modPath, m.gitBaseRef)
-	if err != nil {
-		return false, "", errors.E(err,
-			"listing changes in the module %q",
-			mod.Source)
+// changedPaths returns the sorted paths of the changed files. Both the old
+// and the new paths of renamed files are changed paths.
+func changedPaths(changes []git.FileChange) []string {
+	var paths []string
+	for _, change := range changes {
+		paths = append(paths, change.Path)
+		if change.Kind == git.FileRenamed {
+			paths = append(paths, change.OldPath)
+		}
 	}
+	sort.Strings(paths)
+	return paths
+}
 
-	if len(changedFiles) > 0 {
-		return true, fmt.Sprintf("module %q has unmerged changes", mod.Source), nil
+// deletedPaths returns the set of paths which don't exist anymore, which are
+// the deleted files and the old paths of renamed files.
+func deletedPaths(changes []git.FileChange) map[string]struct{} {
+	deleted := map[string]struct{}{}
+	for _, change := range changes {
+		switch change.Kind {
+		case git.FileDeleted:
+			deleted[change.Path] = struct{}{}
+		case git.FileRenamed:
+			deleted[change.OldPath] = struct{}{}
+		}
 	}
+	return deleted
+}
 
-	visited[mod.Source] = true
+// deletedStackDir returns the directory of the stack which contained the
+// deleted files of dir, if the stack directory was removed from the project
+// (eg.: the stack was deleted or moved). The directories missing in the
+// project are checked against the git base ref, from dir up to the closest
+// directory which still exists, and the results are cached in deletedStacks.
+func (m *Manager) deletedStackDir(
+	g *git.Git,
+	dir project.Path,
+	deletedStacks map[project.Path]bool,
+) (project.Path, bool) {
+	for ; dir.String() != "/"; dir = dir.Dir() {
+		if _, found := m.root.Lookup(dir); found {
+			return project.Path{}, false
+		}
 
-	logger.Debug().
-		Str("path", modPath).
-		Msg("Apply function to files in path.")
-	err = m.filesApply(modPath, func(file fs.DirEntry) error {
-		if changed {
-			return nil
+		isStack, ok := deletedStacks[dir]
+		if !ok {
+			// the old configuration may be invalid or depend on files that
+			// don't exist anymore, then its files are handled as regular
+			// changes.
+			oldcfg, err := m.parseConfigAt(g, m.gitBaseRef, dir)
+			isStack = err == nil && oldcfg.Stack != nil
+			deletedStacks[dir] = isStack
 		}
-		if path.Ext(file.Name()) != ".tf" {
-			return nil
+		if isStack {
+			return dir, true
 		}
+	}
+	return project.Path{}, false
+}
 
-		logger.Trace().
-			Str("path", modPath).
-			Msg("Parse modules.")
-		modules, err := tf.ParseModules(filepath.Join(modPath, file.Name()))
-		if err != nil {
-			return errors.E(err, "parsing module %q", mod.Source)
+// includeLocalChanges tells if the untracked and uncommitted files must be
+// considered as changed files.
+func (m *Manager) includeLocalChanges() bool {
+	return m.opts.IncludeLocalChanges &&
+		!m.withoutGit &&
+		m.touchedFiles == nil &&
+		m.headRef() == "HEAD"
+}
+
+// addLocalChanges adds the untracked and uncommitted files of checks to the
+// changed files. It returns the new changed files and the set of files which
+// were not already changed files.
+func addLocalChanges(changedFiles []string, checks RepoChecks) ([]string, map[string]struct{}) {
+	changed := map[string]struct{}{}
+	for _, file := range changedFiles {
+		changed[file] = struct{}{}
+	}
+
+	local := map[string]struct{}{}
+	var files []string
+	files = append(files, checks.UntrackedFiles...)
+	files = append(files, checks.UncommittedFiles...)
+	for _, file := range files {
+		if _, ok := changed[file]; ok {
+			continue
 		}
+		changed[file] = struct{}{}
+		local[file] = struct{}{}
+		changedFiles = append(changedFiles, file)
+	}
+	return changedFiles, local
+}
 
-		logger.Trace().
-			Str("path", modPath).
-			Msg("Range over modules.")
-		for _, mod2 := range modules {
-			var reason string
-
-			logger.Trace().
-				Str("path", modPath).
-				Msg("Get if module is changed.")
-			changed, reason, err = m.moduleChanged(mod2, modPath, visited)
-			if err != nil {
-				return err
-			}
+// useMergeBase tells if the changed files must be computed from the merge
+// base of the git base ref and the head ref.
+func (m *Manager) useMergeBase() bool {
+	return m.opts.MergeBase &&
+		!m.withoutGit &&
+		m.touchedFiles == nil
+}
 
-			if changed {
-				logger.Trace().
-					Str("path", modPath).
-					Msg("Module was changed.")
-				why = fmt.Sprintf("%s%s changed because %s ", why, mod.Source, reason)
-				return nil
-			}
+// useDefaultBranch tells if the git base ref must be the detected default
+// branch of the repository.
+func (m *Manager) useDefaultBranch() bool {
+	return m.gitBaseRef == "" && !m.withoutGit
+}
+
+// needsBaseRef tells if the git base ref of m must be resolved before
+// comparing changes (see [Manager.withBaseRef]).
+func (m *Manager) needsBaseRef() bool {
+	return m.useDefaultBranch() || m.useMergeBase()
+}
+
+// withBaseRef returns a manager whose git base ref is resolved: an empty git
+// base ref is replaced by the default branch of the repository and, if the
+// MergeBase option is in effect, the merge base of the git base ref and the
+// head ref of m is used. If there's nothing to resolve, m itself is returned.
+func (m *Manager) withBaseRef() (*Manager, error) {
+	if !m.needsBaseRef() {
+		return m, nil
+	}
+
+	g, err := m.newGit(m.root.HostDir())
+	if err != nil {
+		return nil, err
+	}
+
+	if m.useDefaultBranch() {
+		branch, err := g.DefaultBranch()
+		if err != nil {
+			return nil, errors.E(err, "detecting the default branch")
 		}
 
-		return nil
-	})
+		log.Debug().
+			Str("action", "Manager.withBaseRef()").
+			Str("defaultBranch", branch).
+			Msg("Using default branch as git base ref.")
 
+		defaultManager := m.clone()
+		defaultManager.gitBaseRef = branch
+		return defaultManager.withBaseRef()
+	}
+
+	base, err := g.MergeBase(m.gitBaseRef, m.headRef())
 	if err != nil {
-		return false, "", err
+		return nil, errors.E(err, "finding merge base of %s and %s", m.gitBaseRef, m.headRef())
 	}
 
-	return changed, fmt.Sprintf("module %q changed because %s", mod.Source, why), nil
+	log.Debug().
+		Str("action", "Manager.withBaseRef()").
+		Str("baseRef", m.gitBaseRef).
+		Str("mergeBase", base).
+		Msg("Using merge base as git base ref.")
+
+	baseManager := m.clone()
+	baseManager.gitBaseRef = base
+	baseManager.opts.MergeBase = false
+	return baseManager, nil
 }
 
-// listChangedFiles lists all changed files in the dir directory.
-func listChangedFiles(dir string, gitBaseRef string) ([]string, error) {
+// clone returns a copy of the manager with all its settings, sharing the git
+// limiter, so derived managers (eg.: comparing other git refs) behave the
+// same as m except for the fields changed on the copy.
+func (m *Manager) clone() *Manager {
+	c := *m
+	return &c
+}
+
+// headRef returns the git ref compared with the git base ref.
+func (m *Manager) headRef() string {
+	if m.gitHeadRef == "" {
+		return "HEAD"
+	}
+	return m.gitHeadRef
+}
+
+// listFileChanges lists all file changes in the dir directory, between the
+// gitBaseRef and gitHeadRef commits. Renamed files are detected.
+func listFileChanges(dir string, gitBaseRef, gitHeadRef string, limiter *git.Limiter) ([]git.FileChange, error) {
 	logger := log.With().
 		Str("action", "listChangedFiles()").
 		Str("path", dir).
@@ -614,6 +1515,7 @@ func listChangedFiles(dir string, gitBaseRef string) ([]string, error) {
 
 	g, err := git.WithConfig(git.Config{
 		WorkingDir: dir,
+		Limiter:    limiter,
 	})
 	if err != nil {
 		return nil, err
@@ -623,21 +1525,169 @@ func listChangedFiles(dir string, gitBaseRef string) ([]string, error) {
 
 	baseRef, err := g.RevParse(gitBaseRef)
 	if err != nil {
+		if shallow, _ := g.IsShallow(); shallow {
+			return nil, errors.E(ErrShallowRepo, err,
+				"the revision %q is not available in the shallow clone: "+
+					"fetch the missing history (eg.: git fetch --unshallow)",
+				gitBaseRef)
+		}
 		return nil, errors.E(err, "getting revision %q", gitBaseRef)
 	}
 
-	logger.Trace().Msg("Get commit id of HEAD.")
+	logger.Trace().Msg("Get commit id of git head ref.")
 
-	headRef, err := g.RevParse("HEAD")
+	headRef, err := g.RevParse(gitHeadRef)
 	if err != nil {
-		return nil, errors.E(err, "getting HEAD revision")
+		return nil, errors.E(err, "getting revision %q", gitHeadRef)
 	}
 
 	if baseRef == headRef {
-		return []string{}, nil
+		return []git.FileChange{}, nil
 	}
 
-	return g.DiffNames(baseRef, headRef)
+	return g.DiffNamesWithStatus(baseRef, headRef)
+}
+
+// warnBaseNotAncestor logs a warning if the git base ref is not an ancestor of
+// the head ref, since then the changes made only on the base ref are reported
+// as changes. It must be called only once per change detection, after the
+// refs are known to be valid.
+func (m *Manager) warnBaseNotAncestor(g *git.Git) error {
+	isAncestor, err := g.IsAncestor(m.gitBaseRef, m.headRef())
+	if err != nil {
+		return errors.E(err, "checking if %q is an ancestor of %q", m.gitBaseRef, m.headRef())
+	}
+	if !isAncestor {
+		log.Warn().
+			Str("action", "Manager.warnBaseNotAncestor()").
+			Str("baseRef", m.gitBaseRef).
+			Str("headRef", m.headRef()).
+			Msg("base ref is not an ancestor of head ref: changes made only on " +
+				"the base ref are reported as changes (consider comparing " +
+				"from the merge base)")
+	}
+	return nil
+}
+
+// newGit creates a git wrapper for dir which shares the git commands limit of
+// the manager. The env variables are added to the environment of the git
+// commands (eg.: GIT_NOTES_REF).
+func (m *Manager) newGit(dir string, env ...string) (*git.Git, error) {
+	return git.WithConfig(git.Config{
+		WorkingDir: dir,
+		Env:        env,
+		Limiter:    m.gitLimiter,
+	})
+}
+
+// checkMaxStacks returns an error if count exceeds the maximum number of
+// stacks allowed by the manager options.
+func (m *Manager) checkMaxStacks(count int) error {
+	if m.opts.MaxStacks > 0 && count > m.opts.MaxStacks {
+		return errors.E(ErrTooManyStacks,
+			"found %d stacks but the maximum allowed is %d", count, m.opts.MaxStacks)
+	}
+	return nil
+}
+
+// isInScope tells if the dir is the scope directory or is inside of it.
+func isInScope(dir, scope project.Path) bool {
+	if scope.String() == "/" || dir == scope {
+		return true
+	}
+	return dir.HasPrefix(scope.String() + "/")
+}
+
+// parseTrigger parses the trigger file at abspath. Trigger files which can't
+// be parsed mark the stack of their location as changed, as they always did,
+// but invalid tags are reported.
+func parseTrigger(abspath string) (trigger.Info, error) {
+	info, err := trigger.ParseFile(abspath)
+	if err != nil {
+		if errors.IsKind(err, trigger.ErrInvalidTags) {
+			return trigger.Info{}, err
+		}
+		return trigger.Info{Type: trigger.DefaultType}, nil
+	}
+	return info, nil
+}
+
+// ignoreTriggerStacks returns the stacks inside scope marked as unchanged by
+// the ignore trigger, which are the stacks matching its tags, if any, or the
+// stack of its location.
+func (m *Manager) ignoreTriggerStacks(info trigger.Info, triggeredStack, scope project.Path) ([]project.Path, error) {
+	if info.Tags != "" {
+		entries, err := m.stacksMatchingTags(info.Tags, scope)
+		if err != nil {
+			return nil, err
+		}
+		dirs := make([]project.Path, len(entries))
+		for i, entry := range entries {
+			dirs[i] = entry.Stack.Dir
+		}
+		return dirs, nil
+	}
+
+	cfg, found := m.root.Lookup(triggeredStack)
+	if !found || !cfg.IsStack() || !isInScope(triggeredStack, scope) {
+		return nil, nil
+	}
+	return []project.Path{triggeredStack}, nil
+}
+
+// stacksMatchingTags returns the stacks inside scope whose tags match the tags
+// filter.
+func (m *Manager) stacksMatchingTags(tags string, scope project.Path) ([]Entry, error) {
+	clauses, _, err := filter.ParseTagClauses(tags)
+	if err != nil {
+		return nil, errors.E(err, "parsing trigger tags %q", tags)
+	}
+
+	var entries []Entry
+	err = ListWith(m.root.Tree(), func(entry Entry) error {
+		if isInScope(entry.Stack.Dir, scope) && filter.MatchTags(clauses, entry.Stack.Tags) {
+			entries = append(entries, entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// changedWatchedFiles returns the changed files watched by the stack,
+// relative to the project root.
+func changedWatchedFiles(stack *config.Stack, changedFiles []string) []string {
+	return changedProjectFiles(stack.Watch, changedFiles)
+}
+
+// changedProjectFiles returns the changed files which are in the paths list,
+// relative to the project root.
+func changedProjectFiles(paths []project.Path, changedFiles []string) []string {
+	var files []string
+	for _, path := range paths {
+		for _, file := range changedFiles {
+			if file == path.String()[1:] { // project paths
+				files = append(files, file)
+			}
+		}
+	}
+	return files
+}
+
+func uniqSortedStrings(strs []string) []string {
+	set := map[string]struct{}{}
+	var res []string
+	for _, str := range strs {
+		if _, ok := set[str]; ok {
+			continue
+		}
+		set[str] = struct{}{}
+		res = append(res, str)
+	}
+	sort.Strings(res)
+	return res
 }
 
 func hasChangedWatchedFiles(stack *config.Stack, changedFiles []string) (project.Path, bool) {
@@ -16,15 +16,24 @@ package stack_test
 
 import (
 	"fmt"
+	"os"
+	"os/exec"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/madlambda/spells/assert"
 	"github.com/mineiros-io/terramate/config"
+	"github.com/mineiros-io/terramate/errors"
 	"github.com/mineiros-io/terramate/project"
 	"github.com/mineiros-io/terramate/stack"
+	"github.com/mineiros-io/terramate/stack/trigger"
 	"github.com/mineiros-io/terramate/test"
+	"github.com/mineiros-io/terramate/test/sandbox"
 )
 
 type repository struct {
@@ -47,6 +56,80 @@ type listTestcase struct {
 
 const defaultBranch = "origin/main"
 
+func TestManagerListByTags(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		`s:app:tags=["app", "prod"]`,
+		`s:app-dev:tags=["app", "dev"]`,
+		`s:db:tags=["db", "prod"]`,
+		`s:untagged`,
+	})
+	git := s.Git()
+	git.CommitAll("first commit")
+	s.RootEntry().CreateFile("untracked.txt", "untracked")
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+
+	type testcase struct {
+		tags   []string
+		noTags []string
+		want   []string
+	}
+
+	for _, tc := range []testcase{
+		{
+			want: []string{"/app", "/app-dev", "/db", "/untagged"},
+		},
+		{
+			tags: []string{"app"},
+			want: []string{"/app", "/app-dev"},
+		},
+		{
+			tags: []string{"app:prod"},
+			want: []string{"/app"},
+		},
+		{
+			tags: []string{"dev,db"},
+			want: []string{"/app-dev", "/db"},
+		},
+		{
+			tags: []string{"dev", "db"},
+			want: []string{"/app-dev", "/db"},
+		},
+		{
+			tags:   []string{"prod"},
+			noTags: []string{"app"},
+			want:   []string{"/db"},
+		},
+		{
+			noTags: []string{"prod"},
+			want:   []string{"/app-dev", "/untagged"},
+		},
+		{
+			noTags: []string{"app", "db"},
+			want:   []string{"/untagged"},
+		},
+		{
+			tags: []string{"non-existent"},
+			want: []string{},
+		},
+	} {
+		report, err := m.ListByTags(tc.tags, tc.noTags)
+		assert.NoError(t, err, "tags %v no tags %v", tc.tags, tc.noTags)
+		assertStacks(t, tc.want, report.Stacks, false)
+		assert.EqualStrings(t, "untracked.txt",
+			strings.Join(report.Checks.UntrackedFiles, ","),
+			"tags %v no tags %v", tc.tags, tc.noTags)
+	}
+
+	for _, tags := range [][]string{{"app,"}, {"app::prod"}, {"~app"}, {"Invalid"}} {
+		_, err := m.ListByTags(tags, nil)
+		assert.Error(t, err, "tags %v", tags)
+	}
+	_, err := m.ListByTags(nil, []string{"app:prod"})
+	assert.Error(t, err)
+}
+
 func TestListChangedStacks(t *testing.T) {
 	for _, tc := range []listTestcase{
 		{
@@ -200,6 +283,1606 @@ func TestListChangedStackReason(t *testing.T) {
 	}
 }
 
+func TestManagerConcurrentListAndListChanged(t *testing.T) {
+	repo := singleStackDependentModuleChangedRepo(t)
+	m := newManager(t, repo.Dir)
+
+	const workers = 8
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			report, err := m.List()
+			if err == nil && len(report.Stacks) != 1 {
+				err = fmt.Errorf("List(): want 1 stack, got %d", len(report.Stacks))
+			}
+			errs <- err
+		}()
+		go func() {
+			defer wg.Done()
+			report, err := m.ListChanged()
+			if err == nil && len(report.Stacks) != 1 {
+				err = fmt.Errorf("ListChanged(): want 1 stack, got %d", len(report.Stacks))
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+}
+
+func TestListChangedUnderScope(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stacks/stack-a",
+		"s:stacks/stack-b",
+		"s:other-stack",
+		"f:modules/module1/main.tf:# module",
+		`f:stacks/stack-a/main.tf:module "mod" {
+			source = "../../modules/module1"
+		}`,
+		`f:other-stack/main.tf:module "mod" {
+			source = "../modules/module1"
+		}`,
+	})
+
+	git := s.Git()
+	git.CommitAll("first commit")
+	git.Push("main")
+	git.CheckoutNew("change-module")
+
+	s.RootEntry().CreateFile("modules/module1/main.tf", "# changed module")
+	s.RootEntry().CreateFile("other-stack/file.txt", "changed")
+	git.CommitAll("change module")
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+
+	report, err := m.ListChangedUnder(project.NewPath("/stacks"))
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/stacks/stack-a"}, report.Stacks, true)
+
+	report, err = m.ListChangedUnder(project.NewPath("/stacks/stack-b"))
+	assert.NoError(t, err)
+	assertStacks(t, []string{}, report.Stacks, true)
+
+	report, err = m.ListChanged()
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/other-stack", "/stacks/stack-a"}, report.Stacks, true)
+	assert.EqualStrings(t, string(stack.ChangeKindDirect), string(report.Stacks[0].Kind))
+	assert.EqualStrings(t, string(stack.ChangeKindModule), string(report.Stacks[1].Kind))
+
+	summary := report.Summary()
+	assert.EqualInts(t, 1, summary.Direct)
+	assert.EqualInts(t, 1, summary.Module)
+	assert.EqualInts(t, 2, summary.Total)
+
+	_, err = m.ListChangedUnder(project.NewPath("/non-existent"))
+	assert.Error(t, err)
+}
+
+func TestListChangedFrom(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stacks/a",
+		"s:stacks/b",
+		"f:stacks/a/main.tf:# a",
+		"f:stacks/b/main.tf:# b",
+	})
+
+	git := s.Git()
+	git.CommitAll("first commit")
+	git.Push("main")
+	git.CheckoutNew("change-stacks")
+	first := git.RevParse("HEAD")
+
+	s.RootEntry().CreateFile("stacks/a/main.tf", "# a changed")
+	git.CommitAll("change a")
+	second := git.RevParse("HEAD")
+
+	s.RootEntry().CreateFile("stacks/b/main.tf", "# b changed")
+	git.CommitAll("change b")
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+
+	report, err := m.ListChangedFrom(first, second)
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/stacks/a"}, report.Stacks, true)
+
+	report, err = m.ListChangedFrom(second, "HEAD")
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/stacks/b"}, report.Stacks, true)
+
+	report, err = m.ListChangedFrom(first, first)
+	assert.NoError(t, err)
+	assertStacks(t, []string{}, report.Stacks, true)
+
+	report, err = m.ListChanged()
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/stacks/a", "/stacks/b"}, report.Stacks, true)
+
+	_, err = m.ListChangedFrom(first, "non-existent-ref")
+	assert.Error(t, err)
+}
+
+func TestListChangedIncludeLocalChanges(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stacks/committed",
+		"s:stacks/untracked",
+		"s:stacks/uncommitted",
+		"s:stacks/unchanged",
+		"f:stacks/committed/main.tf:# committed",
+		"f:stacks/uncommitted/main.tf:# uncommitted",
+		"f:stacks/unchanged/main.tf:# unchanged",
+	})
+
+	git := s.Git()
+	git.CommitAll("first commit")
+	git.Push("main")
+	git.CheckoutNew("change-stacks")
+
+	s.RootEntry().CreateFile("stacks/committed/main.tf", "# committed changed")
+	git.CommitAll("change committed")
+
+	s.RootEntry().CreateFile("stacks/untracked/new.tf", "# new file")
+	s.RootEntry().CreateFile("stacks/uncommitted/main.tf", "# uncommitted changed")
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+	report, err := m.ListChanged()
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/stacks/committed"}, report.Stacks, true)
+
+	m = stack.NewManagerWithOptions(s.Config(), defaultBranch, stack.ManagerOptions{
+		IncludeLocalChanges: true,
+	})
+	report, err = m.ListChanged()
+	assert.NoError(t, err)
+	assertStacks(t, []string{
+		"/stacks/committed",
+		"/stacks/uncommitted",
+		"/stacks/untracked",
+	}, report.Stacks, true)
+
+	reasons := map[string]string{}
+	for _, entry := range report.Stacks {
+		reasons[entry.Stack.Dir.String()] = entry.Reason
+	}
+	want := map[string]string{
+		"/stacks/committed":   "stack has unmerged changes",
+		"/stacks/uncommitted": "stack has untracked changes",
+		"/stacks/untracked":   "stack has untracked changes",
+	}
+	if diff := cmp.Diff(want, reasons); diff != "" {
+		t.Fatalf("-(want) +(got):\n%s", diff)
+	}
+
+	report, err = m.ListChangedFrom(defaultBranch, git.RevParse("HEAD"))
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/stacks/committed"}, report.Stacks, true)
+}
+
+func TestListChangedIncludeLocalModuleChanges(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stacks/uses-module",
+		"s:stacks/other",
+		`f:stacks/uses-module/main.tf:module "mod" {
+  source = "../../modules/mod"
+}
+`,
+		"f:modules/mod/main.tf:# module",
+		"f:stacks/other/main.tf:# other",
+	})
+
+	git := s.Git()
+	git.CommitAll("first commit")
+	git.Push("main")
+	git.CheckoutNew("change-module")
+
+	s.RootEntry().CreateFile("modules/mod/main.tf", "# module changed")
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+	report, err := m.ListChanged()
+	assert.NoError(t, err)
+	assertStacks(t, []string{}, report.Stacks, true)
+
+	m = stack.NewManagerWithOptions(s.Config(), defaultBranch, stack.ManagerOptions{
+		IncludeLocalChanges: true,
+	})
+	report, err = m.ListChanged()
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/stacks/uses-module"}, report.Stacks, true)
+	assert.EqualStrings(t, string(stack.ChangeKindModule), string(report.Stacks[0].Kind))
+	assert.EqualStrings(t, "modules/mod/main.tf",
+		strings.Join(report.Stacks[0].Stack.ChangedFiles, ","))
+}
+
+func TestListChangedLFSPointer(t *testing.T) {
+	const pointerFmt = `version https://git-lfs.github.com/spec/v1
+oid sha256:%s
+size 12
+`
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stack-lfs",
+		"s:stack",
+		"f:.gitattributes:*.bin filter=lfs diff=lfs merge=lfs -text",
+		"f:stack-lfs/data.bin:" + fmt.Sprintf(pointerFmt, strings.Repeat("a", 64)),
+	})
+
+	git := s.Git()
+	git.CommitAll("first commit")
+	git.Push("main")
+	git.CheckoutNew("change-lfs-object")
+
+	s.RootEntry().CreateFile("stack-lfs/data.bin", pointerFmt, strings.Repeat("b", 64))
+	git.CommitAll("change lfs object")
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+	report, err := m.ListChanged()
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/stack-lfs"}, report.Stacks, true)
+
+	reason := report.Stacks[0].Reason
+	assert.IsTrue(t, strings.Contains(reason, "git LFS"), "unexpected reason: %s", reason)
+	assert.IsTrue(t, strings.Contains(reason, "/stack-lfs/data.bin"), "unexpected reason: %s", reason)
+}
+
+func TestListChangedSymlinkedModule(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stack",
+		"s:other",
+		`f:stack/main.tf:module "shared" {
+			source = "../shared/mod"
+		}`,
+		"f:modules/mod/main.tf:# module",
+		"l:modules:shared",
+	})
+
+	git := s.Git()
+	git.CommitAll("first commit")
+	git.Push("main")
+	git.CheckoutNew("change-module")
+
+	s.RootEntry().CreateFile("modules/mod/main.tf", "# changed")
+	git.CommitAll("change module")
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+	report, err := m.ListChanged()
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/stack"}, report.Stacks, true)
+	assert.EqualStrings(t, string(stack.ChangeKindModule), string(report.Stacks[0].Kind))
+
+	// the changed files are matched against the module directory, which
+	// must be the symlink target.
+	report, err = m.ListChangedFrom("main", "HEAD")
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/stack"}, report.Stacks, true)
+	assert.EqualStrings(t, string(stack.ChangeKindModule), string(report.Stacks[0].Kind))
+}
+
+func TestListChangedJSONModule(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stack",
+		"s:other",
+		`f:stack/main.tf.json:{
+			"module": {
+				"mod": {"source": "../modules/mod"}
+			}
+		}`,
+		`f:modules/mod/main.tf.json:{
+			"module": {
+				"nested": {"source": "../nested"}
+			}
+		}`,
+		"f:modules/nested/main.tf:# nested",
+	})
+
+	git := s.Git()
+	git.CommitAll("first commit")
+	git.Push("main")
+	git.CheckoutNew("change-module")
+
+	s.RootEntry().CreateFile("modules/nested/main.tf", "# changed")
+	git.CommitAll("change module")
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+	report, err := m.ListChanged()
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/stack"}, report.Stacks, true)
+	assert.EqualStrings(t, string(stack.ChangeKindModule), string(report.Stacks[0].Kind))
+}
+
+func TestListChangedOnlyConsidersModuleFilesOwnedByStack(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stack",
+		`f:stack/main.tf:module "mod" {
+			source = "../modules/mod"
+		}`,
+		"f:modules/mod/main.tf:# module",
+		"f:modules/mod/variables.tf:# variables",
+		"f:modules/mod/.hidden/file.txt:hidden",
+	})
+
+	git := s.Git()
+	git.CommitAll("first commit")
+	git.Push("main")
+	git.CheckoutNew("change-module")
+
+	s.RootEntry().CreateFile("modules/mod/.hidden/file.txt", "changed")
+	git.CommitAll("change hidden file of module")
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+	report, err := m.ListChanged()
+	assert.NoError(t, err)
+	assertStacks(t, []string{}, report.Stacks, true)
+
+	// deleted files can't be owned anymore, but they still change the stack.
+	test.RemoveAll(t, filepath.Join(s.RootDir(), "modules/mod/variables.tf"))
+	git.CommitAll("delete module file")
+
+	report, err = m.ListChanged()
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/stack"}, report.Stacks, true)
+	assert.EqualStrings(t, string(stack.ChangeKindModule), string(report.Stacks[0].Kind))
+	assert.EqualStrings(t, "modules/mod/variables.tf",
+		strings.Join(report.Stacks[0].Stack.ChangedFiles, ","))
+}
+
+func TestListChangedModulesConcurrently(t *testing.T) {
+	const nstacks = 20
+
+	layout := []string{
+		"f:modules/changed/main.tf:# changed",
+		"f:modules/unchanged/main.tf:# unchanged",
+	}
+	var want []string
+	for i := 0; i < nstacks; i++ {
+		module := "unchanged"
+		if i%3 == 0 {
+			module = "changed"
+			want = append(want, fmt.Sprintf("/stacks/stack-%02d", i))
+		}
+		layout = append(layout,
+			fmt.Sprintf("s:stacks/stack-%02d", i),
+			fmt.Sprintf(`f:stacks/stack-%02d/main.tf:module "mod" {
+				source = "../../modules/%s"
+			}`, i, module),
+		)
+	}
+
+	s := sandbox.New(t)
+	s.BuildTree(layout)
+
+	git := s.Git()
+	git.CommitAll("first commit")
+	git.Push("main")
+	git.CheckoutNew("change-module")
+
+	s.RootEntry().CreateFile("modules/changed/main.tf", "# module changed")
+	git.CommitAll("change module")
+
+	for _, concurrency := range []int{0, 1, 4, 2 * nstacks} {
+		m := stack.NewManagerWithOptions(s.Config(), defaultBranch, stack.ManagerOptions{
+			ModuleConcurrency: concurrency,
+		})
+		report, err := m.ListChanged()
+		assert.NoError(t, err)
+		assertStacks(t, want, report.Stacks, true)
+
+		for _, entry := range report.Stacks {
+			assert.EqualStrings(t, string(stack.ChangeKindModule), string(entry.Kind))
+			if diff := cmp.Diff([]string{"modules/changed/main.tf"}, entry.Stack.ChangedFiles); diff != "" {
+				t.Fatalf("-(want) +(got):\n%s", diff)
+			}
+		}
+	}
+
+	// an invalid module source used by any stack fails the whole detection.
+	s.RootEntry().CreateFile("modules/unchanged/main.tf", `module "mod" {
+		source = "../missing"
+	}`)
+	git.CommitAll("break module")
+
+	m := stack.NewManagerWithOptions(s.Config(), defaultBranch, stack.ManagerOptions{
+		ModuleConcurrency: 4,
+	})
+	_, err := m.ListChanged()
+	assert.Error(t, err)
+}
+
+func TestListChangedListsSharedModuleChangesOnce(t *testing.T) {
+	const nstacks = 10
+
+	layout := []string{"f:modules/shared/main.tf:# shared"}
+	var want []string
+	for i := 0; i < nstacks; i++ {
+		dir := fmt.Sprintf("stacks/stack-%d", i)
+		want = append(want, "/"+dir)
+		layout = append(layout,
+			"s:"+dir,
+			"f:"+dir+`/main.tf:module "shared" {
+				source = "../../modules/shared"
+			}`,
+		)
+	}
+
+	s := sandbox.New(t)
+	s.BuildTree(layout)
+
+	git := s.Git()
+	git.CommitAll("first commit")
+	git.Push("main")
+	git.CheckoutNew("change-module")
+
+	s.RootEntry().CreateFile("modules/shared/main.tf", "# changed")
+	git.CommitAll("change module")
+
+	// fake git logging the working directory of each command.
+	realGit, err := exec.LookPath("git")
+	assert.NoError(t, err)
+
+	bindir := t.TempDir()
+	logfile := filepath.Join(t.TempDir(), "dirs.log")
+	script := fmt.Sprintf("#!/bin/sh\npwd >> %q\nexec %q \"$@\"\n", logfile, realGit)
+	test.WriteFile(t, bindir, "git", script)
+	assert.NoError(t, os.Chmod(filepath.Join(bindir, "git"), 0755))
+
+	t.Setenv("PATH", bindir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+	report, err := m.ListChanged()
+	assert.NoError(t, err)
+	assertStacks(t, want, report.Stacks, true)
+
+	data, err := os.ReadFile(logfile)
+	assert.NoError(t, err)
+
+	moddir := filepath.Join(s.RootDir(), "modules", "shared")
+	gitCalls := 0
+	for _, dir := range strings.Fields(string(data)) {
+		if dir == moddir {
+			gitCalls++
+		}
+	}
+
+	// the changes of the module directory are listed with a few git
+	// commands, which don't depend on the number of stacks using it.
+	assert.IsTrue(t, gitCalls > 0 && gitCalls < nstacks,
+		"%d git commands run on the shared module directory", gitCalls)
+}
+
+func TestListChangedTfVars(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stack-vars",
+		"s:stack-json-vars",
+		"s:stack",
+		"f:stack-vars/main.tf:# main",
+		`f:stack-vars/terraform.tfvars:region = "us-east-1"`,
+		`f:stack-json-vars/prod.auto.tfvars.json:{"region": "us-east-1"}`,
+		"f:stack/main.tf:# main",
+	})
+
+	git := s.Git()
+	git.CommitAll("first commit")
+	git.Push("main")
+	git.CheckoutNew("change-vars")
+
+	s.RootEntry().CreateFile("stack-vars/terraform.tfvars", `region = "eu-west-1"`)
+	s.RootEntry().CreateFile("stack-json-vars/prod.auto.tfvars.json", `{"region": "eu-west-1"}`)
+	git.CommitAll("change vars")
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+	report, err := m.ListChanged()
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/stack-json-vars", "/stack-vars"}, report.Stacks, true)
+
+	for _, entry := range report.Stacks {
+		assert.EqualStrings(t, string(stack.ChangeKindDirect), string(entry.Kind))
+	}
+
+	want := []string{
+		`stack has unmerged changes in Terraform variables file "/stack-json-vars/prod.auto.tfvars.json"`,
+		`stack has unmerged changes in Terraform variables file "/stack-vars/terraform.tfvars"`,
+	}
+	for i, entry := range report.Stacks {
+		assert.EqualStrings(t, want[i], entry.Reason)
+	}
+}
+
+func TestListChangedVarFiles(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		`s:stack-a:var_files=["/vars/common.tfvars"]`,
+		`s:stack-b:var_files=["../vars/prod.tfvars.json"]`,
+		"s:stack-c",
+		"f:stack-a/main.tf:# main",
+		"f:stack-b/main.tf:# main",
+		"f:stack-c/main.tf:# main",
+		`f:vars/common.tfvars:region = "us-east-1"`,
+		`f:vars/prod.tfvars.json:{"region": "us-east-1"}`,
+	})
+
+	git := s.Git()
+	git.CommitAll("first commit")
+	git.Push("main")
+	git.CheckoutNew("change-var-files")
+
+	s.RootEntry().CreateFile("vars/common.tfvars", `region = "eu-west-1"`)
+	git.CommitAll("change common vars")
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+	report, err := m.ListChanged()
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/stack-a"}, report.Stacks, true)
+
+	entry := report.Stacks[0]
+	assert.EqualStrings(t, string(stack.ChangeKindVarFile), string(entry.Kind))
+	assert.EqualStrings(t,
+		`stack changed because Terraform variables file "/vars/common.tfvars" changed`,
+		entry.Reason)
+	if diff := cmp.Diff([]string{"vars/common.tfvars"}, entry.Stack.ChangedFiles); diff != "" {
+		t.Errorf("unexpected changed files (-want +got):\n%s", diff)
+	}
+	assert.EqualInts(t, 1, report.Summary().VarFile)
+
+	s.RootEntry().CreateFile("vars/prod.tfvars.json", `{"region": "eu-west-1"}`)
+	git.CommitAll("change prod vars")
+
+	m = stack.NewManager(s.ReloadConfig(), defaultBranch)
+	report, err = m.ListChanged()
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/stack-a", "/stack-b"}, report.Stacks, true)
+}
+
+func TestListChangedInvalidVarFiles(t *testing.T) {
+	for _, varFile := range []string{"/vars/common.tf", "/vars"} {
+		s := sandbox.New(t)
+		s.BuildTree([]string{
+			`s:stack:var_files=["` + varFile + `"]`,
+			"f:vars/common.tf:# vars",
+		})
+		git := s.Git()
+		git.CommitAll("first commit")
+		git.Push("main")
+
+		cfg, err := config.LoadRoot(s.RootDir())
+		if err == nil {
+			_, err = stack.NewManager(cfg, defaultBranch).ListChanged()
+		}
+		assert.IsError(t, err, errors.E(config.ErrStackInvalidVarFiles))
+	}
+}
+
+func TestListChangedRootConfig(t *testing.T) {
+	const rootConfigFmt = `terramate {
+  config {
+    git {
+      default_branch = %q
+    }
+    run {
+      env {
+        %s
+      }
+    }
+  }
+}
+`
+	type testcase struct {
+		name   string
+		branch string
+		env    string
+		want   []string
+	}
+
+	for _, tc := range []testcase{
+		{
+			name:   "formatting and comments are not root config changes",
+			branch: "main",
+			env:    "# comment\n        FOO = \"foo\"\n        BAR = \"bar\"",
+		},
+		{
+			name:   "default branch changed",
+			branch: "trunk",
+			env:    "FOO = \"foo\"\nBAR = \"bar\"",
+			want:   []string{stack.RootConfigGitDefaultBranch},
+		},
+		{
+			name:   "env changed, added and removed",
+			branch: "main",
+			env:    "FOO = \"changed\"\nBAZ = \"baz\"",
+			want: []string{
+				stack.RootConfigRunEnv + ".BAR",
+				stack.RootConfigRunEnv + ".BAZ",
+				stack.RootConfigRunEnv + ".FOO",
+			},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			s := sandbox.New(t)
+			s.BuildTree([]string{
+				"s:stack",
+				"f:root.tm:" + fmt.Sprintf(rootConfigFmt, "main", "FOO = \"foo\"\nBAR = \"bar\""),
+			})
+
+			git := s.Git()
+			git.CommitAll("first commit")
+			git.Push("main")
+			git.CheckoutNew("change-root-config")
+
+			s.RootEntry().CreateFile("root.tm", rootConfigFmt, tc.branch, tc.env)
+			git.CommitAll("change root config")
+
+			m := stack.NewManager(s.Config(), defaultBranch)
+			report, err := m.ListChanged()
+			assert.NoError(t, err)
+			assertStacks(t, []string{}, report.Stacks, false)
+			test.AssertDiff(t, report.RootConfigChanges, tc.want)
+		})
+	}
+}
+
+func TestListChangedIgnoresNonRootConfigChanges(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stack",
+		"f:root.tm:terramate {\n  config {\n  }\n}\n",
+	})
+
+	git := s.Git()
+	git.CommitAll("first commit")
+	git.Push("main")
+	git.CheckoutNew("change-stack")
+
+	s.RootEntry().CreateFile("stack/globals.tm", `globals {
+  FOO = "foo"
+}
+`)
+	git.CommitAll("change stack config")
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+	report, err := m.ListChanged()
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/stack"}, report.Stacks, true)
+	assert.EqualInts(t, 0, len(report.RootConfigChanges))
+}
+
+func TestListChangedFiles(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stack-a",
+		"s:stack-a/child",
+		"d:stack-a/dir",
+		`s:stack-b:watch=["/config.json"]`,
+		"s:stack-c",
+		`f:stack-c/main.tf:module "mod" {
+			source = "../modules/mod"
+		}`,
+		"f:modules/mod/main.tf:# module",
+		"f:config.json:{}",
+	})
+
+	git := s.Git()
+	git.CommitAll("first commit")
+	git.Push("main")
+	git.CheckoutNew("change-files")
+
+	s.RootEntry().CreateFile("stack-a/main.tf", "# changed")
+	s.RootEntry().CreateFile("stack-a/dir/file.txt", "changed")
+	s.RootEntry().CreateFile("stack-a/child/main.tf", "# changed")
+	s.RootEntry().CreateFile("config.json", `{"changed": true}`)
+	s.RootEntry().CreateFile("modules/mod/main.tf", "# changed module")
+	git.CommitAll("change files")
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+	report, err := m.ListChanged()
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/stack-a", "/stack-a/child", "/stack-b", "/stack-c"}, report.Stacks, true)
+
+	want := [][]string{
+		{"stack-a/dir/file.txt", "stack-a/main.tf"},
+		{"stack-a/child/main.tf"},
+		{"config.json"},
+		{"modules/mod/main.tf"},
+	}
+	for i, entry := range report.Stacks {
+		if diff := cmp.Diff(want[i], entry.Stack.ChangedFiles); diff != "" {
+			t.Errorf("unexpected changed files of %s (-want +got):\n%s", entry.Stack.Dir, diff)
+		}
+	}
+}
+
+func TestListChangedMovedStack(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:old:id=stack-id",
+		"s:other",
+		"f:old/main.tf:# some terraform code",
+	})
+
+	git := s.Git()
+	git.CommitAll("first commit")
+	git.Push("main")
+	git.CheckoutNew("move-stack")
+
+	g := test.NewGitWrapper(t, s.RootDir(), []string{})
+	_, err := g.Exec("mv", "old", "new")
+	assert.NoError(t, err)
+	git.CommitAll("move stack")
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+	report, err := m.ListChanged()
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/new"}, report.Stacks, true)
+
+	entry := report.Stacks[0]
+	assert.EqualStrings(t, string(stack.ChangeKindMoved), string(entry.Kind))
+	assert.EqualStrings(t, "/old", entry.MovedFrom.String())
+	assert.EqualStrings(t, "stack moved from /old to /new", entry.Reason)
+	assert.EqualInts(t, 1, report.Summary().Moved)
+}
+
+func TestListChangedMovedNestedStack(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:parent",
+		"s:parent/old:id=stack-id",
+		"f:parent/old/main.tf:# some terraform code",
+	})
+
+	git := s.Git()
+	git.CommitAll("first commit")
+	git.Push("main")
+	git.CheckoutNew("move-stack")
+
+	g := test.NewGitWrapper(t, s.RootDir(), []string{})
+	_, err := g.Exec("mv", "parent/old", "parent/new")
+	assert.NoError(t, err)
+	git.CommitAll("move stack")
+
+	// the files of the old directory must not be attributed to the parent.
+	m := stack.NewManager(s.Config(), defaultBranch)
+	report, err := m.ListChanged()
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/parent/new"}, report.Stacks, true)
+	assert.EqualStrings(t, string(stack.ChangeKindMoved), string(report.Stacks[0].Kind))
+}
+
+func TestListChangedDeletedStack(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:parent",
+		"s:parent/child",
+		"s:other",
+		"f:parent/child/main.tf:# some terraform code",
+		"f:parent/dir/file.txt:some file",
+	})
+
+	git := s.Git()
+	git.CommitAll("first commit")
+	git.Push("main")
+	git.CheckoutNew("delete-stack")
+
+	g := test.NewGitWrapper(t, s.RootDir(), []string{})
+	_, err := g.Exec("rm", "-r", "parent/child")
+	assert.NoError(t, err)
+	git.CommitAll("delete stack")
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+	report, err := m.ListChanged()
+	assert.NoError(t, err)
+	assertStacks(t, []string{}, report.Stacks, true)
+
+	// deleting a directory which is not a stack changes the parent stack.
+	_, err = g.Exec("rm", "-r", "parent/dir")
+	assert.NoError(t, err)
+	git.CommitAll("delete dir")
+
+	m = stack.NewManager(s.ReloadConfig(), defaultBranch)
+	report, err = m.ListChanged()
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/parent"}, report.Stacks, true)
+	if diff := cmp.Diff([]string{"parent/dir/file.txt"}, report.Stacks[0].Stack.ChangedFiles); diff != "" {
+		t.Errorf("unexpected changed files (-want +got):\n%s", diff)
+	}
+}
+
+func TestListChangedMovedStackWithDifferentID(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:old:id=old-id",
+		"f:old/main.tf:# some terraform code that is not changed",
+	})
+
+	git := s.Git()
+	git.CommitAll("first commit")
+	git.Push("main")
+	git.CheckoutNew("move-stack")
+
+	g := test.NewGitWrapper(t, s.RootDir(), []string{})
+	_, err := g.Exec("mv", "old", "new")
+	assert.NoError(t, err)
+	s.RootEntry().CreateFile("new/terramate.tm.hcl", `stack {
+  id = "new-id"
+}
+`)
+	git.CommitAll("move stack and change its id")
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+	report, err := m.ListChanged()
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/new"}, report.Stacks, true)
+
+	entry := report.Stacks[0]
+	assert.EqualStrings(t, string(stack.ChangeKindDirect), string(entry.Kind))
+	assert.EqualStrings(t, "", entry.MovedFrom.String())
+}
+
+func TestManagerMaxStacks(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stack-a",
+		"s:stack-b",
+		"s:stack-c",
+	})
+
+	git := s.Git()
+	git.CommitAll("first commit")
+	git.Push("main")
+	git.CheckoutNew("change-stacks")
+
+	s.RootEntry().CreateFile("stack-a/main.tf", "# changed")
+	s.RootEntry().CreateFile("stack-b/main.tf", "# changed")
+	git.CommitAll("change stacks")
+
+	newManager := func(max int) *stack.Manager {
+		return stack.NewManagerWithOptions(s.Config(), defaultBranch, stack.ManagerOptions{
+			MaxStacks: max,
+		})
+	}
+
+	for _, max := range []int{0, 3} {
+		report, err := newManager(max).List()
+		assert.NoError(t, err)
+		assert.EqualInts(t, 3, len(report.Stacks))
+	}
+
+	for _, max := range []int{0, 2} {
+		report, err := newManager(max).ListChanged()
+		assert.NoError(t, err)
+		assertStacks(t, []string{"/stack-a", "/stack-b"}, report.Stacks, true)
+	}
+
+	_, err := newManager(2).List()
+	assert.IsError(t, err, errors.E(stack.ErrTooManyStacks))
+	assert.IsTrue(t, strings.Contains(err.Error(), "found 3 stacks"),
+		"error %q does not contain the stacks count", err)
+
+	_, err = newManager(1).ListChanged()
+	assert.IsError(t, err, errors.E(stack.ErrTooManyStacks))
+	assert.IsTrue(t, strings.Contains(err.Error(), "found 2 stacks"),
+		"error %q does not contain the stacks count", err)
+}
+
+func TestManagerGitConcurrency(t *testing.T) {
+	const concurrency = 2
+
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stack-a",
+		"s:stack-b",
+		"s:stack-c",
+	})
+
+	git := s.Git()
+	git.CommitAll("first commit")
+	git.Push("main")
+	git.CheckoutNew("change-stacks")
+
+	s.RootEntry().CreateFile("stack-a/main.tf", "# changed")
+	git.CommitAll("change stack")
+
+	// the fake git records how many git commands are running when it
+	// starts and holds its slot for a while, so overlapping commands are
+	// detected.
+	realGit, err := exec.LookPath("git")
+	assert.NoError(t, err)
+
+	bindir := t.TempDir()
+	runningDir := t.TempDir()
+	logfile := filepath.Join(t.TempDir(), "running.log")
+	script := fmt.Sprintf(`#!/bin/sh
+mkdir "%[1]s/$$"
+ls "%[1]s" | wc -l >> "%[2]s"
+sleep 0.02
+"%[3]s" "$@"
+status=$?
+rmdir "%[1]s/$$"
+exit $status
+`, runningDir, logfile, realGit)
+	test.WriteFile(t, bindir, "git", script)
+	assert.NoError(t, os.Chmod(filepath.Join(bindir, "git"), 0755))
+
+	t.Setenv("PATH", bindir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	m := stack.NewManagerWithOptions(s.Config(), defaultBranch, stack.ManagerOptions{
+		GitConcurrency: concurrency,
+	})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := m.List()
+			errs <- err
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := m.ListChanged()
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+
+	data, err := os.ReadFile(logfile)
+	assert.NoError(t, err)
+
+	lines := strings.Fields(string(data))
+	if len(lines) == 0 {
+		t.Fatal("fake git was never called")
+	}
+	for _, line := range lines {
+		running, err := strconv.Atoi(line)
+		assert.NoError(t, err)
+		if running > concurrency {
+			t.Fatalf("%d git commands running concurrently, want at most %d",
+				running, concurrency)
+		}
+	}
+}
+
+func TestAddWantedByOf(t *testing.T) {
+	s := sandbox.NoGit(t)
+	s.BuildTree([]string{
+		`s:base`,
+		`s:network:wants=["/base"]`,
+		`s:app:wants=["/network"]`,
+		`s:monitoring:wanted_by=["/app"]`,
+		`s:database:wanted_by=["/monitoring"]`,
+		`s:consumer:wants=["/base", "/network"]`,
+		`s:unrelated`,
+	})
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+	scope := func(paths ...string) config.List[*config.SortableStack] {
+		var stacks config.List[*config.SortableStack]
+		for _, path := range paths {
+			stacks = append(stacks, s.LoadStack(project.NewPath(path)).Sortable())
+		}
+		return stacks
+	}
+	assertSelected := func(want []string, got config.List[*config.SortableStack]) {
+		t.Helper()
+		var paths []string
+		for _, st := range got {
+			paths = append(paths, st.Dir().String())
+		}
+		if diff := cmp.Diff(want, paths); diff != "" {
+			t.Fatalf("-(want) +(got):\n%s", diff)
+		}
+	}
+
+	got, err := m.AddWantedByOf(scope("/base"))
+	assert.NoError(t, err)
+	assertSelected([]string{"/base", "/consumer", "/network", "/app"}, got)
+
+	got, err = m.AddWantedByOf(scope("/database", "/network"))
+	assert.NoError(t, err)
+	assertSelected([]string{"/database", "/network", "/monitoring", "/app", "/consumer"}, got)
+
+	got, err = m.AddWantedByOf(scope("/unrelated"))
+	assert.NoError(t, err)
+	assertSelected([]string{"/unrelated"}, got)
+
+	got, err = m.AddWantedOf(scope("/app"))
+	assert.NoError(t, err)
+	assertSelected([]string{"/app", "/network", "/monitoring", "/base", "/database"}, got)
+}
+
+func TestListChangedSinceForkPoint(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stack-a",
+		"s:stack-b",
+	})
+
+	git := s.Git()
+	git.CommitAll("first commit")
+	git.Push("main")
+
+	s.RootEntry().CreateFile("stack-b/file.txt", "v1")
+	git.CommitAll("change stack-b")
+	git.Push("main")
+
+	git.CheckoutNew("feature")
+	s.RootEntry().CreateFile("stack-a/file.txt", "feature")
+	git.CommitAll("change stack-a")
+
+	// rewrite main history and force-push it.
+	g := test.NewGitWrapper(t, s.RootDir(), []string{})
+	git.Checkout("main")
+	_, err := g.Exec("reset", "--hard", "HEAD~1")
+	assert.NoError(t, err)
+	s.RootEntry().CreateFile("stack-b/file.txt", "v2")
+	git.CommitAll("rewritten change of stack-b")
+	_, err = g.Exec("push", "--force", "origin", "main")
+	assert.NoError(t, err)
+	git.Checkout("feature")
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+
+	// the plain diff attributes the rewritten main changes to the branch.
+	report, err := m.ListChanged()
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/stack-a", "/stack-b"}, report.Stacks, true)
+
+	report, err = m.ListChangedSinceForkPoint()
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/stack-a"}, report.Stacks, true)
+}
+
+func TestListChangedSinceForkPointFallback(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stack-a",
+		"s:stack-b",
+	})
+
+	git := s.Git()
+	git.CommitAll("first commit")
+	git.Push("main")
+
+	git.CheckoutNew("feature")
+	s.RootEntry().CreateFile("stack-a/file.txt", "feature")
+	git.CommitAll("change stack-a")
+
+	git.Checkout("main")
+	s.RootEntry().CreateFile("stack-b/file.txt", "main")
+	git.CommitAll("change stack-b")
+	git.Checkout("feature")
+
+	// tags have no reflog, then the merge base is used.
+	g := test.NewGitWrapper(t, s.RootDir(), []string{})
+	_, err := g.Exec("tag", "main-tag", "main")
+	assert.NoError(t, err)
+
+	m := stack.NewManager(s.Config(), "main-tag")
+	report, err := m.ListChangedSinceForkPoint()
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/stack-a"}, report.Stacks, true)
+}
+
+func TestListChangedMergeBase(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stack-a",
+		"s:stack-b",
+	})
+
+	git := s.Git()
+	git.CommitAll("first commit")
+	git.Push("main")
+
+	git.CheckoutNew("feature")
+	s.RootEntry().CreateFile("stack-a/file.txt", "feature")
+	git.CommitAll("change stack-a")
+
+	git.Checkout("main")
+	s.RootEntry().CreateFile("stack-b/file.txt", "main")
+	git.CommitAll("change stack-b")
+	git.Push("main")
+	git.Checkout("feature")
+
+	// two-dot comparison reports the changes made on main after the branch
+	// point as changes of the current branch.
+	m := stack.NewManager(s.Config(), defaultBranch)
+	report, err := m.ListChanged()
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/stack-a", "/stack-b"}, report.Stacks, true)
+
+	m = stack.NewManagerWithOptions(s.Config(), defaultBranch, stack.ManagerOptions{
+		MergeBase: true,
+	})
+	report, err = m.ListChanged()
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/stack-a"}, report.Stacks, true)
+
+	// explicit refs are also compared from their merge base.
+	report, err = m.ListChangedFrom(defaultBranch, "HEAD")
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/stack-a"}, report.Stacks, true)
+
+	// changed files given explicitly are not affected.
+	diff := "M\tstack-a/file.txt\nA\tstack-b/file.txt\n"
+	report, err = m.ListChangedFromDiff(strings.NewReader(diff))
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/stack-a", "/stack-b"}, report.Stacks, true)
+}
+
+func TestListChangedFromComparesHeadRef(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stack",
+		`f:root.tm:terramate {
+		  config {
+		    git {
+		      default_branch = "main"
+		    }
+		  }
+		}`,
+	})
+
+	git := s.Git()
+	git.CommitAll("first commit")
+	git.Push("main")
+	git.CheckoutNew("feature")
+
+	s.RootEntry().CreateFile("root.tm", `terramate {
+	  config {
+	    git {
+	      default_branch = "trunk"
+	    }
+	  }
+	}`)
+	s.RootEntry().CreateFile("stack/main.tf", "# changed")
+	git.CommitAll("change root config and stack")
+	git.Checkout("main")
+
+	// the root config of the head ref is compared, even if HEAD is not it.
+	m := stack.NewManager(s.Config(), defaultBranch)
+	report, err := m.ListChangedFrom("main", "feature")
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/stack"}, report.Stacks, true)
+	test.AssertDiff(t, report.RootConfigChanges, []string{stack.RootConfigGitDefaultBranch})
+	test.AssertDiff(t, report.ChangedFiles, []string{"root.tm", "stack/main.tf"})
+}
+
+func TestListChangedMergeCommit(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stack-a",
+		"s:stack-b",
+		"s:stack-c",
+	})
+
+	git := s.Git()
+	git.CommitAll("first commit")
+	git.Push("main")
+
+	git.CheckoutNew("feature")
+	s.RootEntry().CreateFile("stack-a/file.txt", "feature")
+	git.CommitAll("change stack-a")
+
+	git.Checkout("main")
+	git.CheckoutNew("change")
+	s.RootEntry().CreateFile("stack-b/file.txt", "change")
+	git.CommitAll("change stack-b")
+	git.MergeNoFF("feature", "merge feature")
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+	report, err := m.ListChanged()
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/stack-a", "/stack-b"}, report.Stacks, true)
+}
+
+func TestListChangedMidMergeConflict(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stack-a",
+		"s:stack-b",
+		"f:stack-a/main.tf:# base",
+	})
+
+	git := s.Git()
+	git.CommitAll("first commit")
+	git.Push("main")
+
+	git.CheckoutNew("feature")
+	s.RootEntry().CreateFile("stack-a/main.tf", "# feature")
+	git.CommitAll("change stack-a on feature")
+
+	git.Checkout("main")
+	git.CheckoutNew("change")
+	s.RootEntry().CreateFile("stack-a/main.tf", "# change")
+	s.RootEntry().CreateFile("stack-b/file.txt", "change")
+	git.CommitAll("change stack-a and stack-b")
+
+	conflicts := git.MergeConflict("feature")
+	test.AssertEqualSets(t, conflicts, []string{"stack-a/main.tf"})
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+	report, err := m.ListChanged()
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/stack-a", "/stack-b"}, report.Stacks, true)
+	test.AssertEqualSets(t, report.Checks.UncommittedFiles, []string{"stack-a/main.tf"})
+	test.AssertEqualSets(t, report.Checks.UntrackedFiles, []string{})
+
+	git.MergeAbort()
+
+	report, err = m.ListChanged()
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/stack-a", "/stack-b"}, report.Stacks, true)
+	test.AssertEqualSets(t, report.Checks.UncommittedFiles, []string{})
+}
+
+func TestListChangedShallowClone(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stack-a",
+		"s:stack-b",
+	})
+
+	git := s.Git()
+	git.CommitAll("first commit")
+	s.RootEntry().CreateFile("stack-a/file.txt", "changed")
+	git.CommitAll("change stack-a")
+	git.Push("main")
+
+	listChanged := func(depth int) (*stack.Report, error) {
+		clonedir := t.TempDir()
+		git.ShallowClone(git.BareRepoAbsPath(), clonedir, depth)
+
+		root, err := config.LoadRoot(clonedir)
+		assert.NoError(t, err)
+		return stack.NewManager(root, "HEAD^").ListChanged()
+	}
+
+	_, err := listChanged(1)
+	assert.IsError(t, err, errors.E(stack.ErrShallowRepo))
+
+	report, err := listChanged(2)
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/stack-a"}, report.Stacks, true)
+}
+
+func TestListChangedDetectsDefaultBranch(t *testing.T) {
+	s := sandbox.NewWithGitConfig(t, sandbox.GitConfig{
+		LocalBranchName:         "trunk",
+		DefaultRemoteName:       "origin",
+		DefaultRemoteBranchName: "trunk",
+	})
+	s.BuildTree([]string{
+		"s:stack-a",
+		"s:stack-b",
+	})
+
+	git := s.Git()
+	git.CommitAll("first commit")
+	git.Push("trunk")
+
+	git.CheckoutNew("feature")
+	s.RootEntry().CreateFile("stack-a/file.txt", "changed")
+	git.CommitAll("change stack-a")
+
+	m := stack.NewManager(s.Config(), "")
+	report, err := m.ListChanged()
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/stack-a"}, report.Stacks, true)
+
+	report, err = m.ListChangedSinceForkPoint()
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/stack-a"}, report.Stacks, true)
+}
+
+func TestListChangedTriggeredByTags(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		`s:stacks/db:tags=["database"]`,
+		`s:stacks/db-replica:tags=["database", "replica"]`,
+		`s:other/db:tags=["database"]`,
+		`s:stacks/app:tags=["app"]`,
+	})
+
+	git := s.Git()
+	git.CommitAll("first commit")
+	git.Push("main")
+	git.CheckoutNew("trigger-tags")
+
+	root := s.Config()
+	assert.NoError(t, trigger.CreateForTags(root, "database", "rotate credentials"))
+	git.CommitAll("trigger database stacks")
+
+	m := stack.NewManager(root, defaultBranch)
+	report, err := m.ListChanged()
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/other/db", "/stacks/db", "/stacks/db-replica"}, report.Stacks, true)
+
+	for _, entry := range report.Stacks {
+		assert.EqualStrings(t, string(stack.ChangeKindTrigger), string(entry.Kind))
+		assert.IsTrue(t, strings.HasPrefix(entry.Reason,
+			"stack has been triggered by tag database via /.tmtriggers/changed-"),
+			"unexpected reason: %s", entry.Reason)
+	}
+
+	report, err = m.ListChangedUnder(project.NewPath("/stacks"))
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/stacks/db", "/stacks/db-replica"}, report.Stacks, true)
+
+	// deleted tags triggers are ignored.
+	git.Push("trigger-tags")
+	m = stack.NewManager(root, "origin/trigger-tags")
+	g := test.NewGitWrapper(t, s.RootDir(), []string{})
+	_, err = g.Exec("rm", "-r", ".tmtriggers")
+	assert.NoError(t, err)
+	git.Commit("delete triggers")
+
+	report, err = m.ListChanged()
+	assert.NoError(t, err)
+	assertStacks(t, []string{}, report.Stacks, true)
+}
+
+func TestListChangedIgnoreTriggers(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stack-ignored",
+		"s:stack-triggered",
+		"s:stack-changed",
+		"s:stack-unchanged",
+	})
+
+	git := s.Git()
+	git.CommitAll("first commit")
+	git.Push("main")
+	git.CheckoutNew("ignore-stacks")
+
+	s.BuildTree([]string{
+		"f:stack-ignored/README.md:# docs",
+		"f:stack-triggered/README.md:# docs",
+		"f:stack-changed/main.tf:# changed",
+	})
+
+	root := s.Config()
+	for _, dir := range []string{"/stack-ignored", "/stack-triggered", "/stack-unchanged"} {
+		assert.NoError(t, trigger.CreateIgnore(root, project.NewPath(dir), "docs only"))
+	}
+	// positive triggers win over ignore triggers.
+	assert.NoError(t, trigger.Create(root, project.NewPath("/stack-triggered"), "forced"))
+	git.CommitAll("ignore stacks")
+
+	m := stack.NewManager(root, defaultBranch)
+	report, err := m.ListChanged()
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/stack-changed", "/stack-triggered"}, report.Stacks, true)
+
+	for _, entry := range report.Stacks {
+		if entry.Stack.Dir.String() == "/stack-triggered" {
+			assert.EqualStrings(t, string(stack.ChangeKindTrigger), string(entry.Kind))
+		}
+	}
+
+	// consumed ignore triggers select no stack.
+	consumed, err := m.ConsumeTriggers()
+	assert.NoError(t, err)
+	test.AssertEqualSets(t, consumed, []project.Path{project.NewPath("/stack-triggered")})
+	git.Commit("consume triggers")
+
+	report, err = m.ListChanged()
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/stack-changed", "/stack-ignored", "/stack-triggered"}, report.Stacks, true)
+}
+
+func TestListChangedInvalidTriggerTags(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{"s:stack"})
+
+	git := s.Git()
+	git.CommitAll("first commit")
+	git.Push("main")
+	git.CheckoutNew("trigger-tags")
+
+	s.BuildTree([]string{`f:.tmtriggers/changed-invalid.tm.hcl:trigger {
+		ctime  = 1
+		reason = "invalid"
+		tags   = "Invalid Tag"
+	}`})
+	git.CommitAll("add invalid trigger")
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+	_, err := m.ListChanged()
+	assert.IsError(t, err, errors.E(trigger.ErrInvalidTags))
+}
+
+func TestStacksChangedByAuthor(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stack-alice",
+		"s:stack-bob",
+		"s:stack-module",
+		"s:stack-untouched",
+		"f:modules/mod/main.tf:# module",
+		`f:stack-module/main.tf:module "mod" {
+			source = "../modules/mod"
+		}`,
+	})
+
+	git := s.Git()
+	git.CommitAll("first commit")
+	git.Push("main")
+	base := git.RevParse("HEAD")
+	git.CheckoutNew("changes")
+
+	commitAs := func(author, file string) {
+		s.RootEntry().CreateFile(file, "# changed by %s", author)
+		git.Add(".")
+		git.Commit("change "+file, "--author="+author)
+	}
+
+	commitAs("Alice <alice@example.com>", "stack-alice/main.tf")
+	commitAs("Bob <bob@example.com>", "stack-bob/main.tf")
+	commitAs("Alice <alice@example.com>", "modules/mod/main.tf")
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+
+	entries, err := m.StacksChangedByAuthor(base, "HEAD", "alice@example.com")
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/stack-alice", "/stack-module"}, entries, true)
+
+	entries, err = m.StacksChangedByAuthor(base, "HEAD", "BOB@example.com")
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/stack-bob"}, entries, true)
+
+	entries, err = m.StacksChangedByAuthor(base, "HEAD", "nobody@example.com")
+	assert.NoError(t, err)
+	assertStacks(t, []string{}, entries, true)
+}
+
+func TestListChangedSinceNotes(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stack-a",
+		"s:stack-b",
+		"s:stack-c",
+	})
+
+	git := s.Git()
+	git.CommitAll("create stacks")
+	git.Push("main")
+	deployedAll := git.RevParse("HEAD")
+
+	s.RootEntry().CreateFile("stack-a/main.tf", "# changed")
+	s.RootEntry().CreateFile("stack-b/main.tf", "# changed")
+	git.CommitAll("change stacks a and b")
+	deployedB := git.RevParse("HEAD")
+
+	s.RootEntry().CreateFile("stack-c/main.tf", "# changed")
+	git.CommitAll("change stack c")
+	git.Push("main")
+
+	notes := test.NewGitWrapper(t, s.RootDir(), []string{"GIT_NOTES_REF=refs/notes/deploy"})
+	_, err := notes.Exec("notes", "add", "-m", "/stack-a\n/stack-b\n/stack-c", deployedAll)
+	assert.NoError(t, err)
+	_, err = notes.Exec("notes", "add", "-m", "# deployed by CI\n/stack-b", deployedB)
+	assert.NoError(t, err)
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+
+	report, err := m.ListChangedSinceNotes("deploy")
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/stack-a", "/stack-c"}, report.Stacks, true)
+
+	// no notes at all: all stacks are compared with the base ref.
+	report, err = m.ListChangedSinceNotes("other")
+	assert.NoError(t, err)
+	assertStacks(t, []string{}, report.Stacks, true)
+
+	// the manager options are honored when comparing each deployed commit.
+	s.RootEntry().CreateFile("stack-b/local.tf", "# uncommitted")
+	m = stack.NewManagerWithOptions(s.Config(), defaultBranch, stack.ManagerOptions{
+		IncludeLocalChanges: true,
+	})
+	report, err = m.ListChangedSinceNotes("deploy")
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/stack-a", "/stack-b", "/stack-c"}, report.Stacks, true)
+
+	m = stack.NewManagerWithOptions(s.Config(), defaultBranch, stack.ManagerOptions{
+		IncludeLocalChanges: true,
+		MaxStacks:           2,
+	})
+	_, err = m.ListChangedSinceNotes("deploy")
+	assert.IsError(t, err, errors.E(stack.ErrTooManyStacks))
+}
+
+func TestListChangedSinceNotesMergesBases(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stack-a",
+		"s:stack-b",
+	})
+
+	git := s.Git()
+	git.CommitAll("create stacks")
+	git.Push("main")
+	deployedA := git.RevParse("HEAD")
+
+	s.RootEntry().CreateFile("stack-a/main.tf", "# changed")
+	git.CommitAll("change stack a")
+	deployedB := git.RevParse("HEAD")
+
+	s.RootEntry().CreateFile("stack-b/main.tf", "# changed")
+	git.CommitAll("change stack b")
+	git.Push("main")
+
+	notes := test.NewGitWrapper(t, s.RootDir(), []string{"GIT_NOTES_REF=refs/notes/deploy"})
+	_, err := notes.Exec("notes", "add", "-m", "/stack-a", deployedA)
+	assert.NoError(t, err)
+	_, err = notes.Exec("notes", "add", "-m", "/stack-b", deployedB)
+	assert.NoError(t, err)
+
+	s.RootEntry().CreateFile("untracked.txt", "untracked")
+
+	tracefile := filepath.Join(t.TempDir(), "trace.jsonl")
+	m := stack.NewManagerWithOptions(s.Config(), defaultBranch, stack.ManagerOptions{
+		TraceFile: tracefile,
+	})
+
+	report, err := m.ListChangedSinceNotes("deploy")
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/stack-a", "/stack-b"}, report.Stacks, true)
+
+	if diff := cmp.Diff([]string{"stack-a/main.tf", "stack-b/main.tf"}, report.ChangedFiles); diff != "" {
+		t.Fatalf("unexpected changed files (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"untracked.txt"}, report.Checks.UntrackedFiles); diff != "" {
+		t.Fatalf("unexpected untracked files (-want +got):\n%s", diff)
+	}
+
+	// the trace has the decisions about the stacks compared against each
+	// deployed commit, without duplicates. The bases are compared in the
+	// order of their commit IDs, so the entries are sorted here.
+	want := []stack.TraceEntry{
+		{
+			File:     "stack-a/main.tf",
+			Stack:    "/stack-a",
+			Decision: stack.TraceChanged,
+			Kind:     stack.ChangeKindDirect,
+			Reason:   "stack has unmerged changes",
+		},
+		{
+			File:     "stack-b/main.tf",
+			Stack:    "/stack-b",
+			Decision: stack.TraceChanged,
+			Kind:     stack.ChangeKindDirect,
+			Reason:   "stack has unmerged changes",
+		},
+	}
+	got := readTrace(t, tracefile)
+	sort.Slice(got, func(i, j int) bool {
+		return got[i].Stack < got[j].Stack
+	})
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("unexpected trace (-want +got):\n%s", diff)
+	}
+}
+
 func assertStacks(
 	t *testing.T, want []string, got []stack.Entry, wantReason bool,
 ) {
@@ -0,0 +1,129 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/mineiros-io/terramate/config"
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/project"
+	"github.com/mineiros-io/terramate/tf"
+	"github.com/rs/zerolog/log"
+)
+
+// ModuleIssue is a local module source of a stack which doesn't resolve to
+// an existing directory.
+type ModuleIssue struct {
+	// Stack is the stack using the module.
+	Stack project.Path
+
+	// File is the Terraform file declaring the module. It is not inside the
+	// stack directory if the module is declared by another local module used
+	// by the stack.
+	File project.Path
+
+	// Source is the module source, as declared in the file.
+	Source string
+
+	// Reason describes the issue.
+	Reason string
+}
+
+// ValidateModuleSources checks that the local module sources (eg.:
+// "../modules/vpc") used by the Terraform files of each stack resolve to
+// existing directories, which usually isn't the case after modules are moved
+// or deleted without updating the stacks using them. The local modules used
+// by the stacks are checked recursively. Remote module sources are ignored.
+//
+// The issues are sorted by stack path, file and source.
+// An error is only returned if the stacks or their Terraform files can't be
+// loaded.
+func (m *Manager) ValidateModuleSources() ([]ModuleIssue, error) {
+	logger := log.With().
+		Str("action", "Manager.ValidateModuleSources()").
+		Logger()
+
+	entries, err := List(m.root.Tree())
+	if err != nil {
+		return nil, err
+	}
+
+	cache := tf.NewModuleCache()
+	issues := []ModuleIssue{}
+	for _, entry := range entries {
+		st := entry.Stack
+
+		logger.Trace().
+			Stringer("stack", st.Dir).
+			Msg("Check module sources.")
+
+		err := st.WalkLocalModules(m.root, cache, func(use config.LocalModuleUse) error {
+			if use.Err == nil {
+				return nil
+			}
+			usedir := filepath.Dir(use.File.HostPath(m.root.HostDir()))
+			issues = append(issues, ModuleIssue{
+				Stack:  st.Dir,
+				File:   use.File,
+				Source: use.Source,
+				Reason: fmt.Sprintf("module source %q %s", use.Source,
+					moduleSourceIssue(filepath.Join(usedir, use.Source), use.Err)),
+			})
+			return nil
+		})
+		if err != nil {
+			return nil, errors.E(err, "checking module sources of stack %s", st.Dir)
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		a, b := issues[i], issues[j]
+		if a.Stack != b.Stack {
+			return a.Stack.String() < b.Stack.String()
+		}
+		if a.File != b.File {
+			return a.File.String() < b.File.String()
+		}
+		return a.Source < b.Source
+	})
+	return issues, nil
+}
+
+// moduleSourceIssue describes why the moddir module source directory, which
+// failed to be resolved with resolveErr, is invalid.
+func moduleSourceIssue(moddir string, resolveErr error) string {
+	st, err := os.Stat(moddir)
+	switch {
+	case errors.Is(err, os.ErrNotExist) && isSymlink(moddir):
+		return "is a broken symbolic link"
+	case errors.Is(err, os.ErrNotExist):
+		return "does not exist"
+	case err != nil:
+		return err.Error()
+	case !st.IsDir():
+		return "is not a directory"
+	}
+	return resolveErr.Error()
+}
+
+func isSymlink(path string) bool {
+	st, err := os.Lstat(path)
+	return err == nil && st.Mode()&fs.ModeSymlink != 0
+}
@@ -0,0 +1,133 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/madlambda/spells/assert"
+	"github.com/mineiros-io/terramate/project"
+	"github.com/mineiros-io/terramate/stack"
+	"github.com/mineiros-io/terramate/test/sandbox"
+)
+
+func TestValidateModuleSources(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stacks/valid",
+		"s:stacks/dangling",
+		"s:stacks/nested",
+		"f:modules/vpc/main.tf:# vpc",
+		"f:modules/file.tf:# not a module dir",
+		`f:modules/wrapper/main.tf:module "gone" {
+			source = "../removed"
+		}`,
+		`f:stacks/valid/main.tf:module "vpc" {
+			source = "../../modules/vpc"
+		}
+		module "remote" {
+			source = "github.com/mineiros-io/example"
+		}`,
+		`f:stacks/dangling/main.tf:module "moved" {
+			source = "../../modules/old-vpc"
+		}`,
+		`f:stacks/dangling/file.tf:module "file" {
+			source = "../../modules/file.tf"
+		}`,
+		`f:stacks/nested/main.tf:module "wrapper" {
+			source = "../../modules/wrapper"
+		}`,
+	})
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+	issues, err := m.ValidateModuleSources()
+	assert.NoError(t, err)
+
+	want := []stack.ModuleIssue{
+		{
+			Stack:  project.NewPath("/stacks/dangling"),
+			File:   project.NewPath("/stacks/dangling/file.tf"),
+			Source: "../../modules/file.tf",
+			Reason: `module source "../../modules/file.tf" is not a directory`,
+		},
+		{
+			Stack:  project.NewPath("/stacks/dangling"),
+			File:   project.NewPath("/stacks/dangling/main.tf"),
+			Source: "../../modules/old-vpc",
+			Reason: `module source "../../modules/old-vpc" does not exist`,
+		},
+		{
+			Stack:  project.NewPath("/stacks/nested"),
+			File:   project.NewPath("/modules/wrapper/main.tf"),
+			Source: "../removed",
+			Reason: `module source "../removed" does not exist`,
+		},
+	}
+	if diff := cmp.Diff(want, issues, cmp.AllowUnexported(project.Path{})); diff != "" {
+		t.Fatalf("unexpected issues (-want +got):\n%s", diff)
+	}
+}
+
+func TestValidateModuleSourcesNoIssues(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stack",
+		"f:modules/vpc/main.tf:# vpc",
+		`f:stack/main.tf:module "vpc" {
+			source = "../modules/vpc"
+		}`,
+	})
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+	issues, err := m.ValidateModuleSources()
+	assert.NoError(t, err)
+	assert.EqualInts(t, 0, len(issues))
+}
+
+func TestValidateModuleSourcesSymlinks(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stack",
+		`f:stack/main.tf:module "shared" {
+			source = "../shared"
+		}
+		module "broken" {
+			source = "../broken"
+		}`,
+		`f:modules/real/main.tf:module "self" {
+			source = "./loop"
+		}`,
+		"l:modules/real:shared",
+		"l:modules/real:modules/real/loop",
+		"l:modules/missing:broken",
+	})
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+	issues, err := m.ValidateModuleSources()
+	assert.NoError(t, err)
+
+	want := []stack.ModuleIssue{
+		{
+			Stack:  project.NewPath("/stack"),
+			File:   project.NewPath("/stack/main.tf"),
+			Source: "../broken",
+			Reason: `module source "../broken" is a broken symbolic link`,
+		},
+	}
+	if diff := cmp.Diff(want, issues, cmp.AllowUnexported(project.Path{})); diff != "" {
+		t.Fatalf("unexpected issues (-want +got):\n%s", diff)
+	}
+}
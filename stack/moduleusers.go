@@ -0,0 +1,198 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mineiros-io/terramate/config"
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/project"
+	"github.com/mineiros-io/terramate/tf"
+	"github.com/rs/zerolog/log"
+)
+
+const errStacksUsingModule errors.Kind = "listing stacks using module error"
+
+// StacksUsingModule lists the stacks whose module graph includes the given
+// module source, which are the stacks affected by a change (eg.: an upgrade)
+// of the module. The module graph of a stack is made of the modules declared
+// by its Terraform files and, recursively, by the files of its local modules
+// (see [config.Stack.LocalModules]).
+//
+// The source can be:
+//
+//   - A project absolute path (eg.: "/modules/vpc"), which matches the local
+//     modules located at that directory, independent of the relative source
+//     used to reference it.
+//   - A remote source, which matches module sources equal to it. Git sources
+//     (see [tf.ParseSource]) without a "ref" query parameter match the same
+//     repository and subdirectory pinned to any ref, so all the stacks using
+//     any version of the module are returned.
+//
+// The Reason of each entry tells where the module is used. The returned
+// stacks are sorted by path.
+func (m *Manager) StacksUsingModule(source string) ([]Entry, error) {
+	logger := log.With().
+		Str("action", "Manager.StacksUsingModule()").
+		Str("source", source).
+		Logger()
+
+	matcher, err := newModuleMatcher(source)
+	if err != nil {
+		return nil, errors.E(errStacksUsingModule, err)
+	}
+
+	stacks, err := List(m.root.Tree())
+	if err != nil {
+		return nil, errors.E(errStacksUsingModule, err)
+	}
+
+	cache := tf.NewModuleCache()
+	var entries []Entry
+	for _, entry := range stacks {
+		st := entry.Stack
+
+		logger.Trace().
+			Stringer("stack", st.Dir).
+			Msg("Check stack modules.")
+
+		reason, found, err := m.stackUsesModule(st, matcher, cache)
+		if err != nil {
+			return nil, errors.E(errStacksUsingModule, err, "stack %s", st.Dir)
+		}
+		if !found {
+			continue
+		}
+		entries = append(entries, Entry{
+			Stack:  st,
+			Reason: reason,
+		})
+	}
+
+	if err := m.checkMaxStacks(len(entries)); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// stackUsesModule tells if the module graph of the stack includes a module
+// matched by matcher, returning the reason describing where the module is
+// used.
+func (m *Manager) stackUsesModule(
+	st *config.Stack,
+	matcher moduleMatcher,
+	cache *tf.ModuleCache,
+) (string, bool, error) {
+	modules, err := st.LocalModulesWithCache(m.root, cache)
+	if err != nil {
+		return "", false, err
+	}
+
+	if matcher.local {
+		for _, mod := range modules {
+			if mod.Dir == matcher.dir {
+				return fmt.Sprintf("stack uses module %s through %q in %s",
+					mod.Dir, mod.Source, mod.UsedBy), true, nil
+			}
+		}
+		return "", false, nil
+	}
+
+	dirs := []project.Path{st.Dir}
+	for _, mod := range modules {
+		dirs = append(dirs, mod.Dir)
+	}
+	for _, dir := range dirs {
+		hostdir := dir.HostPath(m.root.HostDir())
+		files, err := os.ReadDir(hostdir)
+		if err != nil {
+			return "", false, errors.E(err, "listing files of directory %q", hostdir)
+		}
+		for _, file := range files {
+			if file.IsDir() || !tf.IsTerraformFile(file.Name()) {
+				continue
+			}
+			mods, err := cache.ParseModules(filepath.Join(hostdir, file.Name()))
+			if err != nil {
+				return "", false, errors.E(err, "parsing modules of %s", dir.Join(file.Name()))
+			}
+			for _, mod := range mods {
+				if !mod.IsLocal() && matcher.matches(mod.Source) {
+					return fmt.Sprintf("stack uses module %q in %s",
+						mod.Source, dir.Join(file.Name())), true, nil
+				}
+			}
+		}
+	}
+	return "", false, nil
+}
+
+// moduleMatcher matches module sources against the source given to
+// [Manager.StacksUsingModule].
+type moduleMatcher struct {
+	// local tells if the source is a local module directory.
+	local bool
+	dir   project.Path
+
+	source string
+
+	// git is the parsed source, if it is a git source.
+	git   tf.Source
+	isGit bool
+}
+
+func newModuleMatcher(source string) (moduleMatcher, error) {
+	if source == "" {
+		return moduleMatcher{}, errors.E("module source must not be empty")
+	}
+	if strings.HasPrefix(source, "/") {
+		return moduleMatcher{
+			local: true,
+			dir:   project.NewPath(source),
+		}, nil
+	}
+	if (tf.Module{Source: source}).IsLocal() {
+		return moduleMatcher{}, errors.E(
+			"local module source %q must be a project absolute path", source)
+	}
+
+	matcher := moduleMatcher{source: source}
+	if gitsrc, err := tf.ParseSource(source); err == nil {
+		matcher.git = gitsrc
+		matcher.isGit = true
+	}
+	return matcher, nil
+}
+
+func (mm moduleMatcher) matches(source string) bool {
+	if source == mm.source {
+		return true
+	}
+	if !mm.isGit {
+		return false
+	}
+	gitsrc, err := tf.ParseSource(source)
+	if err != nil {
+		return false
+	}
+	if gitsrc.Path != mm.git.Path || gitsrc.Subdir != mm.git.Subdir {
+		return false
+	}
+	return mm.git.Ref == "" || gitsrc.Ref == mm.git.Ref
+}
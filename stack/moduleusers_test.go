@@ -0,0 +1,125 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack_test
+
+import (
+	"testing"
+
+	"github.com/madlambda/spells/assert"
+	"github.com/mineiros-io/terramate/stack"
+	"github.com/mineiros-io/terramate/test/sandbox"
+)
+
+func TestStacksUsingModule(t *testing.T) {
+	const vpcSource = "github.com/mineiros-io/terraform-aws-vpc"
+
+	s := sandbox.NoGit(t)
+	s.BuildTree([]string{
+		"d:modules/network",
+		"d:modules/vpc",
+		"d:modules/unused",
+		`f:modules/network/main.tf:module "vpc" {
+			source = "../vpc"
+		}`,
+		`f:modules/vpc/main.tf:module "remote" {
+			source = "` + vpcSource + `?ref=v1.0.0"
+		}`,
+		"f:modules/unused/main.tf:# unused",
+		"s:stacks/direct",
+		`f:stacks/direct/main.tf:module "vpc" {
+			source = "../../modules/vpc"
+		}`,
+		"s:stacks/transitive",
+		`f:stacks/transitive/main.tf:module "network" {
+			source = "../../modules/network"
+		}`,
+		"s:stacks/remote",
+		`f:stacks/remote/main.tf:module "vpc" {
+			source = "` + vpcSource + `?ref=v2.0.0"
+		}`,
+		"s:stacks/registry",
+		`f:stacks/registry/main.tf:module "consul" {
+			source  = "hashicorp/consul/aws"
+			version = "0.1.0"
+		}`,
+		"s:stacks/none",
+		`f:stacks/none/main.tf:module "unused" {
+			source = "../../modules/unused"
+		}`,
+	})
+
+	type testcase struct {
+		source string
+		want   []string
+	}
+
+	for _, tc := range []testcase{
+		{
+			source: "/modules/vpc",
+			want:   []string{"/stacks/direct", "/stacks/transitive"},
+		},
+		{
+			source: "/modules/network",
+			want:   []string{"/stacks/transitive"},
+		},
+		{
+			source: "/modules/not-used",
+			want:   []string{},
+		},
+		{
+			source: vpcSource,
+			want: []string{
+				"/stacks/direct",
+				"/stacks/remote",
+				"/stacks/transitive",
+			},
+		},
+		{
+			source: vpcSource + "?ref=v1.0.0",
+			want:   []string{"/stacks/direct", "/stacks/transitive"},
+		},
+		{
+			source: vpcSource + "?ref=v2.0.0",
+			want:   []string{"/stacks/remote"},
+		},
+		{
+			source: vpcSource + "?ref=v3.0.0",
+			want:   []string{},
+		},
+		{
+			source: "hashicorp/consul/aws",
+			want:   []string{"/stacks/registry"},
+		},
+	} {
+		tc := tc
+		t.Run(tc.source, func(t *testing.T) {
+			m := stack.NewManager(s.Config(), defaultBranch)
+			got, err := m.StacksUsingModule(tc.source)
+			assert.NoError(t, err)
+			assertStacks(t, tc.want, got, true)
+		})
+	}
+}
+
+func TestStacksUsingModuleInvalidSource(t *testing.T) {
+	s := sandbox.NoGit(t)
+	s.BuildTree([]string{"s:stack"})
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+	for _, source := range []string{"", "./modules/vpc", "../modules/vpc"} {
+		_, err := m.StacksUsingModule(source)
+		assert.Error(t, err, "source %q", source)
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/mineiros-io/terramate/git"
+	"github.com/mineiros-io/terramate/project"
+	"github.com/mineiros-io/terramate/tf"
+	"github.com/rs/zerolog/log"
+)
+
+// detectModuleVersionChanges relabels the directly changed stacks of stackSet
+// whose changed Terraform files changed the version constraint of a remote
+// module between the git base ref and the head ref. It returns the relabeled
+// entries, sorted by stack path.
+//
+// Only the kind and reason of the entries change: a stack is already flagged
+// as changed by the change of its Terraform file, so no stack is added or
+// removed from stackSet. Stacks changed in other ways (eg.: triggers or
+// local modules) are kept as they are.
+//
+// Each checked file is read and parsed at both refs, so the cost grows with
+// the number of changed Terraform files of directly changed stacks. The files
+// are compared as committed, so untracked and uncommitted changes (see
+// localFiles) are not checked. Files which don't exist on any of the refs or
+// can't be parsed are kept as regular changes.
+func (m *Manager) detectModuleVersionChanges(
+	g *git.Git,
+	stackSet map[project.Path]Entry,
+	changedFilesOf map[project.Path][]string,
+	localFiles map[string]struct{},
+) []Entry {
+	logger := log.With().
+		Str("action", "Manager.detectModuleVersionChanges()").
+		Logger()
+
+	if g == nil {
+		return nil
+	}
+
+	var updated []Entry
+	for dir, entry := range stackSet {
+		if entry.Kind != ChangeKindDirect {
+			continue
+		}
+
+		for _, file := range changedFilesOf[dir] {
+			if _, isLocal := localFiles[file]; isLocal || !tf.IsTerraformFile(file) {
+				continue
+			}
+
+			change, ok := m.moduleVersionChange(g, file)
+			if !ok {
+				continue
+			}
+
+			logger.Debug().
+				Stringer("stack", dir).
+				Str("file", file).
+				Str("module", change.Name).
+				Msg("Module version changed.")
+
+			entry.Reason = fmt.Sprintf(
+				"stack changed the version of module %q (%s) from %q to %q in %s",
+				change.Name, change.Source, change.OldVersion, change.NewVersion,
+				project.NewPath("/"+file))
+			entry.Kind = ChangeKindModule
+			stackSet[dir] = entry
+			updated = append(updated, entry)
+			break
+		}
+	}
+
+	sort.Sort(EntrySlice(updated))
+	return updated
+}
+
+// moduleVersionChange returns the first module version change of the
+// Terraform file, relative to the project root, between the git base ref and
+// the head ref.
+func (m *Manager) moduleVersionChange(g *git.Git, file string) (tf.ModuleVersionChange, bool) {
+	logger := log.With().
+		Str("action", "Manager.moduleVersionChange()").
+		Str("file", file).
+		Logger()
+
+	parseAt := func(rev string) ([]tf.Module, bool) {
+		content, err := g.ShowFile(rev, file)
+		if err != nil {
+			logger.Debug().
+				Err(err).
+				Str("rev", rev).
+				Msg("Failed to read file.")
+			return nil, false
+		}
+		modules, err := tf.ParseModulesContent(file, []byte(content))
+		if err != nil {
+			logger.Debug().
+				Err(err).
+				Str("rev", rev).
+				Msg("Failed to parse modules.")
+			return nil, false
+		}
+		return modules, true
+	}
+
+	oldModules, ok := parseAt(m.gitBaseRef)
+	if !ok {
+		return tf.ModuleVersionChange{}, false
+	}
+	newModules, ok := parseAt(m.headRef())
+	if !ok {
+		return tf.ModuleVersionChange{}, false
+	}
+
+	changes := tf.ModuleVersionChanges(oldModules, newModules)
+	if len(changes) == 0 {
+		return tf.ModuleVersionChange{}, false
+	}
+	return changes[0], true
+}
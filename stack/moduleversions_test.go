@@ -0,0 +1,88 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/madlambda/spells/assert"
+	"github.com/mineiros-io/terramate/stack"
+	"github.com/mineiros-io/terramate/test/sandbox"
+)
+
+func TestListChangedModuleVersionChanges(t *testing.T) {
+	const vpcModule = `module "vpc" {
+		source  = "terraform-aws-modules/vpc/aws"
+		version = "%s"
+	}`
+
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stack-version",
+		"s:stack-other-change",
+		"s:stack-local-module",
+		"f:stack-version/main.tf:" + fmt.Sprintf(vpcModule, "~> 3.0"),
+		"f:stack-other-change/main.tf:" + fmt.Sprintf(vpcModule, "~> 3.0"),
+		"f:stack-local-module/main.tf:module \"mod\" {\nsource = \"../modules/a\"\n}",
+		"f:modules/a/main.tf:# module a",
+		"f:modules/b/main.tf:# module b",
+	})
+
+	git := s.Git()
+	git.CommitAll("first commit")
+	git.Push("main")
+	git.CheckoutNew("upgrade-modules")
+
+	s.BuildTree([]string{
+		"f:stack-version/main.tf:" + fmt.Sprintf(vpcModule, "~> 4.0"),
+		"f:stack-other-change/main.tf:" + fmt.Sprintf(vpcModule, "~> 3.0") + "\n# changed",
+		"f:stack-local-module/main.tf:module \"mod\" {\nsource = \"../modules/b\"\n}",
+	})
+	git.CommitAll("upgrade modules")
+
+	byDir := func(report *stack.Report) map[string]stack.Entry {
+		entries := map[string]stack.Entry{}
+		for _, entry := range report.Stacks {
+			entries[entry.Stack.Dir.String()] = entry
+		}
+		return entries
+	}
+
+	wantStacks := []string{"/stack-local-module", "/stack-other-change", "/stack-version"}
+
+	// disabled by default
+	report, err := stack.NewManager(s.Config(), defaultBranch).ListChanged()
+	assert.NoError(t, err)
+	assertStacks(t, wantStacks, report.Stacks, true)
+	for dir, entry := range byDir(report) {
+		assert.EqualStrings(t, string(stack.ChangeKindDirect), string(entry.Kind), "stack %s", dir)
+	}
+
+	m := stack.NewManagerWithOptions(s.Config(), defaultBranch, stack.ManagerOptions{
+		ModuleVersionChanges: true,
+	})
+	report, err = m.ListChanged()
+	assert.NoError(t, err)
+	assertStacks(t, wantStacks, report.Stacks, true)
+
+	entries := byDir(report)
+	assert.EqualStrings(t, string(stack.ChangeKindModule), string(entries["/stack-version"].Kind))
+	assert.EqualStrings(t,
+		`stack changed the version of module "vpc" (terraform-aws-modules/vpc/aws) from "~> 3.0" to "~> 4.0" in /stack-version/main.tf`,
+		entries["/stack-version"].Reason)
+	assert.EqualStrings(t, string(stack.ChangeKindDirect), string(entries["/stack-other-change"].Kind))
+	assert.EqualStrings(t, string(stack.ChangeKindDirect), string(entries["/stack-local-module"].Kind))
+}
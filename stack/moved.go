@@ -0,0 +1,126 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/git"
+	"github.com/mineiros-io/terramate/project"
+	"github.com/rs/zerolog/log"
+)
+
+// detectMovedStacks updates the directly changed stacks of stackSet which
+// were moved from another directory since the git base ref.
+//
+// A stack is considered moved when git detects its files as renamed from a
+// single other directory, which is not a stack anymore but was a stack on
+// the git base ref. If any of the old and new stacks have an id, the ids
+// must be the same. Stacks whose old configuration can't be parsed are
+// reported as regular changes.
+func (m *Manager) detectMovedStacks(g *git.Git, stackSet map[project.Path]Entry) error {
+	logger := log.With().
+		Str("action", "Manager.detectMovedStacks()").
+		Logger()
+
+	files, err := g.DiffNamesWithStatus(m.gitBaseRef, m.headRef())
+	if err != nil {
+		return errors.E(err, "listing renamed files")
+	}
+
+	for dir, entry := range stackSet {
+		if entry.Kind != ChangeKindDirect {
+			continue
+		}
+
+		olddir, ok := movedFrom(dir, files)
+		if !ok {
+			continue
+		}
+
+		if tree, found := m.root.Lookup(olddir); found && tree.IsStack() {
+			continue
+		}
+
+		logger.Debug().
+			Stringer("stack", dir).
+			Stringer("from", olddir).
+			Msg("Check if stack was moved.")
+
+		oldcfg, err := m.parseConfigAt(g, m.gitBaseRef, olddir)
+		if err != nil {
+			// the old configuration may depend on files that don't exist
+			// anymore, so it is reported as a regular change.
+			logger.Debug().
+				Err(err).
+				Stringer("stack", dir).
+				Msg("Failed to parse old stack configuration.")
+			continue
+		}
+
+		if oldcfg.Stack == nil || oldcfg.Stack.ID != entry.Stack.ID {
+			continue
+		}
+
+		stackSet[dir] = Entry{
+			Stack:     entry.Stack,
+			Reason:    fmt.Sprintf("stack moved from %s to %s", olddir, dir),
+			Kind:      ChangeKindMoved,
+			MovedFrom: olddir,
+		}
+	}
+	return nil
+}
+
+// movedFrom returns the directory where the files of the stack dir were
+// renamed from. It returns false if no files were renamed into the stack
+// or if they were renamed from multiple directories.
+func movedFrom(dir project.Path, files []git.FileChange) (project.Path, bool) {
+	var olddir project.Path
+	found := false
+	for _, file := range files {
+		if file.Kind != git.FileRenamed {
+			continue
+		}
+
+		newpath := project.NewPath("/" + file.Path)
+		if !newpath.HasPrefix(dir.String() + "/") {
+			continue
+		}
+
+		suffix := strings.TrimPrefix(newpath.String(), dir.String())
+		oldpath := "/" + file.OldPath
+		if !strings.HasSuffix(oldpath, suffix) {
+			return project.Path{}, false
+		}
+
+		oldprefix := strings.TrimSuffix(oldpath, suffix)
+		if oldprefix == "" {
+			return project.Path{}, false
+		}
+		candidate := project.NewPath(oldprefix)
+		if found && candidate != olddir {
+			return project.Path{}, false
+		}
+		olddir = candidate
+		found = true
+	}
+	if !found || olddir == dir {
+		return project.Path{}, false
+	}
+	return olddir, true
+}
@@ -0,0 +1,189 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/project"
+	"github.com/rs/zerolog/log"
+)
+
+// ListChangedSinceNotes lists the stacks that have changed since their last
+// deployment, where deployments are recorded as git notes in the given notes
+// namespace (eg.: "deploy" for refs/notes/deploy).
+//
+// Each note is attached to the deployed commit and lists the project paths
+// (eg.: /stacks/vpc) of the stacks deployed from that commit, one per line.
+// Empty lines and lines starting with # are ignored.
+//
+// The diff base of each stack is the most recent commit reachable from the
+// manager head ref (HEAD by default) whose note lists the stack. Stacks never
+// deployed (no note found) are compared against the manager git base ref,
+// like [Manager.ListChanged].
+//
+// The checks, changed files and root configuration changes of the report are
+// merged from the comparisons against all the diff bases. If a trace file is
+// configured, it is written once with the decisions about the stacks compared
+// against each diff base.
+func (m *Manager) ListChangedSinceNotes(namespace string) (report *Report, err error) {
+	logger := log.With().
+		Str("action", "ListChangedSinceNotes()").
+		Str("namespace", namespace).
+		Logger()
+
+	allstacks, err := List(m.root.Tree())
+	if err != nil {
+		return nil, errors.E(errListChanged, "searching for stacks", err)
+	}
+
+	logger.Trace().Msg("Lookup deployed commits.")
+
+	deployed, err := m.deployedCommits(namespace, allstacks)
+	if err != nil {
+		return nil, errors.E(errListChanged, err)
+	}
+
+	stacksByBase := map[string]map[project.Path]struct{}{}
+	for _, entry := range allstacks {
+		base, ok := deployed[entry.Stack.Dir]
+		if !ok {
+			base = m.gitBaseRef
+		}
+		if _, ok := stacksByBase[base]; !ok {
+			stacksByBase[base] = map[project.Path]struct{}{}
+		}
+		stacksByBase[base][entry.Stack.Dir] = struct{}{}
+	}
+
+	bases := make([]string, 0, len(stacksByBase))
+	for base := range stacksByBase {
+		bases = append(bases, base)
+	}
+	sort.Strings(bases)
+
+	tracer := &changeTracer{}
+	if m.opts.TraceFile != "" {
+		if err := tracer.open(m.opts.TraceFile); err != nil {
+			return nil, errors.E(errListChanged, err)
+		}
+		defer func() {
+			if closeErr := tracer.close(); closeErr != nil && err == nil {
+				report, err = nil, errors.E(errListChanged, closeErr)
+			}
+		}()
+	}
+
+	var (
+		merged       Report
+		changedFiles []string
+		rootChanges  []string
+		uncommitted  []string
+		untracked    []string
+	)
+	for _, base := range bases {
+		stacks := stacksByBase[base]
+
+		logger.Debug().
+			Str("base", base).
+			Msg("List changed stacks.")
+
+		// the maximum number of stacks applies to the final report, since
+		// each base report has stacks compared against other bases. The
+		// trace is written once for all bases.
+		baseManager := m.clone()
+		baseManager.gitBaseRef = base
+		baseManager.gitHeadRef = m.headRef()
+		baseManager.opts.MaxStacks = 0
+		baseManager.opts.TraceFile = ""
+
+		tracer.restrict(stacks)
+		baseReport, err := baseManager.listChangedTraced(project.NewPath("/"), tracer)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range baseReport.Stacks {
+			if _, ok := stacks[entry.Stack.Dir]; ok {
+				merged.Stacks = append(merged.Stacks, entry)
+			}
+		}
+		changedFiles = append(changedFiles, baseReport.ChangedFiles...)
+		rootChanges = append(rootChanges, baseReport.RootConfigChanges...)
+		uncommitted = append(uncommitted, baseReport.Checks.UncommittedFiles...)
+		untracked = append(untracked, baseReport.Checks.UntrackedFiles...)
+	}
+
+	sort.Sort(EntrySlice(merged.Stacks))
+	merged.ChangedFiles = uniqSortedStrings(changedFiles)
+	merged.RootConfigChanges = uniqSortedStrings(rootChanges)
+	merged.Checks = RepoChecks{
+		UncommittedFiles: uniqSortedStrings(uncommitted),
+		UntrackedFiles:   uniqSortedStrings(untracked),
+	}
+	report = &merged
+
+	if err := m.checkMaxStacks(len(report.Stacks)); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// deployedCommits returns the last deployed commit of each stack recorded in
+// the notes namespace. Stacks never deployed are absent from the result.
+func (m *Manager) deployedCommits(namespace string, stacks []Entry) (map[project.Path]string, error) {
+	notesRef := namespace
+	if !strings.HasPrefix(notesRef, "refs/") {
+		notesRef = path.Join("refs/notes", namespace)
+	}
+
+	g, err := m.newGit(m.root.HostDir(), "GIT_NOTES_REF="+notesRef)
+	if err != nil {
+		return nil, err
+	}
+
+	notes, err := g.LogNotes(m.headRef())
+	if err != nil {
+		return nil, errors.E(err, "listing notes")
+	}
+
+	pending := map[project.Path]struct{}{}
+	for _, entry := range stacks {
+		pending[entry.Stack.Dir] = struct{}{}
+	}
+
+	deployed := map[project.Path]string{}
+	for _, note := range notes {
+		if len(pending) == 0 {
+			break
+		}
+
+		for _, line := range strings.Split(note.Note, "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") || !path.IsAbs(line) {
+				continue
+			}
+			stackdir := project.NewPath(line)
+			if _, ok := pending[stackdir]; ok {
+				deployed[stackdir] = note.CommitID
+				delete(pending, stackdir)
+			}
+		}
+	}
+	return deployed, nil
+}
@@ -0,0 +1,163 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/mineiros-io/terramate/config"
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// StateFilename is the name of the Terraform state file read by the
+// tm_stack_outputs function on each stack directory.
+const StateFilename = "terraform.tfstate"
+
+// ErrStackOutputs indicates that the outputs of stacks could not be read.
+const ErrStackOutputs errors.Kind = "reading stack outputs"
+
+// tagQueryPrefix is the prefix of tm_stack_outputs queries selecting stacks
+// by tag instead of by path.
+const tagQueryPrefix = "tag:"
+
+// StackOutputsFunc returns the tm_stack_outputs function, which returns an
+// output of all stacks matching a query, keyed by stack path.
+//
+// The query is either a glob pattern (see [path.Match]) matched against the
+// stack paths (eg.: "/stacks/*") or a tag prefixed with "tag:"
+// (eg.: "tag:network"). The current stack is never matched, so a stack can't
+// depend on its own outputs.
+//
+// The outputs are read from the local Terraform state file of each stack
+// (see [StateFilename]). Matched stacks without a state file or without the
+// output are not included in the result, so the result is an empty object
+// if no stack was deployed yet.
+func StackOutputsFunc(root *config.Root, current *config.Stack) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{
+				Name: "query",
+				Type: cty.String,
+			},
+			{
+				Name: "output",
+				Type: cty.String,
+			},
+		},
+		Type: function.StaticReturnType(cty.DynamicPseudoType),
+		Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+			return stackOutputs(root, current, args[0].AsString(), args[1].AsString())
+		},
+	})
+}
+
+func stackOutputs(root *config.Root, current *config.Stack, query, output string) (cty.Value, error) {
+	logger := log.With().
+		Str("action", "stack.stackOutputs()").
+		Str("query", query).
+		Str("output", output).
+		Logger()
+
+	if _, err := path.Match(query, "/"); err != nil {
+		return cty.NilVal, errors.E(ErrStackOutputs, err, "invalid query %q", query)
+	}
+
+	stacks, err := List(root.Tree())
+	if err != nil {
+		return cty.NilVal, errors.E(ErrStackOutputs, err)
+	}
+
+	outputs := map[string]cty.Value{}
+	for _, entry := range stacks {
+		st := entry.Stack
+		if st.Dir == current.Dir || !matchStackQuery(st, query) {
+			continue
+		}
+
+		val, found, err := readStackOutput(st.HostDir(root), output)
+		if err != nil {
+			return cty.NilVal, errors.E(ErrStackOutputs, err, "stack %s", st.Dir)
+		}
+		if !found {
+			logger.Debug().
+				Stringer("stack", st.Dir).
+				Msg("stack has no state or output, ignoring")
+			continue
+		}
+		outputs[st.Dir.String()] = val
+	}
+	return cty.ObjectVal(outputs), nil
+}
+
+func matchStackQuery(st *config.Stack, query string) bool {
+	if strings.HasPrefix(query, tagQueryPrefix) {
+		tag := strings.TrimPrefix(query, tagQueryPrefix)
+		for _, stackTag := range st.Tags {
+			if stackTag == tag {
+				return true
+			}
+		}
+		return false
+	}
+	matched, _ := path.Match(query, st.Dir.String())
+	return matched
+}
+
+// readStackOutput reads the output from the state file of the stack located
+// at the dir host directory. It returns found=false if the state file or
+// the output doesn't exist.
+func readStackOutput(dir, output string) (val cty.Value, found bool, err error) {
+	statefile := filepath.Join(dir, StateFilename)
+	data, err := os.ReadFile(statefile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return cty.NilVal, false, nil
+		}
+		return cty.NilVal, false, errors.E(err, "reading state file")
+	}
+
+	var state struct {
+		Outputs map[string]struct {
+			Value json.RawMessage `json:"value"`
+			Type  json.RawMessage `json:"type"`
+		} `json:"outputs"`
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return cty.NilVal, false, errors.E(err, "parsing state file %s", statefile)
+	}
+
+	out, ok := state.Outputs[output]
+	if !ok {
+		return cty.NilVal, false, nil
+	}
+
+	typ, err := ctyjson.UnmarshalType(out.Type)
+	if err != nil {
+		return cty.NilVal, false, errors.E(err, "parsing type of output %q", output)
+	}
+	val, err = ctyjson.Unmarshal(out.Value, typ)
+	if err != nil {
+		return cty.NilVal, false, errors.E(err, "parsing value of output %q", output)
+	}
+	return val, true, nil
+}
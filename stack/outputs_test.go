@@ -0,0 +1,103 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/madlambda/spells/assert"
+	"github.com/mineiros-io/terramate/hcl/eval"
+	"github.com/mineiros-io/terramate/project"
+	"github.com/mineiros-io/terramate/stack"
+	"github.com/mineiros-io/terramate/test"
+	"github.com/mineiros-io/terramate/test/sandbox"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestStackOutputsFunc(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		`s:stacks/a:tags=["net"]`,
+		`s:stacks/b`,
+		`s:stacks/c:tags=["net"]`,
+		`s:stacks/d`,
+		`s:app:tags=["net"]`,
+		`f:stacks/a/terraform.tfstate:` + stateWithOutputs(`"vpc_id": {"value": "vpc-a", "type": "string"}`),
+		`f:stacks/b/terraform.tfstate:` + stateWithOutputs(`"vpc_id": {"value": "vpc-b", "type": "string"}`),
+		`f:stacks/c/terraform.tfstate:` + stateWithOutputs(`"other": {"value": 1, "type": "number"}`),
+		`f:app/terraform.tfstate:` + stateWithOutputs(`"vpc_id": {"value": "vpc-app", "type": "string"}`),
+	})
+
+	root := s.Config()
+	evalStack := func(dir, expr string) (cty.Value, error) {
+		st := s.LoadStack(project.NewPath(dir))
+		evalctx := stack.NewEvalCtx(root, st, eval.NewObject(eval.Info{}))
+		return evalctx.Eval(test.NewExpr(t, expr))
+	}
+
+	// stacks/c lacks the output and stacks/d has no state, so both are
+	// not included.
+	got, err := evalStack("/app", `tm_stack_outputs("/stacks/*", "vpc_id")`)
+	assert.NoError(t, err)
+	assertCtyEquals(t, cty.ObjectVal(map[string]cty.Value{
+		"/stacks/a": cty.StringVal("vpc-a"),
+		"/stacks/b": cty.StringVal("vpc-b"),
+	}), got)
+
+	// the current stack is never included.
+	got, err = evalStack("/app", `tm_stack_outputs("tag:net", "vpc_id")`)
+	assert.NoError(t, err)
+	assertCtyEquals(t, cty.ObjectVal(map[string]cty.Value{
+		"/stacks/a": cty.StringVal("vpc-a"),
+	}), got)
+
+	got, err = evalStack("/stacks/a", `tm_stack_outputs("/stacks/*", "vpc_id")`)
+	assert.NoError(t, err)
+	assertCtyEquals(t, cty.ObjectVal(map[string]cty.Value{
+		"/stacks/b": cty.StringVal("vpc-b"),
+	}), got)
+
+	got, err = evalStack("/app", `tm_stack_outputs("/none/*", "vpc_id")`)
+	assert.NoError(t, err)
+	assertCtyEquals(t, cty.EmptyObjectVal, got)
+}
+
+func TestStackOutputsFuncFailsOnInvalidState(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		`s:stacks/a`,
+		`s:app`,
+		`f:stacks/a/terraform.tfstate:not json`,
+	})
+
+	st := s.LoadStack(project.NewPath("/app"))
+	evalctx := stack.NewEvalCtx(s.Config(), st, eval.NewObject(eval.Info{}))
+	_, err := evalctx.Eval(test.NewExpr(t, `tm_stack_outputs("/stacks/*", "vpc_id")`))
+	assert.Error(t, err)
+	assert.IsTrue(t, strings.Contains(err.Error(), "parsing state file"),
+		"unexpected error: %v", err)
+}
+
+func stateWithOutputs(outputs string) string {
+	return `{"version": 4, "outputs": {` + outputs + `}}`
+}
+
+func assertCtyEquals(t *testing.T, want, got cty.Value) {
+	t.Helper()
+	if !want.RawEquals(got) {
+		t.Fatalf("want %#v but got %#v", want, got)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"path"
+	"strings"
+
+	"github.com/mineiros-io/terramate/config"
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// ErrStackPathRel indicates that the stack path could not be made relative
+// to the given ancestor directory.
+const ErrStackPathRel errors.Kind = "computing relative stack path"
+
+// StackPathRelFunc returns the tm_stackpath_rel function, which returns the
+// path of the current stack relative to an ancestor directory, given as an
+// absolute project path (eg.: tm_stackpath_rel("/envs") returns "prod/app"
+// for the stack /envs/prod/app).
+//
+// It fails if the directory is not an ancestor of the current stack, which
+// includes the stack directory itself.
+func StackPathRelFunc(current *config.Stack) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{
+				Name: "ancestor",
+				Type: cty.String,
+			},
+		},
+		Type: function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+			rel, err := stackPathRel(current, args[0].AsString())
+			if err != nil {
+				return cty.NilVal, err
+			}
+			return cty.StringVal(rel), nil
+		},
+	})
+}
+
+func stackPathRel(current *config.Stack, ancestor string) (string, error) {
+	if !path.IsAbs(ancestor) {
+		return "", errors.E(ErrStackPathRel,
+			"ancestor %q must be an absolute project path", ancestor)
+	}
+
+	ancestor = path.Clean(ancestor)
+	stackdir := current.Dir.String()
+
+	prefix := ancestor
+	if prefix != "/" {
+		prefix += "/"
+	}
+	if stackdir == ancestor || !strings.HasPrefix(stackdir, prefix) {
+		return "", errors.E(ErrStackPathRel,
+			"%s is not an ancestor of the stack %s", ancestor, stackdir)
+	}
+	return strings.TrimPrefix(stackdir, prefix), nil
+}
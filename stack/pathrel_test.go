@@ -0,0 +1,76 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/madlambda/spells/assert"
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/hcl/eval"
+	"github.com/mineiros-io/terramate/project"
+	"github.com/mineiros-io/terramate/stack"
+	"github.com/mineiros-io/terramate/test"
+	"github.com/mineiros-io/terramate/test/sandbox"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestStackPathRelFunc(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:envs/prod/app",
+		"s:envs-other",
+	})
+
+	root := s.Config()
+	evalStack := func(dir, expr string) (cty.Value, error) {
+		st := s.LoadStack(project.NewPath(dir))
+		evalctx := stack.NewEvalCtx(root, st, eval.NewObject(eval.Info{}))
+		return evalctx.Eval(test.NewExpr(t, expr))
+	}
+
+	for expr, want := range map[string]string{
+		`tm_stackpath_rel("/envs")`:       "prod/app",
+		`tm_stackpath_rel("/envs/prod/")`: "app",
+		`tm_stackpath_rel("/")`:           "envs/prod/app",
+	} {
+		got, err := evalStack("/envs/prod/app", expr)
+		assert.NoError(t, err, expr)
+		assertCtyEquals(t, cty.StringVal(want), got)
+	}
+
+	for _, expr := range []string{
+		`tm_stackpath_rel("/envs/prod/app")`,
+		`tm_stackpath_rel("/envs/staging")`,
+		`tm_stackpath_rel("/env")`,
+		`tm_stackpath_rel("envs")`,
+	} {
+		_, err := evalStack("/envs/prod/app", expr)
+		assertStackPathRelErr(t, err)
+	}
+
+	_, err := evalStack("/envs-other", `tm_stackpath_rel("/envs")`)
+	assertStackPathRelErr(t, err)
+}
+
+func assertStackPathRelErr(t *testing.T, err error) {
+	t.Helper()
+
+	// the function errors are reported as evaluation diagnostics.
+	assert.IsError(t, err, errors.E(eval.ErrEval))
+	assert.IsTrue(t, strings.Contains(err.Error(), string(stack.ErrStackPathRel)),
+		"unexpected error: %v", err)
+}
@@ -0,0 +1,390 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/mineiros-io/terramate/config"
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/project"
+)
+
+const (
+	// ReportLineStack is the type of a JSON Lines report line describing
+	// a single stack entry.
+	ReportLineStack = "stack"
+
+	// ReportLineChecks is the type of the last JSON Lines report line which
+	// describes the repository checks.
+	ReportLineChecks = "checks"
+)
+
+type (
+	// ReportStackLine is the JSON schema of a stack line of the JSON Lines report.
+	ReportStackLine struct {
+		Type        string   `json:"type"`
+		Path        string   `json:"path"`
+		ID          string   `json:"id,omitempty"`
+		Name        string   `json:"name"`
+		Description string   `json:"description,omitempty"`
+		Tags        []string `json:"tags"`
+		Reason      string   `json:"reason,omitempty"`
+		MovedFrom   string   `json:"moved_from,omitempty"`
+	}
+
+	// ReportChecksLine is the JSON schema of the checks line of the JSON Lines
+	// report. It is always the last line of the report.
+	ReportChecksLine struct {
+		Type             string   `json:"type"`
+		UncommittedFiles []string `json:"uncommitted_files"`
+		UntrackedFiles   []string `json:"untracked_files"`
+	}
+)
+
+// ChangeKind is the kind of change that caused a stack to be reported as
+// changed.
+type ChangeKind string
+
+const (
+	// ChangeKindDirect means files inside the stack changed.
+	ChangeKindDirect ChangeKind = "direct"
+
+	// ChangeKindModule means a local module used by the stack changed or, if
+	// ManagerOptions.ModuleVersionChanges is set, the stack changed the
+	// version constraint of a remote module.
+	ChangeKindModule ChangeKind = "module"
+
+	// ChangeKindTrigger means the stack was triggered by a trigger file.
+	ChangeKindTrigger ChangeKind = "trigger"
+
+	// ChangeKindWatch means a file watched by the stack changed.
+	ChangeKindWatch ChangeKind = "watch"
+
+	// ChangeKindMoved means the stack directory was moved, keeping its id.
+	ChangeKindMoved ChangeKind = "moved"
+
+	// ChangeKindVarFile means a Terraform variables file used by the stack
+	// (see stack.var_files) changed.
+	ChangeKindVarFile ChangeKind = "var_file"
+)
+
+// ReportSummary is the summary of the changed stacks of a report.
+type ReportSummary struct {
+	Direct  int
+	Module  int
+	Trigger int
+	Watch   int
+	Moved   int
+	VarFile int
+
+	// Total is the total number of stacks in the report, including stacks
+	// with no change kind which are not counted in any of the kinds above.
+	Total int
+}
+
+// Summary returns the counts of stacks in the report by change kind.
+// Entries with no change kind (eg.: reports not created by change detection)
+// are only accounted in the total.
+func (r *Report) Summary() ReportSummary {
+	summary := ReportSummary{
+		Total: len(r.Stacks),
+	}
+	for _, entry := range r.Stacks {
+		switch entry.Kind {
+		case ChangeKindDirect:
+			summary.Direct++
+		case ChangeKindModule:
+			summary.Module++
+		case ChangeKindTrigger:
+			summary.Trigger++
+		case ChangeKindWatch:
+			summary.Watch++
+		case ChangeKindMoved:
+			summary.Moved++
+		case ChangeKindVarFile:
+			summary.VarFile++
+		}
+	}
+	return summary
+}
+
+// SortField is a field used to sort the stacks of a report.
+type SortField string
+
+const (
+	// SortByPath sorts the stacks by their directory.
+	SortByPath SortField = "path"
+
+	// SortByID sorts the stacks by their id. Stacks without id come first.
+	SortByID SortField = "id"
+
+	// SortByName sorts the stacks by their name.
+	SortByName SortField = "name"
+
+	// SortByKind sorts the stacks by their change kind, in the order the
+	// ChangeKind constants are declared. Stacks without change kind come last.
+	SortByKind SortField = "kind"
+)
+
+// SortCriteria defines how the stacks of a report are sorted.
+type SortCriteria struct {
+	Field      SortField
+	Descending bool
+}
+
+// ErrInvalidSortField indicates an unknown sort field.
+const ErrInvalidSortField errors.Kind = "invalid sort field"
+
+var changeKindOrder = map[ChangeKind]int{
+	ChangeKindDirect:  0,
+	ChangeKindModule:  1,
+	ChangeKindTrigger: 2,
+	ChangeKindWatch:   3,
+	ChangeKindMoved:   4,
+	ChangeKindVarFile: 5,
+}
+
+// SortBy sorts the report stacks using the criteria. Stacks with the same
+// value for the criteria field are sorted by path, ascending, regardless of
+// the criteria order. Reports are sorted by path ascending by default.
+func (r *Report) SortBy(criteria SortCriteria) error {
+	var cmpField func(a, b Entry) int
+	switch criteria.Field {
+	case SortByPath:
+		cmpField = func(a, b Entry) int {
+			return strings.Compare(a.Stack.Dir.String(), b.Stack.Dir.String())
+		}
+	case SortByID:
+		cmpField = func(a, b Entry) int {
+			return strings.Compare(a.Stack.ID, b.Stack.ID)
+		}
+	case SortByName:
+		cmpField = func(a, b Entry) int {
+			return strings.Compare(a.Stack.Name, b.Stack.Name)
+		}
+	case SortByKind:
+		cmpField = func(a, b Entry) int {
+			return changeKindRank(a.Kind) - changeKindRank(b.Kind)
+		}
+	default:
+		return errors.E(ErrInvalidSortField, "unknown sort field %q", criteria.Field)
+	}
+
+	sort.SliceStable(r.Stacks, func(i, j int) bool {
+		a, b := r.Stacks[i], r.Stacks[j]
+		c := cmpField(a, b)
+		if criteria.Descending {
+			c = -c
+		}
+		if c != 0 {
+			return c < 0
+		}
+		return a.Stack.Dir.String() < b.Stack.Dir.String()
+	})
+	return nil
+}
+
+func changeKindRank(kind ChangeKind) int {
+	if rank, ok := changeKindOrder[kind]; ok {
+		return rank
+	}
+	return len(changeKindOrder)
+}
+
+const errWriteReport errors.Kind = "writing report error"
+
+// WriteReportJSONL writes the report into w using the JSON Lines format.
+// Each stack entry of the report is written as a single JSON object line
+// (see [ReportStackLine]) in the same order as in the report, followed by a
+// final line (see [ReportChecksLine]) with the repository checks. The "type"
+// field of each line tells which kind of line it is.
+func WriteReportJSONL(w io.Writer, report *Report) error {
+	enc := json.NewEncoder(w)
+	for _, entry := range report.Stacks {
+		st := entry.Stack
+		line := ReportStackLine{
+			Type:        ReportLineStack,
+			Path:        st.Dir.String(),
+			ID:          st.ID,
+			Name:        st.Name,
+			Description: st.Description,
+			Tags:        nonNilStrings(st.Tags),
+			Reason:      entry.Reason,
+		}
+		if entry.Kind == ChangeKindMoved {
+			line.MovedFrom = entry.MovedFrom.String()
+		}
+		if err := enc.Encode(line); err != nil {
+			return errors.E(errWriteReport, err, "encoding stack %s", st.Dir)
+		}
+	}
+
+	checks := ReportChecksLine{
+		Type:             ReportLineChecks,
+		UncommittedFiles: nonNilStrings(report.Checks.UncommittedFiles),
+		UntrackedFiles:   nonNilStrings(report.Checks.UntrackedFiles),
+	}
+	if err := enc.Encode(checks); err != nil {
+		return errors.E(errWriteReport, err, "encoding checks")
+	}
+	return nil
+}
+
+// nonNilStrings makes sure empty lists are encoded as [] instead of null.
+func nonNilStrings(strs []string) []string {
+	if strs == nil {
+		return []string{}
+	}
+	return strs
+}
+
+// reportFormatVersion is the version of the format written by
+// [Report.Encode].
+const reportFormatVersion = 1
+
+const errDecodeReport errors.Kind = "decoding report error"
+
+type (
+	// encodedReport is the JSON schema of an encoded report.
+	encodedReport struct {
+		Version           int            `json:"version"`
+		Stacks            []encodedEntry `json:"stacks"`
+		Checks            encodedChecks  `json:"checks"`
+		RootConfigChanges []string       `json:"root_config_changes"`
+		ChangedFiles      []string       `json:"changed_files,omitempty"`
+	}
+
+	encodedChecks struct {
+		UncommittedFiles []string `json:"uncommitted_files"`
+		UntrackedFiles   []string `json:"untracked_files"`
+	}
+
+	encodedEntry struct {
+		Path      string     `json:"path"`
+		ID        string     `json:"id,omitempty"`
+		Reason    string     `json:"reason,omitempty"`
+		Kind      ChangeKind `json:"kind,omitempty"`
+		MovedFrom string     `json:"moved_from,omitempty"`
+
+		IsChanged    bool     `json:"is_changed,omitempty"`
+		ChangedFiles []string `json:"changed_files,omitempty"`
+	}
+)
+
+// Encode writes the report into w, so it can be restored later with
+// [DecodeReport] (eg.: to list the changed stacks in a CI job and run them in
+// another one). Only the stack paths, ids and change state (see
+// config.Stack.IsChanged and config.Stack.ChangedFiles) are written, the
+// stacks are loaded again from the project when the report is decoded.
+func (r *Report) Encode(w io.Writer) error {
+	encoded := encodedReport{
+		Version: reportFormatVersion,
+		Stacks:  []encodedEntry{},
+		Checks: encodedChecks{
+			UncommittedFiles: nonNilStrings(r.Checks.UncommittedFiles),
+			UntrackedFiles:   nonNilStrings(r.Checks.UntrackedFiles),
+		},
+		RootConfigChanges: nonNilStrings(r.RootConfigChanges),
+		ChangedFiles:      r.ChangedFiles,
+	}
+	for _, entry := range r.Stacks {
+		e := encodedEntry{
+			Path:         entry.Stack.Dir.String(),
+			ID:           entry.Stack.ID,
+			Reason:       entry.Reason,
+			Kind:         entry.Kind,
+			IsChanged:    entry.Stack.IsChanged,
+			ChangedFiles: entry.Stack.ChangedFiles,
+		}
+		if entry.Kind == ChangeKindMoved {
+			e.MovedFrom = entry.MovedFrom.String()
+		}
+		encoded.Stacks = append(encoded.Stacks, e)
+	}
+	if err := json.NewEncoder(w).Encode(encoded); err != nil {
+		return errors.E(errWriteReport, err)
+	}
+	return nil
+}
+
+// DecodeReport reads a report written by [Report.Encode] from r, loading its
+// stacks from the given project root. It returns an error if any of the
+// stacks no longer exists in the project or if its id changed.
+func DecodeReport(r io.Reader, root *config.Root) (*Report, error) {
+	var encoded encodedReport
+	if err := json.NewDecoder(r).Decode(&encoded); err != nil {
+		return nil, errors.E(errDecodeReport, err)
+	}
+	if encoded.Version != reportFormatVersion {
+		return nil, errors.E(errDecodeReport,
+			"unsupported report version %d", encoded.Version)
+	}
+
+	report := &Report{
+		Checks: RepoChecks{
+			UncommittedFiles: encoded.Checks.UncommittedFiles,
+			UntrackedFiles:   encoded.Checks.UntrackedFiles,
+		},
+	}
+	if len(encoded.RootConfigChanges) > 0 {
+		report.RootConfigChanges = encoded.RootConfigChanges
+	}
+	if len(encoded.ChangedFiles) > 0 {
+		report.ChangedFiles = encoded.ChangedFiles
+	}
+	for _, e := range encoded.Stacks {
+		if !strings.HasPrefix(e.Path, "/") {
+			return nil, errors.E(errDecodeReport,
+				"stack path %q is not absolute", e.Path)
+		}
+		dir := project.NewPath(e.Path)
+		tree, found := root.Lookup(dir)
+		if !found || !tree.IsStack() {
+			return nil, errors.E(errDecodeReport,
+				"stack %s does not exist in the project", dir)
+		}
+		st, err := config.NewStackFromHCL(root.HostDir(), tree.Node)
+		if err != nil {
+			return nil, errors.E(errDecodeReport, err, "loading stack %s", dir)
+		}
+		if st.ID != e.ID {
+			return nil, errors.E(errDecodeReport,
+				"stack %s has id %q but the report has id %q", dir, st.ID, e.ID)
+		}
+		st.IsChanged = e.IsChanged
+		if len(e.ChangedFiles) > 0 {
+			st.ChangedFiles = e.ChangedFiles
+		}
+
+		entry := Entry{
+			Stack:  st,
+			Reason: e.Reason,
+			Kind:   e.Kind,
+		}
+		if e.MovedFrom != "" {
+			if !strings.HasPrefix(e.MovedFrom, "/") {
+				return nil, errors.E(errDecodeReport,
+					"stack %s moved from path %q is not absolute", dir, e.MovedFrom)
+			}
+			entry.MovedFrom = project.NewPath(e.MovedFrom)
+		}
+		report.Stacks = append(report.Stacks, entry)
+	}
+	return report, nil
+}
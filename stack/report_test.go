@@ -0,0 +1,324 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/madlambda/spells/assert"
+	"github.com/mineiros-io/terramate/config"
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/project"
+	"github.com/mineiros-io/terramate/stack"
+	"github.com/mineiros-io/terramate/test/sandbox"
+)
+
+func TestWriteReportJSONL(t *testing.T) {
+	report := &stack.Report{
+		Stacks: []stack.Entry{
+			{
+				Stack: &config.Stack{
+					Dir:  project.NewPath("/stack-a"),
+					ID:   "a",
+					Name: "stack-a",
+					Tags: []string{"tag"},
+				},
+				Reason: "stack has unmerged changes",
+			},
+			{
+				Stack: &config.Stack{
+					Dir:  project.NewPath("/stack-b"),
+					Name: "stack-b",
+				},
+			},
+		},
+		Checks: stack.RepoChecks{
+			UntrackedFiles: []string{"file.txt"},
+		},
+	}
+
+	var out bytes.Buffer
+	assert.NoError(t, stack.WriteReportJSONL(&out, report))
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	assert.EqualInts(t, 3, len(lines), "unexpected number of lines: %s", out.String())
+
+	wantStacks := []stack.ReportStackLine{
+		{
+			Type:   stack.ReportLineStack,
+			Path:   "/stack-a",
+			ID:     "a",
+			Name:   "stack-a",
+			Tags:   []string{"tag"},
+			Reason: "stack has unmerged changes",
+		},
+		{
+			Type: stack.ReportLineStack,
+			Path: "/stack-b",
+			Name: "stack-b",
+			Tags: []string{},
+		},
+	}
+
+	for i, want := range wantStacks {
+		var got stack.ReportStackLine
+		assert.NoError(t, json.Unmarshal([]byte(lines[i]), &got), "line %d is not valid JSON", i)
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Fatalf("line %d mismatch (-want +got):\n%s", i, diff)
+		}
+	}
+
+	var checks stack.ReportChecksLine
+	assert.NoError(t, json.Unmarshal([]byte(lines[2]), &checks), "checks line is not valid JSON")
+
+	wantChecks := stack.ReportChecksLine{
+		Type:             stack.ReportLineChecks,
+		UncommittedFiles: []string{},
+		UntrackedFiles:   []string{"file.txt"},
+	}
+	if diff := cmp.Diff(wantChecks, checks); diff != "" {
+		t.Fatalf("checks line mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestReportSummary(t *testing.T) {
+	entry := func(dir string, kind stack.ChangeKind) stack.Entry {
+		return stack.Entry{
+			Stack: &config.Stack{Dir: project.NewPath(dir)},
+			Kind:  kind,
+		}
+	}
+
+	report := &stack.Report{
+		Stacks: []stack.Entry{
+			entry("/a", stack.ChangeKindDirect),
+			entry("/b", stack.ChangeKindDirect),
+			entry("/c", stack.ChangeKindModule),
+			entry("/d", stack.ChangeKindTrigger),
+			entry("/e", stack.ChangeKindWatch),
+			entry("/f", stack.ChangeKindMoved),
+			entry("/g", stack.ChangeKindVarFile),
+			entry("/h", ""),
+		},
+	}
+
+	want := stack.ReportSummary{
+		Direct:  2,
+		Module:  1,
+		Trigger: 1,
+		Watch:   1,
+		Moved:   1,
+		VarFile: 1,
+		Total:   8,
+	}
+	if diff := cmp.Diff(want, report.Summary()); diff != "" {
+		t.Fatalf("summary mismatch (-want +got):\n%s", diff)
+	}
+
+	unstructured := &stack.Report{
+		Stacks: []stack.Entry{
+			entry("/a", ""),
+			entry("/b", ""),
+		},
+	}
+	if diff := cmp.Diff(stack.ReportSummary{Total: 2}, unstructured.Summary()); diff != "" {
+		t.Fatalf("summary mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestReportSortBy(t *testing.T) {
+	newEntry := func(dir, name string, kind stack.ChangeKind) stack.Entry {
+		return stack.Entry{
+			Stack: &config.Stack{
+				Dir:  project.NewPath(dir),
+				Name: name,
+			},
+			Kind: kind,
+		}
+	}
+	newReport := func() *stack.Report {
+		return &stack.Report{
+			Stacks: []stack.Entry{
+				newEntry("/a", "beta", stack.ChangeKindWatch),
+				newEntry("/b", "alpha", stack.ChangeKindDirect),
+				newEntry("/c", "gamma", stack.ChangeKindModule),
+				newEntry("/d", "alpha", stack.ChangeKindDirect),
+				newEntry("/e", "delta", ""),
+			},
+		}
+	}
+	paths := func(report *stack.Report) []string {
+		var paths []string
+		for _, entry := range report.Stacks {
+			paths = append(paths, entry.Stack.Dir.String())
+		}
+		return paths
+	}
+
+	for _, tc := range []struct {
+		name     string
+		criteria stack.SortCriteria
+		want     []string
+	}{
+		{
+			name:     "path descending",
+			criteria: stack.SortCriteria{Field: stack.SortByPath, Descending: true},
+			want:     []string{"/e", "/d", "/c", "/b", "/a"},
+		},
+		{
+			name:     "name descending",
+			criteria: stack.SortCriteria{Field: stack.SortByName, Descending: true},
+			want:     []string{"/c", "/e", "/a", "/b", "/d"},
+		},
+		{
+			name:     "change kind",
+			criteria: stack.SortCriteria{Field: stack.SortByKind},
+			want:     []string{"/b", "/d", "/c", "/a", "/e"},
+		},
+		{
+			name:     "change kind descending",
+			criteria: stack.SortCriteria{Field: stack.SortByKind, Descending: true},
+			want:     []string{"/e", "/a", "/c", "/b", "/d"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			report := newReport()
+			assert.NoError(t, report.SortBy(tc.criteria))
+			if diff := cmp.Diff(tc.want, paths(report)); diff != "" {
+				t.Fatalf("unexpected order (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	err := newReport().SortBy(stack.SortCriteria{Field: "unknown"})
+	assert.IsError(t, err, errors.E(stack.ErrInvalidSortField))
+}
+
+func TestReportEncodeDecode(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		`s:stacks/a:id=a`,
+		`s:stacks/b`,
+		`s:stacks/c`,
+		"f:stacks/a/main.tf:# a",
+		"f:stacks/b/main.tf:# b",
+		"f:stacks/c/main.tf:# c",
+	})
+
+	git := s.Git()
+	git.CommitAll("first commit")
+	git.Push("main")
+	git.CheckoutNew("change-stacks")
+
+	s.RootEntry().CreateFile("stacks/a/main.tf", "# a changed")
+	s.RootEntry().CreateFile("stacks/b/main.tf", "# b changed")
+	git.CommitAll("change stacks")
+	s.RootEntry().CreateFile("untracked.txt", "untracked")
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+	report, err := m.ListChanged()
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/stacks/a", "/stacks/b"}, report.Stacks, true)
+
+	var encoded bytes.Buffer
+	assert.NoError(t, report.Encode(&encoded))
+
+	got, err := stack.DecodeReport(&encoded, s.Config())
+	assert.NoError(t, err)
+
+	type entry struct {
+		Path, ID, Name, Reason string
+		Kind                   stack.ChangeKind
+		IsChanged              bool
+		ChangedFiles           []string
+	}
+	entries := func(report *stack.Report) []entry {
+		var res []entry
+		for _, e := range report.Stacks {
+			res = append(res, entry{
+				Path:         e.Stack.Dir.String(),
+				ID:           e.Stack.ID,
+				Name:         e.Stack.Name,
+				Reason:       e.Reason,
+				Kind:         e.Kind,
+				IsChanged:    e.Stack.IsChanged,
+				ChangedFiles: e.Stack.ChangedFiles,
+			})
+		}
+		return res
+	}
+	for _, e := range got.Stacks {
+		assert.IsTrue(t, e.Stack.IsChanged, "stack %s must be changed", e.Stack.Dir)
+	}
+	assert.EqualStrings(t, "stacks/a/main.tf",
+		strings.Join(got.Stacks[0].Stack.ChangedFiles, ","))
+	if diff := cmp.Diff(entries(report), entries(got)); diff != "" {
+		t.Fatalf("-(want) +(got):\n%s", diff)
+	}
+	if diff := cmp.Diff(report.Checks, got.Checks); diff != "" {
+		t.Fatalf("-(want) +(got):\n%s", diff)
+	}
+	if diff := cmp.Diff(report.RootConfigChanges, got.RootConfigChanges); diff != "" {
+		t.Fatalf("-(want) +(got):\n%s", diff)
+	}
+	if diff := cmp.Diff(report.ChangedFiles, got.ChangedFiles); diff != "" {
+		t.Fatalf("-(want) +(got):\n%s", diff)
+	}
+	assert.EqualStrings(t, "untracked.txt", strings.Join(got.Checks.UntrackedFiles, ","))
+}
+
+func TestDecodeReportFailsIfStackDoesNotExist(t *testing.T) {
+	s := sandbox.NoGit(t)
+	s.BuildTree([]string{
+		`s:stacks/a:id=a`,
+		`s:stacks/b`,
+	})
+
+	report := &stack.Report{
+		Stacks: []stack.Entry{
+			{Stack: s.LoadStack(project.NewPath("/stacks/a")), Reason: "changed"},
+			{Stack: s.LoadStack(project.NewPath("/stacks/b")), Reason: "changed"},
+		},
+	}
+
+	var encoded bytes.Buffer
+	assert.NoError(t, report.Encode(&encoded))
+
+	s.RootEntry().RemoveFile("stacks/b/stack.tm.hcl")
+	root, err := config.LoadRoot(s.RootDir())
+	assert.NoError(t, err)
+
+	_, err = stack.DecodeReport(bytes.NewReader(encoded.Bytes()), root)
+	assert.Error(t, err)
+}
+
+func TestDecodeReportFailsOnInvalidInput(t *testing.T) {
+	s := sandbox.NoGit(t)
+	s.BuildTree([]string{`s:stack`})
+
+	for _, input := range []string{
+		``,
+		`{"version": 2, "stacks": []}`,
+		`{"version": 1, "stacks": [{"path": "stack"}]}`,
+		`{"version": 1, "stacks": [{"path": "/stack", "id": "other"}]}`,
+	} {
+		_, err := stack.DecodeReport(strings.NewReader(input), s.Config())
+		assert.Error(t, err, "input: %s", input)
+	}
+}
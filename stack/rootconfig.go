@@ -0,0 +1,174 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"bytes"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/fs"
+	"github.com/mineiros-io/terramate/git"
+	"github.com/mineiros-io/terramate/hcl"
+	"github.com/mineiros-io/terramate/hcl/ast"
+	"github.com/mineiros-io/terramate/project"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// RootConfigGitDefaultBranch is the root config change reported when
+	// the terramate.config.git.default_branch attribute changes.
+	RootConfigGitDefaultBranch = "terramate.config.git.default_branch"
+
+	// RootConfigRunEnv is the prefix of the root config changes reported
+	// when variables of the terramate.config.run.env block change.
+	// The variable name is appended to it (eg.: terramate.config.run.env.NAME).
+	RootConfigRunEnv = "terramate.config.run.env"
+)
+
+// rootConfigChanges returns the root configuration attributes that changed
+// between the git base ref and the current configuration, or the
+// configuration of the git head ref if it's not HEAD. Only attributes that
+// can affect every stack of the project are considered: the git default branch
+// and the run environment. The changedFiles must be relative to the project
+// root.
+func (m *Manager) rootConfigChanges(g *git.Git, changedFiles []string) ([]string, error) {
+	logger := log.With().
+		Str("action", "Manager.rootConfigChanges()").
+		Logger()
+
+	if !hasRootConfigFile(changedFiles) {
+		return nil, nil
+	}
+
+	logger.Debug().Msg("Root configuration files changed, comparing with base ref.")
+
+	old, err := m.parseRootConfigAt(g, m.gitBaseRef)
+	if err != nil {
+		return nil, errors.E(err, "parsing root configuration at %s", m.gitBaseRef)
+	}
+
+	cur := m.root.Tree().Node
+	if head := m.headRef(); head != "HEAD" {
+		cur, err = m.parseRootConfigAt(g, head)
+		if err != nil {
+			return nil, errors.E(err, "parsing root configuration at %s", head)
+		}
+	}
+
+	var changes []string
+	if rootGitDefaultBranch(old) != rootGitDefaultBranch(cur) {
+		changes = append(changes, RootConfigGitDefaultBranch)
+	}
+
+	oldEnv := rootRunEnv(old)
+	curEnv := rootRunEnv(cur)
+	for name, attr := range curEnv {
+		oldAttr, ok := oldEnv[name]
+		if !ok || !sameExpr(oldAttr, attr) {
+			changes = append(changes, RootConfigRunEnv+"."+name)
+		}
+	}
+	for name := range oldEnv {
+		if _, ok := curEnv[name]; !ok {
+			changes = append(changes, RootConfigRunEnv+"."+name)
+		}
+	}
+
+	sort.Strings(changes)
+	return changes, nil
+}
+
+// parseRootConfigAt parses the Terramate files of the project root directory
+// as they are in the rev commit.
+func (m *Manager) parseRootConfigAt(g *git.Git, rev string) (hcl.Config, error) {
+	return m.parseConfigAt(g, rev, project.NewPath("/"))
+}
+
+// parseConfigAt parses the Terramate files of the dir directory as they are
+// in the rev commit. Files of subdirectories are not parsed and imports are
+// resolved using the working tree, so the result is only accurate for simple
+// configurations (eg.: detecting stack blocks).
+func (m *Manager) parseConfigAt(g *git.Git, rev string, dir project.Path) (hcl.Config, error) {
+	rootdir := m.root.HostDir()
+	cfgdir := dir.HostPath(rootdir)
+
+	treeish := rev
+	if dir.String() != "/" {
+		treeish = rev + ":./" + dir.String()[1:]
+	}
+
+	names, err := g.ListTree(treeish)
+	if err != nil {
+		return hcl.Config{}, err
+	}
+
+	// the dir may not exist anymore in the working tree, so the parser is
+	// created on the root directory.
+	p, err := hcl.NewTerramateParser(rootdir, rootdir)
+	if err != nil {
+		return hcl.Config{}, err
+	}
+
+	for _, name := range names {
+		if !fs.IsTerramateFile(name) {
+			continue
+		}
+		content, err := g.ShowFile(rev, path.Join(dir.String()[1:], name))
+		if err != nil {
+			return hcl.Config{}, err
+		}
+		err = p.AddFileContent(filepath.Join(cfgdir, name), []byte(content))
+		if err != nil {
+			return hcl.Config{}, err
+		}
+	}
+	return p.ParseConfig()
+}
+
+func hasRootConfigFile(files []string) bool {
+	for _, file := range files {
+		if !strings.Contains(file, "/") && fs.IsTerramateFile(file) {
+			return true
+		}
+	}
+	return false
+}
+
+func rootGitDefaultBranch(cfg hcl.Config) string {
+	if cfg.Terramate == nil ||
+		cfg.Terramate.Config == nil ||
+		cfg.Terramate.Config.Git == nil {
+		return ""
+	}
+	return cfg.Terramate.Config.Git.DefaultBranch
+}
+
+func rootRunEnv(cfg hcl.Config) ast.Attributes {
+	if !cfg.HasRunEnv() {
+		return nil
+	}
+	return cfg.Terramate.Config.Run.Env.Attributes
+}
+
+func sameExpr(a, b ast.Attribute) bool {
+	return bytes.Equal(
+		ast.TokensForExpression(a.Expr).Bytes(),
+		ast.TokensForExpression(b.Expr).Bytes(),
+	)
+}
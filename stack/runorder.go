@@ -0,0 +1,62 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"github.com/mineiros-io/terramate/config"
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/run"
+	"github.com/mineiros-io/terramate/run/dag"
+)
+
+// RunOrder returns the given stacks sorted in their execution order, which
+// respects the before/after attributes of the stacks and runs parent stacks
+// before their child stacks. In the case of multiple possible orders, the
+// lexicographic order of the stack paths is used. Stacks referenced by the
+// before/after attributes but not given are not returned.
+//
+// If the order has a cycle, an error of kind [dag.ErrCycleDetected] describing
+// the cycle is returned.
+func (m *Manager) RunOrder(stacks config.List[*config.SortableStack]) ([]*config.Stack, error) {
+	ordered, reason, err := run.Sort(m.root, stacks)
+	if err != nil {
+		if errors.IsKind(err, dag.ErrCycleDetected) {
+			return nil, errors.E(dag.ErrCycleDetected, err, "cycle detected on run order: %s", reason)
+		}
+		return nil, errors.E(err, "computing run order")
+	}
+
+	result := make([]*config.Stack, len(ordered))
+	for i, elem := range ordered {
+		result[i] = elem.Stack
+	}
+	return result, nil
+}
+
+// ReverseRunOrder works like [Manager.RunOrder] but returns the stacks in the
+// reverse execution order, which is the order to destroy them: a stack which
+// runs after another stack is destroyed before it and child stacks are
+// destroyed before their parent stacks. The stacks wanted by the given stacks
+// are not added, use [Manager.AddWantedOf] to select them first.
+func (m *Manager) ReverseRunOrder(stacks config.List[*config.SortableStack]) ([]*config.Stack, error) {
+	ordered, err := m.RunOrder(stacks)
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(ordered)-1; i < j; i, j = i+1, j-1 {
+		ordered[i], ordered[j] = ordered[j], ordered[i]
+	}
+	return ordered, nil
+}
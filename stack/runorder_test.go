@@ -0,0 +1,147 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/madlambda/spells/assert"
+	"github.com/mineiros-io/terramate/config"
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/run/dag"
+	"github.com/mineiros-io/terramate/stack"
+	"github.com/mineiros-io/terramate/test/sandbox"
+)
+
+func TestRunOrder(t *testing.T) {
+	type testcase struct {
+		name   string
+		layout []string
+		want   []string
+		reason string
+	}
+
+	for _, tc := range []testcase{
+		{
+			name: "lexicographic order",
+			layout: []string{
+				`s:b`,
+				`s:a`,
+				`s:c`,
+			},
+			want: []string{"/a", "/b", "/c"},
+		},
+		{
+			name: "before and after",
+			layout: []string{
+				`s:a:after=["/b"]`,
+				`s:b:after=["/c"]`,
+				`s:c`,
+				`s:d:before=["/c"]`,
+			},
+			want: []string{"/d", "/c", "/b", "/a"},
+		},
+		{
+			name: "parent stacks run first",
+			layout: []string{
+				`s:parent:after=["/z"]`,
+				`s:parent/child`,
+				`s:z`,
+			},
+			want: []string{"/z", "/parent", "/parent/child"},
+		},
+		{
+			name: "cycle",
+			layout: []string{
+				`s:a:after=["/b"]`,
+				`s:b:after=["/a"]`,
+			},
+			reason: "/a -> /b -> /a (/a after /b, /b after /a)",
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			s := sandbox.NoGit(t)
+			s.BuildTree(tc.layout)
+			root := s.Config()
+
+			stacks, err := config.LoadAllStacks(root.Tree())
+			assert.NoError(t, err)
+
+			m := stack.NewManager(root, defaultBranch)
+			got, err := m.RunOrder(stacks)
+			if tc.reason != "" {
+				assert.IsError(t, err, errors.E(dag.ErrCycleDetected))
+				assert.IsTrue(t, strings.Contains(err.Error(), tc.reason),
+					"error %q does not contain the reason %q", err, tc.reason)
+
+				_, err = m.ReverseRunOrder(stacks)
+				assert.IsError(t, err, errors.E(dag.ErrCycleDetected))
+				return
+			}
+			assert.NoError(t, err)
+			assertStackDirs(t, tc.want, got)
+
+			reversed, err := m.ReverseRunOrder(stacks)
+			assert.NoError(t, err)
+
+			wantReversed := make([]string, len(tc.want))
+			for i, dir := range tc.want {
+				wantReversed[len(tc.want)-1-i] = dir
+			}
+			assertStackDirs(t, wantReversed, reversed)
+		})
+	}
+}
+
+func TestRunOrderOnlySelectedStacks(t *testing.T) {
+	s := sandbox.NoGit(t)
+	s.BuildTree([]string{
+		`s:a:after=["/b"]`,
+		`s:b:after=["/c"]`,
+		`s:c`,
+	})
+	root := s.Config()
+
+	stacks, err := config.LoadAllStacks(root.Tree())
+	assert.NoError(t, err)
+
+	var selected config.List[*config.SortableStack]
+	for _, elem := range stacks {
+		if elem.Dir().String() != "/b" {
+			selected = append(selected, elem)
+		}
+	}
+
+	got, err := stack.NewManager(root, defaultBranch).RunOrder(selected)
+	assert.NoError(t, err)
+	assert.EqualInts(t, 2, len(got))
+	assert.EqualStrings(t, "/c", got[0].Dir.String())
+	assert.EqualStrings(t, "/a", got[1].Dir.String())
+}
+
+func assertStackDirs(t *testing.T, want []string, got []*config.Stack) {
+	t.Helper()
+
+	gotDirs := make([]string, len(got))
+	for i, st := range got {
+		gotDirs[i] = st.Dir.String()
+	}
+	if diff := cmp.Diff(want, gotDirs); diff != "" {
+		t.Fatalf("-(want) +(got):\n%s", diff)
+	}
+}
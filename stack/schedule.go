@@ -0,0 +1,71 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"github.com/mineiros-io/terramate/config"
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/project"
+	"github.com/mineiros-io/terramate/run"
+	"github.com/rs/zerolog/log"
+)
+
+// ErrSchedule indicates that the stacks could not be scheduled.
+const ErrSchedule errors.Kind = "scheduling stacks"
+
+// Schedule returns the stacks inside the scope directory split in waves.
+// The stacks of each wave can run in parallel once all stacks of the
+// previous waves finished, respecting the before/after ordering of the
+// stacks and their concurrency groups: at most one stack of each concurrency
+// group is scheduled per wave, so stacks of the same group ready to run at
+// the same time are spread over consecutive waves, in lexicographic order.
+// See [run.Waves] for details.
+func (m *Manager) Schedule(scope project.Path) ([][]project.Path, error) {
+	logger := log.With().
+		Str("action", "Manager.Schedule()").
+		Stringer("scope", scope).
+		Logger()
+
+	scopeTree, found := m.root.Lookup(scope)
+	if !found {
+		return nil, errors.E(ErrSchedule,
+			"scope directory %s not found in the project", scope)
+	}
+
+	stacks, err := config.LoadAllStacks(scopeTree)
+	if err != nil {
+		return nil, errors.E(ErrSchedule, err)
+	}
+
+	logger.Trace().Msg("Compute waves.")
+
+	waves, reason, err := run.Waves(m.root, stacks)
+	if err != nil {
+		if reason != "" {
+			return nil, errors.E(ErrSchedule, err, "cycle: %s", reason)
+		}
+		return nil, errors.E(ErrSchedule, err)
+	}
+
+	schedule := make([][]project.Path, 0, len(waves))
+	for _, wave := range waves {
+		paths := make([]project.Path, 0, len(wave))
+		for _, elem := range wave {
+			paths = append(paths, elem.Dir())
+		}
+		schedule = append(schedule, paths)
+	}
+	return schedule, nil
+}
@@ -0,0 +1,163 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/madlambda/spells/assert"
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/project"
+	"github.com/mineiros-io/terramate/run/dag"
+	"github.com/mineiros-io/terramate/stack"
+	"github.com/mineiros-io/terramate/test/sandbox"
+)
+
+func TestManagerSchedule(t *testing.T) {
+	type testcase struct {
+		name   string
+		layout []string
+		scope  string
+		want   [][]string
+	}
+
+	for _, tc := range []testcase{
+		{
+			name: "no stacks",
+			layout: []string{
+				"d:empty",
+			},
+			scope: "/",
+			want:  [][]string{},
+		},
+		{
+			name: "independent stacks run in a single wave",
+			layout: []string{
+				"s:a",
+				"s:b",
+				"s:c",
+			},
+			scope: "/",
+			want:  [][]string{{"/a", "/b", "/c"}},
+		},
+		{
+			name: "parent stacks run in waves before their children",
+			layout: []string{
+				"s:parent",
+				"s:parent/child",
+				"s:other",
+			},
+			scope: "/",
+			want: [][]string{
+				{"/other", "/parent"},
+				{"/parent/child"},
+			},
+		},
+		{
+			name: "shared concurrency group splits waves",
+			layout: []string{
+				`s:infra/network:concurrency_group=aws`,
+				`s:infra/dns:concurrency_group=aws`,
+				`s:app/web:concurrency_group=aws`,
+				`s:app/db:after=["/infra/network"]`,
+				`s:app/api:after=["/app/db"];concurrency_group=aws`,
+				`s:monitoring`,
+			},
+			scope: "/",
+			want: [][]string{
+				{"/app/web", "/monitoring"},
+				{"/infra/dns"},
+				{"/infra/network"},
+				{"/app/db"},
+				{"/app/api"},
+			},
+		},
+		{
+			name: "order through stacks out of scope is kept",
+			layout: []string{
+				`s:app/a:before=["/other"]`,
+				`s:app/b`,
+				`s:app/c`,
+				`s:other:before=["/app/b"]`,
+			},
+			scope: "/app",
+			want: [][]string{
+				{"/app/a", "/app/c"},
+				{"/app/b"},
+			},
+		},
+		{
+			name: "stacks out of scope are not scheduled",
+			layout: []string{
+				`s:infra/network:concurrency_group=aws`,
+				`s:infra/dns:concurrency_group=aws`,
+				`s:app/web:concurrency_group=aws`,
+			},
+			scope: "/infra",
+			want: [][]string{
+				{"/infra/dns"},
+				{"/infra/network"},
+			},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			s := sandbox.NoGit(t)
+			s.BuildTree(tc.layout)
+
+			m := stack.NewManager(s.Config(), defaultBranch)
+			got, err := m.Schedule(project.NewPath(tc.scope))
+			assert.NoError(t, err)
+
+			want := make([][]project.Path, 0, len(tc.want))
+			for _, wave := range tc.want {
+				paths := []project.Path{}
+				for _, p := range wave {
+					paths = append(paths, project.NewPath(p))
+				}
+				want = append(want, paths)
+			}
+
+			if diff := cmp.Diff(want, got, cmp.AllowUnexported(project.Path{})); diff != "" {
+				t.Fatalf("-(want) +(got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestManagerScheduleFailures(t *testing.T) {
+	t.Run("cycle", func(t *testing.T) {
+		s := sandbox.NoGit(t)
+		s.BuildTree([]string{
+			`s:a:after=["/b"]`,
+			`s:b:after=["/a"]`,
+		})
+
+		m := stack.NewManager(s.Config(), defaultBranch)
+		_, err := m.Schedule(project.NewPath("/"))
+		assert.IsError(t, err, errors.E(stack.ErrSchedule))
+		assert.IsError(t, err, errors.E(dag.ErrCycleDetected))
+	})
+
+	t.Run("scope not found", func(t *testing.T) {
+		s := sandbox.NoGit(t)
+		s.BuildTree([]string{"s:a"})
+
+		m := stack.NewManager(s.Config(), defaultBranch)
+		_, err := m.Schedule(project.NewPath("/not-found"))
+		assert.IsError(t, err, errors.E(stack.ErrSchedule))
+	})
+}
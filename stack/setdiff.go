@@ -0,0 +1,127 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/fs"
+	"github.com/mineiros-io/terramate/git"
+	"github.com/mineiros-io/terramate/project"
+	"github.com/rs/zerolog/log"
+)
+
+const errStackSetDiff errors.Kind = "comparing stack sets error"
+
+// StackSetDiff compares the stacks of the refA and refB git refs, which is
+// useful to review the structural changes of a branch. The stacks of each ref
+// are read from the git tree of the ref, so the working tree is ignored.
+// The added stacks exist only at refB and the removed stacks exist only at
+// refA. A moved stack is reported as removed from its old directory and
+// added to the new one. Both lists are sorted.
+func (m *Manager) StackSetDiff(refA, refB string) (added, removed []project.Path, err error) {
+	g, err := m.newGit(m.root.HostDir())
+	if err != nil {
+		return nil, nil, errors.E(errStackSetDiff, err)
+	}
+
+	stacksA, err := m.stacksAtRef(g, refA)
+	if err != nil {
+		return nil, nil, errors.E(errStackSetDiff, err, "listing stacks at %s", refA)
+	}
+	stacksB, err := m.stacksAtRef(g, refB)
+	if err != nil {
+		return nil, nil, errors.E(errStackSetDiff, err, "listing stacks at %s", refB)
+	}
+
+	added = []project.Path{}
+	for dir := range stacksB {
+		if _, ok := stacksA[dir]; !ok {
+			added = append(added, dir)
+		}
+	}
+	removed = []project.Path{}
+	for dir := range stacksA {
+		if _, ok := stacksB[dir]; !ok {
+			removed = append(removed, dir)
+		}
+	}
+
+	sortPaths := func(paths []project.Path) {
+		sort.Slice(paths, func(i, j int) bool {
+			return paths[i].String() < paths[j].String()
+		})
+	}
+	sortPaths(added)
+	sortPaths(removed)
+	return added, removed, nil
+}
+
+// stacksAtRef returns the stack directories of the ref tree, which are the
+// directories whose Terramate files, at the ref, define a stack block.
+// Hidden directories are ignored, the same way as when loading the project.
+func (m *Manager) stacksAtRef(g *git.Git, ref string) (map[project.Path]struct{}, error) {
+	logger := log.With().
+		Str("action", "Manager.stacksAtRef()").
+		Str("ref", ref).
+		Logger()
+
+	files, err := g.ListFilesAtRef(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	cfgdirs := map[project.Path]struct{}{}
+	for _, file := range files {
+		if !fs.IsTerramateFile(path.Base(file)) || hasHiddenDir(file) {
+			continue
+		}
+		cfgdirs[project.NewPath("/"+path.Dir(file))] = struct{}{}
+	}
+
+	stacks := map[project.Path]struct{}{}
+	for dir := range cfgdirs {
+		logger.Trace().
+			Stringer("dir", dir).
+			Msg("Parse configuration at ref.")
+
+		cfg, err := m.parseConfigAt(g, ref, dir)
+		if err != nil {
+			return nil, errors.E(err, "parsing configuration of %s", dir)
+		}
+		if cfg.Stack != nil {
+			stacks[dir] = struct{}{}
+		}
+	}
+	return stacks, nil
+}
+
+// hasHiddenDir tells if any parent directory of the file, relative to the
+// project root, is hidden (starts with a dot).
+func hasHiddenDir(file string) bool {
+	dir := path.Dir(file)
+	if dir == "." {
+		return false
+	}
+	for _, name := range strings.Split(dir, "/") {
+		if strings.HasPrefix(name, ".") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/madlambda/spells/assert"
+	"github.com/mineiros-io/terramate/project"
+	"github.com/mineiros-io/terramate/stack"
+	"github.com/mineiros-io/terramate/test/sandbox"
+)
+
+func TestStackSetDiff(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stacks/kept",
+		"s:stacks/removed",
+		"s:stacks/moved",
+		`f:stacks/globals.tm:globals {
+			a = 1
+		}`,
+	})
+
+	git := s.Git()
+	git.CommitAll("first commit")
+	git.Push("main")
+	git.CheckoutNew("change-stacks")
+
+	assert.NoError(t, os.RemoveAll(filepath.Join(s.RootDir(), "stacks", "removed")))
+	assert.NoError(t, os.Rename(
+		filepath.Join(s.RootDir(), "stacks", "moved"),
+		filepath.Join(s.RootDir(), "stacks", "renamed"),
+	))
+	s.BuildTree([]string{
+		"s:stacks/added",
+		"s:stacks/kept/child",
+		`f:dir/globals.tm:globals {
+			a = 1
+		}`,
+		"s:.hidden/stack",
+	})
+	git.CommitAll("change stacks")
+
+	// the working tree is not used.
+	s.BuildTree([]string{"s:stacks/uncommitted"})
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+
+	paths := func(dirs ...string) []project.Path {
+		res := []project.Path{}
+		for _, dir := range dirs {
+			res = append(res, project.NewPath(dir))
+		}
+		return res
+	}
+
+	assertDiff := func(refA, refB string, wantAdded, wantRemoved []project.Path) {
+		t.Helper()
+		added, removed, err := m.StackSetDiff(refA, refB)
+		assert.NoError(t, err)
+		if diff := cmp.Diff(wantAdded, added, cmp.AllowUnexported(project.Path{})); diff != "" {
+			t.Fatalf("added: -(want) +(got):\n%s", diff)
+		}
+		if diff := cmp.Diff(wantRemoved, removed, cmp.AllowUnexported(project.Path{})); diff != "" {
+			t.Fatalf("removed: -(want) +(got):\n%s", diff)
+		}
+	}
+
+	assertDiff("main", "HEAD",
+		paths("/stacks/added", "/stacks/kept/child", "/stacks/renamed"),
+		paths("/stacks/moved", "/stacks/removed"),
+	)
+	assertDiff("HEAD", "main",
+		paths("/stacks/moved", "/stacks/removed"),
+		paths("/stacks/added", "/stacks/kept/child", "/stacks/renamed"),
+	)
+	assertDiff("HEAD", "HEAD", paths(), paths())
+
+	_, _, err := m.StackSetDiff("main", "non-existent")
+	assert.Error(t, err)
+}
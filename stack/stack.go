@@ -19,14 +19,29 @@ import "github.com/mineiros-io/terramate/config"
 // List loads from the config all terramate stacks.
 // It returns a lexicographic sorted list of stack directories.
 func List(cfg *config.Tree) ([]Entry, error) {
-	stacks, err := config.LoadAllStacks(cfg)
+	var entries []Entry
+	err := ListWith(cfg, func(entry Entry) error {
+		entries = append(entries, entry)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-
-	entries := make([]Entry, len(stacks))
-	for i, elem := range stacks {
-		entries[i] = Entry{Stack: elem.Stack}
+	if entries == nil {
+		entries = []Entry{}
 	}
 	return entries, nil
 }
+
+// ListWith works like [List] but calls fn for each stack as soon as it is
+// found while walking the config, in the same lexicographic order, instead of
+// returning all stacks at once. The walk stops at the first error returned by
+// fn, which is returned as is, so fn can abort the listing early.
+//
+// Stacks with duplicated ids are detected when the second stack is found, so
+// fn may be called for some stacks before the error is returned.
+func ListWith(cfg *config.Tree, fn func(Entry) error) error {
+	return config.WalkStacks(cfg, func(st *config.Stack) error {
+		return fn(Entry{Stack: st})
+	})
+}
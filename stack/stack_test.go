@@ -17,9 +17,11 @@ package stack_test
 import (
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/madlambda/spells/assert"
 	"github.com/mineiros-io/terramate/config"
 	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/stack"
 	"github.com/mineiros-io/terramate/test/sandbox"
 	"github.com/rs/zerolog"
 )
@@ -36,6 +38,79 @@ func TestLoadAllFailsIfStacksIDIsNotUnique(t *testing.T) {
 	assert.IsError(t, err, errors.E(config.ErrStackDuplicatedID))
 }
 
+func TestListWith(t *testing.T) {
+	s := sandbox.NoGit(t)
+	s.BuildTree([]string{
+		"s:a",
+		"s:a/child",
+		"s:a-b",
+		"s:a.b/child",
+		"s:b",
+		"s:b/c/d",
+		"d:empty",
+	})
+
+	var streamed []string
+	err := stack.ListWith(s.Config().Tree(), func(entry stack.Entry) error {
+		streamed = append(streamed, entry.Stack.Dir.String())
+		return nil
+	})
+	assert.NoError(t, err)
+
+	want := []string{"/a", "/a-b", "/a.b/child", "/a/child", "/b", "/b/c/d"}
+	if diff := cmp.Diff(want, streamed); diff != "" {
+		t.Fatalf("-(want) +(got):\n%s", diff)
+	}
+
+	var listed []string
+	entries, err := stack.List(s.Config().Tree())
+	assert.NoError(t, err)
+	for _, entry := range entries {
+		listed = append(listed, entry.Stack.Dir.String())
+	}
+	if diff := cmp.Diff(want, listed); diff != "" {
+		t.Fatalf("-(want) +(got):\n%s", diff)
+	}
+}
+
+func TestListWithAbortsOnCallbackError(t *testing.T) {
+	s := sandbox.NoGit(t)
+	s.BuildTree([]string{
+		"s:stack-1",
+		"s:stack-2",
+		"s:stack-3",
+	})
+
+	const errAbort errors.Kind = "abort"
+
+	m := stack.NewManager(s.Config(), "origin/main")
+	var streamed []string
+	err := m.ListWith(func(entry stack.Entry) error {
+		streamed = append(streamed, entry.Stack.Dir.String())
+		if len(streamed) == 2 {
+			return errors.E(errAbort)
+		}
+		return nil
+	})
+	assert.IsError(t, err, errors.E(errAbort))
+	if diff := cmp.Diff([]string{"/stack-1", "/stack-2"}, streamed); diff != "" {
+		t.Fatalf("-(want) +(got):\n%s", diff)
+	}
+}
+
+func TestListWithFailsIfStacksIDIsNotUnique(t *testing.T) {
+	s := sandbox.NoGit(t)
+	s.BuildTree([]string{
+		"s:stacks/stack-1:id=id",
+		"s:stacks/stack-2:id=id",
+	})
+
+	err := stack.ListWith(s.Config().Tree(), func(stack.Entry) error {
+		return nil
+	})
+	assert.IsError(t, err, errors.E(config.ErrStackDuplicatedID))
+}
+
 func init() {
 	zerolog.SetGlobalLevel(zerolog.Disabled)
 }
@@ -0,0 +1,99 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/mineiros-io/terramate/config"
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// StateReader reports if stacks have Terraform state.
+type StateReader interface {
+	// HasState returns true if the stack has a non-empty Terraform state,
+	// which means it was applied at least once.
+	HasState(st *config.Stack) (bool, error)
+}
+
+// LocalStateReader is a StateReader for stacks using the Terraform local
+// backend, which reads the state file of the stack directory
+// (see [StateFilename]).
+type LocalStateReader struct {
+	root *config.Root
+}
+
+// NewLocalStateReader creates a LocalStateReader for the stacks of root.
+func NewLocalStateReader(root *config.Root) *LocalStateReader {
+	return &LocalStateReader{root: root}
+}
+
+// HasState returns true if the stack state file exists and has resources
+// or outputs.
+func (r *LocalStateReader) HasState(st *config.Stack) (bool, error) {
+	statefile := filepath.Join(st.HostDir(r.root), StateFilename)
+	data, err := os.ReadFile(statefile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, errors.E(err, "reading state file")
+	}
+
+	var state struct {
+		Resources []json.RawMessage          `json:"resources"`
+		Outputs   map[string]json.RawMessage `json:"outputs"`
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return false, errors.E(err, "parsing state file %s", statefile)
+	}
+	return len(state.Resources) > 0 || len(state.Outputs) > 0, nil
+}
+
+// StacksWithState classifies all stacks of the project as applied, if the
+// reader reports that they have Terraform state, or unapplied otherwise.
+// Both lists are sorted by stack path.
+func (m *Manager) StacksWithState(reader StateReader) (applied, unapplied []Entry, err error) {
+	logger := log.With().
+		Str("action", "Manager.StacksWithState()").
+		Logger()
+
+	entries, err := List(m.root.Tree())
+	if err != nil {
+		return nil, nil, errors.E(errList, err)
+	}
+
+	for _, entry := range entries {
+		hasState, err := reader.HasState(entry.Stack)
+		if err != nil {
+			return nil, nil, errors.E(errList, err, "checking state of stack %s", entry.Stack.Dir)
+		}
+
+		logger.Trace().
+			Stringer("stack", entry.Stack.Dir).
+			Bool("hasState", hasState).
+			Msg("Checked stack state.")
+
+		if hasState {
+			applied = append(applied, entry)
+		} else {
+			unapplied = append(unapplied, entry)
+		}
+	}
+	return applied, unapplied, nil
+}
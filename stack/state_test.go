@@ -0,0 +1,70 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack_test
+
+import (
+	"testing"
+
+	"github.com/madlambda/spells/assert"
+	"github.com/mineiros-io/terramate/config"
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/stack"
+	"github.com/mineiros-io/terramate/test/sandbox"
+)
+
+type fakeStateReader struct {
+	applied map[string]bool
+	err     error
+}
+
+func (r fakeStateReader) HasState(st *config.Stack) (bool, error) {
+	return r.applied[st.Dir.String()], r.err
+}
+
+func TestStacksWithState(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stack-a",
+		"s:stack-b",
+	})
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+	applied, unapplied, err := m.StacksWithState(fakeStateReader{
+		applied: map[string]bool{"/stack-b": true},
+	})
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/stack-b"}, applied, false)
+	assertStacks(t, []string{"/stack-a"}, unapplied, false)
+
+	_, _, err = m.StacksWithState(fakeStateReader{err: errors.E("backend unavailable")})
+	assert.Error(t, err)
+}
+
+func TestLocalStateReader(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:no-state",
+		"s:empty-state",
+		"s:applied",
+		`f:empty-state/terraform.tfstate:{"version": 4, "resources": []}`,
+		`f:applied/terraform.tfstate:{"version": 4, "resources": [{"type": "null_resource"}]}`,
+	})
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+	applied, unapplied, err := m.StacksWithState(stack.NewLocalStateReader(s.Config()))
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/applied"}, applied, false)
+	assertStacks(t, []string{"/empty-state", "/no-state"}, unapplied, false)
+}
@@ -0,0 +1,153 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/project"
+)
+
+// Decisions of the change detection trace entries.
+const (
+	// TraceChanged means the stack of the entry was marked as changed.
+	TraceChanged = "changed"
+
+	// TraceIgnored means the file of the entry didn't change any stack.
+	TraceIgnored = "ignored"
+)
+
+// ErrTrace indicates a failure writing the change detection trace.
+const ErrTrace errors.Kind = "writing change detection trace"
+
+// TraceEntry is an attribution decision of the change detection, written as
+// a single JSON object per line (JSONL) to the trace file configured with
+// ManagerOptions.TraceFile. Example:
+//
+//	{"file":"stacks/a/main.tf","stack":"/stacks/a","decision":"changed","kind":"direct","reason":"stack has unmerged changes"}
+//	{"file":".github/ci.yml","decision":"ignored","reason":"file starts with ."}
+type TraceEntry struct {
+	// File is the changed file considered, relative to the project root.
+	// It is empty for decisions which are not caused by a single file
+	// (eg.: moved stacks).
+	File string `json:"file,omitempty"`
+
+	// Stack is the stack matched by the file, if any.
+	Stack string `json:"stack,omitempty"`
+
+	// Decision is either TraceChanged or TraceIgnored.
+	Decision string `json:"decision"`
+
+	// Kind is the kind of change, if the stack was marked as changed.
+	Kind ChangeKind `json:"kind,omitempty"`
+
+	// Reason describes why the decision was taken.
+	Reason string `json:"reason"`
+}
+
+// changeTracer keeps the ignored decisions of the change detection and, if
+// opened, writes all the trace entries to a file.
+type changeTracer struct {
+	file *os.File
+	buf  *bufio.Writer
+	enc  *json.Encoder
+	err  error
+
+	// ignoredEntries are the ignored decisions, in the order they were taken.
+	ignoredEntries []TraceEntry
+
+	// stacks, if not nil, restricts the written entries of stacks to these
+	// stacks (see restrict).
+	stacks map[project.Path]struct{}
+
+	// written are the entries already written, if restricted.
+	written map[TraceEntry]struct{}
+}
+
+// open creates (or truncates) the trace file.
+func (t *changeTracer) open(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return errors.E(ErrTrace, err)
+	}
+	t.file = file
+	t.buf = bufio.NewWriter(file)
+	t.enc = json.NewEncoder(t.buf)
+	return nil
+}
+
+// restrict makes the tracer only write the entries of the given stacks, and
+// the entries not related to any stack, skipping the entries already written.
+// It's used to share a single trace among change detections comparing
+// different sets of stacks.
+func (t *changeTracer) restrict(stacks map[project.Path]struct{}) {
+	t.stacks = stacks
+	if t.written == nil {
+		t.written = map[TraceEntry]struct{}{}
+	}
+}
+
+func (t *changeTracer) changed(file string, entry Entry) {
+	t.trace(TraceEntry{
+		File:     file,
+		Stack:    entry.Stack.Dir.String(),
+		Decision: TraceChanged,
+		Kind:     entry.Kind,
+		Reason:   entry.Reason,
+	})
+}
+
+func (t *changeTracer) ignored(file, stack, reason string) {
+	entry := TraceEntry{
+		File:     file,
+		Stack:    stack,
+		Decision: TraceIgnored,
+		Reason:   reason,
+	}
+	t.ignoredEntries = append(t.ignoredEntries, entry)
+	t.trace(entry)
+}
+
+func (t *changeTracer) trace(entry TraceEntry) {
+	if t.enc == nil || t.err != nil {
+		return
+	}
+	if t.written != nil {
+		if _, ok := t.stacks[project.NewPath(entry.Stack)]; entry.Stack != "" && !ok {
+			return
+		}
+		if _, ok := t.written[entry]; ok {
+			return
+		}
+		t.written[entry] = struct{}{}
+	}
+	t.err = t.enc.Encode(entry)
+}
+
+// close flushes the pending entries and closes the file, if opened. It
+// returns the first error found while writing the trace, if any.
+func (t *changeTracer) close() error {
+	if t.file == nil {
+		return nil
+	}
+	errs := errors.L(t.err, t.buf.Flush(), t.file.Close())
+	if err := errs.AsError(); err != nil {
+		return errors.E(ErrTrace, err)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/madlambda/spells/assert"
+	"github.com/mineiros-io/terramate/stack"
+	"github.com/mineiros-io/terramate/test/sandbox"
+)
+
+func TestManagerTraceFile(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stacks/a",
+		"s:stacks/b",
+		`f:stacks/b/main.tf:module "mod" {
+			source = "../../modules/mod"
+		}`,
+		"f:modules/mod/main.tf:# module",
+		"f:docs/guide.md:# guide",
+	})
+
+	git := s.Git()
+	git.CommitAll("first commit")
+	git.Push("main")
+	git.CheckoutNew("change-stacks")
+
+	s.RootEntry().CreateFile("stacks/a/main.tf", "# changed")
+	s.RootEntry().CreateFile("modules/mod/main.tf", "# changed")
+	s.RootEntry().CreateFile("docs/guide.md", "# changed")
+	s.RootEntry().CreateFile(".ci.yml", "# changed")
+	git.CommitAll("change files")
+
+	tracefile := filepath.Join(t.TempDir(), "trace.jsonl")
+	m := stack.NewManagerWithOptions(s.Config(), defaultBranch, stack.ManagerOptions{
+		TraceFile: tracefile,
+	})
+
+	report, err := m.ListChanged()
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/stacks/a", "/stacks/b"}, report.Stacks, true)
+
+	want := []stack.TraceEntry{
+		{
+			File:     ".ci.yml",
+			Decision: stack.TraceIgnored,
+			Reason:   "file starts with .",
+		},
+		{
+			File:     "docs/guide.md",
+			Decision: stack.TraceIgnored,
+			Reason:   "file does not belong to any stack",
+		},
+		{
+			File:     "modules/mod/main.tf",
+			Decision: stack.TraceIgnored,
+			Reason:   "file does not belong to any stack",
+		},
+		{
+			File:     "stacks/a/main.tf",
+			Stack:    "/stacks/a",
+			Decision: stack.TraceChanged,
+			Kind:     stack.ChangeKindDirect,
+			Reason:   "stack has unmerged changes",
+		},
+		{
+			File:     "modules/mod/main.tf",
+			Stack:    "/stacks/b",
+			Decision: stack.TraceChanged,
+			Kind:     stack.ChangeKindModule,
+			Reason:   report.Stacks[1].Reason,
+		},
+	}
+
+	if diff := cmp.Diff(want, readTrace(t, tracefile)); diff != "" {
+		t.Fatalf("unexpected trace (-want +got):\n%s", diff)
+	}
+}
+
+func readTrace(t *testing.T, path string) []stack.TraceEntry {
+	t.Helper()
+
+	f, err := os.Open(path)
+	assert.NoError(t, err)
+	defer func() {
+		assert.NoError(t, f.Close())
+	}()
+
+	entries := []stack.TraceEntry{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry stack.TraceEntry
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &entry),
+			"invalid trace line: %s", scanner.Text())
+		entries = append(entries, entry)
+	}
+	assert.NoError(t, scanner.Err())
+	return entries
+}
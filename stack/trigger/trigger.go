@@ -28,6 +28,7 @@ import (
 	"github.com/hashicorp/hcl/v2/hclparse"
 	"github.com/hashicorp/hcl/v2/hclwrite"
 	"github.com/mineiros-io/terramate/config"
+	"github.com/mineiros-io/terramate/config/filter"
 	"github.com/mineiros-io/terramate/errors"
 	"github.com/mineiros-io/terramate/hcl/ast"
 	"github.com/mineiros-io/terramate/project"
@@ -41,6 +42,9 @@ const (
 
 	// ErrParsing indicates an error happened while parsing the trigger file.
 	ErrParsing errors.Kind = "parsing trigger file"
+
+	// ErrInvalidTags indicates that the tags filter of a trigger is invalid.
+	ErrInvalidTags errors.Kind = "invalid trigger tags"
 )
 
 // Info represents the parsed contents of a trigger
@@ -54,34 +58,72 @@ type Info struct {
 	Type string
 	// Context is the context of the trigger (only `stack` at the moment)
 	Context string
+	// Tags is the tags filter selecting the triggered stacks, if any. It uses
+	// the same syntax of the --tags command line option. A trigger with tags
+	// triggers all the stacks matching the filter, independently of the
+	// trigger file location.
+	Tags string
 }
 
 const (
-	// DefaultType is the default trigger type when not specified.
+	// DefaultType is the default trigger type when not specified. It marks
+	// the triggered stacks as changed.
 	DefaultType = "changed"
 
+	// IgnoredType is the type of the triggers which mark the triggered stacks
+	// as unchanged, even if they have changed files.
+	IgnoredType = "ignored"
+
 	// DefaultContext is the default context for the trigger file when not
 	// specified.
 	DefaultContext = "stack"
 )
 
-const triggersDir = ".tmtriggers"
+// DefaultDir is the default triggers directory, relative to the project root.
+// It can be changed with the terramate.config.triggers.dir attribute.
+const DefaultDir = ".tmtriggers"
 
 // StackPath accepts a trigger file path and returns the path of the stack
 // that is triggered by the given file. If the given file is not a stack trigger
 // at all it will return false.
+//
+// Deprecated: StackPath always uses the [DefaultDir] triggers directory, use
+// [StackPathFor] to honor the triggers directory configured on the project.
 func StackPath(triggerFile project.Path) (project.Path, bool) {
-	const triggersPrefix = "/" + triggersDir
+	return stackPath(project.NewPath("/"+DefaultDir), triggerFile)
+}
+
+// StackPathFor is like [StackPath] but the triggers directory is obtained
+// from the root configuration.
+func StackPathFor(root *config.Root, triggerFile project.Path) (project.Path, bool) {
+	return stackPath(DirPath(root), triggerFile)
+}
 
-	if !triggerFile.HasPrefix(triggersPrefix) {
+func stackPath(dir project.Path, triggerFile project.Path) (project.Path, bool) {
+	triggersDir := dir.String()
+
+	if !triggerFile.HasPrefix(triggersDir + "/") {
 		return project.NewPath("/"), false
 	}
 
-	stackPath := strings.TrimPrefix(triggerFile.String(), triggersPrefix)
+	stackPath := strings.TrimPrefix(triggerFile.String(), triggersDir)
 	stackPath = path.Dir(stackPath)
 	return project.NewPath(stackPath), true
 }
 
+// DirPath returns the project path of the triggers directory. It is the
+// terramate.config.triggers.dir if configured or [DefaultDir] otherwise.
+func DirPath(root *config.Root) project.Path {
+	cfg := root.Tree().Node
+	if cfg.Terramate != nil &&
+		cfg.Terramate.Config != nil &&
+		cfg.Terramate.Config.Triggers != nil &&
+		cfg.Terramate.Config.Triggers.Dir != "" {
+		return project.NewPath(cfg.Terramate.Config.Triggers.Dir)
+	}
+	return project.NewPath("/" + DefaultDir)
+}
+
 // ParseFile will parse the given trigger file.
 func ParseFile(path string) (Info, error) {
 	parser := hclparse.NewParser()
@@ -123,6 +165,10 @@ func ParseFile(path string) (Info, error) {
 				Name:     "context",
 				Required: false,
 			},
+			{
+				Name:     "tags",
+				Required: false,
+			},
 		},
 	})
 
@@ -140,7 +186,7 @@ func ParseFile(path string) (Info, error) {
 			switch attribute.Name {
 			case "context":
 				if keyword != DefaultContext {
-					errs.Append(errors.E(
+					errs.Append(errors.E(ErrParsing,
 						"trigger: invalid trigger.context = %s (available options: %s)",
 						keyword, DefaultContext,
 					))
@@ -148,10 +194,10 @@ func ParseFile(path string) (Info, error) {
 				}
 				info.Context = keyword
 			case "type":
-				if keyword != DefaultType {
-					errs.Append(errors.E(
-						"trigger: invalid trigger.type = %s (available options: %s)",
-						keyword, DefaultType,
+				if keyword != DefaultType && keyword != IgnoredType {
+					errs.Append(errors.E(ErrParsing,
+						"trigger: invalid trigger.type = %s (available options: %s, %s)",
+						keyword, DefaultType, IgnoredType,
 					))
 					continue
 				}
@@ -181,6 +227,16 @@ func ParseFile(path string) (Info, error) {
 				continue
 			}
 			info.Reason = val.AsString()
+		case "tags":
+			if val.Type() != cty.String {
+				errs.Append(errors.E(ErrParsing, "trigger: %s must be a string", attribute.Name))
+				continue
+			}
+			if err := validateTags(val.AsString()); err != nil {
+				errs.Append(err)
+				continue
+			}
+			info.Tags = val.AsString()
 		default:
 			errs.Append(errors.E(ErrParsing, "trigger: has unknown attribute %q", attribute.Name))
 		}
@@ -204,16 +260,25 @@ func ParseFile(path string) (Info, error) {
 
 // Dir will return the triggers directory for the project rooted at rootdir.
 // Both rootdir and the returned value are host absolute paths.
+//
+// Deprecated: Dir always returns the [DefaultDir] triggers directory, use
+// [DirFor] to honor the triggers directory configured on the project.
 func Dir(rootdir string) string {
-	return filepath.Join(rootdir, triggersDir)
+	return filepath.Join(rootdir, DefaultDir)
 }
 
-func triggerFilename() (string, error) {
+// DirFor will return the triggers directory for the given project root.
+// The returned value is a host absolute path.
+func DirFor(root *config.Root) string {
+	return DirPath(root).HostPath(root.HostDir())
+}
+
+func triggerFilename(triggerType string) (string, error) {
 	id, err := uuid.NewRandom()
 	if err != nil {
 		return "", errors.E(err, "creating trigger UUID")
 	}
-	return fmt.Sprintf("changed-%s.tm.hcl", id.String()), nil
+	return fmt.Sprintf("%s-%s.tm.hcl", triggerType, id.String()), nil
 }
 
 // Create creates a trigger for a stack with the given path and the given reason
@@ -223,11 +288,36 @@ func Create(root *config.Root, path project.Path, reason string) error {
 	if !ok || !tree.IsStack() {
 		return errors.E(ErrTrigger, "path %s is not a stack directory", path)
 	}
-	filename, err := triggerFilename()
+	triggerDir := filepath.Join(DirFor(root), path.String())
+	return create(triggerDir, DefaultType, reason, "")
+}
+
+// CreateIgnore creates a trigger which marks the stack with the given path as
+// unchanged, with the given reason, inside the project rootdir.
+func CreateIgnore(root *config.Root, path project.Path, reason string) error {
+	tree, ok := root.Lookup(path)
+	if !ok || !tree.IsStack() {
+		return errors.E(ErrTrigger, "path %s is not a stack directory", path)
+	}
+	triggerDir := filepath.Join(DirFor(root), path.String())
+	return create(triggerDir, IgnoredType, reason, "")
+}
+
+// CreateForTags creates a trigger for all the stacks matching the tags filter,
+// with the given reason, inside the triggers directory of the project.
+// The tags filter uses the same syntax of the --tags command line option.
+func CreateForTags(root *config.Root, tags string, reason string) error {
+	if err := validateTags(tags); err != nil {
+		return errors.E(ErrTrigger, err)
+	}
+	return create(DirFor(root), DefaultType, reason, tags)
+}
+
+func create(triggerDir, triggerType, reason, tags string) error {
+	filename, err := triggerFilename(triggerType)
 	if err != nil {
 		return errors.E(ErrTrigger, err)
 	}
-	triggerDir := filepath.Join(root.HostDir(), triggersDir, path.String())
 	if err := os.MkdirAll(triggerDir, 0775); err != nil {
 		return errors.E(ErrTrigger, err, "creating trigger dir")
 	}
@@ -238,8 +328,11 @@ func Create(root *config.Root, path project.Path, reason string) error {
 	triggerBody := gen.Body().AppendNewBlock("trigger", nil).Body()
 	triggerBody.SetAttributeValue("ctime", cty.NumberIntVal(ctime))
 	triggerBody.SetAttributeValue("reason", cty.StringVal(reason))
-	triggerBody.SetAttributeRaw("type", hclwrite.TokensForIdentifier(DefaultType))
+	triggerBody.SetAttributeRaw("type", hclwrite.TokensForIdentifier(triggerType))
 	triggerBody.SetAttributeRaw("context", hclwrite.TokensForIdentifier(DefaultContext))
+	if tags != "" {
+		triggerBody.SetAttributeValue("tags", cty.StringVal(tags))
+	}
 
 	triggerPath := filepath.Join(triggerDir, filename)
 
@@ -249,9 +342,22 @@ func Create(root *config.Root, path project.Path, reason string) error {
 
 	log.Debug().
 		Str("action", "trigger.Create").
+		Str("type", triggerType).
 		Int64("ctime", ctime).
 		Str("reason", reason).
+		Str("tags", tags).
 		Msg("trigger file created")
 
 	return nil
 }
+
+func validateTags(tags string) error {
+	_, found, err := filter.ParseTagClauses(tags)
+	if err != nil {
+		return errors.E(ErrInvalidTags, err, "trigger: invalid tags %q", tags)
+	}
+	if !found {
+		return errors.E(ErrInvalidTags, "trigger: empty tags")
+	}
+	return nil
+}
@@ -127,6 +127,90 @@ func testTrigger(t *testing.T, tc testcase) {
 	assert.EqualStrings(t, tc.path, gotPath.String())
 }
 
+func TestTriggerCustomDir(t *testing.T) {
+	t.Parallel()
+
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:dir/stack",
+		`f:terramate.tm.hcl:terramate {
+			config {
+				triggers {
+					dir = ".ci/triggers"
+				}
+			}
+		}`,
+	})
+
+	root := s.Config()
+	assert.EqualStrings(t, "/.ci/triggers", trigger.DirPath(root).String())
+	assert.NoError(t, trigger.Create(root, project.NewPath("/dir/stack"), "reason"))
+
+	triggerDir := filepath.Join(s.RootDir(), ".ci", "triggers", "dir", "stack")
+	entries := test.ReadDir(t, triggerDir)
+	if len(entries) != 1 {
+		t.Fatalf("want 1 trigger file, got %d: %+v", len(entries), entries)
+	}
+
+	triggerFile := project.NewPath("/.ci/triggers/dir/stack/" + entries[0].Name())
+	gotPath, ok := trigger.StackPathFor(root, triggerFile)
+	assert.IsTrue(t, ok)
+	assert.EqualStrings(t, "/dir/stack", gotPath.String())
+
+	_, ok = trigger.StackPathFor(root, project.NewPath("/.tmtriggers/dir/stack/"+entries[0].Name()))
+	assert.IsTrue(t, !ok, "default triggers dir must not be used when configured")
+}
+
+func TestTriggerTags(t *testing.T) {
+	t.Parallel()
+
+	s := sandbox.New(t)
+	s.BuildTree([]string{"s:stack"})
+	root := s.Config()
+
+	assert.NoError(t, trigger.CreateForTags(root, "database,cache", "rotate credentials"))
+
+	entries := test.ReadDir(t, trigger.DirFor(root))
+	if len(entries) != 1 {
+		t.Fatalf("want 1 trigger file, got %d: %+v", len(entries), entries)
+	}
+
+	info, err := trigger.ParseFile(filepath.Join(trigger.DirFor(root), entries[0].Name()))
+	assert.NoError(t, err)
+	assert.EqualStrings(t, "rotate credentials", info.Reason)
+	assert.EqualStrings(t, "database,cache", info.Tags)
+
+	err = trigger.CreateForTags(root, "", "no tags")
+	errtest.Assert(t, err, errors.E(trigger.ErrInvalidTags))
+
+	err = trigger.CreateForTags(root, "Invalid Tag", "invalid tags")
+	errtest.Assert(t, err, errors.E(trigger.ErrInvalidTags))
+}
+
+func TestTriggerIgnore(t *testing.T) {
+	t.Parallel()
+
+	s := sandbox.New(t)
+	s.BuildTree([]string{"s:stack"})
+	root := s.Config()
+
+	assert.NoError(t, trigger.CreateIgnore(root, project.NewPath("/stack"), "docs only"))
+
+	triggerDir := filepath.Join(trigger.DirFor(root), "stack")
+	entries := test.ReadDir(t, triggerDir)
+	if len(entries) != 1 {
+		t.Fatalf("want 1 trigger file, got %d: %+v", len(entries), entries)
+	}
+
+	info, err := trigger.ParseFile(filepath.Join(triggerDir, entries[0].Name()))
+	assert.NoError(t, err)
+	assert.EqualStrings(t, trigger.IgnoredType, info.Type)
+	assert.EqualStrings(t, "docs only", info.Reason)
+
+	err = trigger.CreateIgnore(root, project.NewPath("/non-existent"), "reason")
+	errtest.Assert(t, err, errors.E(trigger.ErrTrigger))
+}
+
 func TestTriggerParser(t *testing.T) {
 	t.Parallel()
 	type testcase struct {
@@ -240,6 +324,52 @@ func TestTriggerParser(t *testing.T) {
 			),
 			err: errors.E(trigger.ErrParsing),
 		},
+		{
+			name: "valid ignore trigger",
+			body: Trigger(
+				Number("ctime", 1000000),
+				Str("reason", "something"),
+				Expr("type", "ignored"),
+				Expr("context", "stack"),
+			),
+		},
+		{
+			name: "invalid type",
+			body: Trigger(
+				Number("ctime", 1000000),
+				Str("reason", "something"),
+				Expr("type", "unknown"),
+			),
+			err: errors.E(trigger.ErrParsing),
+		},
+		{
+			name: "valid file with tags",
+			body: Trigger(
+				Number("ctime", 1000000),
+				Str("reason", "something"),
+				Expr("type", "changed"),
+				Expr("context", "stack"),
+				Str("tags", "database:prod"),
+			),
+		},
+		{
+			name: "tags not string",
+			body: Trigger(
+				Number("ctime", 1000000),
+				Str("reason", "something"),
+				Expr("tags", `["database"]`),
+			),
+			err: errors.E(trigger.ErrParsing),
+		},
+		{
+			name: "invalid tags",
+			body: Trigger(
+				Number("ctime", 1000000),
+				Str("reason", "something"),
+				Str("tags", "database::"),
+			),
+			err: errors.E(trigger.ErrInvalidTags),
+		},
 		{
 			name: "context not a keyword",
 			body: Trigger(
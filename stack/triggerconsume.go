@@ -0,0 +1,144 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/project"
+	"github.com/mineiros-io/terramate/stack/trigger"
+	"github.com/rs/zerolog/log"
+)
+
+const errConsumeTriggers errors.Kind = "consuming triggers error"
+
+// ConsumeTriggers handles the trigger files as one-shot triggers: it
+// returns the stacks selected by the trigger files changed since the git
+// base ref, the same way as [Manager.ListChanged], and deletes these trigger
+// files, so they don't select the stacks again on subsequent runs.
+//
+// The deletions are staged in the git index but not committed, so they can
+// be committed together with the results of the run (eg.: by the CI job
+// consuming the triggers). Trigger files which don't select any stack (eg.:
+// the stack was removed) are kept. Ignore triggers are also deleted but the
+// stacks they ignore are not returned.
+//
+// The stacks are returned sorted and without duplicates.
+func (m *Manager) ConsumeTriggers() ([]project.Path, error) {
+	logger := log.With().
+		Str("action", "Manager.ConsumeTriggers()").
+		Logger()
+
+	g, err := m.newGit(m.root.HostDir())
+	if err != nil {
+		return nil, errors.E(errConsumeTriggers, err)
+	}
+
+	baseManager, err := m.withBaseRef()
+	if err != nil {
+		return nil, errors.E(errConsumeTriggers, err)
+	}
+
+	changedFiles, err := baseManager.listChangedFiles(m.root.HostDir())
+	if err != nil {
+		return nil, errors.E(errConsumeTriggers, err)
+	}
+
+	var consumed []string
+	stackSet := map[project.Path]struct{}{}
+	for _, file := range changedFiles {
+		abspath := filepath.Join(m.root.HostDir(), filepath.FromSlash(file))
+		projpath := project.PrjAbsPath(m.root.HostDir(), abspath)
+		triggeredStack, ok := trigger.StackPathFor(m.root, projpath)
+		if !ok {
+			continue
+		}
+
+		if _, err := os.Stat(abspath); err != nil {
+			// deleted trigger files were already consumed.
+			continue
+		}
+
+		info, err := parseTrigger(abspath)
+		if err != nil {
+			return nil, errors.E(errConsumeTriggers, err)
+		}
+
+		if info.Type == trigger.IgnoredType {
+			// ignore triggers are one-shot too, but select no stack.
+			consumed = append(consumed, file)
+			continue
+		}
+
+		tags := info.Tags
+		if tags != "" {
+			entries, err := m.stacksMatchingTags(tags, project.NewPath("/"))
+			if err != nil {
+				return nil, errors.E(errConsumeTriggers, err)
+			}
+			if len(entries) == 0 {
+				logger.Debug().
+					Stringer("trigger", projpath).
+					Msg("Trigger tags match no stack, keeping trigger file.")
+				continue
+			}
+			for _, entry := range entries {
+				stackSet[entry.Stack.Dir] = struct{}{}
+			}
+			consumed = append(consumed, file)
+			continue
+		}
+
+		cfg, found := m.root.Lookup(triggeredStack)
+		if !found || !cfg.IsStack() {
+			logger.Debug().
+				Stringer("trigger", projpath).
+				Msg("Trigger path is not a stack, keeping trigger file.")
+			continue
+		}
+
+		stackSet[triggeredStack] = struct{}{}
+		consumed = append(consumed, file)
+	}
+
+	for _, file := range consumed {
+		logger.Debug().
+			Str("trigger", file).
+			Msg("Remove consumed trigger file.")
+
+		abspath := filepath.Join(m.root.HostDir(), filepath.FromSlash(file))
+		if err := os.Remove(abspath); err != nil {
+			return nil, errors.E(errConsumeTriggers, err, "removing trigger file")
+		}
+	}
+
+	if len(consumed) > 0 {
+		if err := g.RemoveFromIndex(consumed...); err != nil {
+			return nil, errors.E(errConsumeTriggers, err, "staging removed trigger files")
+		}
+	}
+
+	stacks := make([]project.Path, 0, len(stackSet))
+	for st := range stackSet {
+		stacks = append(stacks, st)
+	}
+	sort.Slice(stacks, func(i, j int) bool {
+		return stacks[i].String() < stacks[j].String()
+	})
+	return stacks, nil
+}
@@ -0,0 +1,125 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/madlambda/spells/assert"
+	"github.com/mineiros-io/terramate/project"
+	"github.com/mineiros-io/terramate/stack"
+	"github.com/mineiros-io/terramate/stack/trigger"
+	"github.com/mineiros-io/terramate/test"
+	"github.com/mineiros-io/terramate/test/sandbox"
+)
+
+func TestConsumeTriggers(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stacks/a",
+		"s:stacks/b",
+	})
+
+	git := s.Git()
+	git.CommitAll("first commit")
+	git.Push("main")
+	git.CheckoutNew("trigger-stacks")
+
+	root := s.Config()
+	assert.NoError(t, trigger.Create(root, project.NewPath("/stacks/a"), "one-shot"))
+	assert.NoError(t, trigger.Create(root, project.NewPath("/stacks/a"), "again"))
+
+	// triggers of removed stacks are kept.
+	s.RootEntry().CreateFile(".tmtriggers/stacks/removed.tm.hcl", `trigger {
+		ctime  = 1
+		reason = "removed"
+	}`)
+	git.CommitAll("trigger stacks")
+
+	triggersDir := filepath.Join(s.RootDir(), ".tmtriggers", "stacks", "a")
+	triggerFiles, err := os.ReadDir(triggersDir)
+	assert.NoError(t, err)
+	assert.EqualInts(t, 2, len(triggerFiles))
+
+	m := stack.NewManager(root, defaultBranch)
+	consumed, err := m.ConsumeTriggers()
+	assert.NoError(t, err)
+
+	want := []project.Path{project.NewPath("/stacks/a")}
+	if diff := cmp.Diff(want, consumed, cmp.AllowUnexported(project.Path{})); diff != "" {
+		t.Fatalf("-(want) +(got):\n%s", diff)
+	}
+
+	for _, file := range triggerFiles {
+		_, err := os.Stat(filepath.Join(triggersDir, file.Name()))
+		assert.IsTrue(t, os.IsNotExist(err), "trigger file %s not removed", file.Name())
+	}
+	_, err = os.Stat(filepath.Join(s.RootDir(), ".tmtriggers", "stacks", "removed.tm.hcl"))
+	assert.NoError(t, err, "trigger of removed stack must be kept")
+
+	g := test.NewGitWrapper(t, s.RootDir(), nil)
+	staged, err := g.Exec("diff", "--cached", "--name-status")
+	assert.NoError(t, err)
+	for _, file := range triggerFiles {
+		wantLine := "D\t.tmtriggers/stacks/a/" + file.Name()
+		assert.IsTrue(t, strings.Contains(staged, wantLine),
+			"deletion of %s not staged: %s", file.Name(), staged)
+	}
+
+	// consumed triggers don't fire again.
+	report, err := m.ListChanged()
+	assert.NoError(t, err)
+	assertStacks(t, []string{}, report.Stacks, true)
+
+	consumed, err = m.ConsumeTriggers()
+	assert.NoError(t, err)
+	assert.EqualInts(t, 0, len(consumed))
+}
+
+func TestConsumeTagsTriggers(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		`s:stacks/db:tags=["database"]`,
+		"s:stacks/app",
+	})
+
+	git := s.Git()
+	git.CommitAll("first commit")
+	git.Push("main")
+	git.CheckoutNew("trigger-tags")
+
+	root := s.Config()
+	assert.NoError(t, trigger.CreateForTags(root, "database", "rotate credentials"))
+	assert.NoError(t, trigger.CreateForTags(root, "unknown", "kept"))
+	git.CommitAll("trigger tags")
+
+	m := stack.NewManager(root, defaultBranch)
+	consumed, err := m.ConsumeTriggers()
+	assert.NoError(t, err)
+
+	want := []project.Path{project.NewPath("/stacks/db")}
+	if diff := cmp.Diff(want, consumed, cmp.AllowUnexported(project.Path{})); diff != "" {
+		t.Fatalf("-(want) +(got):\n%s", diff)
+	}
+
+	// the trigger matching no stack is kept.
+	files, err := os.ReadDir(filepath.Join(s.RootDir(), ".tmtriggers"))
+	assert.NoError(t, err)
+	assert.EqualInts(t, 1, len(files))
+}
@@ -0,0 +1,61 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"github.com/mineiros-io/terramate/config"
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/project"
+	"github.com/mineiros-io/terramate/stack/trigger"
+)
+
+// ErrInvalidTriggerPath indicates that a trigger file path doesn't trigger
+// any stack.
+const ErrInvalidTriggerPath errors.Kind = "invalid trigger path"
+
+// PreviewTrigger returns the stacks that would be selected by a trigger file
+// at triggerPath, the same way [Manager.ListChanged] does for committed
+// trigger files, but without requiring the file to exist or be committed.
+// This is useful to validate trigger paths before creating them.
+//
+// It returns an error of kind ErrInvalidTriggerPath if the path is outside
+// the triggers directory or if it doesn't correspond to a stack.
+func (m *Manager) PreviewTrigger(triggerPath project.Path) ([]Entry, error) {
+	stackPath, ok := trigger.StackPathFor(m.root, triggerPath)
+	if !ok {
+		return nil, errors.E(ErrInvalidTriggerPath,
+			"%s is not inside the triggers directory %s",
+			triggerPath, trigger.DirPath(m.root))
+	}
+
+	tree, found := m.root.Lookup(stackPath)
+	if !found || !tree.IsStack() {
+		return nil, errors.E(ErrInvalidTriggerPath,
+			"%s would trigger %s, which is not a stack", triggerPath, stackPath)
+	}
+
+	st, err := config.NewStackFromHCL(m.root.HostDir(), tree.Node)
+	if err != nil {
+		return nil, errors.E(ErrInvalidTriggerPath, err)
+	}
+
+	return []Entry{
+		{
+			Stack:  st,
+			Reason: "stack would be triggered by: " + triggerPath.String(),
+			Kind:   ChangeKindTrigger,
+		},
+	}, nil
+}
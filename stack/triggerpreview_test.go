@@ -0,0 +1,51 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack_test
+
+import (
+	"testing"
+
+	"github.com/madlambda/spells/assert"
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/project"
+	"github.com/mineiros-io/terramate/stack"
+	"github.com/mineiros-io/terramate/test/sandbox"
+)
+
+func TestPreviewTrigger(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		"s:stacks/stack-a",
+		"d:stacks/dir",
+	})
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+
+	const triggerPath = "/.tmtriggers/stacks/stack-a/trigger-file"
+	entries, err := m.PreviewTrigger(project.NewPath(triggerPath))
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/stacks/stack-a"}, entries, false)
+	assert.EqualStrings(t, string(stack.ChangeKindTrigger), string(entries[0].Kind))
+	assert.EqualStrings(t, "stack would be triggered by: "+triggerPath, entries[0].Reason)
+
+	for _, invalid := range []string{
+		"/stacks/stack-a/trigger-file",
+		"/.tmtriggers/stacks/dir/trigger-file",
+		"/.tmtriggers/stacks/non-existent/trigger-file",
+	} {
+		_, err := m.PreviewTrigger(project.NewPath(invalid))
+		assert.IsError(t, err, errors.E(stack.ErrInvalidTriggerPath))
+	}
+}
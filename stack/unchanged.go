@@ -0,0 +1,161 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/mineiros-io/terramate/config"
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/project"
+	"github.com/rs/zerolog/log"
+)
+
+// UnchangedEntry is a stack not reported as changed by the change detection.
+type UnchangedEntry struct {
+	Stack *config.Stack
+
+	// Reason explains why the stack is not changed, describing each of the
+	// checks of the change detection.
+	Reason string
+}
+
+// ListChangedVerbose works like [Manager.ListChanged] but also returns all
+// the other stacks of the project, sorted by path, with the reason why they
+// are not considered changed, which is useful to debug the change detection
+// (eg.: why a stack was not deployed). The changed stacks report is the same
+// as the one returned by ListChanged and the reasons are the ignored
+// decisions taken while computing it (see [TraceEntry]).
+func (m *Manager) ListChangedVerbose() (*Report, []UnchangedEntry, error) {
+	logger := log.With().
+		Str("action", "Manager.ListChangedVerbose()").
+		Logger()
+
+	tracer := &changeTracer{}
+	report, err := m.listChangedTraced(project.NewPath("/"), tracer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// ignoredOf maps each stack to the ignored decisions about it or about
+	// the changed files inside its directory.
+	ignoredOf := map[project.Path][]TraceEntry{}
+	for _, entry := range tracer.ignoredEntries {
+		if entry.Stack != "" {
+			dir := project.NewPath(entry.Stack)
+			ignoredOf[dir] = append(ignoredOf[dir], entry)
+			continue
+		}
+		if stackdir, ok := m.stackOwningFile(entry.File); ok {
+			ignoredOf[stackdir] = append(ignoredOf[stackdir], entry)
+		}
+	}
+
+	changed := map[project.Path]struct{}{}
+	for _, entry := range report.Stacks {
+		changed[entry.Stack.Dir] = struct{}{}
+	}
+
+	allstacks, err := List(m.root.Tree())
+	if err != nil {
+		return nil, nil, errors.E(errListChanged, err)
+	}
+
+	unchanged := []UnchangedEntry{}
+	for _, entry := range allstacks {
+		st := entry.Stack
+		if _, ok := changed[st.Dir]; ok {
+			continue
+		}
+
+		logger.Trace().
+			Stringer("stack", st.Dir).
+			Msg("Explain unchanged stack.")
+
+		modules, err := st.LocalModules(m.root)
+		if err != nil {
+			return nil, nil, errors.E(errListChanged, err, "stack %s", st.Dir)
+		}
+
+		unchanged = append(unchanged, UnchangedEntry{
+			Stack:  st,
+			Reason: unchangedReason(st, ignoredOf[st.Dir], modules),
+		})
+	}
+	return report, unchanged, nil
+}
+
+// stackOwningFile returns the stack which contains the file, relative to the
+// project root, which is the closest stack in the file parent directories.
+func (m *Manager) stackOwningFile(file string) (project.Path, bool) {
+	dir := project.NewPath(path.Join("/", path.Dir(file)))
+	for {
+		if tree, found := m.root.Lookup(dir); found && tree.IsStack() {
+			return dir, true
+		}
+		if dir.String() == "/" {
+			return project.Path{}, false
+		}
+		dir = dir.Dir()
+	}
+}
+
+func unchangedReason(st *config.Stack, ignored []TraceEntry, modules []config.LocalModule) string {
+	var reasons []string
+	if len(ignored) == 0 {
+		reasons = append(reasons, "no changed files in the stack directory")
+	} else {
+		changes := make([]string, len(ignored))
+		for i, entry := range ignored {
+			changes[i] = fmt.Sprintf("%s: %s", entry.File, entry.Reason)
+		}
+		reasons = append(reasons, fmt.Sprintf(
+			"ignored changes (%s)", strings.Join(changes, ", ")))
+	}
+
+	if len(st.Watch) == 0 {
+		reasons = append(reasons, "no watched files")
+	} else {
+		watched := make([]string, len(st.Watch))
+		for i, file := range st.Watch {
+			watched[i] = file.String()
+		}
+		reasons = append(reasons, fmt.Sprintf(
+			"no changed watched files (%s)", strings.Join(watched, ", ")))
+	}
+
+	if len(st.VarFiles) > 0 {
+		varFiles := make([]string, len(st.VarFiles))
+		for i, file := range st.VarFiles {
+			varFiles[i] = file.String()
+		}
+		reasons = append(reasons, fmt.Sprintf(
+			"no changed var files (%s)", strings.Join(varFiles, ", ")))
+	}
+
+	if len(modules) == 0 {
+		reasons = append(reasons, "no local modules")
+	} else {
+		dirs := make([]string, len(modules))
+		for i, mod := range modules {
+			dirs[i] = mod.Dir.String()
+		}
+		reasons = append(reasons, fmt.Sprintf(
+			"all referenced local modules unchanged (%s)", strings.Join(dirs, ", ")))
+	}
+	return "stack has " + strings.Join(reasons, "; ")
+}
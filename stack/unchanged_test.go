@@ -0,0 +1,94 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/madlambda/spells/assert"
+	"github.com/mineiros-io/terramate/stack"
+	"github.com/mineiros-io/terramate/test/sandbox"
+)
+
+func TestListChangedVerbose(t *testing.T) {
+	s := sandbox.New(t)
+	s.BuildTree([]string{
+		`s:changed`,
+		`s:watcher:watch=["/config/values.txt"]`,
+		`s:module-user`,
+		`s:plain`,
+		"d:config",
+		"d:modules/vpc",
+		"f:changed/main.tf:# changed",
+		"f:config/values.txt:values",
+		"f:plain/main.tf:# plain",
+		"f:plain/README.md:# plain",
+		"f:modules/vpc/main.tf:# vpc",
+		`f:module-user/main.tf:module "vpc" {
+			source = "../modules/vpc"
+		}`,
+	})
+
+	git := s.Git()
+	git.CommitAll("first commit")
+	git.Push("main")
+	git.CheckoutNew("change")
+
+	s.RootEntry().CreateFile("terramate.tm.hcl", `terramate {
+		config {
+			change_detection {
+				ignore = ["*.md"]
+			}
+		}
+	}`)
+	s.RootEntry().CreateFile("changed/main.tf", "# changed again")
+	s.RootEntry().CreateFile("plain/README.md", "# plain changed")
+	git.CommitAll("change stack")
+
+	m := stack.NewManager(s.Config(), defaultBranch)
+	wantReport, err := m.ListChanged()
+	assert.NoError(t, err)
+
+	report, unchanged, err := m.ListChangedVerbose()
+	assert.NoError(t, err)
+	assertStacks(t, []string{"/changed"}, report.Stacks, true)
+	assert.EqualStrings(t, wantReport.Stacks[0].Reason, report.Stacks[0].Reason)
+
+	got := map[string]string{}
+	for _, entry := range unchanged {
+		got[entry.Stack.Dir.String()] = entry.Reason
+	}
+	want := map[string]string{
+		"/module-user": "stack has no changed files in the stack directory; " +
+			"no watched files; all referenced local modules unchanged (/modules/vpc)",
+		"/plain": "stack has ignored changes " +
+			`(plain/README.md: file matches ignore pattern "*.md"); ` +
+			"no watched files; no local modules",
+		"/watcher": "stack has no changed files in the stack directory; " +
+			"no changed watched files (/config/values.txt); no local modules",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("-(want) +(got):\n%s", diff)
+	}
+
+	var paths []string
+	for _, entry := range unchanged {
+		paths = append(paths, entry.Stack.Dir.String())
+	}
+	if diff := cmp.Diff([]string{"/module-user", "/plain", "/watcher"}, paths); diff != "" {
+		t.Fatalf("unchanged stacks are not sorted: -(want) +(got):\n%s", diff)
+	}
+}
@@ -42,7 +42,8 @@ func init() {
 	regexCache = map[string]*regexp.Regexp{}
 }
 
-// Functions returns all the Terramate default functions.
+// Functions returns all the Terramate default functions, including the
+// functions added with [Register].
 // The `basedir` must be an absolute path for an existent directory or it panics.
 func Functions(basedir string) map[string]function.Function {
 	if !filepath.IsAbs(basedir) {
@@ -57,6 +58,13 @@ func Functions(basedir string) map[string]function.Function {
 		panic(errors.E(errors.ErrInternal, "context basedir (%s) must be a directory", basedir))
 	}
 
+	tmfuncs := builtinFunctions(basedir)
+	registeredFunctions(basedir, tmfuncs)
+	return tmfuncs
+}
+
+// builtinFunctions returns the functions provided by this package.
+func builtinFunctions(basedir string) map[string]function.Function {
 	scope := &tflang.Scope{BaseDir: basedir}
 	tffuncs := scope.Functions()
 
@@ -0,0 +1,88 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stdlib
+
+import (
+	"sync"
+
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// FunctionFactory creates a function for an evaluation context whose
+// base directory is basedir (see [Functions]).
+type FunctionFactory func(basedir string) function.Function
+
+// ErrFunctionRegistration indicates that a function could not be registered.
+const ErrFunctionRegistration errors.Kind = "registering function"
+
+var registry = struct {
+	sync.RWMutex
+	factories map[string]FunctionFactory
+}{
+	factories: map[string]FunctionFactory{},
+}
+
+// Register registers a function, so it is returned by [Functions] on every
+// evaluation context, allowing other packages to extend the Terramate
+// functions without changing this package. It is intended to be called from
+// the init() function of the package providing the function.
+//
+// The name must not have the "tm_" prefix, which is added to it, the same
+// way as [Name]. Registered functions can't override the builtin Terramate
+// functions nor other registered functions, so it panics with an error of
+// kind [ErrFunctionRegistration] if the name is already in use, if it is
+// empty or if the factory is nil. Functions set directly on an evaluation
+// context by Terramate (eg.: tm_vendor) have precedence over registered
+// functions with the same name.
+func Register(name string, factory FunctionFactory) {
+	if name == "" {
+		panic(errors.E(ErrFunctionRegistration, "empty function name"))
+	}
+	if factory == nil {
+		panic(errors.E(ErrFunctionRegistration, "nil factory for function %q", Name(name)))
+	}
+
+	fullname := Name(name)
+	if isBuiltinFunction(fullname) {
+		panic(errors.E(ErrFunctionRegistration,
+			"function %q is a builtin function", fullname))
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+
+	if _, ok := registry.factories[fullname]; ok {
+		panic(errors.E(ErrFunctionRegistration,
+			"function %q is already registered", fullname))
+	}
+	registry.factories[fullname] = factory
+}
+
+// registeredFunctions adds the registered functions to funcs.
+func registeredFunctions(basedir string, funcs map[string]function.Function) {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	for name, factory := range registry.factories {
+		funcs[name] = factory(basedir)
+	}
+}
+
+func isBuiltinFunction(name string) bool {
+	// the basedir is irrelevant to check the function names.
+	_, ok := builtinFunctions("")[name]
+	return ok
+}
@@ -0,0 +1,100 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stdlib_test
+
+import (
+	"testing"
+
+	"github.com/madlambda/spells/assert"
+	"github.com/mineiros-io/terramate/errors"
+	"github.com/mineiros-io/terramate/hcl/eval"
+	"github.com/mineiros-io/terramate/stdlib"
+	"github.com/mineiros-io/terramate/test"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+func basedirFunc(basedir string) function.Function {
+	return function.New(&function.Spec{
+		Type: function.StaticReturnType(cty.String),
+		Impl: func(_ []cty.Value, _ cty.Type) (cty.Value, error) {
+			return cty.StringVal(basedir), nil
+		},
+	})
+}
+
+func init() {
+	stdlib.Register("test_registered_basedir", basedirFunc)
+}
+
+func TestRegisteredFunction(t *testing.T) {
+	basedir := t.TempDir()
+	funcs := stdlib.Functions(basedir)
+
+	_, ok := funcs["tm_test_registered_basedir"]
+	assert.IsTrue(t, ok, "registered function not found")
+
+	ctx := eval.NewContext(funcs)
+	got, err := ctx.Eval(test.NewExpr(t, `tm_test_registered_basedir()`))
+	assert.NoError(t, err)
+	assert.EqualStrings(t, basedir, got.AsString())
+}
+
+func TestRegisterFailures(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		fname   string
+		factory stdlib.FunctionFactory
+	}{
+		{
+			name:    "empty name",
+			fname:   "",
+			factory: basedirFunc,
+		},
+		{
+			name:  "nil factory",
+			fname: "test_nil_factory",
+		},
+		{
+			name:    "terraform builtin function",
+			fname:   "upper",
+			factory: basedirFunc,
+		},
+		{
+			name:    "terramate builtin function",
+			fname:   "ternary",
+			factory: basedirFunc,
+		},
+		{
+			name:    "already registered function",
+			fname:   "test_registered_basedir",
+			factory: basedirFunc,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			defer func() {
+				r := recover()
+				if r == nil {
+					t.Fatal("Register() must panic")
+				}
+				err, ok := r.(error)
+				assert.IsTrue(t, ok, "panic value is not an error: %v", r)
+				assert.IsError(t, err, errors.E(stdlib.ErrFunctionRegistration))
+			}()
+			stdlib.Register(tc.fname, tc.factory)
+		})
+	}
+}
@@ -121,6 +121,9 @@ func AssertConfigEquals(t *testing.T, got, want []config.Assert) {
 		if g.Warning != w.Warning {
 			t.Errorf("got.Warning[%d]=%t, want=%t", i, g.Warning, w.Warning)
 		}
+		if w.Severity != "" && g.Severity != w.Severity {
+			t.Errorf("got.Severity[%d]=%s, want=%s", i, g.Severity, w.Severity)
+		}
 		AssertDiff(t, g.Range, w.Range, "range mismatch")
 		assert.EqualStrings(t, w.Message, g.Message, "message mismatch")
 	}
@@ -187,6 +190,9 @@ func assertAssertsBlock(t *testing.T, got, want []hcl.AssertConfig, ctx string)
 		assert.EqualStrings(t,
 			exprAsStr(t, w.Warning), exprAsStr(t, g.Warning),
 			"%s: warning expr mismatch", newctx)
+		assert.EqualStrings(t,
+			exprAsStr(t, w.Severity), exprAsStr(t, g.Severity),
+			"%s: severity expr mismatch", newctx)
 	}
 }
 
@@ -250,6 +256,24 @@ func assertTerramateConfigBlock(t *testing.T, got, want *hcl.RootConfig) {
 	}
 
 	assertTerramateRunBlock(t, got.Run, want.Run)
+
+	if (want.Triggers == nil) != (got.Triggers == nil) {
+		t.Fatalf(
+			"want.Triggers[%+v] != got.Triggers[%+v]",
+			want.Triggers,
+			got.Triggers,
+		)
+	}
+
+	if want.Triggers != nil {
+		if *want.Triggers != *got.Triggers {
+			t.Fatalf("want.Triggers[%+v] != got.Triggers[%+v]", want.Triggers, got.Triggers)
+		}
+	}
+
+	if diff := cmp.Diff(want.ChangeDetection, got.ChangeDetection); diff != "" {
+		t.Fatalf("change_detection config mismatch (-want +got):\n%s", diff)
+	}
 }
 
 func assertGenHCLBlocks(t *testing.T, got, want []hcl.GenHCLBlock) {
@@ -262,6 +286,9 @@ func assertGenHCLBlocks(t *testing.T, got, want []hcl.GenHCLBlock) {
 		wantBlock := want[i]
 		AssertEqualRanges(t, gotBlock.Range, wantBlock.Range, "genhcl range differs")
 		assert.EqualStrings(t, wantBlock.Label, gotBlock.Label, "genhcl label differs")
+		assert.EqualStrings(t, wantBlock.OutDir, gotBlock.OutDir, "genhcl outdir differs")
+		assert.IsTrue(t, wantBlock.OnChangeOnly == gotBlock.OnChangeOnly,
+			"genhcl on_change_only differs")
 		assertAssertsBlock(t, gotBlock.Asserts, wantBlock.Asserts, "genhcl asserts")
 	}
 }
@@ -276,6 +303,9 @@ func assertGenFileBlocks(t *testing.T, got, want []hcl.GenFileBlock) {
 		wantBlock := want[i]
 		AssertEqualRanges(t, gotBlock.Range, wantBlock.Range, "genfile range differs")
 		assert.EqualStrings(t, wantBlock.Label, gotBlock.Label, "genfile label differs")
+		assert.EqualStrings(t, wantBlock.OutDir, gotBlock.OutDir, "genfile outdir differs")
+		assert.IsTrue(t, wantBlock.OnChangeOnly == gotBlock.OnChangeOnly,
+			"genfile on_change_only differs")
 		assertAssertsBlock(t, gotBlock.Asserts, wantBlock.Asserts, "genfile asserts")
 	}
 }
@@ -295,6 +325,14 @@ func assertTerramateRunBlock(t *testing.T, got, want *hcl.RunConfig) {
 		"want.Run.CheckGenCode %v != got.Run.CheckGenCode %v",
 		want.CheckGenCode, got.CheckGenCode)
 
+	if (want.Command == nil) != (got.Command == nil) {
+		t.Fatalf(
+			"want.Run.Command[%+v] != got.Run.Command[%+v]",
+			want.Command,
+			got.Command,
+		)
+	}
+
 	if (want.Env == nil) != (got.Env == nil) {
 		t.Fatalf(
 			"want.Run.Env[%+v] != got.Run.Env[%+v]",
@@ -346,6 +384,18 @@ func assertStackBlock(t *testing.T, got, want *hcl.Stack) {
 	for i, w := range want.After {
 		assert.EqualStrings(t, w, got.After[i], "stack after mismatch")
 	}
+
+	assert.EqualInts(t, len(got.Owners), len(want.Owners), "Owners length mismatch")
+
+	for i, w := range want.Owners {
+		assert.EqualStrings(t, w, got.Owners[i], "stack owner mismatch")
+	}
+
+	assert.EqualInts(t, len(got.VarFiles), len(want.VarFiles), "VarFiles length mismatch")
+
+	for i, w := range want.VarFiles {
+		assert.EqualStrings(t, w, got.VarFiles[i], "stack var file mismatch")
+	}
 }
 
 // WriteRootConfig writes a basic terramate root config.
@@ -132,6 +132,11 @@ func Globals(builders ...hclwrite.BlockBuilder) *hclwrite.Block {
 	return Block("globals", builders...)
 }
 
+// ConditionalGlobals is a helper for a "conditional_globals" block.
+func ConditionalGlobals(builders ...hclwrite.BlockBuilder) *hclwrite.Block {
+	return Block("conditional_globals", builders...)
+}
+
 // Map is a helper for a "map" block.
 func Map(builders ...hclwrite.BlockBuilder) *hclwrite.Block {
 	return Block("map", builders...)
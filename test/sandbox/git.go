@@ -16,6 +16,8 @@ package sandbox
 
 import (
 	"fmt"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -30,9 +32,21 @@ type GitConfig struct {
 	DefaultRemoteName       string
 	DefaultRemoteBranchName string
 
+	// Signature, if set, pins the identity and date of all the commits,
+	// making the repository history reproducible.
+	Signature CommitSignature
+
 	repoDir string
 }
 
+// CommitSignature is the author and committer identity and date of commits.
+// Empty fields are left to git defaults (eg.: the current time).
+type CommitSignature struct {
+	Name  string
+	Email string
+	Date  time.Time
+}
+
 // Git is a git wrapper that makes testing easy by handling
 // errors automatically, failing the caller test.
 type Git struct {
@@ -61,7 +75,7 @@ func NewGitWithConfig(t testing.TB, cfg GitConfig) *Git {
 	return &Git{
 		t:   t,
 		cfg: cfg,
-		g:   test.NewGitWrapper(t, cfg.repoDir, []string{}),
+		g:   test.NewGitWrapper(t, cfg.repoDir, cfg.Signature.env()),
 	}
 }
 
@@ -75,7 +89,8 @@ func (git *Git) Init() {
 	git.InitLocalRepo()
 
 	// the main branch only exists after first commit.
-	// The entropy is used to generate different root commits for different repos.
+	// The entropy is used to generate different root commits for different repos,
+	// even when the commit signature is pinned.
 	// So we can test if disjoint branches are not reachable (ie. no merge-base).
 	path := test.WriteFile(t, git.cfg.repoDir, "README.md",
 		fmt.Sprintf("# generated by terramate (entropy %d)", time.Now().UnixNano()))
@@ -199,6 +214,27 @@ func (git Git) Commit(msg string, args ...string) {
 	}
 }
 
+// CommitWith will commit previously added files using the given signature.
+// The fields of sig override the ones of the configured signature.
+func (git Git) CommitWith(msg string, sig CommitSignature, args ...string) {
+	git.t.Helper()
+
+	if sig.Name == "" {
+		sig.Name = git.cfg.Signature.Name
+	}
+	if sig.Email == "" {
+		sig.Email = git.cfg.Signature.Email
+	}
+	if sig.Date.IsZero() {
+		sig.Date = git.cfg.Signature.Date
+	}
+
+	g := test.NewGitWrapper(git.t, git.cfg.repoDir, sig.env())
+	if err := g.Commit(msg, args...); err != nil {
+		git.t.Fatalf("Git.CommitWith(%q, %+v, %v) = %v", msg, sig, args, err)
+	}
+}
+
 // Clone will clone a repository into the given dir.
 func (git Git) Clone(repoURL, dir string) {
 	git.t.Helper()
@@ -208,6 +244,20 @@ func (git Git) Clone(repoURL, dir string) {
 	}
 }
 
+// ShallowClone will clone a repository into the given dir, truncating the
+// history to depth commits. Local repository paths are cloned using file://
+// URLs, otherwise git ignores the depth.
+func (git Git) ShallowClone(repoURL, dir string, depth int) {
+	git.t.Helper()
+
+	if filepath.IsAbs(repoURL) {
+		repoURL = "file://" + filepath.ToSlash(repoURL)
+	}
+	if err := git.g.CloneDepth(repoURL, dir, depth); err != nil {
+		git.t.Fatalf("Git.ShallowClone(%q, %q, %d) = %v", repoURL, dir, depth, err)
+	}
+}
+
 // Push pushes changes from branch onto default remote and same remote branch name.
 func (git Git) Push(branch string) {
 	git.t.Helper()
@@ -248,6 +298,38 @@ func (git Git) CommitAll(msg string, ignoreErr ...bool) {
 	}
 }
 
+// Tag creates a lightweight tag pointing to HEAD.
+// Fails the caller test if an error is found.
+func (git Git) Tag(name string) {
+	git.t.Helper()
+
+	if err := git.g.Tag(name); err != nil {
+		git.t.Fatalf("Git.Tag(%q) = %v", name, err)
+	}
+}
+
+// AnnotatedTag creates an annotated tag with the given message pointing to HEAD.
+// Fails the caller test if an error is found.
+func (git Git) AnnotatedTag(name, msg string) {
+	git.t.Helper()
+
+	if err := git.g.AnnotatedTag(name, msg); err != nil {
+		git.t.Fatalf("Git.AnnotatedTag(%q, %q) = %v", name, msg, err)
+	}
+}
+
+// ListTags lists all the tags of the repository, sorted.
+// Fails the caller test if an error is found.
+func (git Git) ListTags() []string {
+	git.t.Helper()
+
+	tags, err := git.g.ListTags()
+	if err != nil {
+		git.t.Fatalf("Git.ListTags() = %v", err)
+	}
+	return tags
+}
+
 // Checkout will checkout a pre-existing revision
 func (git Git) Checkout(rev string) {
 	git.t.Helper()
@@ -278,6 +360,69 @@ func (git Git) Merge(branch string) {
 	}
 }
 
+// MergeNoFF will merge the given branch into the current branch, always
+// creating a merge commit with the given message.
+// Fails the caller test if an error is found.
+func (git Git) MergeNoFF(branch, msg string) {
+	git.t.Helper()
+
+	if _, err := git.g.Exec("merge", "--no-ff", "-m", msg, branch); err != nil {
+		git.t.Fatalf("Git.MergeNoFF(%s, %q) = %v", branch, msg, err)
+	}
+}
+
+// MergeConflict will merge the given branch into the current branch expecting
+// the merge to stop with conflicts, leaving the repository mid-merge.
+// It returns the list of conflicted files.
+// Fails the caller test if the merge succeeds or no conflicted file is found.
+func (git Git) MergeConflict(branch string) []string {
+	git.t.Helper()
+
+	if _, err := git.g.Exec("merge", "--no-ff", branch); err == nil {
+		git.t.Fatalf("Git.MergeConflict(%s): merge succeeded with no conflicts", branch)
+	}
+
+	out, err := git.g.Exec("diff", "--name-only", "--diff-filter=U")
+	if err != nil {
+		git.t.Fatalf("Git.MergeConflict(%s): listing conflicted files: %v", branch, err)
+	}
+
+	var conflicts []string
+	for _, file := range strings.Split(out, "\n") {
+		if file != "" {
+			conflicts = append(conflicts, file)
+		}
+	}
+	if len(conflicts) == 0 {
+		git.t.Fatalf("Git.MergeConflict(%s): merge failed with no conflicted files", branch)
+	}
+	return conflicts
+}
+
+// MergeAbort aborts an ongoing merge, restoring the pre-merge state.
+// Fails the caller test if an error is found.
+func (git Git) MergeAbort() {
+	git.t.Helper()
+
+	if _, err := git.g.Exec("merge", "--abort"); err != nil {
+		git.t.Fatalf("Git.MergeAbort() = %v", err)
+	}
+}
+
+// RemoteStatus returns how many commits the local branch is ahead and behind
+// the default remote tracking branch with the same name.
+// Fails the caller test if an error is found.
+func (git Git) RemoteStatus(branch string) (ahead, behind int) {
+	git.t.Helper()
+
+	ahead, behind, err := git.g.RemoteStatus(git.cfg.DefaultRemoteName, branch)
+	if err != nil {
+		git.t.Fatalf("Git.RemoteStatus(%s, %s) = %v",
+			git.cfg.DefaultRemoteName, branch, err)
+	}
+	return ahead, behind
+}
+
 // SetRemoteURL sets the URL of the remote.
 func (git Git) SetRemoteURL(remote, url string) {
 	git.t.Helper()
@@ -289,6 +434,21 @@ func (git Git) BaseDir() string {
 	return git.cfg.repoDir
 }
 
+func (sig CommitSignature) env() []string {
+	env := []string{}
+	if sig.Name != "" {
+		env = append(env, "GIT_AUTHOR_NAME="+sig.Name, "GIT_COMMITTER_NAME="+sig.Name)
+	}
+	if sig.Email != "" {
+		env = append(env, "GIT_AUTHOR_EMAIL="+sig.Email, "GIT_COMMITTER_EMAIL="+sig.Email)
+	}
+	if !sig.Date.IsZero() {
+		date := sig.Date.Format(time.RFC3339)
+		env = append(env, "GIT_AUTHOR_DATE="+date, "GIT_COMMITTER_DATE="+date)
+	}
+	return env
+}
+
 func defaultGitConfig() GitConfig {
 	return GitConfig{
 		LocalBranchName:         "main",
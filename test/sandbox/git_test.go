@@ -49,3 +49,49 @@ func TestInitializeArbitraryRemote(t *testing.T) {
 	git.SetupRemote(remote, remoteBranch, "main")
 	git.RevParse(remote + "/" + remoteBranch)
 }
+
+func TestGitRemoteStatus(t *testing.T) {
+	s := sandbox.New(t)
+	git := s.Git()
+
+	assertStatus := func(wantAhead, wantBehind int) {
+		t.Helper()
+		ahead, behind := git.RemoteStatus("main")
+		if ahead != wantAhead || behind != wantBehind {
+			t.Fatalf("RemoteStatus(main) = (%d, %d), want (%d, %d)",
+				ahead, behind, wantAhead, wantBehind)
+		}
+	}
+
+	assertStatus(0, 0)
+
+	s.RootEntry().CreateFile("local.txt", "local")
+	git.CommitAll("local commit")
+	assertStatus(1, 0)
+
+	git.Push("main")
+	assertStatus(0, 0)
+
+	// another clone pushes to the remote, then the local branch is behind
+	// after fetching.
+	clonedir := t.TempDir()
+	git.Clone("file://"+git.BareRepoAbsPath(), clonedir)
+	other := sandbox.NewGit(t, clonedir)
+	test.WriteFile(t, clonedir, "other.txt", "other")
+	other.Add("other.txt")
+	other.CommitWith("other commit", sandbox.CommitSignature{
+		Name:  test.Username,
+		Email: test.Email,
+	})
+	other.Push("main")
+
+	g := test.NewGitWrapper(t, s.RootDir(), []string{})
+	if err := g.Fetch("origin"); err != nil {
+		t.Fatalf("fetching origin: %v", err)
+	}
+	assertStatus(0, 1)
+
+	s.RootEntry().CreateFile("local2.txt", "local")
+	git.CommitAll("diverging commit")
+	assertStatus(1, 1)
+}
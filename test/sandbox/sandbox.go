@@ -637,10 +637,14 @@ func buildTree(t testing.TB, root *config.Root, layout []string) {
 				cfg.Stack.WantedBy = parseListSpec(t, name, value)
 			case "watch":
 				cfg.Stack.Watch = parseListSpec(t, name, value)
+			case "var_files":
+				cfg.Stack.VarFiles = parseListSpec(t, name, value)
 			case "description":
 				cfg.Stack.Description = value
 			case "tags":
 				cfg.Stack.Tags = parseListSpec(t, name, value)
+			case "concurrency_group":
+				cfg.Stack.ConcurrencyGroup = value
 			default:
 				t.Fatalf("attribute " + parts[0] + " not supported.")
 			}
@@ -16,7 +16,10 @@ package sandbox_test
 
 import (
 	"testing"
+	"time"
 
+	"github.com/madlambda/spells/assert"
+	"github.com/mineiros-io/terramate/test"
 	"github.com/mineiros-io/terramate/test/sandbox"
 )
 
@@ -35,3 +38,42 @@ func TestSandboxWithArbitraryRepoConfig(t *testing.T) {
 	git.RevParse(localBranch)
 	git.RevParse(remote + "/" + remoteBranch)
 }
+
+func TestSandboxWithPinnedCommitSignature(t *testing.T) {
+	date := time.Date(2023, time.January, 2, 3, 4, 5, 0, time.UTC)
+
+	s := sandbox.NewWithGitConfig(t, sandbox.GitConfig{
+		LocalBranchName:         "main",
+		DefaultRemoteName:       "origin",
+		DefaultRemoteBranchName: "main",
+		Signature: sandbox.CommitSignature{
+			Name:  "Pinned",
+			Email: "pinned@example.com",
+			Date:  date,
+		},
+	})
+
+	g := test.NewGitWrapper(t, s.RootDir(), []string{})
+	assertHeadSignature := func(want string) {
+		t.Helper()
+		got, err := g.Exec("log", "-1", "--date=iso-strict",
+			"--format=%an <%ae> %ad|%cn <%ce> %cd")
+		assert.NoError(t, err)
+		assert.EqualStrings(t, want+"|"+want, got)
+	}
+
+	assertHeadSignature("Pinned <pinned@example.com> 2023-01-02T03:04:05+00:00")
+
+	git := s.Git()
+	s.BuildTree([]string{"f:file.txt:content"})
+	git.CommitAll("pinned commit")
+	assertHeadSignature("Pinned <pinned@example.com> 2023-01-02T03:04:05+00:00")
+
+	s.BuildTree([]string{"f:file.txt:changed"})
+	git.Add(".")
+	git.CommitWith("later commit", sandbox.CommitSignature{
+		Name: "Other",
+		Date: date.Add(time.Hour),
+	})
+	assertHeadSignature("Other <pinned@example.com> 2023-01-02T04:04:05+00:00")
+}
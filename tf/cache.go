@@ -0,0 +1,89 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mineiros-io/terramate/errors"
+)
+
+// ModuleCache caches the modules parsed by [ParseModules], keyed by the
+// absolute path of the parsed file. A cached result is discarded if the
+// modification time or the size of the file changes.
+//
+// A ModuleCache is safe for concurrent use. A nil *ModuleCache is valid and
+// just parses the files without caching.
+type ModuleCache struct {
+	mu      sync.Mutex
+	entries map[string]moduleCacheEntry
+}
+
+type moduleCacheEntry struct {
+	modTime time.Time
+	size    int64
+	modules []Module
+}
+
+// NewModuleCache creates a new empty module cache.
+func NewModuleCache() *ModuleCache {
+	return &ModuleCache{
+		entries: map[string]moduleCacheEntry{},
+	}
+}
+
+// ParseModules works like [ParseModules] but returns the cached modules if
+// the file didn't change since it was parsed.
+func (c *ModuleCache) ParseModules(path string) ([]Module, error) {
+	if c == nil {
+		return ParseModules(path)
+	}
+
+	abspath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, errors.E(err, "resolving absolute path of %q", path)
+	}
+
+	st, err := os.Stat(abspath)
+	if err != nil {
+		return nil, errors.E(err, "stat failed on %q", path)
+	}
+
+	c.mu.Lock()
+	entry, ok := c.entries[abspath]
+	c.mu.Unlock()
+
+	if ok && entry.modTime.Equal(st.ModTime()) && entry.size == st.Size() {
+		return entry.modules, nil
+	}
+
+	modules, err := ParseModules(abspath)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.entries[abspath] = moduleCacheEntry{
+		modTime: st.ModTime(),
+		size:    st.Size(),
+		modules: modules,
+	}
+	c.mu.Unlock()
+
+	return modules, nil
+}
@@ -0,0 +1,72 @@
+// Copyright 2023 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/madlambda/spells/assert"
+	"github.com/mineiros-io/terramate/test"
+	"github.com/mineiros-io/terramate/tf"
+)
+
+func TestModuleCache(t *testing.T) {
+	dir := t.TempDir()
+	tfpath := test.WriteFile(t, dir, "main.tf", `module "a" { source = "./aaa" }`)
+
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	assert.NoError(t, os.Chtimes(tfpath, mtime, mtime))
+
+	cache := tf.NewModuleCache()
+	assertModules := func(want ...string) {
+		t.Helper()
+		modules, err := cache.ParseModules(tfpath)
+		assert.NoError(t, err)
+		var got []string
+		for _, mod := range modules {
+			got = append(got, mod.Source)
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Fatalf("-(want) +(got):\n%s", diff)
+		}
+	}
+
+	assertModules("./aaa")
+
+	// same size and modification time, so the cached result is returned.
+	test.WriteFile(t, dir, "main.tf", `module "b" { source = "./bbb" }`)
+	assert.NoError(t, os.Chtimes(tfpath, mtime, mtime))
+	assertModules("./aaa")
+
+	// the modification time changed.
+	newMtime := mtime.Add(time.Minute)
+	assert.NoError(t, os.Chtimes(tfpath, newMtime, newMtime))
+	assertModules("./bbb")
+
+	// the size changed.
+	test.WriteFile(t, dir, "main.tf", `module "c" { source = "./cccc" }`)
+	assert.NoError(t, os.Chtimes(tfpath, newMtime, newMtime))
+	assertModules("./cccc")
+
+	// a nil cache just parses the file.
+	var nilcache *tf.ModuleCache
+	modules, err := nilcache.ParseModules(tfpath)
+	assert.NoError(t, err)
+	assert.EqualInts(t, 1, len(modules))
+	assert.EqualStrings(t, "./cccc", modules[0].Source)
+}
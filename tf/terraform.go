@@ -16,7 +16,9 @@ package tf
 
 import (
 	"os"
+	"strings"
 
+	hhcl "github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hclparse"
 	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/mineiros-io/terramate/errors"
@@ -28,7 +30,23 @@ import (
 // Module represents a terraform module.
 // Note that only the fields relevant for terramate are declared here.
 type Module struct {
+	Name   string // Name is the module block label.
 	Source string // Source is the module source path (eg.: directory, git path, etc).
+
+	// Version is the version constraint of the module (eg.: "~> 3.0"), as
+	// declared in the version attribute. It is empty if the module has no
+	// version or if it's not a string. Only modules from registries support
+	// version constraints.
+	Version string
+}
+
+// ModuleVersionChange is a change of the version constraint of a remote
+// module between two revisions of a Terraform file.
+type ModuleVersionChange struct {
+	Name       string // Name is the module block label.
+	Source     string // Source is the module source on the new revision.
+	OldVersion string
+	NewVersion string
 }
 
 // ErrHCLSyntax represents a HCL syntax error
@@ -41,7 +59,48 @@ func (m Module) IsLocal() bool {
 		(len(m.Source) >= 3 && m.Source[0:3] == "../")
 }
 
+// IsTerraformFile tells if the filename is a Terraform configuration file,
+// in the native (.tf) or JSON (.tf.json) syntax.
+func IsTerraformFile(filename string) bool {
+	return strings.HasSuffix(filename, ".tf") || isJSONFile(filename)
+}
+
+func isJSONFile(filename string) bool {
+	return strings.HasSuffix(filename, ".tf.json")
+}
+
+// ModuleVersionChanges compares the modules parsed from two revisions of the
+// same Terraform file, returning the remote modules whose version constraint
+// changed. Modules are matched by name, so added and removed modules are not
+// reported. The changes are returned in the order of the newModules.
+func ModuleVersionChanges(oldModules, newModules []Module) []ModuleVersionChange {
+	oldVersions := map[string]string{}
+	for _, mod := range oldModules {
+		oldVersions[mod.Name] = mod.Version
+	}
+
+	var changes []ModuleVersionChange
+	for _, mod := range newModules {
+		if mod.IsLocal() {
+			continue
+		}
+		oldVersion, ok := oldVersions[mod.Name]
+		if !ok || oldVersion == mod.Version {
+			continue
+		}
+		changes = append(changes, ModuleVersionChange{
+			Name:       mod.Name,
+			Source:     mod.Source,
+			OldVersion: oldVersion,
+			NewVersion: mod.Version,
+		})
+	}
+	return changes
+}
+
 // ParseModules parses blocks of type "module" containing a single label.
+// Files with the .tf.json extension are parsed using the Terraform JSON
+// syntax and any other file using the native HCL syntax.
 func ParseModules(path string) ([]Module, error) {
 	logger := log.With().
 		Str("action", "ParseModules()").
@@ -55,13 +114,40 @@ func ParseModules(path string) ([]Module, error) {
 		return nil, errors.E(err, "stat failed on %q", path)
 	}
 
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.E(err, "reading %q", path)
+	}
+	return ParseModulesContent(path, content)
+}
+
+// ParseModulesContent works like [ParseModules] but parses the given content
+// instead of reading the file, which is useful to parse other revisions of a
+// file (eg.: the content at a git ref). The filename is used to select the
+// syntax and on the errors.
+func ParseModulesContent(filename string, content []byte) ([]Module, error) {
+	logger := log.With().
+		Str("action", "ParseModulesContent()").
+		Str("path", filename).
+		Logger()
+
 	logger.Trace().Msg("Create new parser")
 
 	p := hclparse.NewParser()
 
+	if isJSONFile(filename) {
+		logger.Debug().Msg("Parse JSON file")
+
+		f, diags := p.ParseJSON(content, filename)
+		if diags.HasErrors() {
+			return nil, errors.E(ErrHCLSyntax, diags)
+		}
+		return parseJSONModules(f.Body)
+	}
+
 	logger.Debug().Msg("Parse HCL file")
 
-	f, diags := p.ParseHCLFile(path)
+	f, diags := p.ParseHCL(content, filename)
 	if diags.HasErrors() {
 		return nil, errors.E(ErrHCLSyntax, diags)
 	}
@@ -104,7 +190,18 @@ func ParseModules(path string) ([]Module, error) {
 
 			continue
 		}
-		modules = append(modules, Module{Source: source})
+
+		version, _, err := findStringAttr(block, "version")
+		if err != nil {
+			logger.Debug().
+				Err(err).
+				Msg("ignoring module version")
+		}
+		modules = append(modules, Module{
+			Name:    moduleName,
+			Source:  source,
+			Version: version,
+		})
 	}
 
 	if err := errs.AsError(); err != nil {
@@ -114,6 +211,76 @@ func ParseModules(path string) ([]Module, error) {
 	return modules, nil
 }
 
+func parseJSONModules(body hhcl.Body) ([]Module, error) {
+	logger := log.With().
+		Str("action", "parseJSONModules()").
+		Logger()
+
+	logger.Trace().Msg("Parse modules")
+
+	content, _, diags := body.PartialContent(&hhcl.BodySchema{
+		Blocks: []hhcl.BlockHeaderSchema{
+			{
+				Type:       "module",
+				LabelNames: []string{"name"},
+			},
+		},
+	})
+	if diags.HasErrors() {
+		return nil, errors.E(ErrHCLSyntax, diags)
+	}
+
+	var modules []Module
+	for _, block := range content.Blocks {
+		logger := logger.With().
+			Str("module", block.Labels[0]).
+			Logger()
+
+		modContent, _, diags := block.Body.PartialContent(&hhcl.BodySchema{
+			Attributes: []hhcl.AttributeSchema{
+				{Name: "source"},
+				{Name: "version"},
+			},
+		})
+		if diags.HasErrors() {
+			return nil, errors.E(ErrHCLSyntax, diags)
+		}
+
+		attr, ok := modContent.Attributes["source"]
+		if !ok {
+			logger.Debug().Msg("ignoring module block without source")
+
+			continue
+		}
+
+		// A nil context would make JSON strings literal, ignoring any
+		// template interpolation, so the native syntax behavior is kept by
+		// evaluating them with an empty context.
+		attrVal, diags := attr.Expr.Value(&hhcl.EvalContext{})
+		if diags.HasErrors() || attrVal.Type() != cty.String {
+			logger.Debug().Msg("ignoring module block with non-string source")
+
+			continue
+		}
+
+		version := ""
+		if attr, ok := modContent.Attributes["version"]; ok {
+			versionVal, diags := attr.Expr.Value(&hhcl.EvalContext{})
+			if !diags.HasErrors() && versionVal.Type() == cty.String {
+				version = versionVal.AsString()
+			} else {
+				logger.Debug().Msg("ignoring non-string module version")
+			}
+		}
+		modules = append(modules, Module{
+			Name:    block.Labels[0],
+			Source:  attrVal.AsString(),
+			Version: version,
+		})
+	}
+	return modules, nil
+}
+
 func findStringAttr(block *hclsyntax.Block, attrName string) (string, bool, error) {
 	logger := log.With().
 		Str("action", "findStringAttr()").
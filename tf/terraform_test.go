@@ -18,6 +18,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
 	hhcl "github.com/hashicorp/hcl/v2"
 	"github.com/madlambda/spells/assert"
 	"github.com/mineiros-io/terramate/errors"
@@ -142,6 +143,47 @@ module "bleh" {
 				},
 			},
 		},
+		{
+			name: "module with version",
+			input: cfgfile{
+				filename: "main.tf",
+				body: `
+module "vpc" {
+	source  = "terraform-aws-modules/vpc/aws"
+	version = "~> 3.0"
+}
+`,
+			},
+			want: want{
+				modules: []tf.Module{
+					{
+						Name:    "vpc",
+						Source:  "terraform-aws-modules/vpc/aws",
+						Version: "~> 3.0",
+					},
+				},
+			},
+		},
+		{
+			name: "module version is ignored if not a string",
+			input: cfgfile{
+				filename: "main.tf",
+				body: `
+module "vpc" {
+	source  = "terraform-aws-modules/vpc/aws"
+	version = var.version
+}
+`,
+			},
+			want: want{
+				modules: []tf.Module{
+					{
+						Name:   "vpc",
+						Source: "terraform-aws-modules/vpc/aws",
+					},
+				},
+			},
+		},
 		{
 			name: "ignored if source is not a string",
 			input: cfgfile{
@@ -181,6 +223,75 @@ module "test" {
 			`,
 			},
 		},
+		{
+			name: "json module without source attribute is ignored",
+			input: cfgfile{
+				filename: "main.tf.json",
+				body:     `{"module": {"test": {}}}`,
+			},
+		},
+		{
+			name: "valid json module",
+			input: cfgfile{
+				filename: "main.tf.json",
+				body:     `{"module": {"test": {"source": "./test"}}}`,
+			},
+			want: want{
+				modules: []tf.Module{
+					{
+						Source: "./test",
+					},
+				},
+			},
+		},
+		{
+			name: "multiple json modules mixed with other blocks",
+			input: cfgfile{
+				filename: "main.tf.json",
+				body: `{
+					"resource": {"null_resource": {"test": {}}},
+					"module": {
+						"test": {"source": "../test", "version": "1.0"},
+						"bleh": {"source": "bleh"}
+					}
+				}`,
+			},
+			want: want{
+				modules: []tf.Module{
+					{
+						Source:  "../test",
+						Version: "1.0",
+					},
+					{
+						Source: "bleh",
+					},
+				},
+			},
+		},
+		{
+			name: "ignored if json source is not a string or has interpolation",
+			input: cfgfile{
+				filename: "main.tf.json",
+				body: `{
+					"module": {
+						"test": {"source": -1},
+						"test2": {"source": "${var.test}"}
+					}
+				}`,
+			},
+		},
+		{
+			name: "json syntax error is reported",
+			input: cfgfile{
+				filename: "main.tf.json",
+				body:     `{"module": `,
+			},
+			want: want{
+				errs: []error{
+					errors.E(tf.ErrHCLSyntax),
+				},
+			},
+		},
 		{
 			name: "multiple syntax errors on same file get reported",
 			input: cfgfile{
@@ -219,11 +330,71 @@ module "test" {
 			for i := 0; i < len(tc.want.modules); i++ {
 				assert.EqualStrings(t, tc.want.modules[i].Source, modules[i].Source,
 					"module source mismatch")
+				assert.EqualStrings(t, tc.want.modules[i].Version, modules[i].Version,
+					"module version mismatch")
+				if tc.want.modules[i].Name != "" {
+					assert.EqualStrings(t, tc.want.modules[i].Name, modules[i].Name,
+						"module name mismatch")
+				}
 			}
 		})
 	}
 }
 
+func TestModuleVersionChanges(t *testing.T) {
+	oldModules, err := tf.ParseModulesContent("main.tf", []byte(`
+module "vpc" {
+	source  = "terraform-aws-modules/vpc/aws"
+	version = "~> 3.0"
+}
+module "s3" {
+	source  = "terraform-aws-modules/s3-bucket/aws"
+	version = "3.1.0"
+}
+module "unversioned" {
+	source = "terraform-aws-modules/iam/aws"
+}
+module "local" {
+	source = "../modules/local"
+}
+module "removed" {
+	source  = "terraform-aws-modules/eks/aws"
+	version = "19.0.0"
+}
+`))
+	assert.NoError(t, err)
+
+	newModules, err := tf.ParseModulesContent("main.tf.json", []byte(`{
+		"module": {
+			"vpc": {"source": "terraform-aws-modules/vpc/aws", "version": "~> 4.0"},
+			"s3": {"source": "terraform-aws-modules/s3-bucket/aws", "version": "3.1.0"},
+			"unversioned": {"source": "terraform-aws-modules/iam/aws", "version": "5.0.0"},
+			"local": {"source": "../modules/other"},
+			"added": {"source": "terraform-aws-modules/rds/aws", "version": "6.0.0"}
+		}
+	}`))
+	assert.NoError(t, err)
+
+	want := []tf.ModuleVersionChange{
+		{
+			Name:       "vpc",
+			Source:     "terraform-aws-modules/vpc/aws",
+			OldVersion: "~> 3.0",
+			NewVersion: "~> 4.0",
+		},
+		{
+			Name:       "unversioned",
+			Source:     "terraform-aws-modules/iam/aws",
+			OldVersion: "",
+			NewVersion: "5.0.0",
+		},
+	}
+	got := tf.ModuleVersionChanges(oldModules, newModules)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("-(want) +(got):\n%s", diff)
+	}
+}
+
 // some helpers to easy build file ranges.
 func mkrange(fname string, start, end hhcl.Pos) hhcl.Range {
 	if start.Byte == end.Byte {